@@ -0,0 +1,29 @@
+package constant
+
+// Termination values stored on ChessGame.Termination: a machine-readable
+// reason for how a finished game ended, alongside the result in Winner.
+//
+// The draw values match the engine's status strings (engine.DrawByRepetition,
+// engine.DrawByFiftyMove) so the status a client already sees and the stored
+// termination read the same.
+const (
+	TerminationCheckmate  = "checkmate"
+	TerminationStalemate  = "stalemate"
+	TerminationRepetition = "draw_repetition"
+	TerminationFiftyMove  = "draw_fifty_move"
+	TerminationTimeout    = "timeout"
+	// TerminationTimeoutVsInsufficientMaterial is a flag fall scored as a draw
+	// because the opponent could not have mated.
+	TerminationTimeoutVsInsufficientMaterial = "timeout_vs_insufficient_material"
+	TerminationResignation                   = "resignation"
+	TerminationAgreement                     = "draw_agreement"
+	TerminationAborted                       = "aborted"
+	// TerminationAdjudication is a game a moderator ended through the admin
+	// API, with whatever result they gave it.
+	TerminationAdjudication = "adjudication"
+)
+
+// WinnerAborted is the ChessGame.Winner value of an aborted game. It is not a
+// result -- nobody won and it was not drawn -- but Winner being non-empty is
+// what marks a game as over everywhere else, so an aborted game needs one.
+const WinnerAborted = "a"
@@ -0,0 +1,10 @@
+package constant
+
+// Tournament.Status values. A tournament takes entries until it is started,
+// runs until its last round or its time is up and every game has finished,
+// and is then over for good.
+const (
+	TournamentStatusCreated  = "created"
+	TournamentStatusRunning  = "running"
+	TournamentStatusFinished = "finished"
+)
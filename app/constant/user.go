@@ -0,0 +1,29 @@
+package constant
+
+// Roles, stored by name in the roles table and ordered by what they may do:
+// each can do everything the ones before it can.
+const (
+	RolePlayer    = "player"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists the roles from least to most privileged.
+var Roles = []string{RolePlayer, RoleModerator, RoleAdmin}
+
+// RoleRank is role's position in Roles, or -1 for a name that is not a role.
+func RoleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
+// User.Status values. A banned user's sessions stop working and they cannot
+// log in until unbanned; their games and account are kept.
+const (
+	UserStatusActive = 1
+	UserStatusBanned = 2
+)
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"chess-engine/app/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminController interface {
+	GetUsers(c *gin.Context)
+	BanUser(c *gin.Context)
+	UnbanUser(c *gin.Context)
+	SetUserRole(c *gin.Context)
+	TerminateGame(c *gin.Context)
+	DeleteAbandonedGames(c *gin.Context)
+	GetAuditLogs(c *gin.Context)
+}
+
+type AdminControllerImpl struct {
+	svc service.AdminService
+}
+
+func (u AdminControllerImpl) GetUsers(c *gin.Context) {
+	u.svc.GetUsers(c)
+}
+
+func (u AdminControllerImpl) BanUser(c *gin.Context) {
+	u.svc.BanUser(c)
+}
+
+func (u AdminControllerImpl) UnbanUser(c *gin.Context) {
+	u.svc.UnbanUser(c)
+}
+
+func (u AdminControllerImpl) SetUserRole(c *gin.Context) {
+	u.svc.SetUserRole(c)
+}
+
+func (u AdminControllerImpl) TerminateGame(c *gin.Context) {
+	u.svc.TerminateGame(c)
+}
+
+func (u AdminControllerImpl) DeleteAbandonedGames(c *gin.Context) {
+	u.svc.DeleteAbandonedGames(c)
+}
+
+func (u AdminControllerImpl) GetAuditLogs(c *gin.Context) {
+	u.svc.GetAuditLogs(c)
+}
+
+func AdminControllerInit(adminService service.AdminService) *AdminControllerImpl {
+	return &AdminControllerImpl{
+		svc: adminService,
+	}
+}
@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"chess-engine/app/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AnalysisController interface {
+	AnalysePosition(c *gin.Context)
+}
+
+type AnalysisControllerImpl struct {
+	svc service.AnalysisService
+}
+
+func (u AnalysisControllerImpl) AnalysePosition(c *gin.Context) {
+	u.svc.AnalysePosition(c)
+}
+
+func AnalysisControllerInit(analysisService service.AnalysisService) *AnalysisControllerImpl {
+	return &AnalysisControllerImpl{
+		svc: analysisService,
+	}
+}
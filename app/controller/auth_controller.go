@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"chess-engine/app/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthController interface {
+	Register(c *gin.Context)
+	Login(c *gin.Context)
+	Logout(c *gin.Context)
+	RefreshSession(c *gin.Context)
+	GetSessions(c *gin.Context)
+	RevokeSession(c *gin.Context)
+	RevokeOtherSessions(c *gin.Context)
+}
+
+type AuthControllerImpl struct {
+	svc service.AuthService
+}
+
+func (u AuthControllerImpl) Register(c *gin.Context) {
+	u.svc.Register(c)
+}
+
+func (u AuthControllerImpl) Login(c *gin.Context) {
+	u.svc.Login(c)
+}
+
+func (u AuthControllerImpl) Logout(c *gin.Context) {
+	u.svc.Logout(c)
+}
+
+func (u AuthControllerImpl) RefreshSession(c *gin.Context) {
+	u.svc.RefreshSession(c)
+}
+
+func (u AuthControllerImpl) GetSessions(c *gin.Context) {
+	u.svc.GetSessions(c)
+}
+
+func (u AuthControllerImpl) RevokeSession(c *gin.Context) {
+	u.svc.RevokeSession(c)
+}
+
+func (u AuthControllerImpl) RevokeOtherSessions(c *gin.Context) {
+	u.svc.RevokeOtherSessions(c)
+}
+
+func AuthControllerInit(authService service.AuthService) *AuthControllerImpl {
+	return &AuthControllerImpl{
+		svc: authService,
+	}
+}
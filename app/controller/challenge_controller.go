@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"chess-engine/app/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChallengeController interface {
+	CreateChallenge(c *gin.Context)
+	GetChallenges(c *gin.Context)
+	AcceptChallenge(c *gin.Context)
+	DeclineChallenge(c *gin.Context)
+	CancelChallenge(c *gin.Context)
+}
+
+type ChallengeControllerImpl struct {
+	svc service.ChallengeService
+}
+
+func (u ChallengeControllerImpl) CreateChallenge(c *gin.Context) {
+	u.svc.CreateChallenge(c)
+}
+
+func (u ChallengeControllerImpl) GetChallenges(c *gin.Context) {
+	u.svc.GetChallenges(c)
+}
+
+func (u ChallengeControllerImpl) AcceptChallenge(c *gin.Context) {
+	u.svc.AcceptChallenge(c)
+}
+
+func (u ChallengeControllerImpl) DeclineChallenge(c *gin.Context) {
+	u.svc.DeclineChallenge(c)
+}
+
+func (u ChallengeControllerImpl) CancelChallenge(c *gin.Context) {
+	u.svc.CancelChallenge(c)
+}
+
+func ChallengeControllerInit(challengeService service.ChallengeService) *ChallengeControllerImpl {
+	return &ChallengeControllerImpl{
+		svc: challengeService,
+	}
+}
@@ -9,10 +9,15 @@ import (
 type ChessController interface {
 	GetAllChessGame(c *gin.Context)
 	GetChessGameById(c *gin.Context)
+	GetChessGamePGN(c *gin.Context)
+	GetGameSeries(c *gin.Context)
+	ImportChessGame(c *gin.Context)
 	CreateChessGame(c *gin.Context)
 	CreateBotChessGame(c *gin.Context)
 	CreateLocalChessGame(c *gin.Context)
 	JoinChessGame(c *gin.Context)
+	GetUserGames(c *gin.Context)
+	GetUserStats(c *gin.Context)
 }
 
 type ChessControllerImpl struct {
@@ -27,6 +32,18 @@ func (u ChessControllerImpl) GetChessGameById(c *gin.Context) {
 	u.svc.GetChessGameById(c)
 }
 
+func (u ChessControllerImpl) GetChessGamePGN(c *gin.Context) {
+	u.svc.GetChessGamePGN(c)
+}
+
+func (u ChessControllerImpl) GetGameSeries(c *gin.Context) {
+	u.svc.GetGameSeries(c)
+}
+
+func (u ChessControllerImpl) ImportChessGame(c *gin.Context) {
+	u.svc.ImportChessGame(c)
+}
+
 func (u ChessControllerImpl) CreateChessGame(c *gin.Context) {
 	u.svc.CreateChessGame(c)
 }
@@ -43,6 +60,14 @@ func (u ChessControllerImpl) JoinChessGame(c *gin.Context) {
 	u.svc.JoinChessGame(c)
 }
 
+func (u ChessControllerImpl) GetUserGames(c *gin.Context) {
+	u.svc.GetUserGames(c)
+}
+
+func (u ChessControllerImpl) GetUserStats(c *gin.Context) {
+	u.svc.GetUserStats(c)
+}
+
 func ChessControllerInit(chessService service.ChessService) *ChessControllerImpl {
 	return &ChessControllerImpl{
 		svc: chessService,
@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"chess-engine/app/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LeaderboardController interface {
+	GetLeaderboard(c *gin.Context)
+}
+
+type LeaderboardControllerImpl struct {
+	svc service.LeaderboardService
+}
+
+func (u LeaderboardControllerImpl) GetLeaderboard(c *gin.Context) {
+	u.svc.GetLeaderboard(c)
+}
+
+func LeaderboardControllerInit(leaderboardService service.LeaderboardService) *LeaderboardControllerImpl {
+	return &LeaderboardControllerImpl{
+		svc: leaderboardService,
+	}
+}
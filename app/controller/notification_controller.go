@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/service"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+type NotificationController interface {
+	HandleUserWebSocket(c *gin.Context)
+}
+
+type NotificationControllerImpl struct {
+	svc service.NotificationService
+}
+
+// HandleUserWebSocket serves /ws/user, the signed-in user's own channel. The
+// server only pushes on it; anything the client sends is read and dropped,
+// which keeps the connection's control frames (pings, close) flowing.
+func (u NotificationControllerImpl) HandleUserWebSocket(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Error("Failed to establish WebSocket connection: ", err)
+		return
+	}
+
+	// As with game sockets, the token rides in the query string.
+	userID, ok := u.svc.RegisterUser(conn, c.Query("token"))
+	if !ok {
+		conn.WriteJSON(dto.WebSocketMessage{Status: "error", Message: "session expired, please reload"})
+		conn.Close()
+		return
+	}
+	defer u.svc.UnregisterUser(userID, conn)
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			log.Info("Closing user WebSocket connection: ", err)
+			return
+		}
+	}
+}
+
+func NotificationControllerInit(notificationService service.NotificationService) *NotificationControllerImpl {
+	return &NotificationControllerImpl{
+		svc: notificationService,
+	}
+}
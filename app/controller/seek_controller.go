@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"chess-engine/app/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SeekController interface {
+	CreateSeek(c *gin.Context)
+	CancelSeek(c *gin.Context)
+	GetSeek(c *gin.Context)
+}
+
+type SeekControllerImpl struct {
+	svc service.SeekService
+}
+
+func (u SeekControllerImpl) CreateSeek(c *gin.Context) {
+	u.svc.CreateSeek(c)
+}
+
+func (u SeekControllerImpl) CancelSeek(c *gin.Context) {
+	u.svc.CancelSeek(c)
+}
+
+func (u SeekControllerImpl) GetSeek(c *gin.Context) {
+	u.svc.GetSeek(c)
+}
+
+func SeekControllerInit(seekService service.SeekService) *SeekControllerImpl {
+	return &SeekControllerImpl{
+		svc: seekService,
+	}
+}
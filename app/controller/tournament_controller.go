@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+type TournamentController interface {
+	CreateTournament(c *gin.Context)
+	GetTournaments(c *gin.Context)
+	GetTournament(c *gin.Context)
+	JoinTournament(c *gin.Context)
+	WithdrawFromTournament(c *gin.Context)
+	StartTournament(c *gin.Context)
+	HandleTournamentWebSocket(c *gin.Context)
+}
+
+type TournamentControllerImpl struct {
+	svc service.TournamentService
+}
+
+func (u TournamentControllerImpl) CreateTournament(c *gin.Context) {
+	u.svc.CreateTournament(c)
+}
+
+func (u TournamentControllerImpl) GetTournaments(c *gin.Context) {
+	u.svc.GetTournaments(c)
+}
+
+func (u TournamentControllerImpl) GetTournament(c *gin.Context) {
+	u.svc.GetTournament(c)
+}
+
+func (u TournamentControllerImpl) JoinTournament(c *gin.Context) {
+	u.svc.JoinTournament(c)
+}
+
+func (u TournamentControllerImpl) WithdrawFromTournament(c *gin.Context) {
+	u.svc.WithdrawFromTournament(c)
+}
+
+func (u TournamentControllerImpl) StartTournament(c *gin.Context) {
+	u.svc.StartTournament(c)
+}
+
+// HandleTournamentWebSocket serves /ws/tournament/:tournamentId, which pushes
+// the standings as they change. Anyone may watch, so no token is needed; as
+// on the user channel, whatever the client sends is read and dropped.
+func (u TournamentControllerImpl) HandleTournamentWebSocket(c *gin.Context) {
+	tournamentID, _ := strconv.Atoi(c.Param("tournamentId"))
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Error("Failed to establish WebSocket connection: ", err)
+		return
+	}
+
+	if !u.svc.RegisterClient(tournamentID, conn) {
+		conn.WriteJSON(dto.WebSocketMessage{Status: "error", Message: "tournament not found"})
+		conn.Close()
+		return
+	}
+	defer u.svc.UnregisterClient(tournamentID, conn)
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			log.Info("Closing tournament WebSocket connection: ", err)
+			return
+		}
+	}
+}
+
+func TournamentControllerInit(tournamentService service.TournamentService) *TournamentControllerImpl {
+	return &TournamentControllerImpl{
+		svc: tournamentService,
+	}
+}
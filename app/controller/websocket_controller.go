@@ -44,13 +44,21 @@ func (wsCtrl WebSocketControllerImpl) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
-	// Register the client with the WebSocket service
-	wsCtrl.svc.RegisterClient(gameID, conn)
+	// Register the client with the WebSocket service. Browsers cannot set
+	// headers on a WebSocket handshake, so the token rides in the query string;
+	// without one the connection watches.
+	seat := wsCtrl.svc.RegisterClient(gameID, conn, c.Query("token"))
 
 	defer wsCtrl.svc.UnregisterClient(gameID, conn) // Ensure cleanup on disconnect
 
+	// Start the client off with the game as it stands; it used to get nothing
+	// until somebody moved.
+	wsCtrl.svc.SendSnapshot(gameID, conn)
+
 	// If the bot has the move (e.g. it drew White), play it now that someone is watching.
 	go wsCtrl.svc.MaybePlayBotMove(gameID)
+	// Decide a game whose flag fell while nobody was connected.
+	go wsCtrl.svc.CheckClock(gameID)
 
 	// Listen for messages from the client
 	for {
@@ -61,24 +69,55 @@ func (wsCtrl WebSocketControllerImpl) HandleWebSocket(c *gin.Context) {
 			break
 		}
 
-		wsCtrl.handleMessage(gameID, message)
+		wsCtrl.handleMessage(gameID, conn, seat, message)
 	}
 }
 
-// handleMessage processes one client message, containing any panic to this
-// connection.
+// handleMessage dispatches one client message by type, containing any panic to
+// this connection. seat is what RegisterClient classified the connection as.
 //
 // This loop runs on its own goroutine, so gin.Recovery() does not cover it: an
 // unrecovered panic here (e.g. a bad type assertion on a client-supplied
 // payload) terminated the entire process and every other live game with it.
-func (wsCtrl WebSocketControllerImpl) handleMessage(gameID string, message dto.WebSocketMessage) {
+func (wsCtrl WebSocketControllerImpl) handleMessage(gameID string, conn *websocket.Conn, seat string, message dto.WebSocketMessage) {
 	defer func() {
 		if rec := recover(); rec != nil {
 			log.Errorf("recovered panic while processing message for game %s: %v", gameID, rec)
 		}
 	}()
 
-	wsCtrl.svc.ProcessMove(gameID, message)
+	// A sync only reads, so anyone connected may ask.
+	if message.Type == dto.MessageSync {
+		wsCtrl.svc.Sync(gameID, conn, message)
+		return
+	}
+
+	// Every other message a client can send acts on the game, and spectators
+	// only watch. The refusal goes to the spectator alone rather than being
+	// broadcast as a game error.
+	if seat == dto.SeatSpectator {
+		log.Warnf("Refusing %q from a spectator of game %s", message.Type, gameID)
+		wsCtrl.svc.SendToClient(gameID, conn, dto.WebSocketMessage{
+			Type:    dto.MessageGameUpdate,
+			Status:  "error",
+			Message: "spectators cannot move or act in the game",
+		})
+		return
+	}
+
+	switch message.Type {
+	case "", dto.MessageMove, dto.MessageGameUpdate:
+		// Untyped and "game_update" messages are moves: that is all the client
+		// sent before other message types existed.
+		wsCtrl.svc.ProcessMove(gameID, message)
+	case dto.MessageResign, dto.MessageDrawOffer, dto.MessageDrawAccept,
+		dto.MessageDrawDecline, dto.MessageAbort,
+		dto.MessageTakebackRequest, dto.MessageTakebackAccept, dto.MessageTakebackDecline,
+		dto.MessageRematchOffer, dto.MessageRematchAccept, dto.MessageRematchDecline:
+		wsCtrl.svc.ProcessAction(gameID, message)
+	default:
+		log.Warnf("Ignoring message of unknown type %q for game %s", message.Type, gameID)
+	}
 }
 
 // WebSocketControllerInit initializes the WebSocket controller
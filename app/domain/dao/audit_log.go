@@ -0,0 +1,21 @@
+package dao
+
+import "time"
+
+// AuditLog records one action taken through the admin API: who did what to
+// which user or game, and why. Rows are only ever added.
+type AuditLog struct {
+	ID      int `gorm:"column:id;primaryKey;autoIncrement;not null" json:"id"`
+	ActorID int `gorm:"column:actor_id;not null;index" json:"actor_id"`
+	// Action is one of the Audit* names in the admin service.
+	Action string `gorm:"column:action;not null;index" json:"action"`
+	// TargetType is "user" or "game", and TargetID its id.
+	TargetType string `gorm:"column:target_type;not null" json:"target_type"`
+	TargetID   int    `gorm:"column:target_id;not null" json:"target_id"`
+	Reason     string `gorm:"column:reason" json:"reason"`
+	// Detail is anything else worth keeping, such as a new role or the ids of
+	// deleted games.
+	Detail string `gorm:"column:detail" json:"detail,omitempty"`
+	// At is set explicitly: BaseModel's created_at is never read back.
+	At time.Time `gorm:"column:at;not null;index" json:"at"`
+}
@@ -3,23 +3,85 @@ package dao
 type ChessGame struct {
 	ID         int    `gorm:"column:id;primaryKey;autoIncrement;not null" json:"id"`
 	InviteCode string `gorm:"column:invite_code" json:"invite_code"`
-	Winner     string `gorm:"column:winner" json:"winner"`
+	// Winner is "" while the game is in progress, then "w", "b", "d" (draw) or
+	// constant.WinnerAborted.
+	Winner string `gorm:"column:winner" json:"winner"`
+	// Termination says how a finished game ended; see constant.Termination*.
+	Termination string `gorm:"column:termination" json:"termination"`
+	// TerminationNote is the reason a moderator gave for ending the game, for
+	// constant.TerminationAdjudication.
+	TerminationNote string `gorm:"column:termination_note" json:"termination_note,omitempty"`
+	// DrawOffer is the side ("w" or "b") with a draw offer standing, or "".
+	DrawOffer string `gorm:"column:draw_offer" json:"draw_offer"`
+	// TakebackOffer is the side ("w" or "b") asking to take a move back, or "".
+	TakebackOffer string `gorm:"column:takeback_offer" json:"takeback_offer"`
+	// RematchOffer is the side ("w" or "b") offering a rematch of the finished
+	// game, or "".
+	RematchOffer string `gorm:"column:rematch_offer" json:"rematch_offer"`
+	// PreviousGameID is the game this one is a rematch of, and RematchGameID
+	// the rematch of this one, so a series can be followed either way.
+	PreviousGameID *int `gorm:"column:previous_game_id;index" json:"previous_game_id,omitempty"`
+	RematchGameID  *int `gorm:"column:rematch_game_id" json:"rematch_game_id,omitempty"`
+	// TournamentID is the tournament the game was paired in, if any.
+	TournamentID *int `gorm:"column:tournament_id;index" json:"tournament_id,omitempty"`
+	// StartFEN is the position the game began from, or "" for the standard
+	// starting position. Replays of the move list start here.
+	StartFEN string `gorm:"column:start_fen" json:"start_fen"`
+	// Seq numbers the game's updates: it goes up by one every time a move or
+	// anything else about the game is saved, and never goes down, not even on
+	// a takeback. Clients use it to put updates in order and to ask for what
+	// they missed.
+	Seq int `gorm:"column:seq;not null;default:0" json:"seq"`
+	// Rated is whether the players asked for a rated game when creating it. A
+	// game that is not is casual. Whether a finished game actually changes
+	// ratings also depends on the server's policy for casual, local and bot
+	// games.
+	Rated bool `gorm:"column:rated;not null;default:false" json:"rated"`
+	// WhiteRatingChange and BlackRatingChange are how many points each
+	// player's rating moved when the game finished, or nil if it did not
+	// count.
+	WhiteRatingChange *int `gorm:"column:white_rating_change" json:"white_rating_change,omitempty"`
+	BlackRatingChange *int `gorm:"column:black_rating_change" json:"black_rating_change,omitempty"`
 	// BotLevel is "" for human games, or "easy" | "medium" | "hard" for bot games.
 	BotLevel string `gorm:"column:bot_level" json:"bot_level"`
+	// Time control. All zero means an untimed game. BaseSeconds and
+	// IncrementSeconds describe a live clock (300 and 3 for 5+3); DaysPerMove
+	// makes it a correspondence game, where each move has its own allowance.
+	BaseSeconds      int `gorm:"column:base_seconds;not null;default:0" json:"base_seconds"`
+	IncrementSeconds int `gorm:"column:increment_seconds;not null;default:0" json:"increment_seconds"`
+	DaysPerMove      int `gorm:"column:days_per_move;not null;default:0" json:"days_per_move"`
 	// ChessStateId int                 `gorm:"column:chess_state_id;not null" json:"chess_state_id"`
 	// ChessState   ChessState          `gorm:"foreignKey:ChessStateId" json:"chess_state"`
-	WhiteUserId  *int                `gorm:"column:white_user_id" json:"white_user_id"`
-	BlackUserId  *int                `gorm:"column:black_user_id" json:"black_user_id"`
-	WhiteUser    *User               `gorm:"foreignKey:WhiteUserId" json:"white_user"`
-	BlackUser    *User               `gorm:"foreignKey:BlackUserId" json:"black_user"`
-	State        GameState           `gorm:"foreignKey:GameID" json:"state"`
-	Moves        []GameMove          `gorm:"foreignKey:GameID" json:"moves"`
-	LegalMoves   map[string][]string `json:"legal_moves" gorm:"-"` // Excluded from GORM
-	CurrentState map[string]string   `json:"current_state" gorm:"-"`
-	BoardLayout  [8][8][2]string     `json:"board_layout" gorm:"-"` // Excluded from GORM
+	WhiteUserId *int  `gorm:"column:white_user_id" json:"white_user_id"`
+	BlackUserId *int  `gorm:"column:black_user_id" json:"black_user_id"`
+	WhiteUser   *User `gorm:"foreignKey:WhiteUserId" json:"white_user"`
+	BlackUser   *User `gorm:"foreignKey:BlackUserId" json:"black_user"`
+	// ChallengedUserId is set on a direct challenge: the one user who may take
+	// the empty seat. Open challenges are kept out of the public lobby.
+	ChallengedUserId *int                `gorm:"column:challenged_user_id;index" json:"challenged_user_id,omitempty"`
+	ChallengedUser   *User               `gorm:"foreignKey:ChallengedUserId" json:"challenged_user,omitempty"`
+	State            GameState           `gorm:"foreignKey:GameID" json:"state"`
+	Moves            []GameMove          `gorm:"foreignKey:GameID" json:"moves"`
+	LegalMoves       map[string][]string `json:"legal_moves" gorm:"-"` // Excluded from GORM
+	CurrentState     map[string]string   `json:"current_state" gorm:"-"`
+	BoardLayout      [8][8][2]string     `json:"board_layout" gorm:"-"` // Excluded from GORM
+	// Clock is each side's remaining time as of the moment the payload was
+	// built. Nil for untimed games.
+	Clock *ClockSnapshot `json:"clock,omitempty" gorm:"-"`
 	BaseModel
 }
 
+// ClockSnapshot is the clock as broadcast to clients. The stored GameState
+// fields are only correct as of ClockStartedAt; this is already brought up to
+// date, so a client can count down from it without knowing the server's time.
+type ClockSnapshot struct {
+	WhiteMs int64 `json:"white_ms"`
+	BlackMs int64 `json:"black_ms"`
+	// Running is the side whose clock is ticking ("w" or "b"), or "" before the
+	// first move and after the game ends.
+	Running string `json:"running"`
+}
+
 type GameState struct {
 	ID             int    `gorm:"primaryKey;autoIncrement" json:"id"`
 	GameID         int    `gorm:"not null;index" json:"game_id"`
@@ -39,6 +101,13 @@ type GameState struct {
 	HalfmoveClock int    `gorm:"column:halfmove_clock;not null;default:0" json:"halfmove_clock"`
 	LastMove      string `gorm:"type:varchar(10)" json:"last_move"`
 	Turn          string `gorm:"type:varchar(1);not null" json:"turn"`
+	// Clocks, in milliseconds. WhiteClockMs and BlackClockMs are each side's
+	// remaining time as of ClockStartedAt, the unix-millisecond instant the side
+	// to move's clock started running. ClockStartedAt is 0 while no clock runs:
+	// untimed games, and timed games before White's first move.
+	WhiteClockMs   int64 `gorm:"column:white_clock_ms;not null;default:0" json:"white_clock_ms"`
+	BlackClockMs   int64 `gorm:"column:black_clock_ms;not null;default:0" json:"black_clock_ms"`
+	ClockStartedAt int64 `gorm:"column:clock_started_at;not null;default:0" json:"clock_started_at"`
 	BaseModel
 }
 
@@ -46,5 +115,7 @@ type GameMove struct {
 	ID     int    `gorm:"primaryKey;autoIncrement" json:"id"`
 	GameID int    `gorm:"not null;index" json:"game_id"`
 	Move   string `gorm:"not null" json:"move"`
+	// Seq is the game's Seq as of this move. Imported moves have 0.
+	Seq int `gorm:"column:seq;not null;default:0" json:"seq"`
 	BaseModel
 }
@@ -0,0 +1,21 @@
+package dao
+
+import "time"
+
+// Rating is a user's Glicko-2 rating in one time-control category (see
+// engine.Category*). A user has a row per category they have played a rated
+// game in; a missing row means the default rating.
+type Rating struct {
+	ID         int     `gorm:"column:id;primaryKey;autoIncrement;not null" json:"-"`
+	UserID     int     `gorm:"column:user_id;not null;uniqueIndex:idx_rating_user_category" json:"user_id"`
+	Category   string  `gorm:"column:category;not null;uniqueIndex:idx_rating_user_category" json:"category"`
+	Rating     float64 `gorm:"column:rating;not null" json:"rating"`
+	Deviation  float64 `gorm:"column:deviation;not null" json:"deviation"`
+	Volatility float64 `gorm:"column:volatility;not null" json:"volatility"`
+	// Games counts the rated games behind the rating.
+	Games int `gorm:"column:games;not null;default:0" json:"games"`
+	// RatedAt is when the last rated game finished. The deviation widens with
+	// the time since, so an idle player's rating is trusted less.
+	RatedAt time.Time `gorm:"column:rated_at" json:"rated_at"`
+	BaseModel
+}
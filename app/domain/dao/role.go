@@ -1,7 +1,9 @@
 package dao
 
+// Role is one of constant.Roles. The table holds a row per role, created at
+// start-up; users point at theirs through User.RoleID.
 type Role struct {
 	ID   int    `gorm:"column:id; primary_key; not null" json:"id"`
-	Role string `gorm:"column:role" json:"role"`
+	Role string `gorm:"column:role;uniqueIndex" json:"role"`
 	BaseModel
 }
@@ -0,0 +1,23 @@
+package dao
+
+import "time"
+
+// Session is one signed-in device. The token itself is never stored: TokenHash
+// is its SHA-256, so a leaked table does not let anyone in. A session ends when
+// it expires, when it is revoked, or when its token is rotated (which keeps the
+// row and replaces the hash). A guest's session does not expire: ExpiresAt
+// only applies once the guest sets a password.
+type Session struct {
+	ID        int    `gorm:"column:id;primaryKey;autoIncrement;not null" json:"id"`
+	UserID    int    `gorm:"column:user_id;not null;index" json:"-"`
+	User      User   `gorm:"foreignKey:UserID" json:"-"`
+	TokenHash string `gorm:"column:token_hash;not null;uniqueIndex" json:"-"`
+	// Device is a label for the session list, from the client or its
+	// User-Agent.
+	Device     string     `gorm:"column:device" json:"device"`
+	IssuedAt   time.Time  `gorm:"column:issued_at;not null" json:"issued_at"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	LastSeenAt time.Time  `gorm:"column:last_seen_at;not null" json:"last_seen_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"-"`
+	BaseModel
+}
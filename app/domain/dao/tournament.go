@@ -0,0 +1,60 @@
+package dao
+
+import "time"
+
+// Tournament is a Swiss or an arena (see package tournament). Its games are
+// ordinary ChessGames with TournamentID set, one TournamentPairing each.
+type Tournament struct {
+	ID   int    `gorm:"column:id;primaryKey;autoIncrement;not null" json:"id"`
+	Name string `gorm:"column:name;not null" json:"name"`
+	// Kind is tournament.KindSwiss or tournament.KindArena.
+	Kind string `gorm:"column:kind;not null" json:"kind"`
+	// Status is one of constant.TournamentStatus*.
+	Status string `gorm:"column:status;not null;index" json:"status"`
+	// Rounds is how many rounds a Swiss plays, and CurrentRound the one under
+	// way; 0 before the start. An arena numbers its pairings instead.
+	Rounds       int `gorm:"column:rounds;not null;default:0" json:"rounds"`
+	CurrentRound int `gorm:"column:current_round;not null;default:0" json:"current_round"`
+	// DurationMinutes is how long an arena pairs players for.
+	DurationMinutes int `gorm:"column:duration_minutes;not null;default:0" json:"duration_minutes"`
+	// The time control of every game, as on ChessGame. Tournaments are live,
+	// never correspondence.
+	BaseSeconds      int  `gorm:"column:base_seconds;not null;default:0" json:"base_seconds"`
+	IncrementSeconds int  `gorm:"column:increment_seconds;not null;default:0" json:"increment_seconds"`
+	Rated            bool `gorm:"column:rated;not null;default:false" json:"rated"`
+	// CreatedByID is the organiser, who may start the tournament.
+	CreatedByID int        `gorm:"column:created_by_id;not null" json:"created_by_id"`
+	CreatedBy   *User      `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+	StartedAt   *time.Time `gorm:"column:started_at" json:"started_at,omitempty"`
+	// EndsAt is when an arena stops pairing; games still going then are
+	// played out.
+	EndsAt     *time.Time         `gorm:"column:ends_at" json:"ends_at,omitempty"`
+	FinishedAt *time.Time         `gorm:"column:finished_at" json:"finished_at,omitempty"`
+	Players    []TournamentPlayer `gorm:"foreignKey:TournamentID" json:"players,omitempty"`
+	BaseModel
+}
+
+// TournamentPlayer is an entry. A player who withdraws keeps their entry and
+// their results, but is not paired again unless they rejoin.
+type TournamentPlayer struct {
+	ID           int       `gorm:"column:id;primaryKey;autoIncrement;not null" json:"-"`
+	TournamentID int       `gorm:"column:tournament_id;not null;uniqueIndex:idx_tournament_player" json:"tournament_id"`
+	UserID       int       `gorm:"column:user_id;not null;uniqueIndex:idx_tournament_player" json:"user_id"`
+	User         *User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Withdrawn    bool      `gorm:"column:withdrawn;not null;default:false" json:"withdrawn"`
+	JoinedAt     time.Time `gorm:"column:joined_at;not null" json:"joined_at"`
+	BaseModel
+}
+
+// TournamentPairing is one board of a round: a game, or a bye for White when
+// BlackUserID is nil. Result is copied from the game's Winner when it ends.
+type TournamentPairing struct {
+	ID           int    `gorm:"column:id;primaryKey;autoIncrement;not null" json:"id"`
+	TournamentID int    `gorm:"column:tournament_id;not null;index" json:"tournament_id"`
+	Round        int    `gorm:"column:round;not null" json:"round"`
+	WhiteUserID  int    `gorm:"column:white_user_id;not null" json:"white_user_id"`
+	BlackUserID  *int   `gorm:"column:black_user_id" json:"black_user_id,omitempty"`
+	GameID       *int   `gorm:"column:game_id;index" json:"game_id,omitempty"`
+	Result       string `gorm:"column:result;not null;default:''" json:"result"`
+	BaseModel
+}
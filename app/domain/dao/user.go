@@ -1,5 +1,7 @@
 package dao
 
+import "chess-engine/app/constant"
+
 type User struct {
 	ID   int    `gorm:"column:id; primary_key; not null" json:"id"`
 	Name string `gorm:"column:name" json:"name"`
@@ -7,9 +9,30 @@ type User struct {
 	// struct is embedded in ChessGame.WhiteUser/BlackUser, and that whole game
 	// object is broadcast to every client watching the game. With a `json:"token"`
 	// tag, any cache-hit broadcast handed both players' tokens to each other.
-	// The token is returned exactly once, by AddUserData, via dto.CreatedUser.
-	Token    string `gorm:"column:token;uniqueIndex" json:"-"`
-	Status   int    `gorm:"column:status" json:"status"`
+	//
+	// Tokens now live in the sessions table (see Session), hashed. This column
+	// only holds the plaintext token of an account from before sessions, until
+	// its first use moves it over; it is nil after that and for new accounts.
+	Token *string `gorm:"column:token;uniqueIndex" json:"-"`
+	// Username and PasswordHash are set once a guest registers (claims the
+	// account); until then the token is the only way in. Username is stored
+	// lowercased and is nil for guests, so the unique index ignores them.
+	Username     *string `gorm:"column:username;uniqueIndex" json:"username,omitempty"`
+	PasswordHash string  `gorm:"column:password_hash" json:"-"`
+	// Status is constant.UserStatusActive or constant.UserStatusBanned.
+	Status int `gorm:"column:status" json:"status"`
+	// RoleID is nil for most users, who are players; see RoleName.
+	RoleID   *int   `gorm:"column:role_id" json:"-"`
+	Role     *Role  `gorm:"foreignKey:RoleID" json:"role,omitempty"`
 	MetaData string `gorm:"column:meta_data" json:"meta_data"`
 	BaseModel
 }
+
+// RoleName is the user's role, constant.RolePlayer when none is set. Role must
+// have been loaded for anything else to show.
+func (u User) RoleName() string {
+	if u.Role == nil || u.Role.Role == "" {
+		return constant.RolePlayer
+	}
+	return u.Role.Role
+}
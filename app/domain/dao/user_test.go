@@ -12,7 +12,7 @@ import (
 func TestUserTokenIsNeverSerialised(t *testing.T) {
 	secret := "SUPER-SECRET-SESSION-TOKEN"
 
-	user := User{ID: 7, Name: "someone", Token: secret, Status: 1}
+	user := User{ID: 7, Name: "someone", Token: &secret, Status: 1}
 	b, err := json.Marshal(user)
 	if err != nil {
 		t.Fatalf("marshal user: %v", err)
@@ -30,3 +30,16 @@ func TestUserTokenIsNeverSerialised(t *testing.T) {
 		t.Errorf("ChessGame JSON leaks a player's token: %s", b)
 	}
 }
+
+// The password hash rides along in the same broadcast as the token would.
+func TestUserPasswordHashIsNeverSerialised(t *testing.T) {
+	hash := "$argon2id$v=19$m=65536,t=1,p=4$c2FsdA$aGFzaA"
+	name := "someone"
+	b, err := json.Marshal(User{ID: 7, Username: &name, PasswordHash: hash})
+	if err != nil {
+		t.Fatalf("marshal user: %v", err)
+	}
+	if strings.Contains(string(b), "argon2id") {
+		t.Errorf("User JSON leaks the password hash: %s", b)
+	}
+}
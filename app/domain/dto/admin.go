@@ -0,0 +1,39 @@
+package dto
+
+import "chess-engine/app/domain/dao"
+
+// AdminReasonRequest is the body of the admin actions that need only a
+// reason: ban and unban.
+type AdminReasonRequest struct {
+	Reason string `json:"reason"`
+}
+
+// SetRoleRequest is the body of PUT /api/admin/users/:userID/role.
+type SetRoleRequest struct {
+	Role   string `json:"role"`
+	Reason string `json:"reason"`
+}
+
+// TerminateGameRequest is the body of POST /api/admin/games/:gameId/terminate.
+// Result is "white" or "black" (that side wins), "draw" or "abort".
+type TerminateGameRequest struct {
+	Result string `json:"result"`
+	Reason string `json:"reason"`
+}
+
+// AdminUsersPage is a page of GET /api/admin/users.
+type AdminUsersPage struct {
+	Users      []dao.User `json:"users"`
+	NextCursor *int       `json:"next_cursor"`
+}
+
+// AuditLogPage is a page of GET /api/admin/audit.
+type AuditLogPage struct {
+	Entries    []dao.AuditLog `json:"entries"`
+	NextCursor *int           `json:"next_cursor"`
+}
+
+// DeletedGames is the response to DELETE /api/admin/games/abandoned.
+type DeletedGames struct {
+	GameIDs []int `json:"game_ids"`
+}
@@ -0,0 +1,37 @@
+package dto
+
+// AnalysisRequest asks the engine to analyse one position: either FEN, or the
+// position in game GameId after Ply half-moves (the current position when Ply
+// is omitted). Depth and MoveTimeMs bound the search; at least one applies,
+// and the server caps both. MultiPV is how many best lines to return.
+type AnalysisRequest struct {
+	FEN        string `json:"fen"`
+	GameId     string `json:"game_id"`
+	Ply        *int   `json:"ply"`
+	Depth      int    `json:"depth"`
+	MoveTimeMs int    `json:"movetime_ms"`
+	MultiPV    int    `json:"multipv"`
+}
+
+// AnalysisLine is one candidate line, best first. Score is in centipawns from
+// the side to move's point of view; Mate, when non-zero, is the distance to
+// mate in moves and is negative when the side to move is being mated. PV is
+// the line in UCI notation and PVSAN the same line in SAN. The lines come from
+// one search, so they share its Depth and Nodes.
+type AnalysisLine struct {
+	Score int      `json:"score"`
+	Mate  int      `json:"mate"`
+	PV    []string `json:"pv"`
+	PVSAN []string `json:"pv_san"`
+	Depth int      `json:"depth"`
+	Nodes int      `json:"nodes"`
+}
+
+// AnalysisResponse is the analysed position and its lines. Lines is empty when
+// the side to move has no legal move.
+type AnalysisResponse struct {
+	FEN    string         `json:"fen"`
+	Lines  []AnalysisLine `json:"lines"`
+	Nodes  int            `json:"nodes"`
+	TimeMs int64          `json:"time_ms"`
+}
@@ -0,0 +1,37 @@
+package dto
+
+import (
+	"chess-engine/app/domain/dao"
+	"time"
+)
+
+// ChallengeRequest is the body of POST /api/challenge. The opponent is named
+// by Username, or by UserID for a player without one. Colour is the colour
+// the challenger wants to play: "white", "black", or "random" (the default).
+type ChallengeRequest struct {
+	TimeControlRequest
+	Username string `json:"username"`
+	UserID   int    `json:"user_id"`
+	Colour   string `json:"colour"`
+	Rated    bool   `json:"rated"`
+	FEN      string `json:"fen"`
+}
+
+// Challenge is an open direct challenge. Colour is the challenger's; the
+// challenged user plays the other side.
+type Challenge struct {
+	GameID      int        `json:"game_id"`
+	Challenger  *dao.User  `json:"challenger"`
+	Challenged  *dao.User  `json:"challenged"`
+	Colour      string     `json:"colour"`
+	TimeControl string     `json:"time_control"`
+	Rated       bool       `json:"rated"`
+	StartFEN    string     `json:"start_fen,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// Challenges are the caller's open challenges, from GET /api/challenge.
+type Challenges struct {
+	Incoming []Challenge `json:"incoming"`
+	Outgoing []Challenge `json:"outgoing"`
+}
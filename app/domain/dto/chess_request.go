@@ -1,5 +1,10 @@
 package dto
 
+import (
+	"chess-engine/app/domain/dao"
+	"time"
+)
+
 type Move struct {
 	// piece: "wR1",
 	// source_square: "a1",
@@ -15,6 +20,13 @@ type Move struct {
 	Token     string `json:"token"`
 }
 
+// GameAction is the payload of a non-move game message (resign, draw offers,
+// abort). It carries only the sender's credentials.
+type GameAction struct {
+	GameId string `json:"game_id"`
+	Token  string `json:"token"`
+}
+
 type ChessRequest struct {
 	Moves []Move `json:"moves"`
 }
@@ -27,12 +39,97 @@ type TokenGetRequest struct {
 	Token string `json:"token"`
 }
 
+// TimeControlRequest is the optional clock part of a create/join request.
+// TimeControl is "minutes+seconds" (e.g. "5+3"); DaysPerMove makes it a
+// correspondence game instead. Leaving both empty creates an untimed game.
+type TimeControlRequest struct {
+	TimeControl string `json:"time_control"`
+	DaysPerMove int    `json:"days_per_move"`
+}
+
+type CreateChessGameRequest struct {
+	Token string `json:"token"`
+	// FEN optionally starts the game from a set-up position instead of the
+	// standard one.
+	FEN string `json:"fen"`
+	// Rated asks for the result to count towards both players' ratings. It
+	// only matters for games between two people; see ChessGame.Rated.
+	Rated bool `json:"rated"`
+	TimeControlRequest
+}
+
 type CreateBotGameRequest struct {
 	Token      string `json:"token"`
 	Difficulty string `json:"difficulty"` // "easy" | "medium" | "hard"
+	FEN        string `json:"fen"`
+	TimeControlRequest
+}
+
+// ImportChessGameRequest creates games from PGN text, which may hold several
+// games. Each is imported as a pass-and-play game owned by the caller, so it
+// can be studied or played on. Ply, when set, starts play after that many
+// half-moves of every imported game instead of at its end.
+type ImportChessGameRequest struct {
+	Token string `json:"token"`
+	PGN   string `json:"pgn"`
+	Ply   *int   `json:"ply"`
 }
 
 type JoinChessGameRequest struct {
 	Token      string `json:"token"`
 	InviteCode string `json:"invite_code"`
+	// Optional. When set, the join is refused unless the game was created with
+	// this time control, so a client cannot be seated in a bullet game it
+	// thought was untimed.
+	TimeControlRequest
+}
+
+// GameSummary is one game in a user's history, seen from that user's side.
+// Result is "win", "loss", "draw", "aborted" or "ongoing"; RatingChange is the
+// user's, when the game was rated.
+type GameSummary struct {
+	ID           int       `json:"id"`
+	White        *dao.User `json:"white"`
+	Black        *dao.User `json:"black"`
+	Winner       string    `json:"winner"`
+	Result       string    `json:"result"`
+	Termination  string    `json:"termination"`
+	BotLevel     string    `json:"bot_level"`
+	TimeControl  string    `json:"time_control"`
+	Category     string    `json:"category"`
+	Rated        bool      `json:"rated"`
+	RatingChange *int      `json:"rating_change,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// UserGamesPage is a page of GET /api/user/:userID/games. NextCursor, when
+// set, is the cursor query parameter for the next, older page.
+type UserGamesPage struct {
+	Games      []GameSummary `json:"games"`
+	NextCursor *int          `json:"next_cursor"`
+}
+
+// ResultTotals counts finished games. Score is the points scored as a
+// percentage, a draw counting half.
+type ResultTotals struct {
+	Games  int     `json:"games"`
+	Wins   int     `json:"wins"`
+	Draws  int     `json:"draws"`
+	Losses int     `json:"losses"`
+	Score  float64 `json:"score"`
+}
+
+// OpeningTotals is how a user has fared in one opening, named by its first
+// moves in SAN.
+type OpeningTotals struct {
+	Moves string `json:"moves"`
+	ResultTotals
+}
+
+// UserStats is GET /api/user/:userID/stats.
+type UserStats struct {
+	Total    ResultTotals    `json:"total"`
+	White    ResultTotals    `json:"white"`
+	Black    ResultTotals    `json:"black"`
+	Openings []OpeningTotals `json:"openings"`
 }
@@ -0,0 +1,21 @@
+package dto
+
+import (
+	"chess-engine/app/domain/dao"
+	"time"
+)
+
+// Leaderboard is the top of one category's ratings, from GET /api/leaderboard.
+type Leaderboard struct {
+	Category string             `json:"category"`
+	Entries  []LeaderboardEntry `json:"entries"`
+}
+
+type LeaderboardEntry struct {
+	Rank      int       `json:"rank"`
+	User      dao.User  `json:"user"`
+	Rating    int       `json:"rating"`
+	Deviation int       `json:"deviation"`
+	Games     int       `json:"games"`
+	RatedAt   time.Time `json:"rated_at"`
+}
@@ -0,0 +1,41 @@
+package dto
+
+import (
+	"chess-engine/app/domain/dao"
+	"time"
+)
+
+// SeekRequest is the body of POST /api/seek: the game the caller wants and
+// how far from their own rating the opponent's may be. RatingRange 0 takes
+// the server's default.
+type SeekRequest struct {
+	TimeControlRequest
+	Rated       bool `json:"rated"`
+	RatingRange int  `json:"rating_range"`
+}
+
+// SeekStatus is the caller's place in the matchmaking pool, from GET and POST
+// /api/seek. While Seeking, Window is how far apart the ratings of a pairing
+// may be right now; it widens the longer the seek waits, up to RatingRange.
+// Once matched, Seeking is false and GameID is set for a short while, for a
+// client that missed the match_found notification.
+type SeekStatus struct {
+	Seeking     bool       `json:"seeking"`
+	TimeControl string     `json:"time_control,omitempty"`
+	Rated       bool       `json:"rated"`
+	Rating      int        `json:"rating,omitempty"`
+	RatingRange int        `json:"rating_range,omitempty"`
+	Window      int        `json:"window,omitempty"`
+	Since       *time.Time `json:"since,omitempty"`
+	GameID      *int       `json:"game_id,omitempty"`
+}
+
+// MatchFound is the payload of a MessageMatchFound: the new game and which
+// side the recipient has in it.
+type MatchFound struct {
+	GameID      int       `json:"game_id"`
+	Colour      string    `json:"colour"`
+	Opponent    *dao.User `json:"opponent"`
+	TimeControl string    `json:"time_control"`
+	Rated       bool      `json:"rated"`
+}
@@ -0,0 +1,52 @@
+package dto
+
+import "chess-engine/app/domain/dao"
+
+// TournamentRequest is the body of POST /api/tournament. Kind is "swiss",
+// which plays Rounds rounds, or "arena", which pairs players for
+// DurationMinutes. TimeControl is a live control such as "3+2".
+type TournamentRequest struct {
+	Name            string `json:"name"`
+	Kind            string `json:"kind"`
+	TimeControl     string `json:"time_control"`
+	Rounds          int    `json:"rounds"`
+	DurationMinutes int    `json:"duration_minutes"`
+	Rated           bool   `json:"rated"`
+}
+
+// TournamentDetail is a tournament with its standings and pairings, from GET
+// /api/tournament/:tournamentId and on /ws/tournament/:tournamentId.
+type TournamentDetail struct {
+	Tournament  dao.Tournament          `json:"tournament"`
+	TimeControl string                  `json:"time_control"`
+	Standings   []TournamentStanding    `json:"standings"`
+	Pairings    []dao.TournamentPairing `json:"pairings"`
+}
+
+// TournamentStanding is a player's line in the standings. Streak is only
+// kept in an arena, where a winning streak doubles the points.
+type TournamentStanding struct {
+	Rank            int       `json:"rank"`
+	User            *dao.User `json:"user"`
+	Rating          int       `json:"rating"`
+	Score           float64   `json:"score"`
+	Buchholz        float64   `json:"buchholz"`
+	SonnebornBerger float64   `json:"sonneborn_berger"`
+	Played          int       `json:"played"`
+	Wins            int       `json:"wins"`
+	Draws           int       `json:"draws"`
+	Losses          int       `json:"losses"`
+	Streak          int       `json:"streak,omitempty"`
+	Withdrawn       bool      `json:"withdrawn"`
+}
+
+// TournamentGame tells a player they have been paired, on their user
+// channel. A bye has no GameID and no Opponent.
+type TournamentGame struct {
+	TournamentID int       `json:"tournament_id"`
+	Round        int       `json:"round"`
+	GameID       *int      `json:"game_id,omitempty"`
+	Colour       string    `json:"colour,omitempty"`
+	Opponent     *dao.User `json:"opponent,omitempty"`
+	TimeControl  string    `json:"time_control"`
+}
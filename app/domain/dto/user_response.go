@@ -1,17 +1,54 @@
 package dto
 
-// CreatedUser is the response to POST /api/user. It is the only place the
-// session token crosses the wire outbound: the client stores it and sends it
-// back to authenticate. dao.User itself hides the token (see dao/user.go).
+import (
+	"chess-engine/app/domain/dao"
+	"time"
+)
+
+// CreatedUser is the response to POST /api/user and to the register and login
+// routes under /api/auth. These are the only places the session token crosses
+// the wire outbound: the client stores it and sends it back to authenticate.
+// dao.User itself hides the token (see dao/user.go).
 type CreatedUser struct {
 	ID       int    `json:"id"`
 	Name     string `json:"name"`
+	Username string `json:"username,omitempty"`
 	Status   int    `json:"status"`
 	MetaData string `json:"meta_data"`
 	Token    string `json:"token"`
+	// ExpiresAt is when the token stops working unless it is used (each use
+	// extends it) or refreshed. A guest's token does not expire until the
+	// guest sets a password.
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 // UpdateUserRequest is the accepted body for PUT /api/user/:userID.
 type UpdateUserRequest struct {
 	Name string `json:"name"`
 }
+
+// RegisterRequest is the body of POST /api/auth/register. With a guest's
+// token the guest's own row is claimed, keeping its games; without one a new
+// account is created.
+type RegisterRequest struct {
+	Token    string `json:"token"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Device   string `json:"device"`
+}
+
+// LoginRequest is the body of POST /api/auth/login.
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Device labels the new session in the session list. The User-Agent is
+	// used when it is empty.
+	Device string `json:"device"`
+}
+
+// SessionInfo is one entry of GET /api/auth/sessions. Current marks the
+// session the request was made with.
+type SessionInfo struct {
+	dao.Session
+	Current bool `json:"current"`
+}
@@ -1,8 +1,126 @@
 package dto
 
+import "chess-engine/app/domain/dao"
+
 type WebSocketMessage struct {
 	Type    string      `json:"type"`    // Type of message (e.g., "move", "state", "broadcast", "error")
 	Status  string      `json:"status"`  // Status of the message (e.g., "success", "error")
 	Message string      `json:"message"` // Message content
 	Payload interface{} `json:"payload"` // Message payload (can be any structured data)
+	// Spectators is how many connections are watching the game, filled in on
+	// every server -> client message.
+	Spectators int `json:"spectators"`
+	// Seq is the game's update number (dao.ChessGame.Seq) on messages that
+	// carry its state. A client that sees one lower than the last it applied
+	// is looking at a stale update and drops it.
+	Seq int `json:"seq,omitempty"`
 }
+
+// Client -> server message types. A move may also arrive as "game_update" or
+// with no type at all: that is what clients sent before messages were typed.
+const (
+	MessageMove        = "move"
+	MessageResign      = "resign"
+	MessageDrawOffer   = "draw_offer"
+	MessageDrawAccept  = "draw_accept"
+	MessageDrawDecline = "draw_decline"
+	MessageAbort       = "abort"
+
+	MessageTakebackRequest = "takeback_request"
+	MessageTakebackAccept  = "takeback_accept"
+	MessageTakebackDecline = "takeback_decline"
+
+	// The rematch messages are sent on a finished game's socket.
+	MessageRematchOffer   = "rematch_offer"
+	MessageRematchAccept  = "rematch_accept"
+	MessageRematchDecline = "rematch_decline"
+
+	// MessageSync asks for whatever came after the update numbered LastSeq.
+	// Spectators may send it too.
+	MessageSync = "sync"
+)
+
+// SyncRequest is the payload of a MessageSync.
+type SyncRequest struct {
+	LastSeq int `json:"last_seq"`
+}
+
+// SyncDelta answers a MessageSync whose gap the moves alone can fill: the
+// moves made after the client's last_seq, oldest first, and the clock as of
+// now. Seq is the game's current update number. When something other than a
+// move happened in the gap -- a takeback, a draw offer, a resignation -- the
+// answer is a full game_update snapshot instead.
+type SyncDelta struct {
+	Seq   int                `json:"seq"`
+	Moves []dao.GameMove     `json:"moves"`
+	Clock *dao.ClockSnapshot `json:"clock,omitempty"`
+}
+
+// Server -> client message types. MessageGameUpdate carries the game;
+// MessageSpectators carries only the spectator count, when it changes. A
+// reply to a sync is a MessageSync carrying a SyncDelta, or a MessageGameUpdate.
+// MessageRematch carries a Rematch, once a rematch has been agreed.
+const (
+	MessageGameUpdate = "game_update"
+	MessageSpectators = "spectators"
+	MessageRematch    = "rematch"
+)
+
+// Rematch tells a finished game's clients where the rematch is: GameID, with
+// the colours swapped, following on from PreviousGameID.
+type Rematch struct {
+	GameID         int         `json:"game_id"`
+	PreviousGameID int         `json:"previous_game_id"`
+	Series         SeriesScore `json:"series"`
+}
+
+// SeriesScore is the head-to-head score of a chain of rematches, from the
+// first game up to and including the game asked about. Unfinished and aborted
+// games count in Games but score nothing.
+type SeriesScore struct {
+	Games   int            `json:"games"`
+	Players []SeriesPlayer `json:"players"`
+	Draws   int            `json:"draws"`
+}
+
+type SeriesPlayer struct {
+	UserID int     `json:"user_id"`
+	Name   string  `json:"name"`
+	Wins   int     `json:"wins"`
+	Score  float64 `json:"score"`
+}
+
+// Message types on the user channel, /ws/user. MessageMatchFound carries a
+// MatchFound; MessageSeekExpired says a seek waited too long and was dropped.
+// The challenge messages all carry a Challenge: MessageChallenge goes to the
+// user challenged, the answers to the challenger, and a cancellation or expiry
+// to both. MessageTournamentGame carries a TournamentGame, and
+// MessageTournamentWithdrawn a TournamentDetail, when a player is taken out
+// of a tournament for not starting their game.
+const (
+	MessageMatchFound  = "match_found"
+	MessageSeekExpired = "seek_expired"
+
+	MessageChallenge          = "challenge"
+	MessageChallengeAccepted  = "challenge_accepted"
+	MessageChallengeDeclined  = "challenge_declined"
+	MessageChallengeCancelled = "challenge_cancelled"
+	MessageChallengeExpired   = "challenge_expired"
+
+	MessageTournamentGame      = "tournament_game"
+	MessageTournamentWithdrawn = "tournament_withdrawn"
+)
+
+// MessageTournament is the one message type on a tournament's channel,
+// /ws/tournament/:tournamentId, carrying a TournamentDetail each time the
+// standings or the pairings change.
+const MessageTournament = "tournament"
+
+// Seats a WebSocket connection can hold, decided when it connects.
+// Spectators receive every update but may not send anything that changes the
+// game.
+const (
+	SeatWhite     = "white"
+	SeatBlack     = "black"
+	SeatSpectator = "spectator"
+)
@@ -47,15 +47,24 @@ func applyBotMove(gs dao.GameState, m botMove) dao.GameState {
 //	easy   - greedy 1-ply (grabs material, hangs pieces)
 //	medium - shallow alpha-beta search (won't hang to an immediate recapture)
 //	hard   - deeper alpha-beta search
-func ChooseBotMove(game *dao.ChessGame) *dto.Move {
+//
+// The searching levels play from book while the position is in it, and
+// perfectly once it is in the tablebases; book and tb may be nil. The easy
+// bot uses neither: its openings and endings are meant to be as weak as the
+// rest of its play.
+func ChooseBotMove(game *dao.ChessGame, book *Book, tb *Tablebase) *dto.Move {
+	depth := hardDepth
 	switch game.BotLevel {
 	case "medium":
-		return ChooseSearchMove(game, mediumDepth)
+		depth = mediumDepth
 	case "hard":
-		return ChooseSearchMove(game, hardDepth)
 	default: // "easy" and anything unset
 		return ChooseGreedyMove(game)
 	}
+	if m, ok := book.Probe(game.State); ok {
+		return &m
+	}
+	return chooseSearchMove(game, depth, tb)
 }
 
 // ChooseGreedyMove picks the move that grabs the most material right now
@@ -89,22 +98,31 @@ func ChooseGreedyMove(game *dao.ChessGame) *dto.Move {
 // sees recaptures, so unlike the greedy bot it won't hang pieces by trading
 // into a defended square. Returns nil when there are no legal moves.
 func ChooseSearchMove(game *dao.ChessGame, depth int) *dto.Move {
+	return chooseSearchMove(game, depth, nil)
+}
+
+// chooseSearchMove is ChooseSearchMove, choosing only among the moves the
+// tablebases rank best when the position is in them.
+func chooseSearchMove(game *dao.ChessGame, depth int, tb *Tablebase) *dto.Move {
 	gs := game.State
 	moves := sideToMoveMovesOrdered(gs)
 	if len(moves) == 0 {
 		return nil
 	}
+	if kept, ok := tb.rootMoves(gs, moves); ok {
+		moves = kept
+	}
 
 	// Seed the repetition path from the moves already played, so the bot does not
 	// shuffle a winning position into a threefold draw.
-	path := append(SearchHistory(ReplayGameKeys(RecordedMoves(game.Moves))), PositionKey(gs))
-	c := &searchCtx{path: path}
+	path := append(SearchHistory(ReplayGameKeys(GameStartState(*game), RecordedMoves(game.Moves))), PositionKey(gs))
+	c := &searchCtx{path: path, tb: tb}
 
 	bestScore := -searchInf
 	var best []botMove
 	for _, m := range moves {
 		// Full window at the root so tied-best moves are collected correctly.
-		score := -negamax(c, applyBotMove(gs, m), depth-1, -searchInf, searchInf)
+		score := -negamax(c, applyBotMove(gs, m), depth-1, -searchInf, searchInf, 1)
 		if score > bestScore {
 			bestScore = score
 			best = []botMove{m}
@@ -117,11 +135,14 @@ func ChooseSearchMove(game *dao.ChessGame, depth int) *dto.Move {
 
 // negamax returns the value of gs from the side-to-move's perspective. It shares
 // searchCtx with the UCI search so both see repetitions and the fifty-move rule.
-func negamax(c *searchCtx, gs dao.GameState, depth, alpha, beta int) int {
+func negamax(c *searchCtx, gs dao.GameState, depth, alpha, beta, ply int) int {
 	key := PositionKey(gs)
 	if c.drawAtNode(gs, key) {
 		return 0
 	}
+	if score, ok := c.tb.probeScore(gs, ply); ok {
+		return score
+	}
 
 	if depth == 0 {
 		return quiescence(c, gs, alpha, beta, maxQuiescenceDepth)
@@ -139,7 +160,7 @@ func negamax(c *searchCtx, gs dao.GameState, depth, alpha, beta int) int {
 
 	best := -searchInf
 	for _, m := range moves {
-		score := -negamax(c, applyBotMove(gs, m), depth-1, -beta, -alpha)
+		score := -negamax(c, applyBotMove(gs, m), depth-1, -beta, -alpha, ply+1)
 		if score > best {
 			best = score
 		}
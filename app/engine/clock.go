@@ -0,0 +1,304 @@
+package engine
+
+import (
+	"chess-engine/app/domain/dao"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chess clocks.
+//
+// A game used to have no notion of time at all, so a player who walked away
+// stalled it forever. The rules here are pure functions over the stored
+// GameState so the server can apply them under its per-game lock and tests can
+// drive them with a fixed "now".
+//
+// The clock starts with White's first move: that move is free, and from then
+// on the side to move is always running down. Correspondence games give each
+// move a fresh allowance instead of a running total.
+
+// Timeout status values, reported in the same place as "white_checkmate". The
+// colour named is the side whose flag fell.
+const (
+	WhiteTimeout = "white_timeout"
+	BlackTimeout = "black_timeout"
+	// DrawByTimeoutVsInsufficientMaterial is a flag fall where the opponent
+	// could not have mated by any sequence of legal moves.
+	DrawByTimeoutVsInsufficientMaterial = "draw_timeout_vs_insufficient_material"
+)
+
+// maxBaseMinutes and maxIncrementSeconds bound a live time control to something
+// a person could actually sit through; maxDaysPerMove does the same for
+// correspondence.
+const (
+	maxBaseMinutes      = 180
+	maxIncrementSeconds = 180
+	maxDaysPerMove      = 14
+)
+
+// TimeControl is a game's clock configuration. The zero value is untimed.
+type TimeControl struct {
+	Base        time.Duration
+	Increment   time.Duration
+	DaysPerMove int
+}
+
+// ParseTimeControl reads a live time control in the usual "minutes+seconds"
+// form ("5+3", "0.5+0", "15+10"), or a correspondence one from daysPerMove. An
+// empty string with no days is an untimed game. Giving both is an error, as is
+// a control with no time on it at all ("0+0").
+func ParseTimeControl(s string, daysPerMove int) (TimeControl, error) {
+	s = strings.TrimSpace(s)
+	if daysPerMove != 0 {
+		if s != "" {
+			return TimeControl{}, fmt.Errorf("a game is either live (%q) or correspondence (%d days), not both", s, daysPerMove)
+		}
+		if daysPerMove < 1 || daysPerMove > maxDaysPerMove {
+			return TimeControl{}, fmt.Errorf("days per move must be between 1 and %d, got %d", maxDaysPerMove, daysPerMove)
+		}
+		return TimeControl{DaysPerMove: daysPerMove}, nil
+	}
+	if s == "" {
+		return TimeControl{}, nil
+	}
+
+	baseStr, incStr, ok := strings.Cut(s, "+")
+	if !ok {
+		return TimeControl{}, fmt.Errorf("invalid time control %q: want minutes+seconds, e.g. 5+3", s)
+	}
+	minutes, err := strconv.ParseFloat(baseStr, 64)
+	// Written so NaN, which ParseFloat accepts and which fails every
+	// comparison, is out of range too.
+	if err != nil || !(minutes >= 0 && minutes <= maxBaseMinutes) {
+		return TimeControl{}, fmt.Errorf("invalid time control %q: base must be 0 to %d minutes", s, maxBaseMinutes)
+	}
+	inc, err := strconv.Atoi(incStr)
+	if err != nil || inc < 0 || inc > maxIncrementSeconds {
+		return TimeControl{}, fmt.Errorf("invalid time control %q: increment must be 0 to %d seconds", s, maxIncrementSeconds)
+	}
+	tc := TimeControl{
+		Base:      time.Duration(minutes * float64(time.Minute)).Round(time.Second),
+		Increment: time.Duration(inc) * time.Second,
+	}
+	if tc.Base == 0 && tc.Increment == 0 {
+		return TimeControl{}, fmt.Errorf("invalid time control %q: no time on the clock", s)
+	}
+	return tc, nil
+}
+
+// GameTimeControl reads the time control stored on a game.
+func GameTimeControl(game dao.ChessGame) TimeControl {
+	return TimeControl{
+		Base:        time.Duration(game.BaseSeconds) * time.Second,
+		Increment:   time.Duration(game.IncrementSeconds) * time.Second,
+		DaysPerMove: game.DaysPerMove,
+	}
+}
+
+// Apply writes the time control onto a game's stored fields.
+func (tc TimeControl) Apply(game *dao.ChessGame) {
+	game.BaseSeconds = int(tc.Base / time.Second)
+	game.IncrementSeconds = int(tc.Increment / time.Second)
+	game.DaysPerMove = tc.DaysPerMove
+}
+
+// Timed reports whether the game has a clock at all.
+func (tc TimeControl) Timed() bool {
+	return tc.Base > 0 || tc.Increment > 0 || tc.DaysPerMove > 0
+}
+
+// Time-control categories. Ratings are kept separately for each, since a
+// player's bullet strength says little about their classical play.
+const (
+	CategoryBullet         = "bullet"
+	CategoryBlitz          = "blitz"
+	CategoryRapid          = "rapid"
+	CategoryClassical      = "classical"
+	CategoryCorrespondence = "correspondence"
+	CategoryUntimed        = "untimed"
+)
+
+// Categories lists every category, fastest first.
+var Categories = []string{
+	CategoryBullet, CategoryBlitz, CategoryRapid, CategoryClassical, CategoryCorrespondence, CategoryUntimed,
+}
+
+// Category classifies a live control by its estimated duration, base plus 40
+// increments (a 40-move game), with Lichess's boundaries: under 3 minutes is
+// bullet, under 8 blitz, under 25 rapid.
+func (tc TimeControl) Category() string {
+	switch {
+	case tc.DaysPerMove > 0:
+		return CategoryCorrespondence
+	case !tc.Timed():
+		return CategoryUntimed
+	}
+	switch estimate := tc.Base + 40*tc.Increment; {
+	case estimate < 3*time.Minute:
+		return CategoryBullet
+	case estimate < 8*time.Minute:
+		return CategoryBlitz
+	case estimate < 25*time.Minute:
+		return CategoryRapid
+	}
+	return CategoryClassical
+}
+
+// String renders the control the way ParseTimeControl reads it: "5+3" for a
+// live game, "3d" for correspondence, "" for untimed.
+func (tc TimeControl) String() string {
+	switch {
+	case tc.DaysPerMove > 0:
+		return strconv.Itoa(tc.DaysPerMove) + "d"
+	case !tc.Timed():
+		return ""
+	}
+	minutes := strconv.FormatFloat(tc.Base.Minutes(), 'f', -1, 64)
+	return minutes + "+" + strconv.Itoa(int(tc.Increment/time.Second))
+}
+
+// allowance is the time each side starts with, and for correspondence the
+// time every move gets.
+func (tc TimeControl) allowance() time.Duration {
+	if tc.DaysPerMove > 0 {
+		return time.Duration(tc.DaysPerMove) * 24 * time.Hour
+	}
+	return tc.Base
+}
+
+// StartClocks gives both sides their starting time. The clock does not run
+// until White's first move.
+func StartClocks(gs *dao.GameState, tc TimeControl) {
+	ms := tc.allowance().Milliseconds()
+	gs.WhiteClockMs = ms
+	gs.BlackClockMs = ms
+	gs.ClockStartedAt = 0
+}
+
+// ClockRemaining returns a side's time left at now. Only the side to move's
+// clock is running, so the other side's stored value is already exact.
+func ClockRemaining(gs dao.GameState, side string, now time.Time) time.Duration {
+	ms := gs.WhiteClockMs
+	if side == "b" {
+		ms = gs.BlackClockMs
+	}
+	if gs.ClockStartedAt != 0 && side == gs.Turn {
+		ms -= now.UnixMilli() - gs.ClockStartedAt
+	}
+	if ms < 0 {
+		ms = 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// PunchClock stops the clock of the side that just moved and starts the
+// opponent's. gs is the position after the move, so the mover is the side not
+// to move. The first move of the game is untimed; every later one is charged
+// its thinking time and earns the increment (or, in correspondence, a fresh
+// allowance).
+func PunchClock(gs *dao.GameState, tc TimeControl, now time.Time) {
+	if !tc.Timed() {
+		return
+	}
+	mover := &gs.WhiteClockMs
+	if gs.Turn == "w" {
+		mover = &gs.BlackClockMs
+	}
+	if gs.ClockStartedAt != 0 {
+		*mover -= now.UnixMilli() - gs.ClockStartedAt
+		if tc.DaysPerMove > 0 {
+			*mover = tc.allowance().Milliseconds()
+		} else {
+			*mover += tc.Increment.Milliseconds()
+		}
+	}
+	gs.ClockStartedAt = now.UnixMilli()
+}
+
+// StopClocks brings the running clock up to date and stops it, for a game that
+// has just ended.
+func StopClocks(gs *dao.GameState, now time.Time) {
+	if gs.ClockStartedAt == 0 {
+		return
+	}
+	remaining := ClockRemaining(*gs, gs.Turn, now).Milliseconds()
+	if gs.Turn == "b" {
+		gs.BlackClockMs = remaining
+	} else {
+		gs.WhiteClockMs = remaining
+	}
+	gs.ClockStartedAt = 0
+}
+
+// FlaggedSide returns the side whose time has run out at now, or "" if none
+// has. Only the side to move can flag: the other clock is stopped.
+func FlaggedSide(gs dao.GameState, tc TimeControl, now time.Time) string {
+	if !tc.Timed() || gs.ClockStartedAt == 0 {
+		return ""
+	}
+	if ClockRemaining(gs, gs.Turn, now) > 0 {
+		return ""
+	}
+	return gs.Turn
+}
+
+// ClockSnapshotAt builds the clock payload sent to clients. It returns nil for
+// an untimed game.
+func ClockSnapshotAt(game dao.ChessGame, now time.Time) *dao.ClockSnapshot {
+	if !GameTimeControl(game).Timed() {
+		return nil
+	}
+	gs := game.State
+	snap := &dao.ClockSnapshot{
+		WhiteMs: ClockRemaining(gs, "w", now).Milliseconds(),
+		BlackMs: ClockRemaining(gs, "b", now).Milliseconds(),
+	}
+	if gs.ClockStartedAt != 0 && game.Winner == "" {
+		snap.Running = gs.Turn
+	}
+	return snap
+}
+
+// TimeoutResult decides a game in which flagged ran out of time. It is a loss,
+// unless the opponent has too little material to ever mate, in which case it
+// is a draw. winner is "w", "b" or "d", as stored on ChessGame.Winner.
+func TimeoutResult(gs dao.GameState, flagged string) (winner, status string) {
+	opponent := ToggleTurn(flagged)
+	if InsufficientMatingMaterial(gs, opponent == "w") {
+		return "d", DrawByTimeoutVsInsufficientMaterial
+	}
+	if flagged == "w" {
+		return opponent, WhiteTimeout
+	}
+	return opponent, BlackTimeout
+}
+
+// InsufficientMatingMaterial reports whether the given side could not deliver
+// mate even with the opponent's help: a bare king, a king and one minor piece,
+// or a king and bishops that all stand on the same colour of square.
+//
+// Two knights are not on the list. They cannot force mate, but a blundering
+// opponent can be mated by them, and that is the test that matters on a flag.
+func InsufficientMatingMaterial(gs dao.GameState, white bool) bool {
+	own := gs.BlackBitboard
+	if white {
+		own = gs.WhiteBitboard
+	}
+	if own&(gs.PawnBitboard|gs.RookBitboard|gs.QueenBitboard) != 0 {
+		return false
+	}
+	knights := own & gs.KnightBitboard
+	bishops := own & gs.BishopBitboard
+	minors := bits.OnesCount64(knights | bishops)
+	if minors <= 1 {
+		return true
+	}
+	if knights != 0 {
+		return false
+	}
+	const lightSquares = uint64(0x55AA55AA55AA55AA)
+	return bishops&lightSquares == 0 || bishops&^lightSquares == 0
+}
@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeControl(t *testing.T) {
+	cases := []struct {
+		in   string
+		days int
+		want TimeControl
+	}{
+		{"", 0, TimeControl{}},
+		{"5+3", 0, TimeControl{Base: 5 * time.Minute, Increment: 3 * time.Second}},
+		{"0.5+0", 0, TimeControl{Base: 30 * time.Second}},
+		{"0+2", 0, TimeControl{Increment: 2 * time.Second}},
+		{"", 3, TimeControl{DaysPerMove: 3}},
+	}
+	for _, c := range cases {
+		got, err := ParseTimeControl(c.in, c.days)
+		if err != nil {
+			t.Errorf("ParseTimeControl(%q, %d): %v", c.in, c.days, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("ParseTimeControl(%q, %d) = %+v, want %+v", c.in, c.days, got, c.want)
+		}
+		again, err := ParseTimeControl(got.String(), 0)
+		if c.days == 0 && (err != nil || again != got) {
+			t.Errorf("%q does not round-trip through String(): %+v, %v", c.in, again, err)
+		}
+	}
+
+	for _, bad := range []struct {
+		in   string
+		days int
+	}{
+		{"5", 0}, {"0+0", 0}, {"-1+0", 0}, {"5+x", 0}, {"999+0", 0}, {"5+3", 2}, {"", 99},
+		{"NaN+3", 0}, {"nan+0", 0}, {"Inf+0", 0},
+	} {
+		if _, err := ParseTimeControl(bad.in, bad.days); err == nil {
+			t.Errorf("ParseTimeControl(%q, %d) expected an error", bad.in, bad.days)
+		}
+	}
+}
+
+func TestTimeControlCategory(t *testing.T) {
+	cases := map[string]string{
+		"1+0":   CategoryBullet,
+		"2+1":   CategoryBullet,
+		"3+0":   CategoryBlitz,
+		"5+3":   CategoryBlitz,
+		"10+0":  CategoryRapid,
+		"15+10": CategoryRapid,
+		"20+10": CategoryClassical,
+		"30+0":  CategoryClassical,
+		"":      CategoryUntimed,
+	}
+	for in, want := range cases {
+		tc, err := ParseTimeControl(in, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tc.Category(); got != want {
+			t.Errorf("%q: Category() = %q, want %q", in, got, want)
+		}
+	}
+	if got := (TimeControl{DaysPerMove: 2}).Category(); got != CategoryCorrespondence {
+		t.Errorf("correspondence category = %q", got)
+	}
+}
+
+// The first move is free; after that the mover is charged its thinking time
+// and earns the increment.
+func TestPunchClockDeductsAndAddsIncrement(t *testing.T) {
+	tc := TimeControl{Base: time.Minute, Increment: 2 * time.Second}
+	gs := StartState()
+	StartClocks(&gs, tc)
+	t0 := time.Unix(1_000_000, 0)
+
+	gs = ApplyMove(gs, mustUCI(t, gs, "e2e4"))
+	PunchClock(&gs, tc, t0)
+	if gs.WhiteClockMs != 60_000 {
+		t.Errorf("White's first move was charged: %d ms left", gs.WhiteClockMs)
+	}
+
+	// Black thinks for 10s.
+	gs = ApplyMove(gs, mustUCI(t, gs, "e7e5"))
+	PunchClock(&gs, tc, t0.Add(10*time.Second))
+	if gs.BlackClockMs != 52_000 {
+		t.Errorf("Black clock = %d ms, want 60000 - 10000 + 2000", gs.BlackClockMs)
+	}
+
+	// White is running; 59s later White has 1s left, 61s later White has flagged.
+	if got := ClockRemaining(gs, "w", t0.Add(69*time.Second)); got != time.Second {
+		t.Errorf("White remaining = %v, want 1s", got)
+	}
+	if got := FlaggedSide(gs, tc, t0.Add(69*time.Second)); got != "" {
+		t.Errorf("flagged with time left: %q", got)
+	}
+	if got := FlaggedSide(gs, tc, t0.Add(71*time.Second)); got != "w" {
+		t.Errorf("FlaggedSide = %q, want w", got)
+	}
+	// Black's clock is stopped, so it does not move however long White takes.
+	if got := ClockRemaining(gs, "b", t0.Add(time.Hour)); got != 52*time.Second {
+		t.Errorf("Black's stopped clock = %v, want 52s", got)
+	}
+}
+
+func TestPunchClockCorrespondenceRefills(t *testing.T) {
+	tc := TimeControl{DaysPerMove: 1}
+	gs := StartState()
+	StartClocks(&gs, tc)
+	t0 := time.Unix(1_000_000, 0)
+
+	gs = ApplyMove(gs, mustUCI(t, gs, "e2e4"))
+	PunchClock(&gs, tc, t0)
+	gs = ApplyMove(gs, mustUCI(t, gs, "e7e5"))
+	PunchClock(&gs, tc, t0.Add(20*time.Hour))
+
+	if gs.BlackClockMs != (24 * time.Hour).Milliseconds() {
+		t.Errorf("Black clock = %d ms, want a full day again", gs.BlackClockMs)
+	}
+}
+
+func TestTimeoutResult(t *testing.T) {
+	cases := []struct {
+		name, fen, flagged, winner, status string
+	}{
+		{"normal loss", StartFEN, "w", "b", WhiteTimeout},
+		{"opponent has bare king", "4k3/8/8/8/8/8/4P3/4K3 b - - 0 1", "w", "d", DrawByTimeoutVsInsufficientMaterial},
+		{"opponent has a lone knight", "4k3/8/8/8/8/8/8/1N2K3 b - - 0 1", "b", "d", DrawByTimeoutVsInsufficientMaterial},
+		{"opponent has two knights", "4k3/8/8/8/8/8/8/1N2KN2 b - - 0 1", "b", "w", BlackTimeout},
+		{"bishop pair", "4k3/8/8/8/8/8/8/2B1KB2 b - - 0 1", "b", "w", BlackTimeout},
+		{"opponent has a pawn", "4k3/4p3/8/8/8/8/8/4K3 w - - 0 1", "w", "b", WhiteTimeout},
+	}
+	for _, c := range cases {
+		gs := mustFEN(t, c.fen)
+		winner, status := TimeoutResult(gs, c.flagged)
+		if winner != c.winner || status != c.status {
+			t.Errorf("%s: TimeoutResult = (%q, %q), want (%q, %q)", c.name, winner, status, c.winner, c.status)
+		}
+	}
+}
+
+func TestInsufficientMatingMaterialBishopColours(t *testing.T) {
+	// c1 and f1 are different colours, so these two bishops can mate.
+	if InsufficientMatingMaterial(mustFEN(t, "4k3/8/8/8/8/8/8/2B1KB2 w - - 0 1"), true) {
+		t.Error("opposite-coloured bishops reported as insufficient")
+	}
+	// c1 and e3 are both dark.
+	if !InsufficientMatingMaterial(mustFEN(t, "4k3/8/8/8/8/4B3/8/2B1K3 w - - 0 1"), true) {
+		t.Error("same-coloured bishops reported as sufficient")
+	}
+}
@@ -3,6 +3,7 @@ package engine
 import (
 	"chess-engine/app/domain/dao"
 	"chess-engine/app/domain/dto"
+	"fmt"
 	"strings"
 )
 
@@ -46,14 +47,14 @@ func ParseRecordedMove(s string) (dto.Move, bool) {
 	return move, true
 }
 
-// ReplayGameKeys replays a game's recorded moves from the standard starting
-// position and returns the Zobrist key of every position that occurred: the
-// start position first, then one per move.
+// ReplayGameKeys replays a game's recorded moves from start (see
+// GameStartState) and returns the Zobrist key of every position that occurred:
+// the start position first, then one per move.
 //
 // An unparseable move stops the replay and returns the keys accumulated so far.
 // A short history can only fail to spot a repetition, never invent one.
-func ReplayGameKeys(moves []string) []uint64 {
-	gs := StartState()
+func ReplayGameKeys(start dao.GameState, moves []string) []uint64 {
+	gs := start
 	keys := make([]uint64, 0, len(moves)+1)
 	keys = append(keys, PositionKey(gs))
 
@@ -68,6 +69,44 @@ func ReplayGameKeys(moves []string) []uint64 {
 	return keys
 }
 
+// ReplayMoves replays recorded moves from start and returns the position they
+// lead to.
+//
+// Unlike ReplayGameKeys it fails on a move it cannot parse or that is not
+// legal: a caller rebuilding the live position from the move list (a takeback)
+// must not silently end up somewhere else.
+func ReplayMoves(start dao.GameState, moves []string) (dao.GameState, error) {
+	gs := start
+	for i, raw := range moves {
+		move, ok := ParseRecordedMove(raw)
+		if !ok || !isSquare(move.Source) || !isSquare(move.Destination) {
+			return dao.GameState{}, fmt.Errorf("ply %d: unreadable move %q", i+1, raw)
+		}
+		if !isLegal(gs, move) {
+			return dao.GameState{}, fmt.Errorf("ply %d: illegal move %q", i+1, raw)
+		}
+		gs = ApplyMove(gs, move)
+	}
+	return gs, nil
+}
+
+// isLegal reports whether move is one of the side to move's legal moves.
+func isLegal(gs dao.GameState, move dto.Move) bool {
+	src := squareBit(move.Source)
+	dst := squareBit(move.Destination)
+	promo := strings.ToLower(move.Promotion)
+	for _, m := range GenerateLegalMoveList(gs) {
+		if m.Src != src || m.Dst != dst {
+			continue
+		}
+		// A recorded promotion with no letter is the historical queen default.
+		if m.Promotion == promo || (promo == "" && m.Promotion == "q") {
+			return true
+		}
+	}
+	return false
+}
+
 // DrawStatus reports whether the position reached at the end of keys is drawn.
 // keys must end with the current position's key, as produced by ReplayGameKeys.
 func DrawStatus(gs dao.GameState, keys []uint64) string {
@@ -94,7 +94,7 @@ func TestDrawStatusThreefold(t *testing.T) {
 		"Ng1f3", "ng8f6", "Nf3g1", "nf6g8",
 		"Ng1f3", "ng8f6", "Nf3g1", "nf6g8",
 	}
-	keys := ReplayGameKeys(moves)
+	keys := ReplayGameKeys(StartState(), moves)
 	if got := len(keys); got != len(moves)+1 {
 		t.Fatalf("replayed %d keys, want %d", got, len(moves)+1)
 	}
@@ -203,3 +203,37 @@ func TestStalemateIsReported(t *testing.T) {
 		}
 	}
 }
+
+func TestReplayMoves(t *testing.T) {
+	moves := []string{"Pe2e4", "pe7e5", "Ng1f3", "nb8c6", "Bf1c4", "ng8f6", "Ke1g1"}
+	gs, err := ReplayMoves(StartState(), moves)
+	if err != nil {
+		t.Fatalf("ReplayMoves: %v", err)
+	}
+	want := "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQ1RK1 b kq - 5 1"
+	if got := ToFEN(gs); got != want {
+		t.Errorf("ReplayMoves = %s, want %s", got, want)
+	}
+	if gs.LastMove != "Ke1g1" {
+		t.Errorf("LastMove = %q, want the final move", gs.LastMove)
+	}
+
+	// Replaying fewer moves is exactly how a takeback rebuilds the position.
+	back, err := ReplayMoves(StartState(), moves[:len(moves)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Turn != "w" || !strings.Contains(back.CastlingRights, "K") {
+		t.Errorf("one ply back: turn %q rights %q, want White to move with castling intact", back.Turn, back.CastlingRights)
+	}
+
+	for _, bad := range [][]string{
+		{"Pe2e5"},          // not a legal pawn move
+		{"Pe2e4", "Pd2d4"}, // wrong side to move
+		{"junk"},
+	} {
+		if _, err := ReplayMoves(StartState(), bad); err == nil {
+			t.Errorf("ReplayMoves(%q) expected an error", bad)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package engine
 import (
 	"chess-engine/app/domain/dao"
 	"fmt"
+	"math/bits"
 	"strconv"
 	"strings"
 )
@@ -187,6 +188,105 @@ func StartState() dao.GameState {
 	return gs
 }
 
+// GameStartState returns the position a game began from: its stored starting
+// FEN, or the standard position for the games (all of them, once) that did not
+// record one. The FEN was validated when the game was created, so a FEN that
+// no longer parses also falls back to the standard position rather than
+// failing every replay of the game.
+func GameStartState(game dao.ChessGame) dao.GameState {
+	if game.StartFEN == "" {
+		return StartState()
+	}
+	gs, err := ParseFEN(game.StartFEN)
+	if err != nil {
+		return StartState()
+	}
+	return gs
+}
+
+// ParseStartFEN parses a FEN for a new game to start from, and rejects a
+// position that ParseFEN would accept but that cannot be played: ParseFEN
+// checks only that the string is well formed, so it takes a board with three
+// kings, or a side to move that could capture the enemy king.
+//
+// The position must have exactly one king per side and no pawns on the back
+// ranks, the side not to move must not be in check, each castling right needs
+// its king and rook on their original squares, an en-passant square must
+// belong to a pawn that just made a double push, and the side to move must
+// have a legal move.
+func ParseStartFEN(fen string) (dao.GameState, error) {
+	gs, err := ParseFEN(fen)
+	if err != nil {
+		return gs, err
+	}
+
+	for _, side := range []struct {
+		name  string
+		own   uint64
+		white bool
+	}{{"white", gs.WhiteBitboard, true}, {"black", gs.BlackBitboard, false}} {
+		if n := bits.OnesCount64(gs.KingBitboard & side.own); n != 1 {
+			return gs, fmt.Errorf("invalid position: %s has %d kings", side.name, n)
+		}
+		if n := bits.OnesCount64(gs.PawnBitboard & side.own); n > 8 {
+			return gs, fmt.Errorf("invalid position: %s has %d pawns", side.name, n)
+		}
+		if n := bits.OnesCount64(side.own); n > 16 {
+			return gs, fmt.Errorf("invalid position: %s has %d pieces", side.name, n)
+		}
+	}
+	if gs.PawnBitboard&(rank1Mask|rank8Mask) != 0 {
+		return gs, fmt.Errorf("invalid position: pawn on the first or eighth rank")
+	}
+	if isKingInCheck(gs, gs.Turn != "w") {
+		return gs, fmt.Errorf("invalid position: the side not to move is in check")
+	}
+
+	seen := ""
+	for _, right := range gs.CastlingRights {
+		king, rook, own := sqE1, sqH1, gs.WhiteBitboard
+		switch right {
+		case 'K':
+		case 'Q':
+			rook = sqA1
+		case 'k':
+			king, rook, own = sqE8, sqH8, gs.BlackBitboard
+		case 'q':
+			king, rook, own = sqE8, sqA8, gs.BlackBitboard
+		default:
+			return gs, fmt.Errorf("invalid position: castling right %q", right)
+		}
+		if strings.ContainsRune(seen, right) {
+			return gs, fmt.Errorf("invalid position: castling right %q repeated", right)
+		}
+		seen += string(right)
+		if gs.KingBitboard&own&king == 0 || gs.RookBitboard&own&rook == 0 {
+			return gs, fmt.Errorf("invalid position: castling right %q without king and rook at home", right)
+		}
+	}
+
+	if gs.EnPassant != 0 {
+		// ParseFEN stores the target square plus the pushed pawn's square.
+		target, pawn := gs.EnPassant&(rank3Mask|rank6Mask), gs.EnPassant&^(rank3Mask|rank6Mask)
+		mover, origin := gs.BlackBitboard, target<<8
+		if target&rank3Mask != 0 {
+			mover, origin = gs.WhiteBitboard, target>>8
+		}
+		if (target&rank3Mask != 0) != (gs.Turn == "b") {
+			return gs, fmt.Errorf("invalid position: en passant square does not match the side to move")
+		}
+		occupied := gs.WhiteBitboard | gs.BlackBitboard
+		if occupied&(target|origin) != 0 || gs.PawnBitboard&mover&pawn == 0 {
+			return gs, fmt.Errorf("invalid position: en passant square without a pawn that just pushed two squares")
+		}
+	}
+
+	if len(GenerateLegalMoveList(gs)) == 0 {
+		return gs, fmt.Errorf("invalid position: the game is already over")
+	}
+	return gs, nil
+}
+
 // bitIndex returns the 0-based index of a single set bit.
 func bitIndex(bit uint64) int {
 	idx := 0
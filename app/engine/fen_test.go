@@ -2,6 +2,7 @@ package engine
 
 import (
 	"chess-engine/app/domain/dao"
+	"strings"
 	"testing"
 )
 
@@ -82,3 +83,43 @@ func TestParseFENErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestParseStartFEN(t *testing.T) {
+	for _, fen := range []string{
+		StartFEN,
+		"4k3/8/8/8/8/8/4P3/4K3 b - - 0 1",
+		"r3k2r/8/8/8/8/8/8/R3K2R w Kq - 0 1",
+		"rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq c6 0 2",
+	} {
+		if _, err := ParseStartFEN(fen); err != nil {
+			t.Errorf("ParseStartFEN(%q): %v", fen, err)
+		}
+	}
+
+	for _, bad := range []struct{ fen, why string }{
+		{"8/8/8/8/8/8/8/4K3 w - - 0 1", "black has 0 kings"},
+		{"4k3/8/8/8/8/8/8/3KK3 w - - 0 1", "white has 2 kings"},
+		{"P3k3/8/8/8/8/8/8/4K3 w - - 0 1", "first or eighth rank"},
+		{"4k3/8/8/8/8/8/8/4K2r b - - 0 1", "not to move is in check"},
+		{"4k3/8/8/8/8/8/8/4K3 w K - 0 1", "without king and rook"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KK - 0 1", "repeated"},
+		{"4k3/8/8/8/4P3/8/8/4K3 w - e3 0 1", "does not match the side to move"},
+		{"4k3/8/8/8/8/8/8/4K3 b - e3 0 1", "without a pawn"},
+		{"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", "already over"},
+	} {
+		_, err := ParseStartFEN(bad.fen)
+		if err == nil || !strings.Contains(err.Error(), bad.why) {
+			t.Errorf("ParseStartFEN(%q): got %v, want an error containing %q", bad.fen, err, bad.why)
+		}
+	}
+}
+
+func TestGameStartState(t *testing.T) {
+	if GameStartState(dao.ChessGame{}) != StartState() {
+		t.Error("a game with no starting FEN must start from the standard position")
+	}
+	fen := "4k3/8/8/8/8/8/4P3/4K3 b - - 0 1"
+	if got := ToFEN(GameStartState(dao.ChessGame{StartFEN: fen})); got != fen {
+		t.Errorf("GameStartState = %q, want %q", got, fen)
+	}
+}
@@ -1,6 +1,9 @@
 package engine
 
-import "chess-engine/app/domain/dao"
+import (
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+)
 
 // LegalMove is a fully specified move. It exists because the generator's native
 // output -- map[srcBit]dstBitboard -- has nowhere to record a promotion choice,
@@ -28,3 +31,14 @@ func GenerateLegalMoveList(gs dao.GameState) []LegalMove {
 	}
 	return out
 }
+
+// LegalMoves is GenerateLegalMoveList as moves in the form the rest of the
+// server speaks, with the moving piece filled in.
+func LegalMoves(gs dao.GameState) []dto.Move {
+	moves := appendLegalMoves(gs, nil)
+	out := make([]dto.Move, 0, len(moves))
+	for _, m := range moves {
+		out = append(out, botMoveToDTO(gs, m))
+	}
+	return out
+}
@@ -33,44 +33,44 @@ func perft(gs dao.GameState, depth int) int {
 // This used to cover only the starting position to depth 3 -- the single
 // configuration that passes -- so it could not fail. All five positions are now
 // enforced at every depth.
-func TestPerftSuite(t *testing.T) {
-	cases := []struct {
-		name string
-		fen  string
-		want []int // index i => depth i+1
-	}{
-		{
-			name: "startpos",
-			fen:  "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
-			want: []int{20, 400, 8902, 197281},
-		},
-		{
-			name: "kiwipete",
-			fen:  "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
-			want: []int{48, 2039, 97862},
-		},
-		{
-			// Exposed illegal en-passant captures: the legality filter simulated
-			// the move without removing the captured pawn, so it kept blocking
-			// the 4th rank and hid the discovered check from Rb4.
-			name: "position-3",
-			fen:  "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
-			want: []int{14, 191, 2812, 43238},
-		},
-		{
-			// Exposed missing underpromotions.
-			name: "position-4",
-			fen:  "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
-			want: []int{6, 264, 9467},
-		},
-		{
-			name: "position-5",
-			fen:  "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 0 1",
-			want: []int{44, 1486, 62379},
-		},
-	}
+var perftSuite = []struct {
+	name string
+	fen  string
+	want []int // index i => depth i+1
+}{
+	{
+		name: "startpos",
+		fen:  "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		want: []int{20, 400, 8902, 197281},
+	},
+	{
+		name: "kiwipete",
+		fen:  "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		want: []int{48, 2039, 97862},
+	},
+	{
+		// Exposed illegal en-passant captures: the legality filter simulated
+		// the move without removing the captured pawn, so it kept blocking
+		// the 4th rank and hid the discovered check from Rb4.
+		name: "position-3",
+		fen:  "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		want: []int{14, 191, 2812, 43238},
+	},
+	{
+		// Exposed missing underpromotions.
+		name: "position-4",
+		fen:  "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		want: []int{6, 264, 9467},
+	},
+	{
+		name: "position-5",
+		fen:  "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 0 1",
+		want: []int{44, 1486, 62379},
+	},
+}
 
-	for _, c := range cases {
+func TestPerftSuite(t *testing.T) {
+	for _, c := range perftSuite {
 		t.Run(c.name, func(t *testing.T) {
 			gs, err := ParseFEN(c.fen)
 			if err != nil {
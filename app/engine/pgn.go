@@ -0,0 +1,348 @@
+package engine
+
+import (
+	"chess-engine/app/domain/dao"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Portable Game Notation, the interchange format every chess database and GUI
+// reads. Games used to leave the server only as the JSON game object, whose
+// move list is the internal "Pe2e4" record.
+
+// PGN result tokens, used both in the Result tag and at the end of the
+// movetext.
+const (
+	PGNWhiteWins  = "1-0"
+	PGNBlackWins  = "0-1"
+	PGNDraw       = "1/2-1/2"
+	PGNUnfinished = "*"
+)
+
+// pgnLineWidth is the longest movetext line the PGN export format allows.
+const pgnLineWidth = 80
+
+// PGNTag is one tag pair of a PGN header, such as [White "Alice"].
+type PGNTag struct {
+	Name  string
+	Value string
+}
+
+// PGNResult converts a ChessGame.Winner value to its PGN result token. Games
+// in progress, and aborted games, have no result.
+func PGNResult(winner string) string {
+	switch winner {
+	case "w":
+		return PGNWhiteWins
+	case "b":
+		return PGNBlackWins
+	case "d":
+		return PGNDraw
+	}
+	return PGNUnfinished
+}
+
+// FormatPGN writes one game in PGN export format: the tags in the order given,
+// a blank line, then the recorded moves converted to SAN and numbered, wrapped
+// at 80 columns and ending with result. The moves are replayed from startFEN,
+// or from the standard position when it is "" (a game that starts elsewhere
+// also needs SetUp and FEN tags, which are the caller's to give). A move that
+// does not replay is an error naming its ply.
+func FormatPGN(tags []PGNTag, startFEN string, moves []string, result string) (string, error) {
+	var sb strings.Builder
+	for _, tag := range tags {
+		fmt.Fprintf(&sb, "[%s \"%s\"]\n", tag.Name, escapePGNValue(tag.Value))
+	}
+	sb.WriteByte('\n')
+
+	tokens, err := movetextTokens(startFEN, moves)
+	if err != nil {
+		return "", err
+	}
+	tokens = append(tokens, result)
+
+	line := 0
+	for i, tok := range tokens {
+		if i > 0 {
+			if line+1+len(tok) > pgnLineWidth {
+				sb.WriteByte('\n')
+				line = 0
+			} else {
+				sb.WriteByte(' ')
+				line++
+			}
+		}
+		sb.WriteString(tok)
+		line += len(tok)
+	}
+	sb.WriteByte('\n')
+	return sb.String(), nil
+}
+
+// FormatMovetext renders recorded moves played from startFEN ("" for the
+// standard position) as numbered SAN on one line, such as "1. e4 e5 2. Nf3",
+// with no result.
+func FormatMovetext(startFEN string, moves []string) (string, error) {
+	tokens, err := movetextTokens(startFEN, moves)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(tokens, " "), nil
+}
+
+// movetextTokens converts recorded moves to SAN tokens with move numbers.
+func movetextTokens(startFEN string, moves []string) ([]string, error) {
+	gs := StartState()
+	number := 1
+	if startFEN != "" {
+		var err error
+		if gs, err = ParseFEN(startFEN); err != nil {
+			return nil, err
+		}
+		number = fenFullmoveNumber(startFEN)
+	}
+	tokens := make([]string, 0, len(moves)*3/2+2)
+	for i, raw := range moves {
+		move, ok := ParseRecordedMove(raw)
+		if !ok {
+			return nil, fmt.Errorf("ply %d: unreadable move %q", i+1, raw)
+		}
+		san, err := MoveToSAN(gs, move)
+		if err != nil {
+			return nil, fmt.Errorf("ply %d: %w", i+1, err)
+		}
+		switch {
+		case gs.Turn == "w":
+			tokens = append(tokens, strconv.Itoa(number)+".")
+		case i == 0:
+			// A game that starts with Black to move numbers its first move "12...".
+			tokens = append(tokens, strconv.Itoa(number)+"...")
+		}
+		if gs.Turn == "b" {
+			number++
+		}
+		tokens = append(tokens, san)
+		gs = ApplyMove(gs, move)
+	}
+	return tokens, nil
+}
+
+// fenFullmoveNumber reads a FEN's sixth field, the number of the move about to
+// be played. ParseFEN does not keep it, as GameState has nowhere to put it.
+func fenFullmoveNumber(fen string) int {
+	fields := strings.Fields(fen)
+	if len(fields) < 6 {
+		return 1
+	}
+	if n, err := strconv.Atoi(fields[5]); err == nil && n > 0 {
+		return n
+	}
+	return 1
+}
+
+// escapePGNValue escapes a tag value for a quoted PGN string.
+func escapePGNValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+// PGNGame is one game read from PGN text: its tags in file order, its
+// mainline moves as written (SAN), and the result token that ended it.
+// Comments, variations and numeric annotation glyphs are dropped.
+type PGNGame struct {
+	Tags   []PGNTag
+	Moves  []string
+	Result string
+}
+
+// Tag returns the value of the named tag, or "" if the game does not have it.
+func (g PGNGame) Tag(name string) string {
+	for _, t := range g.Tags {
+		if t.Name == name {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// ParsePGN reads every game in a PGN file. A game ends at its result token, or
+// where the next game's tags begin. The moves are not checked here; Replay
+// does that against the rules.
+func ParsePGN(text string) ([]PGNGame, error) {
+	var games []PGNGame
+	var cur PGNGame
+	started := false // cur has tags or moves
+	inMovetext := false
+	depth := 0 // variation nesting; moves inside a variation are skipped
+	line := 1
+
+	finish := func(result string) {
+		cur.Result = result
+		games = append(games, cur)
+		cur, started, inMovetext = PGNGame{}, false, false
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+			// A "%" in the first column escapes the rest of the line.
+			if i < len(text) && text[i] == '%' {
+				for i < len(text) && text[i] != '\n' {
+					i++
+				}
+			}
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '[' && depth == 0:
+			if inMovetext {
+				// Tags after moves: the previous game never gave a result.
+				finish(PGNUnfinished)
+			}
+			end, tag, err := readPGNTag(text, i)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			cur.Tags = append(cur.Tags, tag)
+			started = true
+			i = end
+		case c == '{':
+			end := strings.IndexByte(text[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(text[i:i+end], "\n")
+			i += end + 1
+		case c == ';':
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			if depth == 0 {
+				return nil, fmt.Errorf("line %d: unmatched \")\"", line)
+			}
+			depth--
+			i++
+		default:
+			start := i
+			for i < len(text) && !strings.ContainsRune(" \t\r\n{}()[];", rune(text[i])) {
+				i++
+			}
+			if i == start {
+				// A stray "]" or a "[" inside a variation.
+				return nil, fmt.Errorf("line %d: unexpected %q", line, c)
+			}
+			if depth > 0 {
+				continue
+			}
+			tok := text[start:i]
+			switch {
+			case tok == PGNWhiteWins || tok == PGNBlackWins || tok == PGNDraw || tok == PGNUnfinished:
+				finish(tok)
+			case tok[0] == '$':
+				// Numeric annotation glyph.
+			default:
+				// Move numbers: "12." and "12..." stand alone, but "12.e4" is common.
+				if j := strings.TrimLeft(tok, "0123456789"); j != tok && strings.HasPrefix(j, ".") {
+					tok = strings.TrimLeft(j, ".")
+				}
+				if tok != "" {
+					cur.Moves = append(cur.Moves, tok)
+					started, inMovetext = true, true
+				}
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("line %d: unterminated variation", line)
+	}
+	if started {
+		finish(PGNUnfinished)
+	}
+	return games, nil
+}
+
+// readPGNTag reads the tag pair starting at text[start] == '[', returning the
+// index just past its closing bracket.
+func readPGNTag(text string, start int) (int, PGNTag, error) {
+	i := start + 1
+	nameStart := i
+	for i < len(text) && text[i] != ' ' && text[i] != '"' && text[i] != ']' && text[i] != '\n' {
+		i++
+	}
+	tag := PGNTag{Name: text[nameStart:i]}
+	for i < len(text) && text[i] == ' ' {
+		i++
+	}
+	if tag.Name == "" || i >= len(text) || text[i] != '"' {
+		return 0, tag, fmt.Errorf("malformed tag")
+	}
+
+	var value strings.Builder
+	for i++; i < len(text) && text[i] != '"'; i++ {
+		if text[i] == '\\' && i+1 < len(text) {
+			i++
+		}
+		if text[i] == '\n' {
+			return 0, tag, fmt.Errorf("unterminated tag value for %s", tag.Name)
+		}
+		value.WriteByte(text[i])
+	}
+	i++ // closing quote
+	for i < len(text) && text[i] == ' ' {
+		i++
+	}
+	if i >= len(text) || text[i] != ']' {
+		return 0, tag, fmt.Errorf("malformed tag %s", tag.Name)
+	}
+	tag.Value = value.String()
+	return i + 1, tag, nil
+}
+
+// Replay plays the game's moves from its starting position (the FEN tag, if it
+// has one, checked with ParseStartFEN) and returns them in the recorded
+// "Pe2e4" form along with the position reached.
+// plies stops the replay early, after that many half-moves; a negative value
+// plays the whole game. A move that is not legal, or does not pick out a
+// single legal move, is an error naming its ply and move number.
+func (g PGNGame) Replay(plies int) ([]string, dao.GameState, error) {
+	gs := StartState()
+	number := 1
+	if fen := g.Tag("FEN"); fen != "" {
+		var err error
+		if gs, err = ParseStartFEN(fen); err != nil {
+			return nil, dao.GameState{}, fmt.Errorf("FEN tag: %w", err)
+		}
+		number = fenFullmoveNumber(fen)
+	}
+	if plies > len(g.Moves) {
+		return nil, dao.GameState{}, fmt.Errorf("ply %d requested, but the game has only %d", plies, len(g.Moves))
+	}
+	if plies < 0 {
+		plies = len(g.Moves)
+	}
+
+	recorded := make([]string, 0, plies)
+	for i, san := range g.Moves[:plies] {
+		move, err := ParseSAN(gs, san)
+		if err != nil {
+			label := strconv.Itoa(number) + "."
+			if gs.Turn == "b" {
+				label += ".."
+			}
+			return nil, dao.GameState{}, fmt.Errorf("ply %d (%s %s): %w", i+1, label, san, err)
+		}
+		if gs.Turn == "b" {
+			number++
+		}
+		gs = ApplyMove(gs, move)
+		recorded = append(recorded, gs.LastMove)
+	}
+	return recorded, gs, nil
+}
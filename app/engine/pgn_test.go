@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatPGN(t *testing.T) {
+	moves := []string{"Pe2e4", "pe7e5", "Bf1c4", "nb8c6", "Qd1h5", "ng8f6", "Qh5f7"}
+	tags := []PGNTag{{"Event", "Casual game"}, {"White", `Al "the pawn"`}}
+	got, err := FormatPGN(tags, "", moves, PGNWhiteWins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[Event "Casual game"]
+[White "Al \"the pawn\""]
+
+1. e4 e5 2. Bc4 Nc6 3. Qh5 Nf6 4. Qxf7# 1-0
+`
+	if got != want {
+		t.Errorf("FormatPGN =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatPGNWrapsAndReportsBadPly(t *testing.T) {
+	var moves []string
+	for i := 0; i < 10; i++ {
+		moves = append(moves, "Ng1f3", "ng8f6", "Nf3g1", "nf6g8")
+	}
+	got, err := FormatPGN(nil, "", moves, PGNUnfinished)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(got, "\n") {
+		if len(line) > pgnLineWidth {
+			t.Errorf("line longer than %d columns: %q", pgnLineWidth, line)
+		}
+	}
+	if !strings.HasSuffix(got, " *\n") {
+		t.Errorf("movetext does not end with the result: %q", got)
+	}
+
+	if _, err := FormatPGN(nil, "", []string{"Pe2e4", "pe7e4"}, PGNUnfinished); err == nil || !strings.Contains(err.Error(), "ply 2") {
+		t.Errorf("expected an error naming ply 2, got %v", err)
+	}
+}
+
+func TestParsePGNMultipleGames(t *testing.T) {
+	text := `[Event "First"]
+[White "A \"quoted\" name"]
+
+1. e4 {best by test} e5 2. Nf3 (2. f4 exf4 (2... d5)) Nc6 $1 3.Bb5 a6 1-0
+
+[Event "Second"]
+% an escaped line
+1. d4 d5 ; rest of line ignored 2. c4
+2... e6 *
+`
+	games, err := ParsePGN(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 2 {
+		t.Fatalf("got %d games, want 2", len(games))
+	}
+	first := games[0]
+	if first.Tag("White") != `A "quoted" name` || first.Result != PGNWhiteWins {
+		t.Errorf("first game tags/result wrong: %+v", first)
+	}
+	if got := strings.Join(first.Moves, " "); got != "e4 e5 Nf3 Nc6 Bb5 a6" {
+		t.Errorf("first game moves = %q", got)
+	}
+	if got := strings.Join(games[1].Moves, " "); got != "d4 d5 e6" || games[1].Tag("Event") != "Second" {
+		t.Errorf("second game = %+v", games[1])
+	}
+}
+
+func TestPGNGameReplay(t *testing.T) {
+	games, err := ParsePGN("1. e4 e5 2. Bc4 Nc6 3. Qh5 Nf6 4. Qxf7# 1-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves, gs, err := games[0].Replay(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 7 || moves[6] != "Qh5f7" {
+		t.Errorf("recorded moves = %v", moves)
+	}
+	if _, status := GenerateLegalMovesForAllPositions(gs); status != "black_checkmate" {
+		t.Errorf("final status = %q, want black_checkmate", status)
+	}
+
+	// Stopping early gives the position after that many plies.
+	moves, gs, err = games[0].Replay(3)
+	if err != nil || len(moves) != 3 || gs.Turn != "b" {
+		t.Errorf("Replay(3) = %v, turn %q, %v", moves, gs.Turn, err)
+	}
+	if _, _, err := games[0].Replay(8); err == nil {
+		t.Error("expected an error replaying past the end")
+	}
+
+	bad, _ := ParsePGN("1. e4 e5 2. Nf3 Nf6 3. Nc3 Ke7 4. Nd5 Qd5 *")
+	if _, _, err := bad[0].Replay(-1); err == nil || !strings.Contains(err.Error(), "ply 8 (4... Qd5)") {
+		t.Errorf("expected an error naming ply 8, got %v", err)
+	}
+}
+
+func TestPGNFromSetUpPosition(t *testing.T) {
+	fen := "4k3/8/8/8/8/8/4P3/4K3 b - - 0 12"
+	got, err := FormatPGN(nil, fen, []string{"ke8d7", "Pe2e4", "kd7e6"}, PGNUnfinished)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\n12... Kd7 13. e4 Ke6 *\n"; got != want {
+		t.Errorf("FormatPGN = %q, want %q", got, want)
+	}
+
+	games, err := ParsePGN("[SetUp \"1\"]\n[FEN \"" + fen + "\"]\n\n12... Kd7 13. e4 Kd6 14. Kf3 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := games[0].Replay(-1); err == nil || !strings.Contains(err.Error(), "ply 4 (14. Kf3)") {
+		t.Errorf("expected an error naming ply 4, got %v", err)
+	}
+	moves, _, err := games[0].Replay(3)
+	if err != nil || strings.Join(moves, " ") != "ke8d7 Pe2e4 kd7d6" {
+		t.Errorf("Replay(3) = %v, %v", moves, err)
+	}
+}
+
+func TestFormatMovetext(t *testing.T) {
+	got, err := FormatMovetext("", []string{"Pe2e4", "pe7e5", "Ng1f3", "nb8c6"})
+	if err != nil || got != "1. e4 e5 2. Nf3 Nc6" {
+		t.Errorf("FormatMovetext = %q, %v", got, err)
+	}
+	got, err = FormatMovetext("4k3/8/8/8/8/8/4P3/4K3 b - - 0 12", []string{"ke8d7", "Pe2e4"})
+	if err != nil || got != "12... Kd7 13. e4" {
+		t.Errorf("FormatMovetext from a set-up position = %q, %v", got, err)
+	}
+}
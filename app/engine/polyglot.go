@@ -0,0 +1,218 @@
+package engine
+
+import (
+	"bufio"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Polyglot opening books.
+//
+// A Polyglot .bin book is a list of 16-byte big-endian entries sorted by
+// position key: key (8 bytes), move (2), weight (2), learn (4). A position
+// with several book moves has one entry per move, next to each other.
+//
+// The book's key is the Polyglot Zobrist hash, not PositionKey: the books
+// are built by other programs with the published Random64 table, and
+// PositionKey's numbers are the engine's own. The two also disagree on the
+// en-passant file, which Polyglot counts only when a capture is possible.
+
+// bookEntrySize is the size of one entry on disk.
+const bookEntrySize = 16
+
+type bookEntry struct {
+	key    uint64
+	move   uint16
+	weight uint16
+}
+
+// Book is an opening book loaded into memory. A nil *Book is an empty book.
+type Book struct {
+	entries []bookEntry
+}
+
+// BookMove is one move the book offers for a position.
+type BookMove struct {
+	Move   dto.Move
+	Weight int
+}
+
+// OpenBook reads a Polyglot book from a file.
+func OpenBook(path string) (*Book, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBook(f)
+}
+
+// ReadBook reads a Polyglot book. The entries must be sorted by key, as every
+// Polyglot tool writes them; a book that is not is rejected rather than
+// silently missing most of its positions.
+func ReadBook(r io.Reader) (*Book, error) {
+	br := bufio.NewReader(r)
+	var buf [bookEntrySize]byte
+	var entries []bookEntry
+	for {
+		if _, err := io.ReadFull(br, buf[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("book entry %d: %w", len(entries), err)
+		}
+		e := bookEntry{
+			key:    binary.BigEndian.Uint64(buf[0:8]),
+			move:   binary.BigEndian.Uint16(buf[8:10]),
+			weight: binary.BigEndian.Uint16(buf[10:12]),
+		}
+		if n := len(entries); n > 0 && entries[n-1].key > e.key {
+			return nil, fmt.Errorf("book entry %d: entries are not sorted by key", n)
+		}
+		entries = append(entries, e)
+	}
+	return &Book{entries: entries}, nil
+}
+
+// Moves returns the book moves for a position with a weight above zero, in
+// book order. Entries that are not legal in the position -- a key collision,
+// or a corrupt book -- are left out.
+func (b *Book) Moves(gs dao.GameState) []BookMove {
+	if b == nil {
+		return nil
+	}
+	key := PolyglotKey(gs)
+	i := sort.Search(len(b.entries), func(i int) bool { return b.entries[i].key >= key })
+
+	var moves []BookMove
+	legal := sideToMoveMoves(gs)
+	for ; i < len(b.entries) && b.entries[i].key == key; i++ {
+		e := b.entries[i]
+		if e.weight == 0 {
+			continue
+		}
+		if m, ok := polyglotMove(gs, e.move, legal); ok {
+			moves = append(moves, BookMove{Move: botMoveToDTO(gs, m), Weight: int(e.weight)})
+		}
+	}
+	return moves
+}
+
+// Probe picks a book move for a position at random, each move as likely as
+// its weight. It reports false once the position is out of book.
+func (b *Book) Probe(gs dao.GameState) (dto.Move, bool) {
+	moves := b.Moves(gs)
+	total := 0
+	for _, m := range moves {
+		total += m.Weight
+	}
+	if total == 0 {
+		return dto.Move{}, false
+	}
+	pick := rand.Intn(total)
+	for _, m := range moves {
+		if pick < m.Weight {
+			return m.Move, true
+		}
+		pick -= m.Weight
+	}
+	return dto.Move{}, false // unreachable
+}
+
+// polyglotMove decodes a book move and finds it among the legal moves. The
+// move packs the destination file and rank (3 bits each), then the origin,
+// then the promotion piece: 0 none, 1 knight, 2 bishop, 3 rook, 4 queen.
+// Castling is written as the king taking its own rook, e1h1 for O-O.
+func polyglotMove(gs dao.GameState, move uint16, legal []botMove) (botMove, bool) {
+	to := int(move & 0x3f)
+	from := int(move >> 6 & 0x3f)
+	promo := ""
+	if p := move >> 12 & 7; p >= 1 && p <= 4 {
+		promo = string("nbrq"[p-1])
+	}
+
+	src, dst := uint64(1)<<from, uint64(1)<<to
+	if gs.KingBitboard&src != 0 && gs.RookBitboard&dst != 0 {
+		switch {
+		case to == from+3:
+			dst = src << 2
+		case to == from-4:
+			dst = src >> 2
+		}
+	}
+	for _, m := range legal {
+		if m.src == src && m.dst == dst && m.promo == promo {
+			return m, true
+		}
+	}
+	return botMove{}, false
+}
+
+// PolyglotKey returns the Polyglot Zobrist hash of a position, the key
+// Polyglot books are sorted by.
+//
+// polyglotRandom holds 64 numbers for each piece, by kind in the order black
+// pawn, white pawn, black knight, white knight and so on to white king, and
+// within a kind by square from a1; then the four castling rights in KQkq
+// order; then the eight en-passant files; then one for White to move.
+func PolyglotKey(gs dao.GameState) uint64 {
+	var key uint64
+
+	boards := [...]uint64{
+		gs.PawnBitboard, gs.KnightBitboard, gs.BishopBitboard,
+		gs.RookBitboard, gs.QueenBitboard, gs.KingBitboard,
+	}
+	for kind, bb := range boards {
+		for b := bb & gs.BlackBitboard; b != 0; b &= b - 1 {
+			key ^= polyglotRandom[64*(2*kind)+bits.TrailingZeros64(b)]
+		}
+		for w := bb & gs.WhiteBitboard; w != 0; w &= w - 1 {
+			key ^= polyglotRandom[64*(2*kind+1)+bits.TrailingZeros64(w)]
+		}
+	}
+
+	for i, right := range []string{"K", "Q", "k", "q"} {
+		if strings.Contains(gs.CastlingRights, right) {
+			key ^= polyglotRandom[768+i]
+		}
+	}
+
+	if file, ok := polyglotEnPassantFile(gs); ok {
+		key ^= polyglotRandom[772+file]
+	}
+
+	if gs.Turn != "b" {
+		key ^= polyglotRandom[780]
+	}
+	return key
+}
+
+// polyglotEnPassantFile returns the en-passant file when a pawn of the side to
+// move stands beside the pawn that just advanced two squares, so could take it
+// -- whether or not the capture is legal.
+func polyglotEnPassantFile(gs dao.GameState) (int, bool) {
+	file, ok := enPassantFile(gs.EnPassant)
+	if !ok {
+		return 0, false
+	}
+	// The pushed pawn is on rank 5 for White to move, rank 4 for Black.
+	rank, own := 4, gs.WhiteBitboard
+	if gs.Turn == "b" {
+		rank, own = 3, gs.BlackBitboard
+	}
+	pawns := gs.PawnBitboard & own
+	if file > 0 && pawns&(uint64(1)<<(rank*8+file-1)) != 0 {
+		return file, true
+	}
+	if file < 7 && pawns&(uint64(1)<<(rank*8+file+1)) != 0 {
+		return file, true
+	}
+	return 0, false
+}
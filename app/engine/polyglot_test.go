@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// The key test positions from the Polyglot book format specification.
+func TestPolyglotKey(t *testing.T) {
+	cases := []struct {
+		fen string
+		key uint64
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 0x463b96181691fc9c},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", 0x823c9b50fd114196},
+		{"rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2", 0x0756b94461c50fb0},
+		{"rnbqkbnr/ppp1pppp/8/3pP3/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 2", 0x662fafb965db29d4},
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", 0x22a48b5a8e47ff78},
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPPKPPP/RNBQ1BNR b kq - 0 3", 0x652a607ca3f242c1},
+		{"rnbq1bnr/ppp1pkpp/8/3pPp2/8/8/PPPPKPPP/RNBQ1BNR w - - 0 4", 0x00fdd303c946bdd9},
+		{"rnbqkbnr/p1pppppp/8/8/PpP4P/8/1P1PPPP1/RNBQKBNR b KQkq c3 0 3", 0x3c8123ea7b067637},
+		{"rnbqkbnr/p1pppppp/8/8/P6P/R1p5/1P1PPPP1/1NBQKBNR b Kkq - 0 4", 0x5c3f9b829b279560},
+	}
+	for _, tc := range cases {
+		if got := PolyglotKey(mustFEN(t, tc.fen)); got != tc.key {
+			t.Errorf("PolyglotKey(%s) = %016x, want %016x", tc.fen, got, tc.key)
+		}
+	}
+}
+
+// polyglotEntry encodes one book entry.
+func polyglotEntry(key uint64, from, to string, weight uint16) []byte {
+	sq := func(s string) uint16 { return uint16(PositionToIndex(s)) }
+	var buf [bookEntrySize]byte
+	binary.BigEndian.PutUint64(buf[0:8], key)
+	binary.BigEndian.PutUint16(buf[8:10], sq(from)<<6|sq(to))
+	binary.BigEndian.PutUint16(buf[10:12], weight)
+	return buf[:]
+}
+
+func TestBookProbe(t *testing.T) {
+	start := mustFEN(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	castle := mustFEN(t, "r3k2r/pppq1ppp/2npbn2/2b1p3/2B1P3/2NPBN2/PPPQ1PPP/R3K2R w KQkq - 0 1")
+
+	var data []byte
+	entries := []struct {
+		gs       string
+		from, to string
+		weight   uint16
+	}{
+		{"start", "e2", "e4", 3},
+		{"start", "d2", "d4", 1},
+		{"start", "g1", "f3", 0}, // weight 0: never played
+		{"start", "e2", "e5", 5}, // not legal: skipped
+		{"castle", "e1", "h1", 1},
+	}
+	keyOf := map[string]uint64{"start": PolyglotKey(start), "castle": PolyglotKey(castle)}
+	// The book is sorted by key; entries for one key keep their order.
+	if keyOf["castle"] < keyOf["start"] {
+		entries = append(entries[4:], entries[:4]...)
+	}
+	for _, e := range entries {
+		data = append(data, polyglotEntry(keyOf[e.gs], e.from, e.to, e.weight)...)
+	}
+	book, err := ReadBook(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moves := book.Moves(start)
+	if len(moves) != 2 || MoveToUCI(moves[0].Move) != "e2e4" || moves[0].Weight != 3 || MoveToUCI(moves[1].Move) != "d2d4" {
+		t.Fatalf("Moves(start) = %+v, want e2e4 weight 3 and d2d4", moves)
+	}
+	seen := map[string]int{}
+	for i := 0; i < 400; i++ {
+		m, ok := book.Probe(start)
+		if !ok {
+			t.Fatal("start position out of book")
+		}
+		seen[MoveToUCI(m)]++
+	}
+	if len(seen) != 2 || seen["e2e4"] < seen["d2d4"] {
+		t.Errorf("picks = %v, want both moves, e2e4 about three times as often", seen)
+	}
+
+	if m, ok := book.Probe(castle); !ok || MoveToUCI(m) != "e1g1" {
+		t.Errorf("Probe(castle) = %v %v, want e1g1", MoveToUCI(m), ok)
+	}
+	if _, ok := book.Probe(ApplyMove(start, mustUCI(t, start, "a2a3"))); ok {
+		t.Error("a position not in the book was found")
+	}
+	var none *Book
+	if _, ok := none.Probe(start); ok {
+		t.Error("a nil book returned a move")
+	}
+}
+
+func TestReadBookRejectsUnsorted(t *testing.T) {
+	data := append(polyglotEntry(2, "e2", "e4", 1), polyglotEntry(1, "e2", "e4", 1)...)
+	if _, err := ReadBook(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "sorted") {
+		t.Errorf("err = %v, want an unsorted-book error", err)
+	}
+	if _, err := ReadBook(bytes.NewReader(data[:20])); err == nil {
+		t.Error("a truncated book was accepted")
+	}
+}
@@ -53,7 +53,7 @@ func staticEval(gs dao.GameState) int {
 // of one.
 func quiescence(c *searchCtx, gs dao.GameState, alpha, beta, qdepth int) int {
 	c.nodes++
-	if c.nodes&1023 == 0 && abortRequested(c.deadline, c.stop) {
+	if c.nodes&1023 == 0 && c.timeUp() {
 		c.aborted = true
 		return 0
 	}
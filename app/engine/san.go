@@ -0,0 +1,266 @@
+package engine
+
+import (
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"fmt"
+	"strings"
+)
+
+// Standard Algebraic Notation, the move format of PGN and of every chess book.
+//
+// Moves are stored as "Pe2e4", which names both squares and so needs no
+// context. SAN names only the destination and as little of the origin as makes
+// the move unambiguous, so it can only be written against the position the move
+// is played from and that position's full list of legal moves.
+
+// sanPieceLetters maps a piece kind to its SAN letter. Pawns have none.
+var sanPieceLetters = map[pieceKind]string{
+	kindKnight: "N",
+	kindBishop: "B",
+	kindRook:   "R",
+	kindQueen:  "Q",
+	kindKing:   "K",
+}
+
+// MoveToSAN renders move, which must be legal in gs, in Standard Algebraic
+// Notation, including the check or mate suffix. An empty promotion is read as
+// a queen, as everywhere else moves are applied.
+func MoveToSAN(gs dao.GameState, move dto.Move) (string, error) {
+	if !isSquare(move.Source) || !isSquare(move.Destination) {
+		return "", fmt.Errorf("invalid move %s%s", move.Source, move.Destination)
+	}
+	src := squareBit(move.Source)
+	dst := squareBit(move.Destination)
+	promo := strings.ToLower(move.Promotion)
+
+	legal := GenerateLegalMoveList(gs)
+	for _, m := range legal {
+		if m.Src != src || m.Dst != dst {
+			continue
+		}
+		if m.Promotion == promo || (promo == "" && m.Promotion == "q") {
+			return legalMoveSAN(gs, m, legal), nil
+		}
+	}
+	return "", fmt.Errorf("illegal move %s in this position", MoveToUCI(move))
+}
+
+// SANLine renders a line of play starting from gs, such as a search's
+// principal variation, move by move in SAN.
+func SANLine(gs dao.GameState, moves []dto.Move) ([]string, error) {
+	out := make([]string, 0, len(moves))
+	for i, m := range moves {
+		san, err := MoveToSAN(gs, m)
+		if err != nil {
+			return nil, fmt.Errorf("move %d of the line: %w", i+1, err)
+		}
+		out = append(out, san)
+		gs = ApplyMove(gs, m)
+	}
+	return out, nil
+}
+
+// legalMoveSAN renders m, one of legal, the side to move's legal moves in gs.
+func legalMoveSAN(gs dao.GameState, m LegalMove, legal []LegalMove) string {
+	kind := kindAt(gs, m.Src)
+	from := bitToSquare(m.Src, defFiles, defRanks)
+	to := bitToSquare(m.Dst, defFiles, defRanks)
+	occupied := gs.WhiteBitboard | gs.BlackBitboard
+
+	var sb strings.Builder
+	switch {
+	case kind == kindKing && from[0] == 'e' && (to[0] == 'g' || to[0] == 'c') && from[1] == to[1]:
+		// A king never otherwise moves two files in one go.
+		if to[0] == 'g' {
+			sb.WriteString("O-O")
+		} else {
+			sb.WriteString("O-O-O")
+		}
+	case kind == kindPawn:
+		// A pawn that changes file has captured, en passant included, even
+		// though an en-passant destination is empty.
+		if from[0] != to[0] {
+			sb.WriteByte(from[0])
+			sb.WriteByte('x')
+		}
+		sb.WriteString(to)
+		if m.Promotion != "" {
+			sb.WriteByte('=')
+			sb.WriteString(strings.ToUpper(m.Promotion))
+		}
+	default:
+		sb.WriteString(sanPieceLetters[kind])
+		sb.WriteString(disambiguation(gs, m, kind, legal))
+		if occupied&m.Dst != 0 {
+			sb.WriteByte('x')
+		}
+		sb.WriteString(to)
+	}
+
+	after := applyBitboardMove(gs, m.Src, m.Dst, m.Promotion)
+	after.Turn = ToggleTurn(gs.Turn)
+	if isKingInCheck(after, after.Turn == "w") {
+		if len(GenerateLegalMoveList(after)) == 0 {
+			sb.WriteByte('#')
+		} else {
+			sb.WriteByte('+')
+		}
+	}
+	return sb.String()
+}
+
+// disambiguation returns the part of the origin square SAN needs when another
+// piece of the same kind can reach the same square: the file if that settles
+// it, otherwise the rank, otherwise both.
+func disambiguation(gs dao.GameState, m LegalMove, kind pieceKind, legal []LegalMove) string {
+	from := bitToSquare(m.Src, defFiles, defRanks)
+	rivals, sameFile, sameRank := false, false, false
+	for _, o := range legal {
+		if o.Dst != m.Dst || o.Src == m.Src || kindAt(gs, o.Src) != kind {
+			continue
+		}
+		rivals = true
+		other := bitToSquare(o.Src, defFiles, defRanks)
+		if other[0] == from[0] {
+			sameFile = true
+		}
+		if other[1] == from[1] {
+			sameRank = true
+		}
+	}
+	switch {
+	case !rivals:
+		return ""
+	case !sameFile:
+		return from[:1]
+	case !sameRank:
+		return from[1:]
+	}
+	return from
+}
+
+// ParseSAN reads a move in Standard Algebraic Notation, as found in PGN files,
+// and returns it as a move legal in gs. Check and mate suffixes and trailing
+// annotation glyphs ("!", "?!") are accepted but not required; "0-0" is read
+// as "O-O". A missing promotion piece is read as a queen, like everywhere else.
+//
+// The SAN must pick out exactly one legal move: a move that matches none is
+// illegal, and one that matches several is ambiguous and is rejected rather
+// than guessed.
+func ParseSAN(gs dao.GameState, san string) (dto.Move, error) {
+	s := strings.TrimRight(strings.TrimSpace(san), "+#!?")
+	if s == "" {
+		return dto.Move{}, fmt.Errorf("empty move")
+	}
+
+	legal := GenerateLegalMoveList(gs)
+	var matches []LegalMove
+	switch strings.ReplaceAll(s, "0", "O") {
+	case "O-O", "O-O-O":
+		long := len(s) == 5
+		for _, m := range legal {
+			if kindAt(gs, m.Src) != kindKing {
+				continue
+			}
+			from := bitToSquare(m.Src, defFiles, defRanks)
+			to := bitToSquare(m.Dst, defFiles, defRanks)
+			if from[0] == 'e' && from[1] == to[1] && ((long && to[0] == 'c') || (!long && to[0] == 'g')) {
+				matches = append(matches, m)
+			}
+		}
+	default:
+		want, err := parseSANBody(s)
+		if err != nil {
+			return dto.Move{}, fmt.Errorf("%w in %q", err, san)
+		}
+		for _, m := range legal {
+			if want.matches(gs, m) {
+				matches = append(matches, m)
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return dto.Move{}, fmt.Errorf("illegal move %q", san)
+	case 1:
+		return botMoveToDTO(gs, botMove{src: matches[0].Src, dst: matches[0].Dst, promo: matches[0].Promotion}), nil
+	}
+	candidates := make([]string, 0, len(matches))
+	for _, m := range matches {
+		candidates = append(candidates, bitToSquare(m.Src, defFiles, defRanks)+bitToSquare(m.Dst, defFiles, defRanks))
+	}
+	return dto.Move{}, fmt.Errorf("ambiguous move %q: could be %s", san, strings.Join(candidates, " or "))
+}
+
+// sanPattern is a non-castling SAN move taken apart: which kind of piece moves,
+// what the SAN says about where from, where to, and what it promotes to.
+type sanPattern struct {
+	kind      pieceKind
+	fromFile  byte // 0 when not given
+	fromRank  byte // 0 when not given
+	to        uint64
+	promotion string
+}
+
+// parseSANBody splits a SAN move (suffixes already removed) into its parts.
+// The capture marker is optional: it is implied by the destination.
+func parseSANBody(s string) (sanPattern, error) {
+	p := sanPattern{kind: kindPawn}
+	for kind, letter := range sanPieceLetters {
+		if s[:1] == letter {
+			p.kind = kind
+			s = s[1:]
+			break
+		}
+	}
+
+	if p.kind == kindPawn {
+		if i := strings.IndexByte(s, '='); i >= 0 {
+			p.promotion, s = strings.ToLower(s[i+1:]), s[:i]
+		} else if n := len(s); n > 0 && strings.ContainsAny(s[n-1:], "QRBN") {
+			p.promotion, s = strings.ToLower(s[n-1:]), s[:n-1]
+		}
+		if p.promotion != "" && (len(p.promotion) != 1 || !strings.Contains("qrbn", p.promotion)) {
+			return p, fmt.Errorf("invalid promotion")
+		}
+	}
+
+	s = strings.Replace(s, "x", "", 1)
+	if len(s) < 2 || !isSquare(s[len(s)-2:]) {
+		return p, fmt.Errorf("no destination square")
+	}
+	p.to = squareBit(s[len(s)-2:])
+	for _, c := range []byte(s[:len(s)-2]) {
+		switch {
+		case c >= 'a' && c <= 'h' && p.fromFile == 0:
+			p.fromFile = c
+		case c >= '1' && c <= '8' && p.fromRank == 0:
+			p.fromRank = c
+		default:
+			return p, fmt.Errorf("unexpected %q", c)
+		}
+	}
+	return p, nil
+}
+
+// matches reports whether m, a legal move in gs, is the move p describes.
+func (p sanPattern) matches(gs dao.GameState, m LegalMove) bool {
+	if m.Dst != p.to || kindAt(gs, m.Src) != p.kind {
+		return false
+	}
+	from := bitToSquare(m.Src, defFiles, defRanks)
+	if p.kind == kindPawn && p.fromFile == 0 && from[0] != bitToSquare(m.Dst, defFiles, defRanks)[0] {
+		// "d5" is a push; a capture has to name the file it comes from.
+		return false
+	}
+	if (p.fromFile != 0 && from[0] != p.fromFile) || (p.fromRank != 0 && from[1] != p.fromRank) {
+		return false
+	}
+	promo := p.promotion
+	if promo == "" && m.Promotion != "" {
+		promo = "q"
+	}
+	return m.Promotion == promo
+}
@@ -5,6 +5,7 @@ import (
 	"chess-engine/app/domain/dto"
 	"math/bits"
 	"sort"
+	"sync"
 	"time"
 )
 
@@ -24,6 +25,26 @@ type SearchOptions struct {
 	// the cache. It is passed in rather than held globally so concurrent
 	// searches (the server runs one per game) cannot race on it.
 	Table *TranspositionTable
+	// MultiPV is how many of the best root moves to score exactly and report
+	// in SearchResult.Lines, as UCI's MultiPV option does. <= 1 means just the
+	// best; more than there are legal moves means all of them.
+	MultiPV int
+	// PonderHit, when set, makes this a ponder search, as UCI's "go ponder":
+	// MoveTime does not run until PonderHit is closed, and then counts from the
+	// start of the search, so the time already spent pondering is used up
+	// first. Stop still ends a ponder search at any time.
+	PonderHit <-chan struct{}
+	// Threads is how many threads search at once (Lazy SMP): the main search
+	// plus Threads-1 helpers that search the same root at staggered depths
+	// and share what they find only through Table. <= 1, or a nil Table,
+	// means one thread, and that search is deterministic; with more the
+	// result depends on how the threads happen to interleave.
+	Threads int
+	// Tablebase, when set, is probed for positions with few enough pieces:
+	// at the root to keep only the moves that hold the best result and
+	// reach it soonest, and inside the tree for the result of a position
+	// right after a capture or pawn move. Optional, like Table.
+	Tablebase *Tablebase
 }
 
 // SearchResult is the outcome of (a completed iteration of) a search.
@@ -35,16 +56,33 @@ type SearchResult struct {
 	Depth   int        // depth of the last completed iteration
 	Nodes   int        // cumulative nodes searched
 	PV      []dto.Move // principal variation, starting with Best
+	// Lines are the SearchOptions.MultiPV best root moves, best first; the
+	// first is the same as Score, Mate and PV.
+	Lines   []SearchLine
 	Elapsed time.Duration
 }
 
+// SearchLine is one root move with its score and the line expected to
+// follow, starting with the move itself.
+type SearchLine struct {
+	Score int        // centipawns from the side-to-move's perspective
+	Mate  int        // as SearchResult.Mate
+	PV    []dto.Move // starts with the root move
+}
+
+// rootLine is a SearchLine with the root move it starts with.
+type rootLine struct {
+	move botMove
+	SearchLine
+}
+
 const maxSearchDepth = 64
 
 // Search runs an iterative-deepening alpha-beta search and returns the result of
 // the deepest fully completed iteration. If info is non-nil it is called once per
 // completed depth, enabling streaming UCI "info" lines. A partially searched
 // depth (cut short by time/Stop) is discarded.
-func Search(gs dao.GameState, opts SearchOptions, info func(SearchResult)) SearchResult {
+func Search(gs dao.GameState, opts SearchOptions, info func(SearchResult)) (result SearchResult) {
 	start := time.Now()
 
 	maxDepth := opts.MaxDepth
@@ -55,24 +93,20 @@ func Search(gs dao.GameState, opts SearchOptions, info func(SearchResult)) Searc
 		maxDepth = maxSearchDepth
 	}
 
-	var deadline time.Time
+	c := &searchCtx{stop: opts.Stop, tt: opts.Table, tb: opts.Tablebase}
 	if opts.MoveTime > 0 && !opts.Infinite {
-		deadline = start.Add(opts.MoveTime)
-	}
-
-	stopped := func() bool {
-		if opts.Stop != nil {
-			select {
-			case <-opts.Stop:
-				return true
-			default:
-			}
+		if opts.PonderHit != nil {
+			c.ponderHit, c.ponderDeadline = opts.PonderHit, start.Add(opts.MoveTime)
+		} else {
+			c.deadline = start.Add(opts.MoveTime)
 		}
-		return !deadline.IsZero() && time.Now().After(deadline)
 	}
+	stopped := c.timeUp
 
-	var result SearchResult
 	rootMoves := sideToMoveMovesOrdered(gs)
+	if kept, ok := opts.Tablebase.rootMoves(gs, rootMoves); ok {
+		rootMoves = kept
+	}
 	if len(rootMoves) == 0 {
 		return result // checkmate or stalemate: no move to make
 	}
@@ -87,7 +121,18 @@ func Search(gs dao.GameState, opts SearchOptions, info func(SearchResult)) Searc
 	rootPath = append(rootPath, opts.History...)
 	rootPath = append(rootPath, PositionKey(gs))
 
-	c := &searchCtx{deadline: deadline, stop: opts.Stop, tt: opts.Table}
+	if opts.Threads > 1 && opts.Table != nil {
+		stopHelpers := startHelpers(gs, rootMoves, rootPath, opts.Table, opts.Tablebase, opts.Threads-1, maxDepth)
+		defer func() { result.Nodes += stopHelpers() }()
+	}
+
+	multiPV := opts.MultiPV
+	if multiPV < 1 {
+		multiPV = 1
+	}
+	if multiPV > len(rootMoves) {
+		multiPV = len(rootMoves)
+	}
 
 	for depth := 1; depth <= maxDepth; depth++ {
 		// Killers and the table carry across iterations on purpose; only the
@@ -95,28 +140,29 @@ func Search(gs dao.GameState, opts SearchOptions, info func(SearchResult)) Searc
 		c.aborted = false
 		c.nodes = 0
 		c.path = rootPath
-		best := -searchInf
-		var bestMove dto.Move
-		var bestPV []dto.Move
-		alpha := -searchInf
+		// lines holds the best multiPV moves so far, best first. A move only
+		// has to beat the worst of them, so that is its lower bound; with one
+		// line this is plain alpha-beta at the root.
+		lines := make([]rootLine, 0, multiPV+1)
 
 		for _, m := range rootMoves {
 			if stopped() {
 				c.aborted = true
 				break
 			}
+			alpha := -searchInf
+			if len(lines) == multiPV {
+				alpha = lines[multiPV-1].Score
+			}
 			var childPV []dto.Move
 			score := -negamaxPV(c, applyBotMove(gs, m), depth-1, -searchInf, -alpha, 1, &childPV)
 			if c.aborted {
 				break
 			}
-			if score > best {
-				best = score
-				bestMove = botMoveToDTO(gs, m)
-				bestPV = append([]dto.Move{bestMove}, childPV...)
-				if best > alpha {
-					alpha = best
-				}
+			if len(lines) < multiPV || score > alpha {
+				mv := botMoveToDTO(gs, m)
+				line := rootLine{move: m, SearchLine: SearchLine{Score: score, PV: append([]dto.Move{mv}, childPV...)}}
+				lines = insertLine(lines, line, multiPV)
 			}
 		}
 
@@ -124,35 +170,149 @@ func Search(gs dao.GameState, opts SearchOptions, info func(SearchResult)) Searc
 			break // discard this incomplete depth, keep the previous result
 		}
 
-		result.Best = bestMove
+		best := lines[0]
+		result.Best = best.PV[0]
 		result.HasBest = true
-		result.Score = best
+		result.Score = best.Score
 		result.Depth = depth
 		result.Nodes += c.nodes
-		result.PV = bestPV
+		result.PV = best.PV
 		result.Elapsed = time.Since(start)
-		if isMateScore(best) {
-			mateMoves := (len(bestPV) + 1) / 2
-			if best < 0 {
-				mateMoves = -mateMoves
-			}
-			result.Mate = mateMoves
-		} else {
-			result.Mate = 0
+		result.Lines = make([]SearchLine, len(lines))
+		solved := true
+		for i := range lines {
+			lines[i].Mate = mateDistance(lines[i].Score, lines[i].PV)
+			result.Lines[i] = lines[i].SearchLine
+			solved = solved && lines[i].Mate != 0
 		}
+		result.Mate = result.Lines[0].Mate
 
 		if info != nil {
 			info(result)
 		}
 
-		if result.Mate != 0 || stopped() {
+		if solved || stopped() {
 			break // solved, or out of time
 		}
+		if multiPV > 1 {
+			// Search last depth's lines first next time, so the bound the
+			// other moves must beat is high from the start.
+			rootMoves = linesFirst(rootMoves, lines)
+		}
 	}
 
 	return result
 }
 
+// startHelpers starts n helper searches of the root for Lazy SMP and returns
+// a function that stops them, waits for them, and reports how many nodes they
+// searched. Helper i starts at depth 1 or 2 by its parity, so at any moment
+// half the helpers are a ply ahead of the other half and of the main search,
+// filling the table with the entries the main search is about to need.
+func startHelpers(gs dao.GameState, rootMoves []botMove, rootPath []uint64, tt *TranspositionTable, tb *Tablebase, n, maxDepth int) func() int {
+	stop := make(chan struct{})
+	nodes := make([]int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		// Each helper appends to its own path, so none may share the backing
+		// array of rootPath.
+		path := make([]uint64, len(rootPath), cap(rootPath))
+		copy(path, rootPath)
+		wg.Add(1)
+		go func(i int, path []uint64) {
+			defer wg.Done()
+			c := &searchCtx{stop: stop, tt: tt, tb: tb}
+			nodes[i] = helperSearch(c, gs, rootMoves, path, 1+(i+1)%2, maxDepth)
+		}(i, path)
+	}
+	return func() int {
+		close(stop)
+		wg.Wait()
+		total := 0
+		for _, n := range nodes {
+			total += n
+		}
+		return total
+	}
+}
+
+// helperSearch deepens a helper's search of the root from startDepth until it
+// is stopped or reaches maxDepth, and returns the nodes it searched. Its
+// results are not reported; they reach the main search through the table.
+func helperSearch(c *searchCtx, gs dao.GameState, rootMoves []botMove, rootPath []uint64, startDepth, maxDepth int) int {
+	total := 0
+	for depth := startDepth; depth <= maxDepth && !c.aborted; depth++ {
+		c.nodes = 0
+		c.path = rootPath
+		alpha := -searchInf
+		for _, m := range rootMoves {
+			var childPV []dto.Move
+			score := -negamaxPV(c, applyBotMove(gs, m), depth-1, -searchInf, -alpha, 1, &childPV)
+			if c.aborted || c.timeUp() {
+				c.aborted = true
+				break
+			}
+			if score > alpha {
+				alpha = score
+			}
+		}
+		total += c.nodes
+	}
+	return total
+}
+
+// insertLine adds line to lines, which are ranked best first, and keeps the
+// best n. A line ties below those already there with the same score.
+func insertLine(lines []rootLine, line rootLine, n int) []rootLine {
+	i := len(lines)
+	for i > 0 && lines[i-1].Score < line.Score {
+		i--
+	}
+	lines = append(lines, rootLine{})
+	copy(lines[i+1:], lines[i:])
+	lines[i] = line
+	if len(lines) > n {
+		lines = lines[:n]
+	}
+	return lines
+}
+
+// linesFirst reorders the root moves with the moves of lines first, in rank
+// order, and the rest after them as they were.
+func linesFirst(moves []botMove, lines []rootLine) []botMove {
+	ordered := make([]botMove, 0, len(moves))
+	for _, l := range lines {
+		ordered = append(ordered, l.move)
+	}
+	for _, m := range moves {
+		ranked := false
+		for _, l := range lines {
+			if l.move == m {
+				ranked = true
+				break
+			}
+		}
+		if !ranked {
+			ordered = append(ordered, m)
+		}
+	}
+	return ordered
+}
+
+// mateDistance is the SearchResult.Mate of a score and its PV: the mate
+// distance in moves, negative when the side to move is mated, or 0 for a
+// score that is not a mate.
+func mateDistance(score int, pv []dto.Move) int {
+	if !isMateScore(score) {
+		return 0
+	}
+	mateMoves := (len(pv) + 1) / 2
+	if score < 0 {
+		mateMoves = -mateMoves
+	}
+	return mateMoves
+}
+
 // searchCtx is the mutable state threaded through a search: node count, the
 // abort conditions, and the repetition path.
 //
@@ -164,6 +324,11 @@ type searchCtx struct {
 	stop     <-chan struct{}
 	aborted  bool
 	tt       *TranspositionTable
+	tb       *Tablebase
+	// ponderHit is SearchOptions.PonderHit until it is closed, when deadline
+	// becomes ponderDeadline and ponderHit is cleared.
+	ponderHit      <-chan struct{}
+	ponderDeadline time.Time
 	// path holds the position keys from the start of the game down to the
 	// current node. Pre-root game history is seeded from SearchOptions.History.
 	path []uint64
@@ -268,7 +433,7 @@ func negamaxPV(c *searchCtx, gs dao.GameState, depth, alpha, beta, ply int, pv *
 	c.nodes++
 
 	// Check for time/Stop periodically to keep the overhead negligible.
-	if c.nodes&1023 == 0 && abortRequested(c.deadline, c.stop) {
+	if c.nodes&1023 == 0 && c.timeUp() {
 		c.aborted = true
 		return 0
 	}
@@ -285,6 +450,14 @@ func negamaxPV(c *searchCtx, gs dao.GameState, depth, alpha, beta, ply int, pv *
 		return ttScore
 	}
 
+	// A position in the tablebases has a known result; the root's moves were
+	// already narrowed by them.
+	if ply > 0 {
+		if score, ok := c.tb.probeScore(gs, ply); ok {
+			return score
+		}
+	}
+
 	if depth == 0 {
 		return quiescence(c, gs, alpha, beta, maxQuiescenceDepth)
 	}
@@ -414,15 +587,26 @@ func (c *searchCtx) killerMoves(ply int) [2]botMove {
 	return c.killers[ply]
 }
 
-func abortRequested(deadline time.Time, stop <-chan struct{}) bool {
-	if stop != nil {
+// timeUp reports whether the search must stop: Stop has been signalled, or
+// the deadline has passed. A ponder search has no deadline until the ponder
+// hit.
+func (c *searchCtx) timeUp() bool {
+	if c.stop != nil {
 		select {
-		case <-stop:
+		case <-c.stop:
 			return true
 		default:
 		}
 	}
-	return !deadline.IsZero() && time.Now().After(deadline)
+	if c.ponderHit != nil {
+		select {
+		case <-c.ponderHit:
+			c.deadline, c.ponderHit = c.ponderDeadline, nil
+		default:
+			return false
+		}
+	}
+	return !c.deadline.IsZero() && time.Now().After(c.deadline)
 }
 
 // botMoveToDTO builds a dto.Move from a search move, deriving the piece letter
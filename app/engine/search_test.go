@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"chess-engine/app/domain/dto"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSearchMultiPVRanksLines(t *testing.T) {
+	// Rxd8+ wins the queen and mates; every other move is quiet.
+	gs := mustFEN(t, "3q2k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 0 1")
+	single := Search(gs, SearchOptions{MaxDepth: 3}, nil)
+	res := Search(gs, SearchOptions{MaxDepth: 3, MultiPV: 3}, nil)
+	if len(res.Lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(res.Lines))
+	}
+	if MoveToUCI(res.Best) != "d1d8" || MoveToUCI(res.Lines[0].PV[0]) != "d1d8" {
+		t.Errorf("best = %v, first line %v; want d1d8 for both", res.Best, res.Lines[0].PV)
+	}
+	// A lone mate ends the single-line search at once; the other lines keep
+	// this one going deeper, so compare the mate rather than the raw score.
+	if res.Lines[0].Score != res.Score || res.Lines[0].Mate != res.Mate || res.Mate != single.Mate {
+		t.Errorf("first line %+v disagrees with the result (%d, mate %d) or the single-PV mate %d",
+			res.Lines[0], res.Score, res.Mate, single.Mate)
+	}
+	seen := map[string]bool{}
+	for i, line := range res.Lines {
+		if i > 0 && line.Score > res.Lines[i-1].Score {
+			t.Errorf("line %d scores %d above line %d's %d", i+1, line.Score, i, res.Lines[i-1].Score)
+		}
+		move := MoveToUCI(line.PV[0])
+		if seen[move] {
+			t.Errorf("%s appears in two lines", move)
+		}
+		seen[move] = true
+	}
+
+	// Each lower line is exact: the same as searching its move's reply a ply
+	// shallower.
+	second := res.Lines[1]
+	reply := Search(ApplyMove(gs, second.PV[0]), SearchOptions{MaxDepth: 2}, nil)
+	if second.Score != -reply.Score {
+		t.Errorf("second line scores %d, searched alone %d", second.Score, -reply.Score)
+	}
+
+	// Asking for more lines than there are moves gives one per move.
+	king := mustFEN(t, "7k/8/8/8/8/8/8/K7 w - - 0 1")
+	if res := Search(king, SearchOptions{MaxDepth: 2, MultiPV: 10}, nil); len(res.Lines) != 3 {
+		t.Errorf("lone king: got %d lines, want 3", len(res.Lines))
+	}
+}
+
+// A ponder search ignores its move time until the ponder hit, then stops at
+// once if pondering already used the time up.
+func TestPonderSearchWaitsForPonderHit(t *testing.T) {
+	gs := mustFEN(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	hit := make(chan struct{})
+	done := make(chan SearchResult, 1)
+	go func() {
+		done <- Search(gs, SearchOptions{MoveTime: 20 * time.Millisecond, PonderHit: hit}, nil)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("the ponder search stopped on its move time before the ponder hit")
+	case <-time.After(200 * time.Millisecond):
+	}
+	close(hit)
+	select {
+	case res := <-done:
+		if !res.HasBest || res.Depth == 0 {
+			t.Errorf("ponder search returned %+v, want a completed depth", res)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the ponder search kept going after the ponder hit with its time spent")
+	}
+}
+
+// With one thread a search is deterministic: the same position and a fresh
+// table give the same line and node count every time.
+func TestSearchSingleThreadIsDeterministic(t *testing.T) {
+	gs := mustFEN(t, "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4")
+	search := func() SearchResult {
+		return Search(gs, SearchOptions{MaxDepth: 5, Threads: 1, Table: NewTranspositionTable(4)}, nil)
+	}
+	a, b := search(), search()
+	if a.Nodes != b.Nodes || a.Score != b.Score || len(a.PV) != len(b.PV) {
+		t.Fatalf("two runs differ: %d nodes score %d, then %d nodes score %d", a.Nodes, a.Score, b.Nodes, b.Score)
+	}
+	for i := range a.PV {
+		if a.PV[i] != b.PV[i] {
+			t.Fatalf("PVs differ at ply %d: %v and %v", i, a.PV, b.PV)
+		}
+	}
+}
+
+// Helper threads share the table with the main search and must not change
+// what it finds, only how fast.
+func TestSearchThreadsFindsSameMove(t *testing.T) {
+	gs := mustFEN(t, "3q2k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 0 1")
+	res := Search(gs, SearchOptions{MaxDepth: 4, Threads: 4, Table: NewTranspositionTable(4)}, nil)
+	if !res.HasBest || MoveToUCI(res.Best) != "d1d8" {
+		t.Fatalf("best = %v, want d1d8", res.Best)
+	}
+	if res.Mate != 1 {
+		t.Errorf("mate = %d, want 1", res.Mate)
+	}
+}
+
+// BenchmarkSearchThreads measures time to depth for 1, 2 and 4 threads on a
+// middlegame position, each search with a fresh table. Thread counts above
+// GOMAXPROCS are skipped: the helpers would only take turns with the main
+// search on the same cores, so time to depth goes up rather than down.
+func BenchmarkSearchThreads(b *testing.B) {
+	gs, err := ParseStartFEN("r1bq1rk1/pp2bppp/2n1pn2/3p4/2PP4/2N1PN2/PP3PPP/R2QKB1R w KQ - 0 8")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, threads := range []int{1, 2, 4} {
+		b.Run(fmt.Sprintf("threads=%d", threads), func(b *testing.B) {
+			if procs := runtime.GOMAXPROCS(0); threads > procs {
+				b.Skipf("%d threads need %d cores, GOMAXPROCS is %d", threads, threads, procs)
+			}
+			nodes := 0
+			for i := 0; i < b.N; i++ {
+				nodes += Search(gs, SearchOptions{MaxDepth: 6, Threads: threads, Table: NewTranspositionTable(16)}, nil).Nodes
+			}
+			// All threads' nodes: more threads search more of them per depth.
+			b.ReportMetric(float64(nodes)/float64(b.N), "nodes/op")
+		})
+	}
+}
+
+func TestSANLine(t *testing.T) {
+	gs := mustFEN(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	line := []dto.Move{mustUCI(t, gs, "e2e4")}
+	gs1 := ApplyMove(gs, line[0])
+	line = append(line, mustUCI(t, gs1, "e7e5"))
+	got, err := SANLine(gs, line)
+	if err != nil || len(got) != 2 || got[0] != "e4" || got[1] != "e5" {
+		t.Errorf("SANLine = %v, %v", got, err)
+	}
+	if _, err := SANLine(gs, []dto.Move{line[1]}); err == nil {
+		t.Error("expected an error for a move that is illegal in its position")
+	}
+}
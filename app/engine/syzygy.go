@@ -0,0 +1,934 @@
+package engine
+
+import (
+	"chess-engine/app/domain/dao"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Syzygy endgame tablebases.
+//
+// A Syzygy table holds the result of every position with one set of material:
+// KRvK.rtbw gives win, draw or loss (WDL) for every position of king and rook
+// against king, and KRvK.rtbz the distance to zeroing (DTZ) -- the number of
+// plies to the next capture or pawn move, mate counting as one -- along the
+// fastest win or slowest loss. Files are named with the side that has more
+// first; a position with the colours the other way round is looked up with
+// them flipped.
+//
+// The reader below follows the published format: each table is a list of
+// values, one per position index, compressed by recursive pairing with a
+// canonical Huffman code and stored in fixed-size blocks. A position is turned
+// into its index by a per-table ordering of the pieces, folding the board's
+// symmetries away first. Tables are mapped into memory the first time they
+// are probed (see mapTable), so only the blocks probes touch are read: a
+// 6-piece set runs to 150GB.
+
+const (
+	tbMaxPieces = 7
+
+	tbWDLSuffix = ".rtbw"
+	tbDTZSuffix = ".rtbz"
+)
+
+var (
+	tbWDLMagic = [4]byte{0x71, 0xE8, 0x23, 0x5D}
+	tbDTZMagic = [4]byte{0xD7, 0x66, 0x0C, 0xA5}
+)
+
+// Piece codes as the tables store them: White pawn to king 1-6, Black 9-14.
+const (
+	tbPawn   = 1
+	tbKnight = 2
+	tbBishop = 3
+	tbRook   = 4
+	tbQueen  = 5
+	tbKing   = 6
+	tbBlack  = 8
+)
+
+// Flags of a pairsData.
+const (
+	tbFlagSTM         = 1
+	tbFlagMapped      = 2
+	tbFlagWinPlies    = 4
+	tbFlagLossPlies   = 8
+	tbFlagWide        = 16
+	tbFlagSingleValue = 128
+)
+
+// Tablebase probes the Syzygy tables found in one or more directories.
+// It is safe for concurrent use.
+type Tablebase struct {
+	wdl map[string]*tbTable
+	dtz map[string]*tbTable
+	// MaxPieces is the most pieces, kings included, of any WDL table found.
+	// Positions with more are never probed.
+	MaxPieces int
+}
+
+// OpenTablebase finds the Syzygy tables in path, a list of directories
+// separated as in PATH. The tables themselves are read when first probed.
+func OpenTablebase(path string) (*Tablebase, error) {
+	tb := &Tablebase{wdl: map[string]*tbTable{}, dtz: map[string]*tbTable{}}
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			continue
+		}
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			name := f.Name()
+			ext := filepath.Ext(name)
+			if f.IsDir() || (ext != tbWDLSuffix && ext != tbDTZSuffix) {
+				continue
+			}
+			t, ok := newTBTable(filepath.Join(dir, name), strings.TrimSuffix(name, ext), ext == tbDTZSuffix)
+			if !ok {
+				continue
+			}
+			tables := tb.wdl
+			if t.dtz {
+				tables = tb.dtz
+			} else if t.pieceCount > tb.MaxPieces {
+				tb.MaxPieces = t.pieceCount
+			}
+			// The first directory to have a table wins.
+			if _, dup := tables[t.key]; !dup {
+				tables[t.key] = t
+				tables[t.key2] = t
+			}
+		}
+	}
+	if len(tb.wdl) == 0 {
+		return nil, fmt.Errorf("no Syzygy tables in %s", path)
+	}
+	return tb, nil
+}
+
+// tbTable is one table file. Everything but the path and the material is
+// filled in by load.
+type tbTable struct {
+	path string
+	dtz  bool
+	// key is the material with the file's first side as White, as
+	// materialName writes it; key2 with the colours swapped. They are equal
+	// for a table like KRvKR.
+	key, key2       string
+	pieceCount      int
+	hasPawns        bool
+	hasUniquePieces bool
+	// pawnCount[0] is the pawns of the leading colour: the side with fewer
+	// pawns but not none, White on a tie.
+	pawnCount [2]int
+
+	once sync.Once
+	err  error
+	data []byte
+	// sides is how many sides to move the table stores: 2 for a WDL table of
+	// unequal material, otherwise 1.
+	sides int
+	// items holds one pairsData per side to move and, with pawns, per file
+	// of the leading pawn.
+	items [2][4]pairsData
+	// dtzMap is where a DTZ table's value maps begin.
+	dtzMap int
+}
+
+// pairsData describes one compressed list of values. The int fields that
+// locate data in the file are offsets into tbTable.data.
+type pairsData struct {
+	flags  byte
+	pieces [tbMaxPieces]byte
+	// groupLen lists how many pieces are encoded together, zero-terminated;
+	// groupIdx the multiplier of each group's part of the index, with the
+	// table's size after the last group.
+	groupLen [tbMaxPieces + 1]int
+	groupIdx [tbMaxPieces + 1]uint64
+
+	sizeofBlock     uint64
+	span            uint64
+	sparseIndexSize uint64
+	blocksNum       uint64
+	blockLengthSize uint64
+	minSymLen       int // the value itself, for a single-value table
+	maxSymLen       int
+	lowestSym       int
+	base64          []uint64
+	symlen          []uint8
+	btree           int
+	sparseIndex     int
+	blockLength     int
+	blocks          int
+	// mapIdx locates the DTZ value map for each kind of result, offset by
+	// one, in bytes or, for a wide map, in 16-bit words from tbTable.dtzMap.
+	mapIdx [4]int
+}
+
+// newTBTable describes the table file named name, a material like "KRvK".
+func newTBTable(path, name string, dtz bool) (*tbTable, bool) {
+	sides := strings.Split(name, "v")
+	if len(sides) != 2 {
+		return nil, false
+	}
+	var count [2][7]int // [colour][piece code]
+	for c, side := range sides {
+		if !strings.HasPrefix(side, "K") {
+			return nil, false
+		}
+		for _, r := range side {
+			i := strings.IndexRune(" PNBRQK", r)
+			if i <= 0 {
+				return nil, false
+			}
+			count[c][i]++
+		}
+		if count[c][tbKing] != 1 {
+			return nil, false
+		}
+	}
+
+	t := &tbTable{path: path, dtz: dtz}
+	t.key = materialString(count[0], count[1])
+	t.key2 = materialString(count[1], count[0])
+	for c := 0; c < 2; c++ {
+		for p := tbPawn; p <= tbKing; p++ {
+			t.pieceCount += count[c][p]
+			if p != tbKing && count[c][p] == 1 {
+				t.hasUniquePieces = true
+			}
+		}
+	}
+	if t.pieceCount > tbMaxPieces {
+		return nil, false
+	}
+	white, black := count[0][tbPawn], count[1][tbPawn]
+	t.hasPawns = white+black > 0
+	if black == 0 || (white > 0 && black >= white) {
+		t.pawnCount = [2]int{white, black}
+	} else {
+		t.pawnCount = [2]int{black, white}
+	}
+	return t, true
+}
+
+// materialString names a material balance the way table files are named:
+// each side's pieces from the king down, White first.
+func materialString(white, black [7]int) string {
+	var b strings.Builder
+	for c, count := range [2][7]int{white, black} {
+		if c == 1 {
+			b.WriteByte('v')
+		}
+		for p := tbKing; p >= tbPawn; p-- {
+			b.WriteString(strings.Repeat(string(" PNBRQK"[p]), count[p]))
+		}
+	}
+	return b.String()
+}
+
+// get returns the pairsData for a side to move and leading pawn file.
+func (t *tbTable) get(stm, file int) *pairsData {
+	return &t.items[stm%t.sides][file]
+}
+
+// load maps and parses the file, once; later calls return the first
+// result.
+func (t *tbTable) load() error {
+	t.once.Do(func() {
+		data, err := mapTable(t.path)
+		if err == nil {
+			err = t.parse(data)
+		}
+		if err != nil {
+			t.err = fmt.Errorf("syzygy table %s: %w", t.path, err)
+		}
+	})
+	return t.err
+}
+
+var errTBCorrupt = errors.New("corrupt or truncated file")
+
+// parse reads the table's header and locates its data.
+func (t *tbTable) parse(data []byte) (err error) {
+	// The offsets below come from the file; a damaged one indexes past the
+	// end, which is reported rather than allowed to take the process down.
+	defer func() {
+		if recover() != nil {
+			err = errTBCorrupt
+		}
+	}()
+
+	magic := tbWDLMagic
+	if t.dtz {
+		magic = tbDTZMagic
+	}
+	if len(data) < 5 || [4]byte(data[:4]) != magic {
+		return errors.New("not a Syzygy table")
+	}
+	const hasPawns = 2
+	if (data[4]&hasPawns != 0) != t.hasPawns {
+		return errors.New("header does not match the file name")
+	}
+	pos := 5
+
+	t.sides = 1
+	if !t.dtz && t.key != t.key2 {
+		t.sides = 2
+	}
+	maxFile := 0
+	if t.hasPawns {
+		maxFile = 3
+	}
+	pp := t.hasPawns && t.pawnCount[1] > 0 // pawns on both sides
+
+	for f := 0; f <= maxFile; f++ {
+		order := [2][2]int{{int(data[pos] & 0xF), 0xF}, {int(data[pos] >> 4), 0xF}}
+		if pp {
+			order[0][1], order[1][1] = int(data[pos+1]&0xF), int(data[pos+1]>>4)
+			pos++
+		}
+		pos++
+		for k := 0; k < t.pieceCount; k, pos = k+1, pos+1 {
+			t.items[0][f].pieces[k] = data[pos] & 0xF
+			t.items[1][f].pieces[k] = data[pos] >> 4
+		}
+		for i := 0; i < t.sides; i++ {
+			t.setGroups(t.get(i, f), order[i], f)
+		}
+	}
+	pos += pos & 1
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < t.sides; i++ {
+			pos = t.get(i, f).setSizes(data, pos)
+		}
+	}
+	if t.dtz {
+		pos = t.setDTZMap(data, pos, maxFile)
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < t.sides; i++ {
+			d := t.get(i, f)
+			d.sparseIndex = pos
+			pos += int(d.sparseIndexSize) * 6
+		}
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < t.sides; i++ {
+			d := t.get(i, f)
+			d.blockLength = pos
+			pos += int(d.blockLengthSize) * 2
+		}
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < t.sides; i++ {
+			d := t.get(i, f)
+			pos = (pos + 0x3F) &^ 0x3F
+			d.blocks = pos
+			pos += int(d.blocksNum * d.sizeofBlock)
+		}
+	}
+	if pos > len(data) {
+		return errTBCorrupt
+	}
+	t.data = data
+	return nil
+}
+
+// setGroups splits the pieces into the groups that are encoded together and
+// works out each group's multiplier. order gives the position of the leading
+// group and, with pawns on both sides, of the other side's pawns among the
+// groups; the rest follow in piece order.
+func (t *tbTable) setGroups(d *pairsData, order [2]int, file int) {
+	n := 0
+	firstLen := 2
+	switch {
+	case t.hasPawns:
+		firstLen = 0
+	case t.hasUniquePieces:
+		firstLen = 3
+	}
+	d.groupLen[0] = 1
+	for i := 1; i < t.pieceCount; i++ {
+		firstLen--
+		if firstLen > 0 || d.pieces[i] == d.pieces[i-1] {
+			d.groupLen[n]++
+		} else {
+			n++
+			d.groupLen[n] = 1
+		}
+	}
+	n++
+	d.groupLen[n] = 0
+
+	pp := t.hasPawns && t.pawnCount[1] > 0
+	next := 1
+	freeSquares := 64 - d.groupLen[0]
+	if pp {
+		next = 2
+		freeSquares -= d.groupLen[1]
+	}
+	idx := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		switch {
+		case k == order[0]: // the leading pawns or pieces
+			d.groupIdx[0] = idx
+			switch {
+			case t.hasPawns:
+				idx *= tbLeadPawnsSize[d.groupLen[0]][file]
+			case t.hasUniquePieces:
+				idx *= 31332
+			default:
+				idx *= 462
+			}
+		case k == order[1]: // the other side's pawns
+			d.groupIdx[1] = idx
+			idx *= tbBinomial[d.groupLen[1]][48-d.groupLen[0]]
+		default: // the remaining pieces
+			d.groupIdx[next] = idx
+			idx *= tbBinomial[d.groupLen[next]][freeSquares]
+			freeSquares -= d.groupLen[next]
+			next++
+		}
+	}
+	d.groupIdx[n] = idx
+}
+
+// setSizes reads the compression parameters at pos and returns the offset
+// after them.
+func (d *pairsData) setSizes(data []byte, pos int) int {
+	d.flags = data[pos]
+	pos++
+	if d.flags&tbFlagSingleValue != 0 {
+		d.minSymLen = int(data[pos])
+		return pos + 1
+	}
+
+	n := 0
+	for d.groupLen[n] != 0 {
+		n++
+	}
+	tbSize := d.groupIdx[n]
+
+	d.sizeofBlock = 1 << data[pos]
+	d.span = 1 << data[pos+1]
+	d.sparseIndexSize = (tbSize + d.span - 1) / d.span
+	padding := uint64(data[pos+2])
+	d.blocksNum = uint64(binary.LittleEndian.Uint32(data[pos+3:]))
+	d.blockLengthSize = d.blocksNum + padding
+	d.maxSymLen = int(data[pos+7])
+	d.minSymLen = int(data[pos+8])
+	d.lowestSym = pos + 9
+	pos += 9
+
+	// The Huffman code is canonical, longer codes having lower values, so
+	// base64[l] -- the lowest code of length minSymLen+l, left-aligned in 64
+	// bits -- falls as l rises, and a code's length is the first l whose
+	// base64 it is not below.
+	d.base64 = make([]uint64, d.maxSymLen-d.minSymLen+1)
+	for i := len(d.base64) - 2; i >= 0; i-- {
+		d.base64[i] = (d.base64[i+1] + uint64(d.lowest(data, i)) - uint64(d.lowest(data, i+1))) / 2
+	}
+	for i := range d.base64 {
+		d.base64[i] <<= 64 - i - d.minSymLen
+	}
+	pos += len(d.base64) * 2
+
+	symbols := int(binary.LittleEndian.Uint16(data[pos:]))
+	pos += 2
+	d.btree = pos
+	d.symlen = make([]uint8, symbols)
+	visited := make([]bool, symbols)
+	for s := 0; s < symbols; s++ {
+		if !visited[s] {
+			d.symlen[s] = d.setSymlen(data, s, visited)
+		}
+	}
+	return pos + symbols*3 + symbols&1
+}
+
+// lowest returns the lowest symbol of code length minSymLen+i.
+func (d *pairsData) lowest(data []byte, i int) uint16 {
+	return binary.LittleEndian.Uint16(data[d.lowestSym+2*i:])
+}
+
+// children returns the pair a symbol stands for. A symbol with no pair (right
+// is 0xFFF) is a value, left.
+func (d *pairsData) children(data []byte, s int) (left, right int) {
+	lr := data[d.btree+3*s:]
+	return int(lr[1]&0xF)<<8 | int(lr[0]), int(lr[2])<<4 | int(lr[1]>>4)
+}
+
+// setSymlen returns how many values, less one, symbol s expands to.
+func (d *pairsData) setSymlen(data []byte, s int, visited []bool) uint8 {
+	visited[s] = true
+	left, right := d.children(data, s)
+	if right == 0xFFF {
+		return 0
+	}
+	if !visited[left] {
+		d.symlen[left] = d.setSymlen(data, left, visited)
+	}
+	if !visited[right] {
+		d.symlen[right] = d.setSymlen(data, right, visited)
+	}
+	return d.symlen[left] + d.symlen[right] + 1
+}
+
+// setDTZMap records where each file's value maps start and returns the
+// offset after them.
+func (t *tbTable) setDTZMap(data []byte, pos, maxFile int) int {
+	t.dtzMap = pos
+	for f := 0; f <= maxFile; f++ {
+		d := t.get(0, f)
+		if d.flags&tbFlagMapped == 0 {
+			continue
+		}
+		if d.flags&tbFlagWide != 0 {
+			pos += pos & 1
+			for i := 0; i < 4; i++ {
+				d.mapIdx[i] = (pos-t.dtzMap)/2 + 1
+				pos += 2*int(binary.LittleEndian.Uint16(data[pos:])) + 2
+			}
+		} else {
+			for i := 0; i < 4; i++ {
+				d.mapIdx[i] = pos - t.dtzMap + 1
+				pos += int(data[pos]) + 1
+			}
+		}
+	}
+	return pos + pos&1
+}
+
+// decompress returns the value at index idx.
+func (t *tbTable) decompress(d *pairsData, idx uint64) int {
+	if d.flags&tbFlagSingleValue != 0 {
+		return d.minSymLen
+	}
+	data := t.data
+
+	// Block b holds blockLength[b]+1 values. The sparse index gives, for every
+	// span-th index k*span+span/2, its block and offset in it, so the block
+	// holding idx is found by stepping from the nearest entry.
+	k := idx / d.span
+	entry := data[d.sparseIndex+6*int(k):]
+	block := int(binary.LittleEndian.Uint32(entry))
+	offset := int(binary.LittleEndian.Uint16(entry[4:]))
+	offset += int(idx%d.span) - int(d.span/2)
+
+	blockLength := func(b int) int {
+		return int(binary.LittleEndian.Uint16(data[d.blockLength+2*b:]))
+	}
+	for offset < 0 {
+		block--
+		offset += blockLength(block) + 1
+	}
+	for offset > blockLength(block) {
+		offset -= blockLength(block) + 1
+		block++
+	}
+
+	// Read Huffman codes from the start of the block until the symbol that
+	// expands to cover offset.
+	ptr := d.blocks + block*int(d.sizeofBlock)
+	buf := binary.BigEndian.Uint64(data[ptr:])
+	ptr += 8
+	bufSize := 64
+	var sym int
+	for {
+		l := 0
+		for buf < d.base64[l] {
+			l++
+		}
+		sym = int((buf-d.base64[l])>>(64-l-d.minSymLen)) + int(d.lowest(data, l))
+		if offset < int(d.symlen[sym])+1 {
+			break
+		}
+		offset -= int(d.symlen[sym]) + 1
+		l += d.minSymLen
+		buf <<= l
+		bufSize -= l
+		if bufSize <= 32 {
+			bufSize += 32
+			buf |= uint64(binary.BigEndian.Uint32(data[ptr:])) << (64 - bufSize)
+			ptr += 4
+		}
+	}
+
+	// Expand the symbol's pairs down to the single value at offset.
+	for d.symlen[sym] != 0 {
+		left, right := d.children(data, sym)
+		if offset < int(d.symlen[left])+1 {
+			sym = left
+		} else {
+			offset -= int(d.symlen[left]) + 1
+			sym = right
+		}
+	}
+	left, _ := d.children(data, sym)
+	return left
+}
+
+// mapScore turns a stored value into a WDL result, or into a DTZ in plies
+// given the position's WDL result.
+func (t *tbTable) mapScore(file, value int, wdl WDL) int {
+	if !t.dtz {
+		return value - 2
+	}
+	d := t.get(0, file)
+	if d.flags&tbFlagMapped != 0 {
+		// Maps are stored for win, loss, cursed win and blessed loss.
+		i := d.mapIdx[[...]int{1, 3, 0, 2, 0}[int(wdl)+2]] + value
+		if d.flags&tbFlagWide != 0 {
+			value = int(binary.LittleEndian.Uint16(t.data[t.dtzMap+2*i:]))
+		} else {
+			value = int(t.data[t.dtzMap+i])
+		}
+	}
+	if (wdl == WDLWin && d.flags&tbFlagWinPlies == 0) ||
+		(wdl == WDLLoss && d.flags&tbFlagLossPlies == 0) ||
+		wdl == WDLCursedWin || wdl == WDLBlessedLoss {
+		value *= 2
+	}
+	return value + 1
+}
+
+// probe looks a position up in the table. For a DTZ table wdl is the
+// position's result; changeSTM reports that the table holds only the other
+// side to move, so the caller has to search a ply.
+func (t *tbTable) probe(gs dao.GameState, wdl WDL) (value int, changeSTM bool) {
+	d, file, idx, changeSTM := t.index(gs)
+	if changeSTM {
+		return 0, true
+	}
+	return t.mapScore(file, t.decompress(d, idx), wdl), false
+}
+
+// index finds where a position is stored: the list for its side to move and
+// leading pawn file, and its index in that list. changeSTM is as for probe.
+func (t *tbTable) index(gs dao.GameState) (d *pairsData, file int, idx uint64, changeSTM bool) {
+	blackToMove := gs.Turn == "b"
+	// A table stores its material with the first side as White. When the
+	// position has it the other way round, or both sides are the same and
+	// the table only holds White to move, look up the mirror image.
+	flip := materialName(gs) != t.key || (t.key == t.key2 && blackToMove)
+	flipColour, flipSquares, stm := byte(0), 0, 0
+	if flip {
+		flipColour, flipSquares = tbBlack, 56
+	}
+	if flip != blackToMove {
+		stm = 1
+	}
+
+	var squares [tbMaxPieces]int
+	var pieces [tbMaxPieces]byte
+	size, leadPawnsCnt := 0, 0
+	var leadPawns uint64
+
+	// With pawns there is one list per file of the leading pawn: the one
+	// nearest the edge, and on that file the lowest.
+	if t.hasPawns {
+		own := gs.WhiteBitboard
+		if (t.items[0][0].pieces[0]^flipColour)&tbBlack != 0 {
+			own = gs.BlackBitboard
+		}
+		leadPawns = gs.PawnBitboard & own
+		for b := leadPawns; b != 0; b &= b - 1 {
+			squares[size] = bits.TrailingZeros64(b) ^ flipSquares
+			size++
+		}
+		leadPawnsCnt = size
+		lead := 0
+		for i := 1; i < leadPawnsCnt; i++ {
+			if tbMapPawns[squares[i]] > tbMapPawns[squares[lead]] {
+				lead = i
+			}
+		}
+		squares[0], squares[lead] = squares[lead], squares[0]
+		file = squares[0] & 7
+		if file > 3 {
+			file = 7 - file
+		}
+	}
+
+	// A DTZ table stores one side to move only.
+	if t.dtz && int(t.get(0, file).flags&tbFlagSTM) != stm && (t.key != t.key2 || t.hasPawns) {
+		return nil, file, 0, true
+	}
+
+	for b := (gs.WhiteBitboard | gs.BlackBitboard) &^ leadPawns; b != 0; b &= b - 1 {
+		sq := bits.TrailingZeros64(b)
+		squares[size] = sq ^ flipSquares
+		pieces[size] = tbPieceAt(gs, uint64(1)<<sq) ^ flipColour
+		size++
+	}
+
+	d = t.get(stm, file)
+
+	// Put the pieces in the table's order.
+	for i := leadPawnsCnt; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if d.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+
+	// Mirror so the leading piece is on files a-d.
+	if squares[0]&7 > 3 {
+		for i := 0; i < size; i++ {
+			squares[i] ^= 7
+		}
+	}
+
+	if t.hasPawns {
+		idx = tbLeadPawnIdx[leadPawnsCnt][squares[0]]
+		rest := squares[1:leadPawnsCnt]
+		sort.SliceStable(rest, func(i, j int) bool { return tbMapPawns[rest[i]] < tbMapPawns[rest[j]] })
+		for i := 1; i < leadPawnsCnt; i++ {
+			idx += tbBinomial[i][tbMapPawns[squares[i]]]
+		}
+	} else {
+		idx = t.pieceIndex(d, squares[:size])
+	}
+
+	// The remaining groups, each by its squares in ascending order, counting
+	// only the squares the earlier groups left free.
+	idx *= d.groupIdx[0]
+	start := d.groupLen[0]
+	remainingPawns := t.hasPawns && t.pawnCount[1] > 0
+	for next := 1; d.groupLen[next] != 0; next++ {
+		group := squares[start : start+d.groupLen[next]]
+		sort.Ints(group)
+		var n uint64
+		for i, sq := range group {
+			adjust := 0
+			for _, s := range squares[:start] {
+				if sq > s {
+					adjust++
+				}
+			}
+			if remainingPawns {
+				adjust += 8
+			}
+			n += tbBinomial[i+1][sq-adjust]
+		}
+		remainingPawns = false
+		idx += n * d.groupIdx[next]
+		start += len(group)
+	}
+	return d, file, idx, false
+}
+
+// pieceIndex encodes the leading group of a pawnless table, mirroring the
+// board first so the leading piece is in the a1-d1-d4 triangle.
+func (t *tbTable) pieceIndex(d *pairsData, squares []int) uint64 {
+	if squares[0]>>3 > 3 {
+		for i := range squares {
+			squares[i] ^= 56
+		}
+	}
+	// The first of the leading group off the a1-h8 diagonal goes below it.
+	for i := 0; i < d.groupLen[0]; i++ {
+		o := offA1H8(squares[i])
+		if o == 0 {
+			continue
+		}
+		if o > 0 {
+			for j := i; j < len(squares); j++ {
+				squares[j] = (squares[j]>>3 | squares[j]<<3) & 63
+			}
+		}
+		break
+	}
+
+	if !t.hasUniquePieces {
+		// Two kings placed together: 462 ways.
+		return uint64(tbMapKK[tbMapA1D1D4[squares[0]]][squares[1]])
+	}
+
+	// Three unique pieces placed together: 31332 ways.
+	s0, s1, s2 := squares[0], squares[1], squares[2]
+	adjust1 := b2i(s1 > s0)
+	adjust2 := b2i(s2 > s0) + b2i(s2 > s1)
+	switch {
+	case offA1H8(s0) != 0:
+		return uint64((tbMapA1D1D4[s0]*63+(s1-adjust1))*62 + s2 - adjust2)
+	case offA1H8(s1) != 0:
+		return uint64((6*63+(s0>>3)*28+tbMapB1H1H7[s1])*62 + s2 - adjust2)
+	case offA1H8(s2) != 0:
+		return uint64(6*63*62 + 4*28*62 + (s0>>3)*7*28 + ((s1>>3)-adjust1)*28 + tbMapB1H1H7[s2])
+	default:
+		return uint64(6*63*62 + 4*28*62 + 4*7*28 + (s0>>3)*7*6 + ((s1>>3)-adjust1)*6 + (s2 >> 3) - adjust2)
+	}
+}
+
+// offA1H8 is positive above the a1-h8 diagonal, negative below and zero on it.
+func offA1H8(sq int) int {
+	return sq>>3 - sq&7
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// tbPieceAt returns the table's code for the piece on sq.
+func tbPieceAt(gs dao.GameState, sq uint64) byte {
+	code := byte(tbKing)
+	switch kindAt(gs, sq) {
+	case kindPawn:
+		code = tbPawn
+	case kindKnight:
+		code = tbKnight
+	case kindBishop:
+		code = tbBishop
+	case kindRook:
+		code = tbRook
+	case kindQueen:
+		code = tbQueen
+	}
+	if gs.BlackBitboard&sq != 0 {
+		code |= tbBlack
+	}
+	return code
+}
+
+// materialName names a position's material as materialString does.
+func materialName(gs dao.GameState) string {
+	var count [2][7]int
+	for b := gs.WhiteBitboard | gs.BlackBitboard; b != 0; b &= b - 1 {
+		code := tbPieceAt(gs, b&-b)
+		count[code>>3][code&7]++
+	}
+	return materialString(count[0], count[1])
+}
+
+// Indexing tables, built once.
+var (
+	// tbMapB1H1H7 numbers the 28 squares below the a1-h8 diagonal.
+	tbMapB1H1H7 [64]int
+	// tbMapA1D1D4 numbers the a1-d1-d4 triangle: the six squares below the
+	// diagonal, then the four on it.
+	tbMapA1D1D4 [64]int
+	// tbMapKK numbers the 462 placements of two kings with the first in the
+	// triangle, and the second not above the diagonal when the first is on it.
+	tbMapKK [10][64]int
+	// tbBinomial[k][n] is n choose k.
+	tbBinomial [tbMaxPieces][64]uint64
+	// tbMapPawns numbers the pawn squares a2-h7 so that the leading pawn has
+	// the highest number.
+	tbMapPawns [64]int
+	// tbLeadPawnIdx and tbLeadPawnsSize encode the leading pawns, by how many
+	// there are.
+	tbLeadPawnIdx   [6][64]uint64
+	tbLeadPawnsSize [6][4]uint64
+)
+
+func init() {
+	code := 0
+	for sq := 0; sq < 64; sq++ {
+		if offA1H8(sq) < 0 {
+			tbMapB1H1H7[sq] = code
+			code++
+		}
+	}
+
+	code = 0
+	var diagonal []int
+	for sq := 0; sq <= 27; sq++ { // a1 to d4
+		if sq&7 > 3 {
+			continue
+		}
+		switch {
+		case offA1H8(sq) < 0:
+			tbMapA1D1D4[sq] = code
+			code++
+		case offA1H8(sq) == 0:
+			diagonal = append(diagonal, sq)
+		}
+	}
+	for _, sq := range diagonal {
+		tbMapA1D1D4[sq] = code
+		code++
+	}
+
+	code = 0
+	var bothOnDiagonal [][2]int
+	for idx := 0; idx < 10; idx++ {
+		for s1 := 0; s1 <= 27; s1++ {
+			if s1&7 > 3 || offA1H8(s1) > 0 || tbMapA1D1D4[s1] != idx {
+				continue
+			}
+			for s2 := 0; s2 < 64; s2++ {
+				switch {
+				case abs(s1>>3-s2>>3) <= 1 && abs(s1&7-s2&7) <= 1:
+					// the same or adjacent squares: illegal
+				case offA1H8(s1) == 0 && offA1H8(s2) > 0:
+					// mirrored away
+				case offA1H8(s1) == 0 && offA1H8(s2) == 0:
+					bothOnDiagonal = append(bothOnDiagonal, [2]int{idx, s2})
+				default:
+					tbMapKK[idx][s2] = code
+					code++
+				}
+			}
+		}
+	}
+	for _, p := range bothOnDiagonal {
+		tbMapKK[p[0]][p[1]] = code
+		code++
+	}
+
+	tbBinomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < tbMaxPieces && k <= n; k++ {
+			if k > 0 {
+				tbBinomial[k][n] += tbBinomial[k-1][n-1]
+			}
+			if k < n {
+				tbBinomial[k][n] += tbBinomial[k][n-1]
+			}
+		}
+	}
+
+	available := 47
+	for leadPawnsCnt := 1; leadPawnsCnt <= 5; leadPawnsCnt++ {
+		for f := 0; f < 4; f++ {
+			var idx uint64
+			for r := 1; r <= 6; r++ {
+				sq := r*8 + f
+				if leadPawnsCnt == 1 {
+					tbMapPawns[sq] = available
+					available--
+					tbMapPawns[sq^7] = available
+					available--
+				}
+				tbLeadPawnIdx[leadPawnsCnt][sq] = idx
+				idx += tbBinomial[leadPawnsCnt-1][tbMapPawns[sq]]
+			}
+			tbLeadPawnsSize[leadPawnsCnt][f] = idx
+		}
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package engine
+
+import "os"
+
+// mapTable reads the file at path whole. Elsewhere than on unix the tables are
+// not mapped, so each table probed stays on the heap: keep SYZYGY_PATH to
+// tables that fit in memory there.
+func mapTable(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package engine
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mapTable maps the file at path into memory, read-only. Pages are read in
+// as probes touch them and belong to the page cache, not the heap, so a large
+// table costs address space rather than memory. The mapping is never undone:
+// a table lives as long as the process, and a search may still be probing one
+// when its Tablebase is replaced.
+func mapTable(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return nil, nil // nothing to map; parse rejects it
+	}
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("%d bytes is too large to map", size)
+	}
+	return syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
@@ -0,0 +1,348 @@
+package engine
+
+import (
+	"chess-engine/app/domain/dao"
+	"math/bits"
+)
+
+// WDL is a tablebase result from the side to move's point of view. A cursed
+// win is a win that takes more than fifty moves without a capture or pawn
+// move, so is a draw under the fifty-move rule; a blessed loss is the same
+// from the losing side.
+type WDL int
+
+const (
+	WDLLoss        WDL = -2
+	WDLBlessedLoss WDL = -1
+	WDLDraw        WDL = 0
+	WDLCursedWin   WDL = 1
+	WDLWin         WDL = 2
+)
+
+// tbState is how a probe went.
+type tbState int
+
+const (
+	tbOK tbState = iota
+	tbFail
+	// tbZeroingBestMove: the best move captures or moves a pawn, and the
+	// table's own value for the position cannot be trusted.
+	tbZeroingBestMove
+	// tbChangeSTM: the DTZ table holds the other side to move.
+	tbChangeSTM
+)
+
+// tbWinScore is the search score of a tablebase win at the root, less a
+// point per ply to it. It is above any evaluation but well clear of the mate
+// scores, which must stay exact.
+const tbWinScore = 20000
+
+// canProbe reports whether gs is small enough for the tables and has no
+// castling rights, which the tables leave out.
+func (tb *Tablebase) canProbe(gs dao.GameState) bool {
+	return tb != nil && gs.CastlingRights == "" &&
+		bits.OnesCount64(gs.WhiteBitboard|gs.BlackBitboard) <= tb.MaxPieces
+}
+
+// ProbeWDL returns the result of a position with best play, ignoring the
+// fifty-move counter. It reports false when the position has too many pieces
+// or castling rights, or a table it needs is missing or unreadable.
+func (tb *Tablebase) ProbeWDL(gs dao.GameState) (WDL, bool) {
+	if !tb.canProbe(gs) {
+		return WDLDraw, false
+	}
+	wdl, state := tb.search(gs, false)
+	return wdl, state != tbFail
+}
+
+// ProbeDTZ returns the distance to zeroing in plies with best play, positive
+// when the side to move wins and negative when it loses, and 0 for a draw.
+// Cursed wins and blessed losses are 100 plies further out, so a value
+// beyond 100 marks a result the fifty-move rule turns into a draw.
+func (tb *Tablebase) ProbeDTZ(gs dao.GameState) (int, bool) {
+	if !tb.canProbe(gs) {
+		return 0, false
+	}
+	dtz, state := tb.probeDTZ(gs)
+	return dtz, state != tbFail
+}
+
+// probeTable reads a position's value from its table: the WDL result, or the
+// DTZ given the position's WDL result.
+func (tb *Tablebase) probeTable(gs dao.GameState, dtz bool, wdl WDL) (int, tbState) {
+	if bits.OnesCount64(gs.WhiteBitboard|gs.BlackBitboard) == 2 {
+		return int(WDLDraw), tbOK // bare kings
+	}
+	tables := tb.wdl
+	if dtz {
+		tables = tb.dtz
+	}
+	t := tables[materialName(gs)]
+	if t == nil || t.load() != nil {
+		return 0, tbFail
+	}
+	value, changeSTM, ok := t.probeSafely(gs, wdl)
+	switch {
+	case !ok:
+		return 0, tbFail
+	case changeSTM:
+		return 0, tbChangeSTM
+	}
+	return value, tbOK
+}
+
+// probeSafely is probe, failing where a damaged table would make it index out
+// of range.
+func (t *tbTable) probeSafely(gs dao.GameState, wdl WDL) (value int, changeSTM, ok bool) {
+	defer func() {
+		if recover() != nil {
+			value, changeSTM, ok = 0, false, false
+		}
+	}()
+	value, changeSTM = t.probe(gs, wdl)
+	return value, changeSTM, true
+}
+
+// search finds a position's WDL result. The tables do not store values where
+// the side to move has a winning capture -- or, with checkZeroing, a winning
+// pawn move -- and may store a loss for a draw the side to move can reach by
+// capturing, since either choice compresses better; so the captures are
+// searched and the best of them and the table value is the result.
+func (tb *Tablebase) search(gs dao.GameState, checkZeroing bool) (WDL, tbState) {
+	best := WDLLoss
+	moves := sideToMoveMoves(gs)
+	searched := 0
+	for _, m := range moves {
+		if !isCapture(gs, m) && (!checkZeroing || gs.PawnBitboard&m.src == 0) {
+			continue
+		}
+		searched++
+		v, state := tb.search(applyBotMove(gs, m), false)
+		if state == tbFail {
+			return WDLDraw, tbFail
+		}
+		if v = -v; v > best {
+			best = v
+			if v >= WDLWin {
+				return v, tbZeroingBestMove
+			}
+		}
+	}
+
+	// With every move searched the table is not needed, and may be wrong:
+	// it ignores en passant, for one.
+	noMoreMoves := searched > 0 && searched == len(moves)
+	value := best
+	if !noMoreMoves {
+		v, state := tb.probeTable(gs, false, WDLDraw)
+		if state == tbFail {
+			return WDLDraw, tbFail
+		}
+		value = WDL(v)
+	}
+
+	if best >= value {
+		if best > WDLDraw || noMoreMoves {
+			return best, tbZeroingBestMove
+		}
+		return best, tbOK
+	}
+	return value, tbOK
+}
+
+// probeDTZ is ProbeDTZ without the checks.
+func (tb *Tablebase) probeDTZ(gs dao.GameState) (int, tbState) {
+	wdl, state := tb.search(gs, true)
+	if state == tbFail || wdl == WDLDraw {
+		return 0, state // the tables store no DTZ for draws
+	}
+	// The best move zeroes, so the table's value is a don't-care.
+	if state == tbZeroingBestMove {
+		return dtzBeforeZeroing(wdl), tbOK
+	}
+
+	dtz, state := tb.probeTable(gs, true, wdl)
+	if state == tbFail {
+		return 0, tbFail
+	}
+	if state != tbChangeSTM {
+		if wdl == WDLBlessedLoss || wdl == WDLCursedWin {
+			dtz += 100
+		}
+		if wdl < 0 {
+			dtz = -dtz
+		}
+		return dtz, tbOK
+	}
+
+	// The table has the other side to move: search a ply and take the move
+	// that wins fastest or loses slowest.
+	minDTZ := 0xFFFF
+	for _, m := range sideToMoveMoves(gs) {
+		zeroing := isCapture(gs, m) || gs.PawnBitboard&m.src != 0
+		child := applyBotMove(gs, m)
+
+		// A zeroing move's own DTZ is what it resets to; the position after
+		// it only gives the result.
+		if zeroing {
+			v, st := tb.search(child, false)
+			dtz, state = -dtzBeforeZeroing(v), st
+		} else {
+			dtz, state = tb.probeDTZ(child)
+			dtz = -dtz
+		}
+		if state == tbFail {
+			return 0, tbFail
+		}
+		if dtz == 1 && isMated(child) {
+			minDTZ = 1
+		}
+		if !zeroing {
+			dtz += sign(dtz)
+		}
+		if dtz < minDTZ && sign(dtz) == sign(int(wdl)) {
+			minDTZ = dtz
+		}
+	}
+	if minDTZ == 0xFFFF {
+		return -1, tbOK // no legal move: mated
+	}
+	return minDTZ, tbOK
+}
+
+// dtzBeforeZeroing is the DTZ of a position whose best move zeroes.
+func dtzBeforeZeroing(wdl WDL) int {
+	switch wdl {
+	case WDLWin:
+		return 1
+	case WDLCursedWin:
+		return 101
+	case WDLBlessedLoss:
+		return -101
+	case WDLLoss:
+		return -1
+	}
+	return 0
+}
+
+// rootMoves narrows moves to the best by the tables: the winning moves that
+// reach a capture or pawn move soonest, the drawing moves, or the losing moves
+// that put it off longest. A win the fifty-move counter would cut short counts
+// below a sure one. It reports false when the position cannot be probed.
+//
+// DTZ is used when the tables are there; with only WDL tables the moves are
+// ranked by result alone, which keeps a win but may not make progress.
+func (tb *Tablebase) rootMoves(gs dao.GameState, moves []botMove) ([]botMove, bool) {
+	if !tb.canProbe(gs) || len(moves) == 0 {
+		return moves, false
+	}
+	ranks := make([]int, len(moves))
+	dtzs := make([]int, len(moves))
+	useDTZ := true
+	for i, m := range moves {
+		child := applyBotMove(gs, m)
+		var dtz int
+		var state tbState
+		if child.HalfmoveClock == 0 {
+			var wdl WDL
+			wdl, state = tb.search(child, false)
+			dtz = dtzBeforeZeroing(-wdl)
+		} else {
+			dtz, state = tb.probeDTZ(child)
+			dtz = -dtz
+			dtz += sign(dtz)
+		}
+		if state == tbFail {
+			useDTZ = false
+			break
+		}
+		if dtz == 2 && isMated(child) {
+			dtz = 1
+		}
+		dtzs[i] = dtz
+		ranks[i] = dtzRank(dtz, gs.HalfmoveClock)
+	}
+	if !useDTZ {
+		for i, m := range moves {
+			wdl, ok := tb.ProbeWDL(applyBotMove(gs, m))
+			if !ok {
+				return moves, false
+			}
+			ranks[i] = -int(wdl)
+			dtzs[i] = 0
+		}
+	}
+
+	best := 0
+	for i := range moves {
+		if ranks[i] > ranks[best] || (ranks[i] == ranks[best] && dtzs[i] < dtzs[best]) {
+			best = i
+		}
+	}
+	var kept []botMove
+	for i, m := range moves {
+		if ranks[i] == ranks[best] && dtzs[i] == dtzs[best] {
+			kept = append(kept, m)
+		}
+	}
+	return kept, true
+}
+
+// dtzRank ranks a root move by its DTZ and the fifty-move counter: sure wins
+// highest, then wins the counter may cut short, draws, losses it may save,
+// and sure losses.
+func dtzRank(dtz, halfmoveClock int) int {
+	switch {
+	case dtz > 0 && dtz+halfmoveClock <= 99:
+		return 1000
+	case dtz > 0:
+		return 1000 - (dtz + halfmoveClock)
+	case dtz < 0 && -dtz*2+halfmoveClock < 100:
+		return -1000
+	case dtz < 0:
+		return -1000 + (-dtz + halfmoveClock)
+	}
+	return 0
+}
+
+// probeScore is the search score of an interior node from the tables, from
+// the side to move's point of view. It probes only right after a capture or
+// pawn move: the WDL tables ignore the fifty-move counter, which is zero
+// there, and that is where a position first drops into the tables.
+func (tb *Tablebase) probeScore(gs dao.GameState, ply int) (int, bool) {
+	if gs.HalfmoveClock != 0 {
+		return 0, false
+	}
+	wdl, ok := tb.ProbeWDL(gs)
+	if !ok {
+		return 0, false
+	}
+	switch wdl {
+	case WDLWin:
+		return tbWinScore - ply, true
+	case WDLLoss:
+		return -tbWinScore + ply, true
+	}
+	return 0, true // a draw, under the fifty-move rule too
+}
+
+// isCapture reports whether m takes a piece, en passant included.
+func isCapture(gs dao.GameState, m botMove) bool {
+	return m.dst&(gs.WhiteBitboard|gs.BlackBitboard) != 0 ||
+		(gs.PawnBitboard&m.src != 0 && m.dst&gs.EnPassant != 0)
+}
+
+// isMated reports whether the side to move is checkmated.
+func isMated(gs dao.GameState) bool {
+	return isKingInCheck(gs, gs.Turn != "b") && len(sideToMoveMoves(gs)) == 0
+}
+
+func sign(x int) int {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
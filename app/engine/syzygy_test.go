@@ -0,0 +1,229 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// syzygyTestdata holds the published 3- and 4-piece tables the probing tests
+// read; make syzygy-testdata fetches them.
+const syzygyTestdata = "testdata/syzygy"
+
+func openTestTablebase(t *testing.T, tables ...string) *Tablebase {
+	t.Helper()
+	for _, name := range tables {
+		if _, err := os.Stat(filepath.Join(syzygyTestdata, name)); err != nil {
+			t.Fatalf("%s missing (make syzygy-testdata): %v", name, err)
+		}
+	}
+	tb, err := OpenTablebase(syzygyTestdata)
+	if err != nil {
+		t.Fatalf("OpenTablebase: %v", err)
+	}
+	return tb
+}
+
+func TestTablebaseIndexTables(t *testing.T) {
+	max := 0
+	for idx := range tbMapKK {
+		for _, code := range tbMapKK[idx] {
+			if code > max {
+				max = code
+			}
+		}
+	}
+	if max != 461 {
+		t.Errorf("highest king pair code = %d, want 461", max)
+	}
+
+	seen := make(map[int]bool)
+	for sq := 8; sq < 56; sq++ {
+		seen[tbMapPawns[sq]] = true
+	}
+	if len(seen) != 48 {
+		t.Errorf("pawn squares have %d codes, want 48", len(seen))
+	}
+	if got := tbMapPawns[PositionToIndex("a2")]; got != 47 {
+		t.Errorf("tbMapPawns[a2] = %d, want 47", got)
+	}
+
+	if got := tbBinomial[3][8]; got != 56 {
+		t.Errorf("8 choose 3 = %d, want 56", got)
+	}
+	if got := tbLeadPawnsSize[1][0]; got != 6 {
+		t.Errorf("one leading pawn on the a-file: %d placements, want 6", got)
+	}
+}
+
+func TestMaterialName(t *testing.T) {
+	cases := []struct{ fen, want string }{
+		{"8/8/8/4k3/8/8/8/KR6 w - - 0 1", "KRvK"},
+		{"8/8/8/4k3/8/8/8/KR6 b - - 0 1", "KRvK"},
+		{"8/8/8/4kq2/8/8/8/K7 w - - 0 1", "KvKQ"},
+		{"8/4p3/8/4k3/8/8/3P4/KNB5 w - - 0 1", "KBNPvKP"},
+	}
+	for _, tc := range cases {
+		if got := materialName(mustFEN(t, tc.fen)); got != tc.want {
+			t.Errorf("materialName(%s) = %s, want %s", tc.fen, got, tc.want)
+		}
+	}
+}
+
+func TestOpenTablebase(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"KRvK.rtbw", "KRvK.rtbz", "KQvKR.rtbw", "KXvK.rtbw", "README"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tb, err := OpenTablebase(dir)
+	if err != nil {
+		t.Fatalf("OpenTablebase: %v", err)
+	}
+	if tb.MaxPieces != 4 {
+		t.Errorf("MaxPieces = %d, want 4", tb.MaxPieces)
+	}
+	for _, key := range []string{"KRvK", "KvKR", "KQvKR", "KRvKQ"} {
+		if tb.wdl[key] == nil {
+			t.Errorf("no WDL table for %s", key)
+		}
+	}
+	if len(tb.dtz) != 2 {
+		t.Errorf("%d DTZ keys, want 2 (KRvK both ways round)", len(tb.dtz))
+	}
+
+	// Bare kings need no table; anything else fails without a readable one.
+	if wdl, ok := tb.ProbeWDL(mustFEN(t, "8/8/8/4k3/8/8/8/K7 w - - 0 1")); !ok || wdl != WDLDraw {
+		t.Errorf("KvK = %d, %v; want a draw", wdl, ok)
+	}
+	if _, ok := tb.ProbeWDL(mustFEN(t, "8/8/8/4k3/8/8/8/KR6 w - - 0 1")); ok {
+		t.Error("probing an empty KRvK file succeeded")
+	}
+	if _, ok := tb.ProbeWDL(mustFEN(t, "8/8/8/4k3/8/8/8/KN6 w - - 0 1")); ok {
+		t.Error("probing KNvK with no table succeeded")
+	}
+
+	if _, err := OpenTablebase(t.TempDir()); err == nil {
+		t.Error("OpenTablebase of an empty directory succeeded")
+	}
+}
+
+func TestNilTablebase(t *testing.T) {
+	var tb *Tablebase
+	gs := mustFEN(t, "8/8/8/4k3/8/8/8/KR6 w - - 0 1")
+	if _, ok := tb.ProbeWDL(gs); ok {
+		t.Error("ProbeWDL on a nil tablebase succeeded")
+	}
+	moves := sideToMoveMoves(gs)
+	if kept, ok := tb.rootMoves(gs, moves); ok || len(kept) != len(moves) {
+		t.Errorf("rootMoves on a nil tablebase = %d moves, %v", len(kept), ok)
+	}
+}
+
+func TestProbeWDL(t *testing.T) {
+	tb := openTestTablebase(t, "KRvK.rtbw", "KNvK.rtbw", "KBvK.rtbw", "KQvK.rtbw", "KPvK.rtbw", "KQvKR.rtbw")
+	cases := []struct {
+		fen  string
+		want WDL
+	}{
+		{"8/8/8/4k3/8/8/8/KR6 w - - 0 1", WDLWin},
+		{"8/8/8/4k3/8/8/8/KR6 b - - 0 1", WDLLoss},
+		// Black takes the hanging rook.
+		{"8/8/8/8/8/8/2k5/K1R5 b - - 0 1", WDLDraw},
+		{"8/8/8/4k3/8/8/8/KN6 w - - 0 1", WDLDraw},
+		{"8/8/8/4k3/8/8/8/KB6 b - - 0 1", WDLDraw},
+		{"8/8/8/4k3/8/8/8/KQ6 b - - 0 1", WDLLoss},
+		// Stalemate.
+		{"k7/2Q5/1K6/8/8/8/8/8 b - - 0 1", WDLDraw},
+		// The king on the sixth in front of its pawn wins whoever is to move;
+		// with a rook's pawn it does not.
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", WDLWin},
+		{"4k3/8/4K3/4P3/8/8/8/8 b - - 0 1", WDLLoss},
+		{"k7/8/K7/P7/8/8/8/8 w - - 0 1", WDLDraw},
+		{"8/P7/8/8/8/8/8/K6k w - - 0 1", WDLWin},
+		{"8/8/8/4k3/8/3r4/8/KQ6 b - - 0 1", WDLLoss},
+		// The rook takes the queen and is taken: bare kings.
+		{"8/8/8/8/8/8/1r6/KQ5k b - - 0 1", WDLDraw},
+	}
+	for _, tc := range cases {
+		got, ok := tb.ProbeWDL(mustFEN(t, tc.fen))
+		if !ok || got != tc.want {
+			t.Errorf("ProbeWDL(%s) = %d, %v; want %d", tc.fen, got, ok, tc.want)
+		}
+	}
+}
+
+func TestTablebaseGroups(t *testing.T) {
+	tb := openTestTablebase(t, "KRvK.rtbw", "KQvKR.rtbw", "KPvK.rtbw")
+	cases := []struct {
+		key  string
+		lens []int
+		size uint64
+	}{
+		// Three unique pieces are placed together, the rest one group each.
+		{"KRvK", []int{3}, 31332},
+		{"KQvKR", []int{3, 1}, 31332 * 61},
+		// With pawns the leading pawn leads, on the a-file here.
+		{"KPvK", []int{1, 1, 1}, tbLeadPawnsSize[1][0] * 63 * 62},
+	}
+	for _, tc := range cases {
+		table := tb.wdl[tc.key]
+		if err := table.load(); err != nil {
+			t.Fatalf("%s: %v", tc.key, err)
+		}
+		d := table.get(0, 0)
+		n := 0
+		for d.groupLen[n] != 0 {
+			n++
+		}
+		if got := d.groupLen[:n]; fmt.Sprint(got) != fmt.Sprint(tc.lens) || d.groupIdx[n] != tc.size {
+			t.Errorf("%s: groups %v of %d positions, want %v of %d", tc.key, got, d.groupIdx[n], tc.lens, tc.size)
+		}
+	}
+}
+
+func TestProbeDTZ(t *testing.T) {
+	tb := openTestTablebase(t, "KRvK.rtbw", "KRvK.rtbz", "KQvK.rtbw", "KQvK.rtbz",
+		"KPvK.rtbw", "KPvK.rtbz", "KQvKR.rtbw", "KQvKR.rtbz")
+	// Wins are checked with an odd distance only: a table that counts in
+	// moves rather than plies rounds the others.
+	cases := []struct {
+		fen  string
+		want int
+	}{
+		// Mate in one: the next move zeroes nothing, it ends the game.
+		{"k7/8/1K6/8/8/8/8/7R w - - 0 1", 1},
+		{"k7/8/1K6/8/8/8/8/2Q5 w - - 0 1", 1},
+		// Mated: the loss is immediate.
+		{"R1k5/8/2K5/8/8/8/8/8 b - - 0 1", -1},
+		{"8/8/8/4k3/8/8/8/KR6 w - - 0 1", 29},
+		// A pawn move zeroes the counter.
+		{"8/8/8/8/8/8/4P3/4K2k w - - 0 1", 1},
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", 3},
+		// So does taking the rook.
+		{"8/8/8/4k3/8/3r4/8/KQ6 w - - 0 1", 1},
+		{"k7/8/K7/P7/8/8/8/8 w - - 0 1", 0},
+	}
+	for _, tc := range cases {
+		if dtz, ok := tb.ProbeDTZ(mustFEN(t, tc.fen)); !ok || dtz != tc.want {
+			t.Errorf("ProbeDTZ(%s) = %d, %v; want %d", tc.fen, dtz, ok, tc.want)
+		}
+	}
+
+	gs := mustFEN(t, "k7/8/1K6/8/8/8/8/7R w - - 0 1")
+	kept, ok := tb.rootMoves(gs, sideToMoveMoves(gs))
+	if !ok || len(kept) != 1 || botMoveToDTO(gs, kept[0]).Source != "h1" || botMoveToDTO(gs, kept[0]).Destination != "h8" {
+		t.Errorf("rootMoves = %v, %v; want only h1h8", kept, ok)
+	}
+}
+
+func TestSearchUsesTablebase(t *testing.T) {
+	tb := openTestTablebase(t, "KRvK.rtbw", "KRvK.rtbz")
+	gs := mustFEN(t, "k7/8/1K6/8/8/8/8/7R w - - 0 1")
+	res := Search(gs, SearchOptions{MaxDepth: 2, Tablebase: tb}, nil)
+	if res.Best.Source != "h1" || res.Best.Destination != "h8" {
+		t.Errorf("best move %s%s, want h1h8", res.Best.Source, res.Best.Destination)
+	}
+}
@@ -1,6 +1,9 @@
 package engine
 
-import "math/bits"
+import (
+	"math/bits"
+	"sync/atomic"
+)
 
 // Transposition table.
 //
@@ -9,8 +12,13 @@ import "math/bits"
 // whole tree at every depth. Without a table all of that is recomputed.
 //
 // The table is owned by the caller and passed in through SearchOptions rather
-// than living in a package variable, because the web server runs searches for
-// several games concurrently and a shared table would be a data race.
+// than living in a package variable, so separate searches -- the web server
+// runs one per game -- keep separate tables. The threads of one search
+// (SearchOptions.Threads) share one, which is how they help each other, so
+// the table takes concurrent readers and writers without a lock: each entry
+// is two words written and read atomically, the packed data and the key
+// XORed with it. A reader that catches a slot halfway through a write sees
+// the checksum fail and treats it as a miss, which for a cache is harmless.
 
 type ttFlag uint8
 
@@ -20,17 +28,55 @@ const (
 	ttUpper               // score is an upper bound (fail-low)
 )
 
+// ttEntry is one slot. data packs the entry (see packEntry); check is the
+// position key XOR data.
 type ttEntry struct {
-	key   uint64
-	src   uint64
-	dst   uint64
-	score int32
-	depth int8
-	flag  ttFlag
-	promo byte
+	check atomic.Uint64
+	data  atomic.Uint64
+}
+
+// The layout of ttEntry.data, low bits first: from and to squares (6 bits
+// each), whether there is a move, the flag (2 bits), depth (8), the
+// promotion piece letter (8) and the score (32).
+const (
+	ttHasMove    = 1 << 12
+	ttFlagShift  = 13
+	ttDepthShift = 16
+	ttPromoShift = 24
+	ttScoreShift = 32
+)
+
+func packEntry(depth, score int, flag ttFlag, best botMove) uint64 {
+	data := uint64(uint8(int8(depth)))<<ttDepthShift |
+		uint64(flag)<<ttFlagShift |
+		uint64(uint32(int32(score)))<<ttScoreShift
+	if best.src != 0 {
+		data |= uint64(bits.TrailingZeros64(best.src)) |
+			uint64(bits.TrailingZeros64(best.dst))<<6 |
+			ttHasMove
+		if best.promo != "" {
+			data |= uint64(best.promo[0]) << ttPromoShift
+		}
+	}
+	return data
+}
+
+func unpackEntry(data uint64) (depth, score int, flag ttFlag, move botMove, hasMove bool) {
+	depth = int(int8(data >> ttDepthShift))
+	score = int(int32(uint32(data >> ttScoreShift)))
+	flag = ttFlag(data>>ttFlagShift) & 3
+	if data&ttHasMove != 0 {
+		move = botMove{src: 1 << (data & 63), dst: 1 << (data >> 6 & 63)}
+		if promo := byte(data >> ttPromoShift); promo != 0 {
+			move.promo = string([]byte{promo})
+		}
+		hasMove = true
+	}
+	return depth, score, flag, move, hasMove
 }
 
-// TranspositionTable is a fixed-size, always-replace hash table.
+// TranspositionTable is a fixed-size hash table that prefers deeper entries,
+// safe for the threads of one search to share.
 type TranspositionTable struct {
 	entries []ttEntry
 	mask    uint64
@@ -42,7 +88,7 @@ func NewTranspositionTable(sizeMB int) *TranspositionTable {
 	if sizeMB < 1 {
 		sizeMB = 1
 	}
-	const entrySize = 40 // approximate; only used to pick a count
+	const entrySize = 16
 	count := uint64(sizeMB) * 1024 * 1024 / entrySize
 	if count < 1024 {
 		count = 1024
@@ -57,16 +103,24 @@ func NewTranspositionTable(sizeMB int) *TranspositionTable {
 }
 
 // Clear empties the table. Called for "ucinewgame" so a new game does not
-// inherit the previous one's entries.
+// inherit the previous one's entries. It must not run during a search.
 func (t *TranspositionTable) Clear() {
 	if t == nil {
 		return
 	}
 	for i := range t.entries {
-		t.entries[i] = ttEntry{}
+		t.entries[i].data.Store(0)
+		t.entries[i].check.Store(0)
 	}
 }
 
+// load reads the slot for key, reporting false unless it holds key intact.
+func (t *TranspositionTable) load(key uint64) (uint64, bool) {
+	e := &t.entries[key&t.mask]
+	data := e.data.Load()
+	return data, e.check.Load()^data == key
+}
+
 // probe looks up a position. It returns the stored best move (for ordering)
 // whenever the key matches, and a usable score only when the entry was searched
 // at least as deep as this node needs and its bound permits a cutoff.
@@ -74,28 +128,20 @@ func (t *TranspositionTable) probe(key uint64, depth, alpha, beta int) (move bot
 	if t == nil {
 		return botMove{}, false, 0, false
 	}
-	e := &t.entries[key&t.mask]
-	if e.key != key {
+	data, ok := t.load(key)
+	if !ok {
 		return botMove{}, false, 0, false
 	}
-
-	if e.src != 0 {
-		promo := ""
-		if e.promo != 0 {
-			promo = string([]byte{e.promo})
-		}
-		move, hasMove = botMove{src: e.src, dst: e.dst, promo: promo}, true
-	}
+	eDepth, s, flag, move, hasMove := unpackEntry(data)
 
 	// Mate scores encode a distance, which is relative to where they were found;
 	// reusing one at a different point in the tree reports the wrong mate
 	// distance. Bounds from mate scores are skipped rather than corrected.
-	if int(e.depth) < depth || isMateScore(int(e.score)) {
+	if eDepth < depth || isMateScore(s) {
 		return move, hasMove, 0, false
 	}
 
-	s := int(e.score)
-	switch e.flag {
+	switch flag {
 	case ttExact:
 		return move, hasMove, s, true
 	case ttLower:
@@ -120,23 +166,14 @@ func (t *TranspositionTable) store(key uint64, depth int, score int, flag ttFlag
 	if t == nil {
 		return
 	}
-	if e := &t.entries[key&t.mask]; e.key == key && int(e.depth) > depth {
+	if data, ok := t.load(key); ok && int(int8(data>>ttDepthShift)) > depth {
 		return
 	}
 	if depth > 127 {
 		depth = 127
 	}
-	var promo byte
-	if best.promo != "" {
-		promo = best.promo[0]
-	}
-	t.entries[key&t.mask] = ttEntry{
-		key:   key,
-		src:   best.src,
-		dst:   best.dst,
-		score: int32(score),
-		depth: int8(depth),
-		flag:  flag,
-		promo: promo,
-	}
+	data := packEntry(depth, score, flag, best)
+	e := &t.entries[key&t.mask]
+	e.data.Store(data)
+	e.check.Store(key ^ data)
 }
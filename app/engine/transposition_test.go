@@ -0,0 +1,34 @@
+package engine
+
+import "testing"
+
+func TestTranspositionTableRoundTrip(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	move := botMove{src: squareBit("e7"), dst: squareBit("e8"), promo: "n"}
+	tt.store(0x1234, 5, -250, ttExact, move)
+
+	got, hasMove, score, usable := tt.probe(0x1234, 5, -searchInf, searchInf)
+	if !hasMove || got != move || !usable || score != -250 {
+		t.Fatalf("probe = %v %v %d %v, want %v true -250 true", got, hasMove, score, usable, move)
+	}
+	if _, _, _, usable := tt.probe(0x1234, 6, -searchInf, searchInf); usable {
+		t.Error("a depth-5 entry was usable for a depth-6 node")
+	}
+	// Same slot, different position.
+	if _, hasMove, _, _ := tt.probe(0x1234+tt.mask+1, 0, -searchInf, searchInf); hasMove {
+		t.Error("probe matched an entry stored for another key")
+	}
+}
+
+// A slot whose words disagree -- what a reader sees if it races a writer --
+// fails its checksum and reads as empty.
+func TestTranspositionTableRejectsTornEntry(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	tt.store(0x1234, 3, 40, ttExact, botMove{src: squareBit("g1"), dst: squareBit("f3")})
+	e := &tt.entries[0x1234&tt.mask]
+	e.data.Store(packEntry(9, 900, ttExact, botMove{}))
+
+	if _, hasMove, _, usable := tt.probe(0x1234, 0, -searchInf, searchInf); hasMove || usable {
+		t.Error("a torn entry was read as valid")
+	}
+}
@@ -5,20 +5,25 @@ import (
 	"chess-engine/app/domain/dao"
 	"chess-engine/app/pkg"
 	"chess-engine/app/repository"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-// authUserKey is the gin context key holding the authenticated user.
-const authUserKey = "authUser"
+// Gin context keys holding the authenticated user and their session.
+const (
+	authUserKey    = "authUser"
+	authSessionKey = "authSession"
+)
 
-// RequireAuth resolves the bearer token into a user and aborts with 401 if it
-// does not match one. Routes that mutate or expose a specific user's data must
-// sit behind this: PUT and DELETE /api/user/:userID previously accepted any
-// caller and let anyone delete any account by guessing an integer id.
-func RequireAuth(userRepo repository.UserRepository) gin.HandlerFunc {
+// RequireAuth resolves the bearer token into a session and aborts with 401 if
+// it does not match a live one. Routes that mutate or expose a specific user's
+// data must sit behind this: PUT and DELETE /api/user/:userID previously
+// accepted any caller and let anyone delete any account by guessing an
+// integer id.
+func RequireAuth(sessionRepo repository.SessionRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := bearerToken(c)
 		if token == "" {
@@ -26,13 +31,52 @@ func RequireAuth(userRepo repository.UserRepository) gin.HandlerFunc {
 			return
 		}
 
-		user, err := userRepo.FindUserByToken(token)
-		if err != nil || user.ID == 0 {
-			abortUnauthorized(c, "invalid token")
+		session, err := sessionRepo.FindSessionByToken(token)
+		if errors.Is(err, repository.ErrUserBanned) {
+			abortForbidden(c, "account is banned")
+			return
+		}
+		if err != nil || session.User.ID == 0 {
+			abortUnauthorized(c, "invalid or expired token")
 			return
 		}
 
-		c.Set(authUserKey, user)
+		c.Set(authUserKey, session.User)
+		c.Set(authSessionKey, session)
+		c.Next()
+	}
+}
+
+// OptionalAuth is RequireAuth for routes open to everyone that treat a
+// signed-in caller differently: a request without a token goes through
+// anonymously, and AuthUser then reports false. A request with a token that
+// does not resolve is still refused, so a client whose session has lapsed is
+// told so rather than quietly served as a stranger.
+func OptionalAuth(sessionRepo repository.SessionRepository) gin.HandlerFunc {
+	requireAuth := RequireAuth(sessionRepo)
+	return func(c *gin.Context) {
+		if bearerToken(c) == "" {
+			c.Next()
+			return
+		}
+		requireAuth(c)
+	}
+}
+
+// RequireRole lets the request through only if the user RequireAuth resolved
+// holds role or one above it (see constant.Roles). It must come after
+// RequireAuth.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := AuthUser(c)
+		if !ok {
+			abortUnauthorized(c, "missing authentication")
+			return
+		}
+		if constant.RoleRank(user.RoleName()) < constant.RoleRank(role) {
+			abortForbidden(c, "requires the "+role+" role")
+			return
+		}
 		c.Next()
 	}
 }
@@ -48,6 +92,16 @@ func AuthUser(c *gin.Context) (dao.User, bool) {
 	return user, ok
 }
 
+// AuthSession returns the session RequireAuth resolved the token to.
+func AuthSession(c *gin.Context) (dao.Session, bool) {
+	v, exists := c.Get(authSessionKey)
+	if !exists {
+		return dao.Session{}, false
+	}
+	session, ok := v.(dao.Session)
+	return session, ok
+}
+
 // bearerToken reads the token from "Authorization: Bearer <token>", falling back
 // to the X-Auth-Token header.
 func bearerToken(c *gin.Context) string {
@@ -71,3 +125,9 @@ func abortUnauthorized(c *gin.Context, msg string) {
 		constant.Unauthorized.GetResponseStatus(), msg, pkg.Null(),
 	))
 }
+
+func abortForbidden(c *gin.Context, msg string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, pkg.BuildResponse_(
+		constant.Forbidden.GetResponseStatus(), msg, pkg.Null(),
+	))
+}
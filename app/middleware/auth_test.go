@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"chess-engine/app/constant"
 	"chess-engine/app/domain/dao"
+	"chess-engine/app/repository"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -10,22 +12,39 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// stubUserRepo resolves exactly one token.
-type stubUserRepo struct{ validToken string }
+// stubSessionRepo resolves validToken to a player, modToken to a moderator and
+// bannedToken to a banned user.
+type stubSessionRepo struct{ validToken string }
 
-func (s stubUserRepo) FindUserByToken(token string) (dao.User, error) {
-	if token == s.validToken {
-		return dao.User{ID: 42, Name: "owner", Token: token}, nil
+const (
+	modToken    = "moderator-token"
+	bannedToken = "banned-token"
+)
+
+func (s stubSessionRepo) FindSessionByToken(token string) (dao.Session, error) {
+	switch token {
+	case s.validToken:
+		return dao.Session{ID: 3, UserID: 42, User: dao.User{ID: 42, Name: "owner"}}, nil
+	case modToken:
+		role := dao.Role{ID: 2, Role: constant.RoleModerator}
+		return dao.Session{ID: 4, UserID: 43, User: dao.User{ID: 43, Name: "mod", Role: &role}}, nil
+	case bannedToken:
+		return dao.Session{}, repository.ErrUserBanned
 	}
-	return dao.User{}, errors.New("not found")
+	return dao.Session{}, errors.New("not found")
 }
 
-func (s stubUserRepo) FindAllUser() ([]dao.User, error)      { return nil, nil }
-func (s stubUserRepo) FindUserById(id int) (dao.User, error) { return dao.User{}, nil }
-func (s stubUserRepo) Save(u *dao.User) (dao.User, error)    { return *u, nil }
-func (s stubUserRepo) DeleteUserById(id int) error           { return nil }
+func (s stubSessionRepo) CreateSession(int, string) (dao.Session, string, error) {
+	return dao.Session{}, "", nil
+}
+func (s stubSessionRepo) RotateSession(dao.Session) (dao.Session, string, error) {
+	return dao.Session{}, "", nil
+}
+func (s stubSessionRepo) FindUserSessions(int) ([]dao.Session, error) { return nil, nil }
+func (s stubSessionRepo) RevokeSession(int, int) (bool, error)        { return false, nil }
+func (s stubSessionRepo) RevokeOtherSessions(int, int) error          { return nil }
 
-func newTestRouter(repo stubUserRepo) *gin.Engine {
+func newTestRouter(repo stubSessionRepo) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 	r.GET("/protected", RequireAuth(repo), func(c *gin.Context) {
@@ -34,6 +53,10 @@ func newTestRouter(repo stubUserRepo) *gin.Engine {
 			c.String(http.StatusInternalServerError, "no user in context")
 			return
 		}
+		if s, ok := AuthSession(c); !ok || s.UserID != u.ID {
+			c.String(http.StatusInternalServerError, "no session in context")
+			return
+		}
 		c.String(http.StatusOK, u.Name)
 	})
 	return r
@@ -41,7 +64,7 @@ func newTestRouter(repo stubUserRepo) *gin.Engine {
 
 func TestRequireAuth(t *testing.T) {
 	const good = "valid-token"
-	r := newTestRouter(stubUserRepo{validToken: good})
+	r := newTestRouter(stubSessionRepo{validToken: good})
 
 	cases := []struct {
 		name     string
@@ -58,6 +81,7 @@ func TestRequireAuth(t *testing.T) {
 		{"valid bearer", "Authorization", "Bearer " + good, http.StatusOK},
 		{"case-insensitive scheme", "Authorization", "bearer " + good, http.StatusOK},
 		{"x-auth-token fallback", "X-Auth-Token", good, http.StatusOK},
+		{"banned", "Authorization", "Bearer " + bannedToken, http.StatusForbidden},
 	}
 
 	for _, c := range cases {
@@ -78,3 +102,80 @@ func TestRequireAuth(t *testing.T) {
 		})
 	}
 }
+
+func TestOptionalAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/open", OptionalAuth(stubSessionRepo{validToken: "player-token"}), func(c *gin.Context) {
+		if u, ok := AuthUser(c); ok {
+			c.String(http.StatusOK, u.Name)
+			return
+		}
+		c.String(http.StatusOK, "anonymous")
+	})
+
+	cases := []struct {
+		name     string
+		token    string
+		wantCode int
+		wantBody string
+	}{
+		{"anonymous", "", http.StatusOK, "anonymous"},
+		{"signed in", "player-token", http.StatusOK, "owner"},
+		{"lapsed token", "nope", http.StatusUnauthorized, ""},
+		{"banned", bannedToken, http.StatusForbidden, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/open", nil)
+			if c.token != "" {
+				req.Header.Set("Authorization", "Bearer "+c.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != c.wantCode {
+				t.Errorf("status = %d, want %d (body %q)", w.Code, c.wantCode, w.Body.String())
+			}
+			if c.wantBody != "" && w.Body.String() != c.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), c.wantBody)
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	repo := stubSessionRepo{validToken: "player-token"}
+	r.GET("/mod", RequireAuth(repo), RequireRole(constant.RoleModerator), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/admin", RequireAuth(repo), RequireRole(constant.RoleAdmin), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	cases := []struct {
+		name     string
+		path     string
+		token    string
+		wantCode int
+	}{
+		{"player on moderator route", "/mod", "player-token", http.StatusForbidden},
+		{"moderator on moderator route", "/mod", modToken, http.StatusOK},
+		{"moderator on admin route", "/admin", modToken, http.StatusForbidden},
+		{"anonymous", "/mod", "", http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, c.path, nil)
+			if c.token != "" {
+				req.Header.Set("Authorization", "Bearer "+c.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != c.wantCode {
+				t.Errorf("status = %d, want %d (body %q)", w.Code, c.wantCode, w.Body.String())
+			}
+		})
+	}
+}
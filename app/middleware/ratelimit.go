@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/pkg"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit allows each client IP burst requests at once and then one every
+// per/burst, refilling continuously; past that it answers 429 with a
+// Retry-After. It is for routes that are costly to serve to anyone who asks,
+// such as sign-in and registration, which each hash a password.
+//
+// The counts are in memory, so each server instance limits on its own.
+func RateLimit(burst int, per time.Duration) gin.HandlerFunc {
+	l := &rateLimiter{
+		burst:    float64(burst),
+		interval: per / time.Duration(burst),
+		buckets:  make(map[string]*rateBucket),
+		now:      time.Now,
+	}
+	return l.handle
+}
+
+// rateLimiter is a token bucket per client IP.
+type rateLimiter struct {
+	burst    float64
+	interval time.Duration // time to earn one token back
+
+	mu        sync.Mutex
+	buckets   map[string]*rateBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type rateBucket struct {
+	tokens float64
+	at     time.Time // when tokens was last brought up to date
+}
+
+func (l *rateLimiter) handle(c *gin.Context) {
+	if wait := l.take(c.ClientIP()); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, pkg.BuildResponse_(
+			constant.ServiceBusy.GetResponseStatus(), "too many requests, try again shortly", pkg.Null(),
+		))
+		return
+	}
+	c.Next()
+}
+
+// take spends one of key's tokens, returning 0, or how long until one is
+// available if there is none.
+func (l *rateLimiter) take(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rateBucket{tokens: l.burst, at: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+float64(now.Sub(b.at))/float64(l.interval))
+	b.at = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) * float64(l.interval))
+	}
+	b.tokens--
+	return 0
+}
+
+// sweep drops the buckets that have refilled, which are the same as no bucket,
+// so clients that have gone away do not stay in memory. It runs at most once
+// per refill of a whole bucket.
+func (l *rateLimiter) sweep(now time.Time) {
+	full := time.Duration(l.burst * float64(l.interval))
+	if now.Sub(l.lastSweep) < full {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.at) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
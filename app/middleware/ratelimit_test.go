@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/login", RateLimit(3, time.Minute), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	post := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := post("192.0.2.1:1000"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i+1, w.Code)
+		}
+	}
+	w := post("192.0.2.1:1001")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request past the burst: status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "20" {
+		t.Errorf("Retry-After = %q, want 20", got)
+	}
+	if w := post("192.0.2.2:1000"); w.Code != http.StatusOK {
+		t.Errorf("another client: status = %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := &rateLimiter{
+		burst:    2,
+		interval: 30 * time.Second,
+		buckets:  make(map[string]*rateBucket),
+		now:      func() time.Time { return now },
+	}
+
+	if l.take("a") != 0 || l.take("a") != 0 {
+		t.Fatal("the burst was refused")
+	}
+	if wait := l.take("a"); wait != 30*time.Second {
+		t.Errorf("wait = %v, want 30s", wait)
+	}
+
+	now = now.Add(10 * time.Second)
+	if wait := l.take("a"); wait != 20*time.Second {
+		t.Errorf("wait after 10s = %v, want 20s", wait)
+	}
+	now = now.Add(20 * time.Second)
+	if wait := l.take("a"); wait != 0 {
+		t.Errorf("a token earned back was refused: wait %v", wait)
+	}
+	if wait := l.take("a"); wait == 0 {
+		t.Error("the earned token was spent twice")
+	}
+
+	// A client idle long enough to refill is forgotten.
+	now = now.Add(time.Hour)
+	l.take("b")
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("a refilled bucket was kept")
+	}
+	if len(l.buckets) != 1 {
+		t.Errorf("%d buckets, want 1", len(l.buckets))
+	}
+}
@@ -39,6 +39,10 @@ func PanicHandler(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, BuildResponse_(key, msg, Null()))
 	case constant.Unauthorized.GetResponseStatus():
 		c.AbortWithStatusJSON(http.StatusUnauthorized, BuildResponse_(key, msg, Null()))
+	case constant.Forbidden.GetResponseStatus():
+		c.AbortWithStatusJSON(http.StatusForbidden, BuildResponse_(key, msg, Null()))
+	case constant.ServiceBusy.GetResponseStatus():
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, BuildResponse_(key, msg, Null()))
 	case constant.UnknownError.GetResponseStatus():
 		c.AbortWithStatusJSON(http.StatusInternalServerError, BuildResponse_(key, msg, Null()))
 	default:
@@ -25,6 +25,8 @@ func TestPanicHandlerSurvivesArbitraryPanicValues(t *testing.T) {
 		{"nil map write", map[string]string(nil), http.StatusInternalServerError},
 		{"known key", "DATA_NOT_FOUND: nope", http.StatusBadRequest},
 		{"unauthorized", "UNAUTHORIZED: nope", http.StatusUnauthorized},
+		{"busy", "SERVICE_BUSY: later", http.StatusServiceUnavailable},
+		{"forbidden", "FORBIDDEN: nope", http.StatusForbidden},
 	}
 
 	for _, c := range cases {
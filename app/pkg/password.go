@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Password hashing with argon2id, stored in the PHC string format
+// ("$argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>") so the parameters travel
+// with each hash and can be raised later without invalidating old ones.
+//
+// The parameters are RFC 9106's second recommended option (64 MiB, one pass)
+// with four lanes.
+const (
+	argon2Memory  = 64 * 1024
+	argon2Time    = 1
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+	// argon2Concurrency is how many hashes may run at once. Each holds
+	// argon2Memory (64 MiB) until it finishes, so a burst of sign-ins would
+	// otherwise take memory without bound; past the limit they queue.
+	argon2Concurrency = 4
+)
+
+// argon2Slots is the semaphore bounding concurrent hashes.
+var argon2Slots = make(chan struct{}, argon2Concurrency)
+
+// idKey is argon2.IDKey holding one of argon2Slots.
+func idKey(password, salt []byte, time, memory uint32, threads uint8, keyLen uint32) []byte {
+	argon2Slots <- struct{}{}
+	defer func() { <-argon2Slots }()
+	return argon2.IDKey(password, salt, time, memory, threads, keyLen)
+}
+
+// HashPassword returns the argon2id hash of password with a fresh random salt.
+func HashPassword(password string) string {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		panic("pkg: crypto/rand unavailable: " + err.Error())
+	}
+	key := idKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// CheckPassword reports whether password matches a hash from HashPassword. An
+// empty or malformed hash matches nothing.
+func CheckPassword(password, hash string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false
+	}
+	var version int
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false
+	}
+	salt, err1 := base64.RawStdEncoding.DecodeString(parts[4])
+	want, err2 := base64.RawStdEncoding.DecodeString(parts[5])
+	if err1 != nil || err2 != nil || len(want) == 0 {
+		return false
+	}
+	got := idKey([]byte(password), salt, time, memory, threads, uint32(len(want)))
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
@@ -0,0 +1,65 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashPasswordRoundTrip(t *testing.T) {
+	hash := HashPassword("correct horse battery staple")
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=1,p=4$") {
+		t.Errorf("hash = %q, want the PHC argon2id format", hash)
+	}
+	if !CheckPassword("correct horse battery staple", hash) {
+		t.Error("the right password was rejected")
+	}
+	if CheckPassword("correct horse battery stapler", hash) {
+		t.Error("a wrong password was accepted")
+	}
+	if HashPassword("correct horse battery staple") == hash {
+		t.Error("two hashes of one password should differ by salt")
+	}
+}
+
+func TestCheckPasswordRejectsMalformedHashes(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"plaintext",
+		"$argon2i$v=19$m=65536,t=1,p=4$c2FsdA$aGFzaA",
+		"$argon2id$v=18$m=65536,t=1,p=4$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=65536,t=1,p=4$c2FsdA$",
+		"$argon2id$v=19$m=x,t=1,p=4$c2FsdA$aGFzaA",
+	} {
+		if CheckPassword("", hash) {
+			t.Errorf("CheckPassword accepted the malformed hash %q", hash)
+		}
+	}
+}
+
+func TestHashingIsBounded(t *testing.T) {
+	// Take every slot: a hash must then wait for one to come free.
+	for i := 0; i < argon2Concurrency; i++ {
+		argon2Slots <- struct{}{}
+	}
+	done := make(chan string)
+	go func() { done <- HashPassword("queued") }()
+	select {
+	case <-done:
+		t.Fatal("a hash ran with every slot taken")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	<-argon2Slots
+	select {
+	case hash := <-done:
+		if !CheckPassword("queued", hash) {
+			t.Error("the queued hash does not verify")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the queued hash never ran once a slot was freed")
+	}
+	for i := 1; i < argon2Concurrency; i++ {
+		<-argon2Slots
+	}
+}
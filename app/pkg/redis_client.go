@@ -2,6 +2,7 @@ package pkg
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -59,3 +60,149 @@ func (r *RedisClient) Delete(key string) error {
 	}
 	return nil
 }
+
+// ScoredMember is one member of a sorted set with its score.
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// ZAdd adds member to the sorted set key with score, or moves it there.
+func (r *RedisClient) ZAdd(key string, score float64, member string) error {
+	err := r.client.ZAdd(ctx, key, &redis.Z{Score: score, Member: member}).Err()
+	if err != nil {
+		log.Errorf("Failed to add to sorted set %s in Redis: %v", key, err)
+		return err
+	}
+	return nil
+}
+
+// ZRem removes member from the sorted set key.
+func (r *RedisClient) ZRem(key, member string) error {
+	err := r.client.ZRem(ctx, key, member).Err()
+	if err != nil {
+		log.Errorf("Failed to remove from sorted set %s in Redis: %v", key, err)
+		return err
+	}
+	return nil
+}
+
+// ZRevRange returns the members of the sorted set key ranked start to stop,
+// both inclusive, highest score first.
+func (r *RedisClient) ZRevRange(key string, start, stop int64) ([]ScoredMember, error) {
+	zs, err := r.client.ZRevRangeWithScores(ctx, key, start, stop).Result()
+	if err != nil {
+		log.Errorf("Failed to read sorted set %s from Redis: %v", key, err)
+		return nil, err
+	}
+	members := make([]ScoredMember, len(zs))
+	for i, z := range zs {
+		members[i] = ScoredMember{Member: fmt.Sprint(z.Member), Score: z.Score}
+	}
+	return members, nil
+}
+
+// HSet sets field of the hash key.
+func (r *RedisClient) HSet(key, field string, value interface{}) error {
+	err := r.client.HSet(ctx, key, field, value).Err()
+	if err != nil {
+		log.Errorf("Failed to set field %s of hash %s in Redis: %v", field, key, err)
+		return err
+	}
+	return nil
+}
+
+// HMGet returns fields of the hash key, in order; a missing field is "".
+func (r *RedisClient) HMGet(key string, fields ...string) ([]string, error) {
+	vals, err := r.client.HMGet(ctx, key, fields...).Result()
+	if err != nil {
+		log.Errorf("Failed to get fields of hash %s from Redis: %v", key, err)
+		return nil, err
+	}
+	out := make([]string, len(vals))
+	for i, v := range vals {
+		if s, ok := v.(string); ok {
+			out[i] = s
+		}
+	}
+	return out, nil
+}
+
+// Rename renames key to newKey, replacing whatever newKey held.
+func (r *RedisClient) Rename(key, newKey string) error {
+	err := r.client.Rename(ctx, key, newKey).Err()
+	if err != nil {
+		log.Errorf("Failed to rename key %s in Redis: %v", key, err)
+		return err
+	}
+	return nil
+}
+
+// redisBatch is how many members or fields go in one ZADD or HSET, so a
+// large batch is neither one round trip per item nor one huge command.
+const redisBatch = 500
+
+// ReplaceSortedSet replaces the sorted set key and the hash infoKey beside it
+// with members and info, which holds a field per member. Everything is sent in
+// one pipeline, written under scratch keys and renamed into place, so readers
+// see the old pair or the new one, never half of either.
+func (r *RedisClient) ReplaceSortedSet(key, infoKey string, members []ScoredMember, info map[string]string) error {
+	scratch, scratchInfo := key+":rebuild", infoKey+":rebuild"
+	_, err := r.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, scratch, scratchInfo)
+		for start := 0; start < len(members); start += redisBatch {
+			batch := members[start:min(start+redisBatch, len(members))]
+			zs := make([]*redis.Z, len(batch))
+			for i, m := range batch {
+				zs[i] = &redis.Z{Score: m.Score, Member: m.Member}
+			}
+			p.ZAdd(ctx, scratch, zs...)
+		}
+		fields := make([]interface{}, 0, 2*redisBatch)
+		for field, value := range info {
+			fields = append(fields, field, value)
+			if len(fields) == 2*redisBatch {
+				p.HSet(ctx, scratchInfo, fields...)
+				fields = fields[:0]
+			}
+		}
+		if len(fields) > 0 {
+			p.HSet(ctx, scratchInfo, fields...)
+		}
+		if len(members) == 0 {
+			p.Del(ctx, key, infoKey)
+			return nil
+		}
+		p.Rename(ctx, scratchInfo, infoKey)
+		p.Rename(ctx, scratch, key)
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Failed to replace sorted set %s in Redis: %v", key, err)
+		return err
+	}
+	return nil
+}
+
+// removeIfUnchanged drops a member from a sorted set and its info hash, but
+// only while the hash still holds the value the caller read: a write since
+// means the member is current again.
+var removeIfUnchanged = redis.NewScript(`
+if redis.call("HGET", KEYS[2], ARGV[1]) == ARGV[2] then
+	redis.call("ZREM", KEYS[1], ARGV[1])
+	redis.call("HDEL", KEYS[2], ARGV[1])
+	return 1
+end
+return 0`)
+
+// ZRemIfUnchanged removes member from the sorted set key and the hash infoKey
+// beside it if infoKey's field for member still holds info, and reports
+// whether it did.
+func (r *RedisClient) ZRemIfUnchanged(key, infoKey, member, info string) (bool, error) {
+	removed, err := removeIfUnchanged.Run(ctx, r.client, []string{key, infoKey}, member, info).Int()
+	if err != nil {
+		log.Errorf("Failed to remove from sorted set %s in Redis: %v", key, err)
+		return false, err
+	}
+	return removed == 1, nil
+}
@@ -0,0 +1,141 @@
+// Package rating implements the Glicko-2 rating system (Glickman, "Example of
+// the Glicko-2 system", 2013), the same model Lichess uses. It is pure
+// arithmetic: storing ratings and deciding which games count is up to the
+// caller.
+package rating
+
+import "math"
+
+// Defaults for a player with no rated games, and the system constant tau,
+// which limits how fast volatility can change. Glickman suggests 0.3 to 1.2.
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+	Tau               = 0.5
+
+	// scale converts between the Glicko display scale and Glicko-2's internal
+	// one.
+	scale = 173.7178
+	// epsilon is the convergence tolerance of the volatility iteration.
+	epsilon = 0.000001
+	// maxDeviation caps how uncertain inactivity can make a rating: never more
+	// than a newcomer's.
+	maxDeviation = DefaultDeviation
+	// minDeviation keeps a very active player's rating from freezing solid.
+	minDeviation = 45.0
+)
+
+// Rating is a player's Glicko-2 state on the display scale.
+type Rating struct {
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+}
+
+// Default is the rating of a player with no games.
+func Default() Rating {
+	return Rating{Rating: DefaultRating, Deviation: DefaultDeviation, Volatility: DefaultVolatility}
+}
+
+// Result is one game against an opponent: Score is 1 for a win, 0.5 for a
+// draw and 0 for a loss.
+type Result struct {
+	Opponent Rating
+	Score    float64
+}
+
+// Decay widens the deviation for periods rating periods without a game
+// (step 6 of the algorithm on its own), so a returning player's rating moves
+// faster. A fractional number of periods is allowed.
+func (r Rating) Decay(periods float64) Rating {
+	if periods <= 0 {
+		return r
+	}
+	phi := r.Deviation / scale
+	phi = math.Sqrt(phi*phi + periods*r.Volatility*r.Volatility)
+	r.Deviation = math.Min(phi*scale, maxDeviation)
+	return r
+}
+
+// Update returns the player's rating after one rating period containing
+// results. With no results only the deviation changes, as in Decay(1).
+func Update(player Rating, results []Result) Rating {
+	if len(results) == 0 {
+		return player.Decay(1)
+	}
+
+	// Step 2: convert to the Glicko-2 scale.
+	mu := (player.Rating - DefaultRating) / scale
+	phi := player.Deviation / scale
+	sigma := player.Volatility
+
+	// Steps 3 and 4: the estimated variance v and improvement delta.
+	var vInv, deltaSum float64
+	for _, res := range results {
+		muJ := (res.Opponent.Rating - DefaultRating) / scale
+		phiJ := res.Opponent.Deviation / scale
+		g := g(phiJ)
+		e := expected(mu, muJ, g)
+		vInv += g * g * e * (1 - e)
+		deltaSum += g * (res.Score - e)
+	}
+	v := 1 / vInv
+	delta := v * deltaSum
+
+	// Step 5: the new volatility, by the Illinois variant of regula falsi.
+	sigma = newVolatility(sigma, phi, v, delta)
+
+	// Steps 6 and 7: the new deviation and rating.
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * deltaSum
+
+	// Step 8: back to the display scale.
+	return Rating{
+		Rating:     mu*scale + DefaultRating,
+		Deviation:  math.Max(math.Min(phi*scale, maxDeviation), minDeviation),
+		Volatility: sigma,
+	}
+}
+
+func g(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func expected(mu, muJ, g float64) float64 {
+	return 1 / (1 + math.Exp(-g*(mu-muJ)))
+}
+
+func newVolatility(sigma, phi, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-d)/(2*d*d) - (x-a)/(Tau*Tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*Tau) < 0 {
+			k++
+		}
+		B = a - k*Tau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
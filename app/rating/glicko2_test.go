@@ -0,0 +1,58 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+// The worked example from Glickman's paper: a 1500/200 player beats a 1400/30
+// player and loses to 1550/100 and 1700/300 in one rating period.
+func TestUpdateMatchesGlickmanExample(t *testing.T) {
+	player := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	got := Update(player, []Result{
+		{Opponent: Rating{Rating: 1400, Deviation: 30, Volatility: 0.06}, Score: 1},
+		{Opponent: Rating{Rating: 1550, Deviation: 100, Volatility: 0.06}, Score: 0},
+		{Opponent: Rating{Rating: 1700, Deviation: 300, Volatility: 0.06}, Score: 0},
+	})
+	if !near(got.Rating, 1464.06, 0.01) || !near(got.Deviation, 151.52, 0.01) || !near(got.Volatility, 0.05999, 0.00001) {
+		t.Errorf("Update = %+v, want 1464.06 / 151.52 / 0.05999", got)
+	}
+}
+
+func TestUpdateSingleGame(t *testing.T) {
+	a, b := Default(), Default()
+	win := Update(a, []Result{{Opponent: b, Score: 1}})
+	loss := Update(b, []Result{{Opponent: a, Score: 0}})
+	draw := Update(a, []Result{{Opponent: b, Score: 0.5}})
+
+	if win.Rating <= DefaultRating || loss.Rating >= DefaultRating {
+		t.Errorf("win %v, loss %v: the winner should gain and the loser lose", win.Rating, loss.Rating)
+	}
+	if !near(win.Rating-DefaultRating, DefaultRating-loss.Rating, 1e-9) {
+		t.Errorf("equal players should move by equal amounts: +%v / -%v", win.Rating-DefaultRating, DefaultRating-loss.Rating)
+	}
+	if !near(draw.Rating, DefaultRating, 1e-9) {
+		t.Errorf("a draw between equals moved the rating to %v", draw.Rating)
+	}
+	if win.Deviation >= DefaultDeviation {
+		t.Errorf("a game should reduce the deviation, got %v", win.Deviation)
+	}
+}
+
+func TestDecay(t *testing.T) {
+	r := Rating{Rating: 1800, Deviation: 60, Volatility: 0.06}
+	if got := r.Decay(0); got != r {
+		t.Errorf("Decay(0) = %+v, want unchanged", got)
+	}
+	later := r.Decay(30)
+	if later.Deviation <= r.Deviation || later.Rating != r.Rating {
+		t.Errorf("Decay(30) = %+v, want a wider deviation and the same rating", later)
+	}
+	if got := r.Decay(1e6); got.Deviation != maxDeviation {
+		t.Errorf("Decay(1e6) deviation = %v, want the cap %v", got.Deviation, maxDeviation)
+	}
+}
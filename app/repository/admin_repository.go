@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AdminUserFilter selects users for the admin user list. Zero fields do not
+// filter.
+type AdminUserFilter struct {
+	// Query matches anywhere in the display name or username, ignoring case.
+	Query  string
+	Status int
+	// Role is one of constant.Roles.
+	Role string
+	// Before is the pagination cursor: only users with a lower id, who signed
+	// up earlier, are returned.
+	Before int
+	Limit  int
+}
+
+// Each change an admin makes goes through AdminRepository, which writes the
+// audit log entry in the same transaction: an action is never done without
+// being recorded, or recorded without being done. Ending a game is the
+// exception, saved with its entry by ChessRepository.SaveTerminatedGame under
+// the game's lock.
+type AdminRepository interface {
+	FindUsers(filter AdminUserFilter) ([]dao.User, error)
+	FindUserWithRole(userID int) (dao.User, error)
+	SetUserStatus(userID, status int, entry dao.AuditLog) (bool, error)
+	SetUserRole(userID int, role string, entry dao.AuditLog) (bool, error)
+	DeleteAbandonedGames(now time.Time, age time.Duration, entry dao.AuditLog) ([]int, error)
+	FindAuditLogs(before, limit int) ([]dao.AuditLog, error)
+}
+
+type AdminRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func AdminRepositoryInit(db *gorm.DB) *AdminRepositoryImpl {
+	db.AutoMigrate(&dao.AuditLog{})
+	return &AdminRepositoryImpl{
+		db: db,
+	}
+}
+
+// FindUsers returns a page of users, newest first, with their roles.
+func (r AdminRepositoryImpl) FindUsers(f AdminUserFilter) ([]dao.User, error) {
+	q := r.db.Preload("Role")
+	if f.Query != "" {
+		like := "%" + escapeLike(strings.ToLower(f.Query)) + "%"
+		q = q.Where("LOWER(name) LIKE ? OR username LIKE ?", like, like)
+	}
+	if f.Status != 0 {
+		q = q.Where("status = ?", f.Status)
+	}
+	switch f.Role {
+	case "":
+	case constant.RolePlayer:
+		q = q.Where("role_id IS NULL OR role_id IN (SELECT id FROM roles WHERE role = ?)", f.Role)
+	default:
+		q = q.Where("role_id IN (SELECT id FROM roles WHERE role = ?)", f.Role)
+	}
+	if f.Before > 0 {
+		q = q.Where("id < ?", f.Before)
+	}
+
+	var users []dao.User
+	if err := q.Order("id desc").Limit(f.Limit).Find(&users).Error; err != nil {
+		log.Error("Error finding users:", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// FindUserWithRole returns a user with their Role loaded.
+func (r AdminRepositoryImpl) FindUserWithRole(userID int) (dao.User, error) {
+	var user dao.User
+	if err := r.db.Preload("Role").First(&user, userID).Error; err != nil {
+		log.Error("Error finding user by id:", err)
+		return dao.User{}, err
+	}
+	return user, nil
+}
+
+// SetUserStatus sets a user's status and records entry. false means the user
+// already had that status, and nothing was recorded.
+func (r AdminRepositoryImpl) SetUserStatus(userID, status int, entry dao.AuditLog) (bool, error) {
+	changed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&dao.User{}).Where("id = ? AND status <> ?", userID, status).Update("status", status)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		changed = true
+		return recordAudit(tx, entry)
+	})
+	if err != nil {
+		log.Error("Error setting user status:", err)
+	}
+	return changed, err
+}
+
+// SetUserRole gives a user one of constant.Roles and records entry. false
+// means the user already had the role, and nothing was recorded.
+func (r AdminRepositoryImpl) SetUserRole(userID int, role string, entry dao.AuditLog) (bool, error) {
+	changed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var user dao.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "role_id").First(&user, userID).Error; err != nil {
+			return err
+		}
+		// Players have no role row of their own, so a plain player is a null
+		// role_id either way.
+		var roleID *int
+		if role != constant.RolePlayer {
+			var row dao.Role
+			if err := tx.Where("role = ?", role).First(&row).Error; err != nil {
+				return err
+			}
+			roleID = &row.ID
+		}
+		if sameRoleID(user.RoleID, roleID) {
+			return nil
+		}
+		if err := tx.Model(&dao.User{}).Where("id = ?", userID).Update("role_id", roleID).Error; err != nil {
+			return err
+		}
+		changed = true
+		return recordAudit(tx, entry)
+	})
+	if err != nil {
+		log.Error("Error setting user role:", err)
+	}
+	return changed, err
+}
+
+// DeleteAbandonedGames deletes the games older than age that never got a move
+// and never finished -- invites nobody took up, games both players walked away
+// from -- and records entry with their ids in its Detail. It returns the ids.
+//
+// Games that may still be waiting for good are kept: correspondence and other
+// games whose time control allows more than age for the first move, direct
+// challenges, which expire on their own (see DeleteExpiredChallenges), and
+// tournament games, which their pairings point at.
+func (r AdminRepositoryImpl) DeleteAbandonedGames(now time.Time, age time.Duration, entry dao.AuditLog) ([]int, error) {
+	var ids []int
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&dao.ChessGame{}).
+			Where("winner = '' AND created_at < ?", now.Add(-age)).
+			Where("base_seconds + days_per_move * 86400 <= ?", int64(age/time.Second)).
+			Where("challenged_user_id IS NULL").
+			Where("NOT EXISTS (SELECT 1 FROM game_moves WHERE game_moves.game_id = chess_games.id AND game_moves.deleted_at IS NULL)").
+			Where("NOT EXISTS (SELECT 1 FROM tournament_pairings WHERE tournament_pairings.game_id = chess_games.id)").
+			Order("id").Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Where("game_id IN ?", ids).Delete(&dao.GameState{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id IN ?", ids).Delete(&dao.ChessGame{}).Error; err != nil {
+			return err
+		}
+		entry.Detail = fmt.Sprint(ids)
+		return recordAudit(tx, entry)
+	})
+	if err != nil {
+		log.Error("Error deleting abandoned games:", err)
+		return nil, err
+	}
+	return ids, nil
+}
+
+// FindAuditLogs returns a page of the audit log, newest first.
+func (r AdminRepositoryImpl) FindAuditLogs(before, limit int) ([]dao.AuditLog, error) {
+	q := r.db.Model(&dao.AuditLog{})
+	if before > 0 {
+		q = q.Where("id < ?", before)
+	}
+	var entries []dao.AuditLog
+	if err := q.Order("id desc").Limit(limit).Find(&entries).Error; err != nil {
+		log.Error("Error finding audit logs:", err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+func recordAudit(db *gorm.DB, entry dao.AuditLog) error {
+	if entry.At.IsZero() {
+		entry.At = time.Now()
+	}
+	return db.Create(&entry).Error
+}
+
+func sameRoleID(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
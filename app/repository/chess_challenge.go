@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"chess-engine/app/domain/dao"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ChallengeTTL is how long a direct challenge waits for an answer. A challenge
+// is an invite with its empty seat reserved, so it lapses with the invite.
+const ChallengeTTL = inviteTTL
+
+// openChallenge matches challenges still waiting for an answer,
+// expiredChallenge those that lapsed unanswered, and notOpenChallenge
+// everything but open ones: ordinary invites and games under way.
+const (
+	openChallenge    = "challenged_user_id IS NOT NULL AND (white_user_id IS NULL OR black_user_id IS NULL) AND created_at >= ?"
+	expiredChallenge = "challenged_user_id IS NOT NULL AND (white_user_id IS NULL OR black_user_id IS NULL) AND created_at < ?"
+	notOpenChallenge = "challenged_user_id IS NULL OR (white_user_id IS NOT NULL AND black_user_id IS NOT NULL)"
+)
+
+// FindOpenChallenges returns the open challenges userID sent or received,
+// newest first, with the players loaded.
+func (r ChessRepositoryImpl) FindOpenChallenges(userID int) ([]dao.ChessGame, error) {
+	var games []dao.ChessGame
+	err := r.challenges().
+		Where(openChallenge, time.Now().Add(-ChallengeTTL)).
+		Where("challenged_user_id = ? OR white_user_id = ? OR black_user_id = ?", userID, userID, userID).
+		Order("id desc").Find(&games).Error
+	if err != nil {
+		log.Error("Error finding open challenges:", err)
+		return nil, err
+	}
+	return games, nil
+}
+
+// FindOpenChallenge returns an open challenge by game id, with the players
+// loaded.
+func (r ChessRepositoryImpl) FindOpenChallenge(gameID int) (dao.ChessGame, error) {
+	var game dao.ChessGame
+	err := r.challenges().
+		Where(openChallenge, time.Now().Add(-ChallengeTTL)).
+		First(&game, gameID).Error
+	if err != nil {
+		log.Error("Error finding open challenge:", err)
+		return dao.ChessGame{}, err
+	}
+	return game, nil
+}
+
+// AcceptChallenge seats userID, who must be the challenged user, in the empty
+// seat of game. false means the challenge was no longer open: answered,
+// withdrawn or expired in the meantime.
+func (r ChessRepositoryImpl) AcceptChallenge(game dao.ChessGame, userID int) (bool, error) {
+	column := "white_user_id"
+	if game.WhiteUserId != nil {
+		column = "black_user_id"
+	}
+	result := r.db.Model(&dao.ChessGame{}).
+		Where("id = ? AND challenged_user_id = ? AND "+column+" IS NULL", game.ID, userID).
+		Where(openChallenge, time.Now().Add(-ChallengeTTL)).
+		Update(column, userID)
+	if result.Error != nil {
+		log.Error("Error accepting challenge:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// WithdrawChallenge deletes an open challenge, declined or called off, with
+// its position. false means it was no longer open.
+func (r ChessRepositoryImpl) WithdrawChallenge(gameID int) (bool, error) {
+	withdrawn := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", gameID).Where(openChallenge, time.Now().Add(-ChallengeTTL)).
+			Delete(&dao.ChessGame{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		withdrawn = true
+		return tx.Where("game_id = ?", gameID).Delete(&dao.GameState{}).Error
+	})
+	if err != nil {
+		log.Error("Error withdrawing challenge:", err)
+	}
+	return withdrawn, err
+}
+
+// DeleteExpiredChallenges deletes the challenges that lapsed unanswered, with
+// their positions, and returns them with the players loaded. A challenge is
+// deleted, and so returned, once, however many callers sweep at the same time.
+func (r ChessRepositoryImpl) DeleteExpiredChallenges() ([]dao.ChessGame, error) {
+	cutoff := time.Now().Add(-ChallengeTTL)
+	var games []dao.ChessGame
+	if err := r.challenges().Where(expiredChallenge, cutoff).Order("id").Find(&games).Error; err != nil {
+		log.Error("Error finding expired challenges:", err)
+		return nil, err
+	}
+
+	var deleted []dao.ChessGame
+	for _, game := range games {
+		gone := false
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Where("id = ?", game.ID).Where(expiredChallenge, cutoff).Delete(&dao.ChessGame{})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			gone = true
+			return tx.Where("game_id = ?", game.ID).Delete(&dao.GameState{}).Error
+		})
+		if err != nil {
+			log.Error("Error deleting expired challenge:", err)
+			return deleted, err
+		}
+		if gone {
+			deleted = append(deleted, game)
+		}
+	}
+	return deleted, nil
+}
+
+func (r ChessRepositoryImpl) challenges() *gorm.DB {
+	return r.db.
+		Preload("WhiteUser", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name")
+		}).
+		Preload("BlackUser", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name")
+		}).
+		Preload("ChallengedUser", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name")
+		})
+}
@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"database/sql"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Result values for UserGameFilter.Result, from the filtered user's side of
+// the board.
+const (
+	ResultWin     = "win"
+	ResultLoss    = "loss"
+	ResultDraw    = "draw"
+	ResultAborted = "aborted"
+	ResultOngoing = "ongoing"
+)
+
+// UserGameFilter selects from the games a user played. Zero fields do not
+// filter.
+type UserGameFilter struct {
+	UserID int
+	// Colour is "w" or "b".
+	Colour string
+	// Result is one of the Result* values.
+	Result     string
+	OpponentID int
+	BotLevel   string
+	// From and To bound the creation time, From inclusive and To exclusive.
+	From, To time.Time
+	// Before is the pagination cursor: only games with a lower id, which are
+	// older, are returned.
+	Before int
+	Limit  int
+}
+
+// UserResultCounts is a user's finished games by colour and result. Aborted
+// games and pass-and-play games, which a user plays against themself, are left
+// out.
+type UserResultCounts struct {
+	WhiteWins   int
+	WhiteDraws  int
+	WhiteLosses int
+	BlackWins   int
+	BlackDraws  int
+	BlackLosses int
+}
+
+// UserGameOpening is the start of one of a user's finished games, for grouping
+// them by opening.
+type UserGameOpening struct {
+	GameID  int
+	AsWhite bool
+	Winner  string
+	Moves   []string
+}
+
+// FindUserGames returns a page of the games a user played, newest first,
+// with the players loaded but not the moves or the position. Open invites that
+// expired unanswered are not games and are left out.
+func (r ChessRepositoryImpl) FindUserGames(f UserGameFilter) ([]dao.ChessGame, error) {
+	q := r.db.
+		Preload("WhiteUser", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name")
+		}).
+		Preload("BlackUser", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name")
+		}).
+		Where("white_user_id = ? OR black_user_id = ?", f.UserID, f.UserID).
+		Where(notExpiredWaiting, time.Now().Add(-inviteTTL))
+
+	switch f.Colour {
+	case "w":
+		q = q.Where("white_user_id = ?", f.UserID)
+	case "b":
+		q = q.Where("black_user_id = ?", f.UserID)
+	}
+	switch f.Result {
+	case ResultWin:
+		q = q.Where("(white_user_id = ? AND winner = 'w') OR (black_user_id = ? AND winner = 'b')", f.UserID, f.UserID)
+	case ResultLoss:
+		q = q.Where("(white_user_id = ? AND winner = 'b') OR (black_user_id = ? AND winner = 'w')", f.UserID, f.UserID)
+	case ResultDraw:
+		q = q.Where("winner = 'd'")
+	case ResultAborted:
+		q = q.Where("winner = ?", constant.WinnerAborted)
+	case ResultOngoing:
+		q = q.Where("winner = ''")
+	}
+	if f.OpponentID != 0 {
+		q = q.Where("(white_user_id = ? AND black_user_id = ?) OR (black_user_id = ? AND white_user_id = ?)",
+			f.UserID, f.OpponentID, f.UserID, f.OpponentID)
+	}
+	if f.BotLevel != "" {
+		q = q.Where("bot_level = ?", f.BotLevel)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("created_at < ?", f.To)
+	}
+	if f.Before > 0 {
+		q = q.Where("id < ?", f.Before)
+	}
+
+	var games []dao.ChessGame
+	if err := q.Order("id desc").Limit(f.Limit).Find(&games).Error; err != nil {
+		log.Error("Error finding user games:", err)
+		return nil, err
+	}
+	return games, nil
+}
+
+// FindGameSeries returns the chain of rematches that ends with gameID, oldest
+// first, with the players loaded but not the moves or the position. A game
+// that is nobody's rematch is a series of one.
+func (r ChessRepositoryImpl) FindGameSeries(gameID int) ([]dao.ChessGame, error) {
+	var ids []int
+	err := r.db.Raw(`WITH RECURSIVE series AS (
+			SELECT id, previous_game_id FROM chess_games WHERE id = ? AND deleted_at IS NULL
+			UNION ALL
+			SELECT g.id, g.previous_game_id FROM chess_games g
+			JOIN series s ON g.id = s.previous_game_id
+			WHERE g.deleted_at IS NULL
+		) SELECT id FROM series`, gameID).Scan(&ids).Error
+	if err != nil {
+		log.Error("Error finding game series:", err)
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var games []dao.ChessGame
+	err = r.db.
+		Preload("WhiteUser", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name")
+		}).
+		Preload("BlackUser", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name")
+		}).
+		Where("id IN ?", ids).Order("id").Find(&games).Error
+	if err != nil {
+		log.Error("Error finding game series:", err)
+		return nil, err
+	}
+	return games, nil
+}
+
+// FindChessGamesCreatedAt returns the creation time of each game in ids, as
+// FindChessGameCreatedAt does for one.
+func (r ChessRepositoryImpl) FindChessGamesCreatedAt(ids []int) (map[int]time.Time, error) {
+	created := make(map[int]time.Time, len(ids))
+	if len(ids) == 0 {
+		return created, nil
+	}
+	rows, err := r.db.Model(&dao.ChessGame{}).Select("id, created_at").Where("id IN ?", ids).Rows()
+	if err != nil {
+		log.Error("Error finding chess game creation times:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var createdAt sql.NullTime
+		if err := rows.Scan(&id, &createdAt); err != nil {
+			log.Error("Error reading chess game creation time:", err)
+			return nil, err
+		}
+		created[id] = createdAt.Time
+	}
+	return created, rows.Err()
+}
+
+// CountUserResults totals a user's finished games by colour and result.
+func (r ChessRepositoryImpl) CountUserResults(userID int) (UserResultCounts, error) {
+	var counts UserResultCounts
+	err := r.db.Model(&dao.ChessGame{}).
+		Select(`
+			COUNT(*) FILTER (WHERE white_user_id = @user AND winner = 'w') AS white_wins,
+			COUNT(*) FILTER (WHERE white_user_id = @user AND winner = 'd') AS white_draws,
+			COUNT(*) FILTER (WHERE white_user_id = @user AND winner = 'b') AS white_losses,
+			COUNT(*) FILTER (WHERE black_user_id = @user AND winner = 'b') AS black_wins,
+			COUNT(*) FILTER (WHERE black_user_id = @user AND winner = 'd') AS black_draws,
+			COUNT(*) FILTER (WHERE black_user_id = @user AND winner = 'w') AS black_losses`,
+			sql.Named("user", userID)).
+		Where("(white_user_id = @user OR black_user_id = @user) AND white_user_id <> black_user_id", sql.Named("user", userID)).
+		Scan(&counts).Error
+	if err != nil {
+		log.Error("Error counting user results:", err)
+		return UserResultCounts{}, err
+	}
+	return counts, nil
+}
+
+// FindUserOpenings returns the first plies moves of each game a user finished
+// from the standard position, leaving out aborted and pass-and-play games as
+// CountUserResults does. Games shorter than plies are included with what they
+// have. The moves are numbered only for the user's own games: numbering the
+// whole table would scan every move ever played.
+func (r ChessRepositoryImpl) FindUserOpenings(userID, plies int) ([]UserGameOpening, error) {
+	rows, err := r.db.Raw(`
+		SELECT g.id, g.white_user_id = @user, g.winner, m.move
+		FROM chess_games g
+		JOIN (
+			SELECT game_id, move, id,
+				ROW_NUMBER() OVER (PARTITION BY game_id ORDER BY id) AS ply
+			FROM game_moves
+			WHERE deleted_at IS NULL
+				AND game_id IN (
+					SELECT id FROM chess_games
+					WHERE white_user_id = @user OR black_user_id = @user
+				)
+		) m ON m.game_id = g.id AND m.ply <= @plies
+		WHERE (g.white_user_id = @user OR g.black_user_id = @user)
+			AND g.white_user_id <> g.black_user_id
+			AND g.winner IN ('w', 'b', 'd')
+			AND g.start_fen = ''
+			AND g.deleted_at IS NULL
+		ORDER BY g.id, m.id`,
+		sql.Named("user", userID), sql.Named("plies", plies)).Rows()
+	if err != nil {
+		log.Error("Error finding user openings:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var openings []UserGameOpening
+	for rows.Next() {
+		var id int
+		var asWhite bool
+		var winner, move string
+		if err := rows.Scan(&id, &asWhite, &winner, &move); err != nil {
+			log.Error("Error reading user opening:", err)
+			return nil, err
+		}
+		if n := len(openings); n == 0 || openings[n-1].GameID != id {
+			openings = append(openings, UserGameOpening{GameID: id, AsWhite: asWhite, Winner: winner})
+		}
+		last := &openings[len(openings)-1]
+		last.Moves = append(last.Moves, move)
+	}
+	return openings, rows.Err()
+}
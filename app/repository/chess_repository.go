@@ -4,6 +4,7 @@ import (
 	"chess-engine/app/constant"
 	"chess-engine/app/domain/dao"
 	"chess-engine/app/pkg"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -27,6 +28,17 @@ type ChessRepository interface {
 	FindAllChessGame() ([]dao.ChessGame, error)
 	FindChessGameById(id string) (dao.ChessGame, error)
 	FindChessGameByInviteCode(inviteCode string) (dao.ChessGame, error)
+	FindChessGameCreatedAt(id string) (time.Time, error)
+	FindChessGamesCreatedAt(ids []int) (map[int]time.Time, error)
+	FindUserGames(filter UserGameFilter) ([]dao.ChessGame, error)
+	FindOpenChallenges(userID int) ([]dao.ChessGame, error)
+	FindOpenChallenge(gameID int) (dao.ChessGame, error)
+	AcceptChallenge(game dao.ChessGame, userID int) (bool, error)
+	WithdrawChallenge(gameID int) (bool, error)
+	DeleteExpiredChallenges() ([]dao.ChessGame, error)
+	FindGameSeries(gameID int) ([]dao.ChessGame, error)
+	CountUserResults(userID int) (UserResultCounts, error)
+	FindUserOpenings(userID, plies int) ([]UserGameOpening, error)
 	GetChessGameFromCache(gameId string) (dao.ChessGame, error)
 	// GetChessGameFromDB(gameId string) (dao.ChessGame, error)
 	SaveChessGameToCache(game *dao.ChessGame) error
@@ -35,6 +47,9 @@ type ChessRepository interface {
 	FindUserByToken(token string) (dao.User, error)
 	FindOrCreateBotUser() (dao.User, error)
 	SaveGameMoveToDB(game *dao.GameMove) error
+	SaveImportedGames(games []ImportedGame) error
+	TakeBackGameMoves(game *dao.ChessGame, dropped []dao.GameMove) error
+	SaveTerminatedGame(game *dao.ChessGame, entry *dao.AuditLog) error
 }
 
 type ChessRepositoryImpl struct {
@@ -64,6 +79,7 @@ func (r ChessRepositoryImpl) FindAllChessGame() ([]dao.ChessGame, error) {
 			return db.Select("id, name")
 		}).
 		Where(notExpiredWaiting, time.Now().Add(-inviteTTL)).
+		Where(notOpenChallenge).
 		Order("id desc").Find(&chesses).Error
 	if err != nil {
 		log.Error("Error finding all chess games:", err)
@@ -110,6 +126,22 @@ func (r ChessRepositoryImpl) FindChessGameByInviteCode(inviteCode string) (dao.C
 	return chess, nil
 }
 
+// FindChessGameCreatedAt returns when a game was created. BaseModel never reads
+// created_at back into the struct, so this fetches the column on its own. Rows
+// from before the column existed return the zero time.
+func (r ChessRepositoryImpl) FindChessGameCreatedAt(id string) (time.Time, error) {
+	var createdAt sql.NullTime
+	err := r.db.Model(&dao.ChessGame{}).
+		Select("created_at").
+		Where("id = ?", id).
+		Row().Scan(&createdAt)
+	if err != nil {
+		log.Error("Error finding chess game creation time:", err)
+		return time.Time{}, err
+	}
+	return createdAt.Time, nil
+}
+
 func (r ChessRepositoryImpl) SaveChessGameToDB(game *dao.ChessGame) error {
 	if err := r.db.Save(game).Error; err != nil {
 		log.Error("Error saving chess game to DB:", err)
@@ -169,30 +201,120 @@ func (r ChessRepositoryImpl) SaveGameMoveToDB(gameMove *dao.GameMove) error {
 	return nil
 }
 
+// ImportedGame is a game that arrives with a history (an import): the game,
+// the position it stops at, and its moves in order. The ids are filled in
+// when it is saved.
+type ImportedGame struct {
+	Game  *dao.ChessGame
+	State dao.GameState
+	Moves []dao.GameMove
+}
+
+// SaveImportedGames creates games with their positions and moves in one
+// transaction, so a failure partway through leaves none of them behind.
+func (r ChessRepositoryImpl) SaveImportedGames(games []ImportedGame) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range games {
+			g := &games[i]
+			if err := tx.Save(g.Game).Error; err != nil {
+				return err
+			}
+			g.State.GameID = g.Game.ID
+			if err := tx.Save(&g.State).Error; err != nil {
+				return err
+			}
+			if len(g.Moves) == 0 {
+				continue
+			}
+			for j := range g.Moves {
+				g.Moves[j].GameID = g.Game.ID
+			}
+			if err := tx.Create(&g.Moves).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("Error saving imported games to DB:", err)
+	}
+	return err
+}
+
+// TakeBackGameMoves deletes the taken-back move rows and saves the rewound
+// state and game in one transaction, so a failure leaves the game as it was
+// rather than short of moves its stored position still shows. Only the ids of
+// dropped are used.
+func (r ChessRepositoryImpl) TakeBackGameMoves(game *dao.ChessGame, dropped []dao.GameMove) error {
+	ids := make([]int, 0, len(dropped))
+	for _, m := range dropped {
+		ids = append(ids, m.ID)
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if len(ids) > 0 {
+			if err := tx.Where("id IN ?", ids).Delete(&dao.GameMove{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&game.State).Error; err != nil {
+			return err
+		}
+		return tx.Save(game).Error
+	})
+	if err != nil {
+		log.Error("Error taking back game moves:", err)
+	}
+	return err
+}
+
+// SaveTerminatedGame saves a game ended from outside it, with the audit entry
+// of the moderator who ended it when entry is not nil, in one transaction: the
+// game is not ended without the entry, nor the entry kept for a game that did
+// not end.
+func (r ChessRepositoryImpl) SaveTerminatedGame(game *dao.ChessGame, entry *dao.AuditLog) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&game.State).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(game).Error; err != nil {
+			return err
+		}
+		if entry == nil {
+			return nil
+		}
+		return recordAudit(tx, *entry)
+	})
+	if err != nil {
+		log.Error("Error saving terminated game:", err)
+	}
+	return err
+}
+
+// FindUserByToken resolves a session token to its user (see
+// findSessionByToken).
 func (u ChessRepositoryImpl) FindUserByToken(token string) (dao.User, error) {
-	var user dao.User
-	err := u.db.Where("token = ?", token).First(&user).Error
+	session, err := findSessionByToken(u.db, token)
 	if err != nil {
 		log.Error("Got and error when find user by token. Error: ", err)
 		return dao.User{}, err
 	}
-	return user, nil
+	return session.User, nil
 }
 
 // FindOrCreateBotUser returns the singleton computer-opponent user, creating it
 // the first time a bot game is requested.
 //
-// The bot is looked up by name and given a random token. It used to be keyed on
+// The bot is looked up by name and has no session. It used to be keyed on
 // constant.BotToken, a fixed credential committed to the repository, which the
 // bot then replayed through the normal token-authentication path. Bot moves now
-// bypass that lookup entirely (see WebSocketServiceImpl.applyMove), so nothing
-// needs to know this token.
+// bypass that lookup entirely (see WebSocketServiceImpl.applyMove), so the bot
+// needs no way to sign in.
 func (u ChessRepositoryImpl) FindOrCreateBotUser() (dao.User, error) {
 	var user dao.User
 	if err := u.db.Where("name = ?", constant.BotName).First(&user).Error; err == nil {
 		return user, nil
 	}
-	user = dao.User{Name: constant.BotName, Token: pkg.GenerateRandomString(80), Status: 1}
+	user = dao.User{Name: constant.BotName, Status: constant.UserStatusActive}
 	if err := u.db.Create(&user).Error; err != nil {
 		log.Error("Error creating bot user. Error: ", err)
 		return dao.User{}, err
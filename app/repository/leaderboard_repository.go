@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/pkg"
+	"fmt"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// The leaderboards live in Redis, one sorted set per category keyed
+// "leaderboard:<category>", with user ids as members scored by rating. Beside
+// each is a hash, "leaderboard:<category>:info", holding what the ranking
+// alone does not: each player's deviation and volatility, rated-game count
+// and last rated game, from which provisional and inactive players are told
+// apart when the board is read. Both are updated as each rated game finishes,
+// and rebuilt from the ratings table at start-up, so Redis only ever holds a
+// copy.
+
+// leaderboardScanBatch is how many ranks FindLeaders reads from Redis at a
+// time while skipping ineligible players, and leaderboardMaxScan how many it
+// reads in all.
+const (
+	leaderboardScanBatch = 100
+	leaderboardMaxScan   = 2000
+)
+
+// leaderboardRebuildMargin is how long before a rebuild began a game may have
+// been rated and still be missing from what the rebuild read.
+const leaderboardRebuildMargin = time.Minute
+
+// LeaderboardEntry is one player on a leaderboard.
+type LeaderboardEntry struct {
+	User       dao.User
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+	Games      int
+	RatedAt    time.Time
+}
+
+type LeaderboardRepository interface {
+	UpdateLeaderboard(user dao.User, row dao.Rating) error
+	FindLeaders(category string, limit int, eligible func(LeaderboardEntry) bool) ([]LeaderboardEntry, error)
+	RebuildLeaderboards(categories []string) error
+}
+
+type LeaderboardRepositoryImpl struct {
+	db          *gorm.DB
+	redisClient *pkg.RedisClient
+}
+
+// UpdateLeaderboard puts a player's new rating on their category's board. The
+// bot is never on a leaderboard.
+func (r LeaderboardRepositoryImpl) UpdateLeaderboard(user dao.User, row dao.Rating) error {
+	if user.Name == constant.BotName {
+		return nil
+	}
+	member := strconv.Itoa(row.UserID)
+	if err := r.redisClient.HSet(leaderboardInfoKey(row.Category), member, leaderboardInfo(row)); err != nil {
+		return err
+	}
+	return r.redisClient.ZAdd(leaderboardKey(row.Category), row.Rating, member)
+}
+
+// FindLeaders returns up to limit players from the top of category's board,
+// highest rated first, leaving out those eligible rejects and any who are
+// banned or gone.
+//
+// eligible must only ever turn a player away for good until their next rated
+// game, as going inactive or provisional does: the players it rejects are
+// taken off the board, and UpdateLeaderboard puts them back. That keeps the
+// ineligible from piling up at the top for every read to wade through. Even
+// so, no read goes past leaderboardMaxScan ranks.
+func (r LeaderboardRepositoryImpl) FindLeaders(category string, limit int,
+	eligible func(LeaderboardEntry) bool) ([]LeaderboardEntry, error) {
+	key, infoKey := leaderboardKey(category), leaderboardInfoKey(category)
+	leaders := []LeaderboardEntry{}
+	scanned := 0
+	for start := int64(0); len(leaders) < limit && scanned < leaderboardMaxScan; start += leaderboardScanBatch {
+		ranked, err := r.redisClient.ZRevRange(key, start, start+leaderboardScanBatch-1)
+		if err != nil {
+			return nil, err
+		}
+		if len(ranked) == 0 {
+			break
+		}
+		scanned += len(ranked)
+		members := make([]string, len(ranked))
+		for i, m := range ranked {
+			members[i] = m.Member
+		}
+		infos, err := r.redisClient.HMGet(infoKey, members...)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates := make([]LeaderboardEntry, 0, len(ranked))
+		ids := make([]int, 0, len(ranked))
+		removed := int64(0)
+		for i, m := range ranked {
+			id, err := strconv.Atoi(m.Member)
+			if err != nil {
+				continue
+			}
+			entry := LeaderboardEntry{User: dao.User{ID: id}, Rating: m.Score}
+			if !parseLeaderboardInfo(infos[i], &entry) {
+				continue
+			}
+			if !eligible(entry) {
+				// Unless a game has been rated since the info was read.
+				if gone, err := r.redisClient.ZRemIfUnchanged(key, infoKey, m.Member, infos[i]); err == nil && gone {
+					removed++
+				}
+				continue
+			}
+			candidates = append(candidates, entry)
+			ids = append(ids, id)
+		}
+		// The next batch starts that much higher up for what was taken off.
+		start -= removed
+		if len(ids) == 0 {
+			continue
+		}
+
+		var users []dao.User
+		if err := r.db.Select("id, name, username, status").Where("id IN ?", ids).Find(&users).Error; err != nil {
+			log.Error("Error finding leaderboard users:", err)
+			return nil, err
+		}
+		byID := make(map[int]dao.User, len(users))
+		for _, u := range users {
+			byID[u.ID] = u
+		}
+		for _, entry := range candidates {
+			u, ok := byID[entry.User.ID]
+			if !ok || u.Status == constant.UserStatusBanned || u.Name == constant.BotName {
+				continue
+			}
+			entry.User = dao.User{ID: u.ID, Name: u.Name, Username: u.Username}
+			leaders = append(leaders, entry)
+			if len(leaders) == limit {
+				break
+			}
+		}
+	}
+	return leaders, nil
+}
+
+// RebuildLeaderboards replaces the boards of categories with what the ratings
+// table holds. Each board goes to Redis in one pipeline and is swapped in
+// whole, so readers see the old board or the new one, never half of one.
+//
+// Games go on being rated while it runs, and the swap would put back the
+// ratings from before them, so those rated since shortly before it began are
+// written again once it is done.
+func (r LeaderboardRepositoryImpl) RebuildLeaderboards(categories []string) error {
+	started := time.Now()
+	rows, err := r.leaderboardRatings(time.Time{})
+	if err != nil {
+		log.Error("Error finding ratings for the leaderboards:", err)
+		return err
+	}
+	byCategory := map[string][]dao.Rating{}
+	for _, row := range rows {
+		byCategory[row.Category] = append(byCategory[row.Category], row)
+	}
+
+	for _, category := range categories {
+		members := make([]pkg.ScoredMember, 0, len(byCategory[category]))
+		info := make(map[string]string, len(byCategory[category]))
+		for _, row := range byCategory[category] {
+			member := strconv.Itoa(row.UserID)
+			members = append(members, pkg.ScoredMember{Member: member, Score: row.Rating})
+			info[member] = leaderboardInfo(row)
+		}
+		if err := r.redisClient.ReplaceSortedSet(leaderboardKey(category), leaderboardInfoKey(category), members, info); err != nil {
+			return err
+		}
+	}
+
+	// A game's rating is stamped when it finishes, a little before it is
+	// saved, so the margin.
+	rows, err = r.leaderboardRatings(started.Add(-leaderboardRebuildMargin))
+	if err != nil {
+		log.Error("Error finding ratings for the leaderboards:", err)
+		return err
+	}
+	for _, row := range rows {
+		member := strconv.Itoa(row.UserID)
+		if err := r.redisClient.HSet(leaderboardInfoKey(row.Category), member, leaderboardInfo(row)); err != nil {
+			return err
+		}
+		if err := r.redisClient.ZAdd(leaderboardKey(row.Category), row.Rating, member); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// leaderboardRatings reads the ratings of everyone but the bot and deleted
+// users, of games rated since since.
+func (r LeaderboardRepositoryImpl) leaderboardRatings(since time.Time) ([]dao.Rating, error) {
+	var rows []dao.Rating
+	q := r.db.Joins("JOIN users ON users.id = ratings.user_id AND users.deleted_at IS NULL").
+		Where("users.name <> ?", constant.BotName)
+	if !since.IsZero() {
+		q = q.Where("ratings.rated_at >= ?", since)
+	}
+	err := q.Find(&rows).Error
+	return rows, err
+}
+
+func leaderboardKey(category string) string {
+	return "leaderboard:" + category
+}
+
+func leaderboardInfoKey(category string) string {
+	return "leaderboard:" + category + ":info"
+}
+
+// leaderboardInfo encodes the info hash value for a rating: deviation,
+// volatility, games and the unix time of the last rated game.
+func leaderboardInfo(row dao.Rating) string {
+	return fmt.Sprintf("%g %g %d %d", row.Deviation, row.Volatility, row.Games, row.RatedAt.Unix())
+}
+
+func parseLeaderboardInfo(info string, entry *LeaderboardEntry) bool {
+	var ratedAt int64
+	if _, err := fmt.Sscan(info, &entry.Deviation, &entry.Volatility, &entry.Games, &ratedAt); err != nil {
+		return false
+	}
+	entry.RatedAt = time.Unix(ratedAt, 0)
+	return true
+}
+
+func LeaderboardRepositoryInit(db *gorm.DB, redisClient *pkg.RedisClient) *LeaderboardRepositoryImpl {
+	return &LeaderboardRepositoryImpl{
+		db:          db,
+		redisClient: redisClient,
+	}
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"chess-engine/app/domain/dao"
+	"testing"
+	"time"
+)
+
+func TestLeaderboardInfo(t *testing.T) {
+	ratedAt := time.Date(2024, 6, 1, 12, 30, 15, 0, time.UTC)
+	row := dao.Rating{UserID: 7, Category: "blitz", Rating: 1834.5, Deviation: 62.25, Volatility: 0.0599, Games: 41, RatedAt: ratedAt}
+
+	info := leaderboardInfo(row)
+	if want := "62.25 0.0599 41 1717245015"; info != want {
+		t.Errorf("leaderboardInfo = %q, want %q", info, want)
+	}
+
+	var entry LeaderboardEntry
+	if !parseLeaderboardInfo(info, &entry) {
+		t.Fatalf("parseLeaderboardInfo(%q) failed", info)
+	}
+	if entry.Deviation != row.Deviation || entry.Volatility != row.Volatility || entry.Games != row.Games ||
+		!entry.RatedAt.Equal(ratedAt) {
+		t.Errorf("round trip = %+v, want the deviation, volatility, games and time of %+v", entry, row)
+	}
+
+	// Full float precision survives, and times lose only what is under a
+	// second.
+	row = dao.Rating{Deviation: 1.0 / 3, Volatility: 0.06000000001, RatedAt: ratedAt.Add(900 * time.Millisecond)}
+	entry = LeaderboardEntry{}
+	if !parseLeaderboardInfo(leaderboardInfo(row), &entry) || entry.Deviation != row.Deviation ||
+		entry.Volatility != row.Volatility || !entry.RatedAt.Equal(ratedAt) {
+		t.Errorf("round trip of %+v = %+v", row, entry)
+	}
+
+	// Missing or damaged fields, as HMGet gives for a member without info.
+	for _, bad := range []string{"", "62.25 0.06 41", "62.25 0.06 x 1717245015", "a b c d"} {
+		if parseLeaderboardInfo(bad, &LeaderboardEntry{}) {
+			t.Errorf("parseLeaderboardInfo(%q) succeeded", bad)
+		}
+	}
+}
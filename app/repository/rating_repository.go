@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/rating"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RatingRepository interface {
+	FindRatingsByUser(userID int) ([]dao.Rating, error)
+	FindRatings(userIDs []int, category string) ([]dao.Rating, error)
+	RateGame(game *dao.ChessGame, category string, rate func(white, black *dao.Rating)) (bool, error)
+}
+
+type RatingRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func RatingRepositoryInit(db *gorm.DB) *RatingRepositoryImpl {
+	db.AutoMigrate(&dao.Rating{})
+	return &RatingRepositoryImpl{
+		db: db,
+	}
+}
+
+// FindRatingsByUser returns a user's ratings, one per category played.
+func (r RatingRepositoryImpl) FindRatingsByUser(userID int) ([]dao.Rating, error) {
+	var ratings []dao.Rating
+	if err := r.db.Where("user_id = ?", userID).Order("category").Find(&ratings).Error; err != nil {
+		log.Error("Error finding ratings:", err)
+		return nil, err
+	}
+	return ratings, nil
+}
+
+// FindRatings returns the ratings in category of those of userIDs who have
+// one.
+func (r RatingRepositoryImpl) FindRatings(userIDs []int, category string) ([]dao.Rating, error) {
+	var ratings []dao.Rating
+	if len(userIDs) == 0 {
+		return ratings, nil
+	}
+	if err := r.db.Where("user_id IN ? AND category = ?", userIDs, category).Find(&ratings).Error; err != nil {
+		log.Error("Error finding ratings:", err)
+		return nil, err
+	}
+	return ratings, nil
+}
+
+// RateGame updates both players' ratings in category for a finished game, and
+// records the change on the game, in one transaction. rate receives the two
+// rating rows as they stand -- a player new to the category gets the default
+// rating -- and must update them and set game.WhiteRatingChange and
+// game.BlackRatingChange. In a game an account plays against itself both
+// arguments are the same row.
+//
+// The game row is locked and checked first, so a game is rated at most once
+// however many times it is reported finished; false means it already was.
+func (r RatingRepositoryImpl) RateGame(game *dao.ChessGame, category string, rate func(white, black *dao.Rating)) (bool, error) {
+	rated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var current dao.ChessGame
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "white_rating_change").First(&current, game.ID).Error; err != nil {
+			return err
+		}
+		if current.WhiteRatingChange != nil {
+			return nil
+		}
+
+		// Lock the rating rows in user id order, so two games finishing at
+		// once between the same players cannot deadlock.
+		whiteID, blackID := game.WhiteUser.ID, game.BlackUser.ID
+		first, second := whiteID, blackID
+		if first > second {
+			first, second = second, first
+		}
+		rows := map[int]*dao.Rating{}
+		for _, id := range []int{first, second} {
+			if rows[id] != nil {
+				continue
+			}
+			row, err := lockRating(tx, id, category)
+			if err != nil {
+				return err
+			}
+			rows[id] = row
+		}
+
+		rate(rows[whiteID], rows[blackID])
+		for _, row := range rows {
+			if err := tx.Save(row).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&dao.ChessGame{}).Where("id = ?", game.ID).Updates(map[string]interface{}{
+			"white_rating_change": game.WhiteRatingChange,
+			"black_rating_change": game.BlackRatingChange,
+		}).Error; err != nil {
+			return err
+		}
+		rated = true
+		return nil
+	})
+	if err != nil {
+		log.Error("Error rating game:", err)
+		return false, err
+	}
+	return rated, nil
+}
+
+// lockRating reads a user's rating row in category for update, creating it
+// with the default rating first if the user has none. Inserting before
+// locking, and ignoring a conflict, means two games that both bring a player
+// into a category cannot both try to create the row.
+func lockRating(tx *gorm.DB, userID int, category string) (*dao.Rating, error) {
+	def := rating.Default()
+	fresh := dao.Rating{
+		UserID:     userID,
+		Category:   category,
+		Rating:     def.Rating,
+		Deviation:  def.Deviation,
+		Volatility: def.Volatility,
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&fresh).Error; err != nil {
+		return nil, err
+	}
+	var row dao.Rating
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND category = ?", userID, category).First(&row).Error; err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
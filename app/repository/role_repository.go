@@ -1,23 +1,49 @@
 package repository
 
 import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+
+	log "github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
 type RoleRepository interface {
-	FindAllRole()
+	FindAllRole() ([]dao.Role, error)
+	FindRoleByName(name string) (dao.Role, error)
 }
 
 type RoleRepositoryImpl struct {
 	db *gorm.DB
 }
 
-func (r RoleRepositoryImpl) FindAllRole() {
-	panic("implement me")
+func (r RoleRepositoryImpl) FindAllRole() ([]dao.Role, error) {
+	var roles []dao.Role
+	if err := r.db.Order("id").Find(&roles).Error; err != nil {
+		log.Error("Error finding roles:", err)
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r RoleRepositoryImpl) FindRoleByName(name string) (dao.Role, error) {
+	var role dao.Role
+	if err := r.db.Where("role = ?", name).First(&role).Error; err != nil {
+		log.Error("Error finding role by name:", err)
+		return dao.Role{}, err
+	}
+	return role, nil
 }
 
+// RoleRepositoryInit creates the roles table and a row for each of
+// constant.Roles that is missing.
 func RoleRepositoryInit(db *gorm.DB) *RoleRepositoryImpl {
-	// db.AutoMigrate(&dao.Role{}, &dao.User{})
+	db.AutoMigrate(&dao.Role{})
+	for _, name := range constant.Roles {
+		if err := db.Where(dao.Role{Role: name}).FirstOrCreate(&dao.Role{}).Error; err != nil {
+			log.Error("Error creating role "+name+":", err)
+		}
+	}
 	return &RoleRepositoryImpl{
 		db: db,
 	}
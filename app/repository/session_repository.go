@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/pkg"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sessionTTL is how long a session lasts without being used. Each use pushes
+// the expiry out again, so a player who plays weekly is never signed out; a
+// device left alone for a month is. Guests' sessions do not expire (see
+// sessionLive): with no password, a guest signed out has lost the account.
+const sessionTTL = 30 * 24 * time.Hour
+
+// sessionTouchInterval limits how often using a session writes its last-seen
+// time. Every move is authenticated, and a write per move would double the
+// database traffic of a game for a timestamp nobody needs to the second.
+const sessionTouchInterval = 5 * time.Minute
+
+// sessionTokenLength matches the tokens users have always been issued.
+const sessionTokenLength = 80
+
+// legacyDevice labels the session a pre-session token is moved into.
+const legacyDevice = "Signed in before sessions"
+
+// ErrSessionNotFound is returned for a token that is unknown, expired or
+// revoked.
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrUserBanned is returned for a live session of a banned user. The session
+// is left alone, so it works again if the ban is lifted.
+var ErrUserBanned = errors.New("user is banned")
+
+type SessionRepository interface {
+	CreateSession(userID int, device string) (dao.Session, string, error)
+	FindSessionByToken(token string) (dao.Session, error)
+	RotateSession(session dao.Session) (dao.Session, string, error)
+	FindUserSessions(userID int) ([]dao.Session, error)
+	RevokeSession(userID, sessionID int) (bool, error)
+	RevokeOtherSessions(userID, keepID int) error
+}
+
+type SessionRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func SessionRepositoryInit(db *gorm.DB) *SessionRepositoryImpl {
+	db.AutoMigrate(&dao.Session{})
+	return &SessionRepositoryImpl{
+		db: db,
+	}
+}
+
+// CreateSession signs userID in on a new device and returns the session and
+// its token. The token is not stored and cannot be recovered later.
+func (r SessionRepositoryImpl) CreateSession(userID int, device string) (dao.Session, string, error) {
+	token := pkg.GenerateRandomString(sessionTokenLength)
+	now := time.Now()
+	session := dao.Session{
+		UserID:     userID,
+		TokenHash:  hashToken(token),
+		Device:     device,
+		IssuedAt:   now,
+		ExpiresAt:  now.Add(sessionTTL),
+		LastSeenAt: now,
+	}
+	if err := r.db.Create(&session).Error; err != nil {
+		log.Error("Error creating session:", err)
+		return dao.Session{}, "", err
+	}
+	return session, token, nil
+}
+
+// FindSessionByToken returns the live session token belongs to, with its User
+// and the user's Role.
+func (r SessionRepositoryImpl) FindSessionByToken(token string) (dao.Session, error) {
+	return findSessionByToken(r.db, token)
+}
+
+// RotateSession gives a session a new token, reissued now with a fresh expiry.
+// The old token stops working at once, so a copy of it taken from the device
+// is worth nothing after the device refreshes.
+func (r SessionRepositoryImpl) RotateSession(session dao.Session) (dao.Session, string, error) {
+	token := pkg.GenerateRandomString(sessionTokenLength)
+	now := time.Now()
+	updates := map[string]interface{}{
+		"token_hash":   hashToken(token),
+		"issued_at":    now,
+		"expires_at":   now.Add(sessionTTL),
+		"last_seen_at": now,
+	}
+	// Matching on the old hash as well makes two concurrent refreshes of one
+	// token yield one winner instead of two live tokens.
+	result := r.db.Model(&dao.Session{}).
+		Where("id = ? AND token_hash = ? AND revoked_at IS NULL", session.ID, session.TokenHash).
+		Updates(updates)
+	if result.Error != nil {
+		log.Error("Error rotating session:", result.Error)
+		return dao.Session{}, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return dao.Session{}, "", ErrSessionNotFound
+	}
+	session.TokenHash = updates["token_hash"].(string)
+	session.IssuedAt, session.ExpiresAt, session.LastSeenAt = now, now.Add(sessionTTL), now
+	return session, token, nil
+}
+
+// FindUserSessions lists a user's live sessions, most recently used first.
+func (r SessionRepositoryImpl) FindUserSessions(userID int) ([]dao.Session, error) {
+	var sessions []dao.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL", userID).
+		Where("expires_at > ? OR user_id IN (SELECT id FROM users WHERE COALESCE(password_hash, '') = '')", time.Now()).
+		Order("last_seen_at desc").Find(&sessions).Error
+	if err != nil {
+		log.Error("Error finding user sessions:", err)
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends one of userID's sessions. false means there was no such
+// live session belonging to the user.
+func (r SessionRepositoryImpl) RevokeSession(userID, sessionID int) (bool, error) {
+	result := r.db.Model(&dao.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		log.Error("Error revoking session:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RevokeOtherSessions ends every session of userID except keepID.
+func (r SessionRepositoryImpl) RevokeOtherSessions(userID, keepID int) error {
+	err := r.db.Model(&dao.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		log.Error("Error revoking sessions:", err)
+	}
+	return err
+}
+
+// findSessionByToken resolves a token to its live session and user. It is the
+// one place a token is checked: the user and chess repositories' lookups go
+// through it as well.
+//
+// A token from before sessions existed is still in users.token. The first time
+// one is used it is moved into a session (hashed) and the column cleared, so
+// those players stay signed in.
+func findSessionByToken(db *gorm.DB, token string) (dao.Session, error) {
+	if token == "" {
+		return dao.Session{}, ErrSessionNotFound
+	}
+	hash := hashToken(token)
+	var session dao.Session
+	err := db.Preload("User.Role").Where("token_hash = ?", hash).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err = adoptLegacyToken(db, token); err != nil {
+			return dao.Session{}, err
+		}
+		err = db.Preload("User.Role").Where("token_hash = ?", hash).First(&session).Error
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return dao.Session{}, ErrSessionNotFound
+		}
+		log.Error("Error finding session:", err)
+		return dao.Session{}, err
+	}
+
+	now := time.Now()
+	if !sessionLive(session, now) {
+		return dao.Session{}, ErrSessionNotFound
+	}
+	if session.User.Status == constant.UserStatusBanned {
+		return dao.Session{}, ErrUserBanned
+	}
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		err := db.Model(&dao.Session{}).Where("id = ?", session.ID).
+			Updates(map[string]interface{}{"last_seen_at": now, "expires_at": now.Add(sessionTTL)}).Error
+		if err != nil {
+			// The session is valid either way; a missed touch only costs accuracy.
+			log.Error("Error touching session:", err)
+		} else {
+			session.LastSeenAt, session.ExpiresAt = now, now.Add(sessionTTL)
+		}
+	}
+	return session, nil
+}
+
+// sessionLive reports whether session, with its User loaded, still signs its
+// user in at now. A guest's session only ends when revoked: idle expiry would
+// lose the account for good, since there is no password to sign in again with.
+func sessionLive(session dao.Session, now time.Time) bool {
+	if session.RevokedAt != nil || session.User.ID == 0 {
+		return false
+	}
+	return now.Before(session.ExpiresAt) || session.User.PasswordHash == ""
+}
+
+// adoptLegacyToken moves a plaintext users.token into a session. It does
+// nothing if no user has the token.
+func adoptLegacyToken(db *gorm.DB, token string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var user dao.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token = ?", token).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		session := dao.Session{
+			UserID:     user.ID,
+			TokenHash:  hashToken(token),
+			Device:     legacyDevice,
+			IssuedAt:   now,
+			ExpiresAt:  now.Add(sessionTTL),
+			LastSeenAt: now,
+		}
+		// A concurrent request with the same token may have got here first.
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&session).Error; err != nil {
+			return err
+		}
+		return tx.Model(&dao.User{}).Where("id = ?", user.ID).Update("token", nil).Error
+	})
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
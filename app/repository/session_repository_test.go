@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"chess-engine/app/domain/dao"
+	"testing"
+	"time"
+)
+
+func TestSessionLive(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	revoked := now.Add(-time.Hour)
+	registered := dao.User{ID: 1, PasswordHash: "hash"}
+	guest := dao.User{ID: 2}
+	cases := []struct {
+		name    string
+		session dao.Session
+		want    bool
+	}{
+		{"fresh", dao.Session{User: registered, ExpiresAt: now.Add(time.Hour)}, true},
+		{"idle too long", dao.Session{User: registered, ExpiresAt: now}, false},
+		{"revoked", dao.Session{User: registered, ExpiresAt: now.Add(time.Hour), RevokedAt: &revoked}, false},
+		{"user deleted", dao.Session{ExpiresAt: now.Add(time.Hour)}, false},
+		// A guest has no other way back in.
+		{"guest idle too long", dao.Session{User: guest, ExpiresAt: now.Add(-sessionTTL)}, true},
+		{"guest revoked", dao.Session{User: guest, ExpiresAt: now.Add(time.Hour), RevokedAt: &revoked}, false},
+	}
+	for _, tc := range cases {
+		if got := sessionLive(tc.session, now); got != tc.want {
+			t.Errorf("%s: sessionLive = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// tournamentListLimit caps GET /api/tournament.
+const tournamentListLimit = 50
+
+type TournamentRepository interface {
+	SaveTournament(tournament *dao.Tournament) error
+	UpdateTournament(tournamentID int, updates map[string]interface{}) error
+	FindTournaments() ([]dao.Tournament, error)
+	FindTournamentById(id int) (dao.Tournament, error)
+	FindRunningTournaments() ([]dao.Tournament, error)
+	JoinTournament(tournamentID, userID int) error
+	WithdrawFromTournament(tournamentID, userID int) (bool, error)
+	FindPairings(tournamentID int) ([]dao.TournamentPairing, error)
+	CreatePairings(tournament dao.Tournament, round int, pairings []dao.TournamentPairing,
+		newGame func(pairing dao.TournamentPairing) (dao.ChessGame, dao.GameState)) ([]dao.TournamentPairing, error)
+	SettleResults(tournamentID int) (int64, error)
+	FindUnstartedGames(tournamentID int, pairedBefore time.Time) ([]dao.TournamentPairing, error)
+}
+
+type TournamentRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func TournamentRepositoryInit(db *gorm.DB) *TournamentRepositoryImpl {
+	db.AutoMigrate(&dao.Tournament{})
+	db.AutoMigrate(&dao.TournamentPlayer{})
+	db.AutoMigrate(&dao.TournamentPairing{})
+	return &TournamentRepositoryImpl{
+		db: db,
+	}
+}
+
+func (r TournamentRepositoryImpl) SaveTournament(tournament *dao.Tournament) error {
+	if err := r.db.Omit("Players", "CreatedBy").Save(tournament).Error; err != nil {
+		log.Error("Error saving tournament to DB:", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateTournament sets columns of a tournament, such as its status and
+// round, without touching the rest.
+func (r TournamentRepositoryImpl) UpdateTournament(tournamentID int, updates map[string]interface{}) error {
+	if err := r.db.Model(&dao.Tournament{}).Where("id = ?", tournamentID).Updates(updates).Error; err != nil {
+		log.Error("Error updating tournament:", err)
+		return err
+	}
+	return nil
+}
+
+// FindTournaments returns the latest tournaments, newest first, with their
+// organisers but not their players.
+func (r TournamentRepositoryImpl) FindTournaments() ([]dao.Tournament, error) {
+	var tournaments []dao.Tournament
+	err := r.db.
+		Preload("CreatedBy", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name")
+		}).
+		Order("id desc").Limit(tournamentListLimit).Find(&tournaments).Error
+	if err != nil {
+		log.Error("Error finding tournaments:", err)
+		return nil, err
+	}
+	return tournaments, nil
+}
+
+// FindTournamentById returns a tournament with its organiser and its players,
+// in the order they joined.
+func (r TournamentRepositoryImpl) FindTournamentById(id int) (dao.Tournament, error) {
+	var tournament dao.Tournament
+	err := r.db.
+		Preload("CreatedBy", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name")
+		}).
+		Preload("Players", func(db *gorm.DB) *gorm.DB {
+			return db.Order("joined_at, id")
+		}).
+		Preload("Players.User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name")
+		}).
+		First(&tournament, id).Error
+	if err != nil {
+		log.Error("Error finding tournament:", err)
+		return dao.Tournament{}, err
+	}
+	return tournament, nil
+}
+
+// FindRunningTournaments returns the tournaments under way, with their
+// players, for the runner to advance.
+func (r TournamentRepositoryImpl) FindRunningTournaments() ([]dao.Tournament, error) {
+	var ids []int
+	err := r.db.Model(&dao.Tournament{}).Where("status = ?", constant.TournamentStatusRunning).
+		Order("id").Pluck("id", &ids).Error
+	if err != nil {
+		log.Error("Error finding running tournaments:", err)
+		return nil, err
+	}
+	tournaments := make([]dao.Tournament, 0, len(ids))
+	for _, id := range ids {
+		tournament, err := r.FindTournamentById(id)
+		if err != nil {
+			return nil, err
+		}
+		tournaments = append(tournaments, tournament)
+	}
+	return tournaments, nil
+}
+
+// JoinTournament enters userID, or brings back a player who withdrew.
+func (r TournamentRepositoryImpl) JoinTournament(tournamentID, userID int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&dao.TournamentPlayer{}).
+			Where("tournament_id = ? AND user_id = ?", tournamentID, userID).
+			Update("withdrawn", false)
+		if result.Error != nil {
+			log.Error("Error rejoining tournament:", result.Error)
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+		player := dao.TournamentPlayer{TournamentID: tournamentID, UserID: userID, JoinedAt: time.Now()}
+		if err := tx.Create(&player).Error; err != nil {
+			log.Error("Error joining tournament:", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// WithdrawFromTournament stops userID being paired again. false means they
+// were not in the tournament, or had already withdrawn.
+func (r TournamentRepositoryImpl) WithdrawFromTournament(tournamentID, userID int) (bool, error) {
+	result := r.db.Model(&dao.TournamentPlayer{}).
+		Where("tournament_id = ? AND user_id = ? AND NOT withdrawn", tournamentID, userID).
+		Update("withdrawn", true)
+	if result.Error != nil {
+		log.Error("Error withdrawing from tournament:", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FindPairings returns every pairing of a tournament in the order it was made.
+func (r TournamentRepositoryImpl) FindPairings(tournamentID int) ([]dao.TournamentPairing, error) {
+	var pairings []dao.TournamentPairing
+	if err := r.db.Where("tournament_id = ?", tournamentID).Order("id").Find(&pairings).Error; err != nil {
+		log.Error("Error finding tournament pairings:", err)
+		return nil, err
+	}
+	return pairings, nil
+}
+
+// CreatePairings stores pairings and creates their games in one transaction,
+// and moves the tournament's current round on to round. newGame builds the
+// game and its starting state for each pairing that is not a bye; the game
+// and pairing ids are filled in here. It returns the pairings as stored.
+func (r TournamentRepositoryImpl) CreatePairings(tournament dao.Tournament, round int, pairings []dao.TournamentPairing,
+	newGame func(pairing dao.TournamentPairing) (dao.ChessGame, dao.GameState)) ([]dao.TournamentPairing, error) {
+	stored := make([]dao.TournamentPairing, len(pairings))
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for i, pairing := range pairings {
+			pairing.TournamentID = tournament.ID
+			if pairing.BlackUserID != nil {
+				game, state := newGame(pairing)
+				game.TournamentID = &tournament.ID
+				if err := tx.Create(&game).Error; err != nil {
+					return err
+				}
+				state.GameID = game.ID
+				if err := tx.Create(&state).Error; err != nil {
+					return err
+				}
+				pairing.GameID = &game.ID
+			}
+			if err := tx.Create(&pairing).Error; err != nil {
+				return err
+			}
+			stored[i] = pairing
+		}
+		return tx.Model(&dao.Tournament{}).Where("id = ?", tournament.ID).Update("current_round", round).Error
+	})
+	if err != nil {
+		log.Error("Error creating tournament pairings:", err)
+		return nil, err
+	}
+	return stored, nil
+}
+
+// SettleResults copies the result of each finished game onto its pairing,
+// and returns how many pairings it settled. A game's Winner and a pairing's
+// Result share their values, an abort included.
+func (r TournamentRepositoryImpl) SettleResults(tournamentID int) (int64, error) {
+	result := r.db.Exec(`UPDATE tournament_pairings p SET result = g.winner, updated_at = ?
+		FROM chess_games g
+		WHERE p.game_id = g.id AND p.tournament_id = ? AND p.result = '' AND g.winner <> ''
+			AND p.deleted_at IS NULL`, time.Now(), tournamentID)
+	if result.Error != nil {
+		log.Error("Error settling tournament results:", result.Error)
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// FindUnstartedGames returns the pairings made before pairedBefore whose games
+// are still waiting for White's first move.
+func (r TournamentRepositoryImpl) FindUnstartedGames(tournamentID int, pairedBefore time.Time) ([]dao.TournamentPairing, error) {
+	var pairings []dao.TournamentPairing
+	err := r.db.
+		Joins("JOIN chess_games ON chess_games.id = tournament_pairings.game_id").
+		Where("tournament_pairings.tournament_id = ? AND tournament_pairings.result = ''", tournamentID).
+		Where("tournament_pairings.created_at < ? AND COALESCE(chess_games.winner, '') = ''", pairedBefore).
+		Where("NOT EXISTS (SELECT 1 FROM game_moves WHERE game_moves.game_id = chess_games.id)").
+		Find(&pairings).Error
+	if err != nil {
+		log.Error("Error finding unstarted tournament games:", err)
+		return nil, err
+	}
+	return pairings, nil
+}
@@ -11,6 +11,7 @@ type UserRepository interface {
 	FindAllUser() ([]dao.User, error)
 	FindUserById(id int) (dao.User, error)
 	FindUserByToken(token string) (dao.User, error)
+	FindUserByUsername(username string) (dao.User, error)
 	Save(user *dao.User) (dao.User, error)
 	DeleteUserById(id int) error
 }
@@ -43,13 +44,24 @@ func (u UserRepositoryImpl) FindUserById(id int) (dao.User, error) {
 	return user, nil
 }
 
+// FindUserByToken resolves a session token to its user (see
+// findSessionByToken).
 func (u UserRepositoryImpl) FindUserByToken(token string) (dao.User, error) {
-	var user dao.User
-	err := u.db.Where("token = ?", token).First(&user).Error
+	session, err := findSessionByToken(u.db, token)
 	if err != nil {
 		log.Error("Got and error when find user by token. Error: ", err)
 		return dao.User{}, err
 	}
+	return session.User, nil
+}
+
+func (u UserRepositoryImpl) FindUserByUsername(username string) (dao.User, error) {
+	var user dao.User
+	err := u.db.Where("username = ?", username).First(&user).Error
+	if err != nil {
+		log.Error("Got and error when find user by username. Error: ", err)
+		return dao.User{}, err
+	}
 	return user, nil
 }
 
@@ -77,7 +89,8 @@ func (u UserRepositoryImpl) DeleteUserById(id int) error {
 }
 
 func UserRepositoryInit(db *gorm.DB) *UserRepositoryImpl {
-	db.AutoMigrate(&dao.User{})
+	// Role first: users.role_id references it.
+	db.AutoMigrate(&dao.Role{}, &dao.User{})
 	return &UserRepositoryImpl{
 		db: db,
 	}
@@ -1,10 +1,13 @@
 package router
 
 import (
+	"chess-engine/app/constant"
 	"chess-engine/app/middleware"
 	"chess-engine/config"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,6 +16,10 @@ import (
 func Init(init *config.Initialization) *gin.Engine {
 
 	router := gin.New()
+	// Behind a CDN every request reaches us from the CDN's tunnel, so the
+	// client's address, which the auth rate limits key on, is taken from the
+	// header the CDN sets (CF-Connecting-IP for Cloudflare) when one is named.
+	router.TrustedPlatform = os.Getenv("CLIENT_IP_HEADER")
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 
@@ -31,10 +38,13 @@ func Init(init *config.Initialization) *gin.Engine {
 		c.File("./app/static/html/bitboard.html")
 	})
 
-	// WebSocket route
+	// WebSocket routes: one per game, the signed-in user's own channel, and
+	// one per tournament for its standings.
 	router.GET("/ws/:gameId", init.SocketCtrl.HandleWebSocket)
+	router.GET("/ws/user", init.NotifyCtrl.HandleUserWebSocket)
+	router.GET("/ws/tournament/:tournamentId", init.TournamentCtrl.HandleTournamentWebSocket)
 
-	requireAuth := middleware.RequireAuth(init.UserRepo)
+	requireAuth := middleware.RequireAuth(init.SessionRepo)
 
 	// API routes
 	api := router.Group("/api")
@@ -47,6 +57,20 @@ func Init(init *config.Initialization) *gin.Engine {
 			// routes previously had no authentication at all.
 			user.PUT("/:userID", requireAuth, init.UserCtrl.UpdateUserData)
 			user.DELETE("/:userID", requireAuth, init.UserCtrl.DeleteUser)
+			user.GET("/:userID/games", init.ChessCtrl.GetUserGames)
+			user.GET("/:userID/stats", init.ChessCtrl.GetUserStats)
+		}
+		auth := api.Group("/auth")
+		{
+			// Both hash a password, which takes 64 MiB and tens of
+			// milliseconds, so each client IP gets a handful a minute.
+			auth.POST("/register", middleware.RateLimit(5, time.Minute), init.AuthCtrl.Register)
+			auth.POST("/login", middleware.RateLimit(10, time.Minute), init.AuthCtrl.Login)
+			auth.POST("/logout", requireAuth, init.AuthCtrl.Logout)
+			auth.POST("/refresh", requireAuth, init.AuthCtrl.RefreshSession)
+			auth.GET("/sessions", requireAuth, init.AuthCtrl.GetSessions)
+			auth.DELETE("/sessions", requireAuth, init.AuthCtrl.RevokeOtherSessions)
+			auth.DELETE("/sessions/:sessionID", requireAuth, init.AuthCtrl.RevokeSession)
 		}
 		chess := api.Group("/chess")
 		{
@@ -54,9 +78,50 @@ func Init(init *config.Initialization) *gin.Engine {
 			chess.POST("/game", init.ChessCtrl.CreateChessGame)
 			chess.POST("/game/bot", init.ChessCtrl.CreateBotChessGame)
 			chess.POST("/game/local", init.ChessCtrl.CreateLocalChessGame)
+			chess.POST("/game/import", init.ChessCtrl.ImportChessGame)
 			chess.GET("/game/:gameId", init.ChessCtrl.GetChessGameById)
+			chess.GET("/game/:gameId/pgn", init.ChessCtrl.GetChessGamePGN)
+			chess.GET("/game/:gameId/series", init.ChessCtrl.GetGameSeries)
 			chess.POST("/game/join", init.ChessCtrl.JoinChessGame)
 		}
+		// Open to all, but a game in progress may only be analysed by a
+		// signed-in spectator, so the caller is identified when they can be.
+		api.POST("/analysis", middleware.OptionalAuth(init.SessionRepo), init.AnalysisCtrl.AnalysePosition)
+		api.GET("/leaderboard", init.LeaderboardCtrl.GetLeaderboard)
+		seek := api.Group("/seek", requireAuth)
+		{
+			seek.GET("", init.SeekCtrl.GetSeek)
+			seek.POST("", init.SeekCtrl.CreateSeek)
+			seek.DELETE("", init.SeekCtrl.CancelSeek)
+		}
+		challenge := api.Group("/challenge", requireAuth)
+		{
+			challenge.GET("", init.ChallengeCtrl.GetChallenges)
+			challenge.POST("", init.ChallengeCtrl.CreateChallenge)
+			challenge.POST("/:gameId/accept", init.ChallengeCtrl.AcceptChallenge)
+			challenge.POST("/:gameId/decline", init.ChallengeCtrl.DeclineChallenge)
+			challenge.DELETE("/:gameId", init.ChallengeCtrl.CancelChallenge)
+		}
+		tournament := api.Group("/tournament")
+		{
+			tournament.GET("", init.TournamentCtrl.GetTournaments)
+			tournament.POST("", requireAuth, init.TournamentCtrl.CreateTournament)
+			tournament.GET("/:tournamentId", init.TournamentCtrl.GetTournament)
+			tournament.POST("/:tournamentId/join", requireAuth, init.TournamentCtrl.JoinTournament)
+			tournament.POST("/:tournamentId/withdraw", requireAuth, init.TournamentCtrl.WithdrawFromTournament)
+			tournament.POST("/:tournamentId/start", requireAuth, init.TournamentCtrl.StartTournament)
+		}
+		requireAdmin := middleware.RequireRole(constant.RoleAdmin)
+		admin := api.Group("/admin", requireAuth, middleware.RequireRole(constant.RoleModerator))
+		{
+			admin.GET("/users", init.AdminCtrl.GetUsers)
+			admin.POST("/users/:userID/ban", init.AdminCtrl.BanUser)
+			admin.POST("/users/:userID/unban", init.AdminCtrl.UnbanUser)
+			admin.PUT("/users/:userID/role", requireAdmin, init.AdminCtrl.SetUserRole)
+			admin.POST("/games/:gameId/terminate", init.AdminCtrl.TerminateGame)
+			admin.DELETE("/games/abandoned", requireAdmin, init.AdminCtrl.DeleteAbandonedGames)
+			admin.GET("/audit", requireAdmin, init.AdminCtrl.GetAuditLogs)
+		}
 	}
 
 	// Client-side routes (e.g. /game/123) fall back to the SPA shell; everything
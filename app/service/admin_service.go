@@ -0,0 +1,381 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/middleware"
+	"chess-engine/app/pkg"
+	"chess-engine/app/repository"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// The admin API, under /api/admin. Moderators can see users, ban and unban
+// players, and end games; admins can also change roles, delete abandoned games
+// and read the audit log. Every change is written to the audit log.
+
+// Audit log actions.
+const (
+	AuditBan             = "ban"
+	AuditUnban           = "unban"
+	AuditSetRole         = "set_role"
+	AuditTerminateGame   = "terminate_game"
+	AuditDeleteAbandoned = "delete_abandoned_games"
+)
+
+// Page sizes for the admin lists.
+const (
+	defaultAdminLimit = 50
+	maxAdminLimit     = 200
+)
+
+// defaultAbandonedAge is how old a game without a move must be before
+// DELETE /api/admin/games/abandoned counts it abandoned, unless the request
+// says otherwise.
+const defaultAbandonedAge = 24 * time.Hour
+
+// maxReasonLength caps the free-text reason of an admin action.
+const maxReasonLength = 500
+
+type AdminService interface {
+	GetUsers(c *gin.Context)
+	BanUser(c *gin.Context)
+	UnbanUser(c *gin.Context)
+	SetUserRole(c *gin.Context)
+	TerminateGame(c *gin.Context)
+	DeleteAbandonedGames(c *gin.Context)
+	GetAuditLogs(c *gin.Context)
+}
+
+type AdminServiceImpl struct {
+	adminRepository repository.AdminRepository
+	socketService   WebSocketService
+}
+
+// GetUsers lists users, newest first. Query parameters, all optional: q (part
+// of a name or username), status (active|banned), role, cursor and limit.
+func (u AdminServiceImpl) GetUsers(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program admin get users")
+	filter := repository.AdminUserFilter{
+		Query:  strings.TrimSpace(c.Query("q")),
+		Before: queryInt(c, "cursor"),
+		Limit:  adminLimit(c),
+	}
+	switch status := c.Query("status"); status {
+	case "":
+	case "active":
+		filter.Status = constant.UserStatusActive
+	case "banned":
+		filter.Status = constant.UserStatusBanned
+	default:
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "status must be active or banned")
+	}
+	if role := c.Query("role"); role != "" {
+		if constant.RoleRank(role) < 0 {
+			pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "unknown role "+role)
+		}
+		filter.Role = role
+	}
+
+	pageSize := filter.Limit
+	filter.Limit++
+	users, err := u.adminRepository.FindUsers(filter)
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	page := dto.AdminUsersPage{Users: users}
+	if len(users) > pageSize {
+		page.Users = users[:pageSize]
+		next := page.Users[pageSize-1].ID
+		page.NextCursor = &next
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, page))
+}
+
+// BanUser bans a user. Their sessions stop working at once, and they cannot
+// log in, until they are unbanned.
+func (u AdminServiceImpl) BanUser(c *gin.Context) {
+	u.setUserStatus(c, constant.UserStatusBanned, AuditBan)
+}
+
+// UnbanUser lifts a ban.
+func (u AdminServiceImpl) UnbanUser(c *gin.Context) {
+	u.setUserStatus(c, constant.UserStatusActive, AuditUnban)
+}
+
+func (u AdminServiceImpl) setUserStatus(c *gin.Context, status int, action string) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program admin " + action + " user")
+	actor := adminActor(c)
+	var request dto.AdminReasonRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error("Happened error when mapping request from FE. Error", err)
+		pkg.PanicException(constant.InvalidRequest)
+	}
+	target := u.outrankedUser(c, actor)
+
+	changed, err := u.adminRepository.SetUserStatus(target.ID, status, dao.AuditLog{
+		ActorID:    actor.ID,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   target.ID,
+		Reason:     requireReason(request.Reason),
+	})
+	if err != nil {
+		log.Error("Happened error when saving data to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	if !changed {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "user is already "+statusName(status))
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, pkg.Null()))
+}
+
+// SetUserRole gives a user a role. Admin only, and not on one's own account,
+// so the last admin cannot demote themself by accident.
+func (u AdminServiceImpl) SetUserRole(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program admin set user role")
+	actor := adminActor(c)
+	var request dto.SetRoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error("Happened error when mapping request from FE. Error", err)
+		pkg.PanicException(constant.InvalidRequest)
+	}
+	if constant.RoleRank(request.Role) < 0 {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+			"role must be one of "+strings.Join(constant.Roles, ", "))
+	}
+	target := u.pathUser(c)
+	if target.ID == actor.ID {
+		pkg.PanicException_(constant.Forbidden.GetResponseStatus(), "you cannot change your own role")
+	}
+
+	changed, err := u.adminRepository.SetUserRole(target.ID, request.Role, dao.AuditLog{
+		ActorID:    actor.ID,
+		Action:     AuditSetRole,
+		TargetType: "user",
+		TargetID:   target.ID,
+		Reason:     requireReason(request.Reason),
+		Detail:     target.RoleName() + " -> " + request.Role,
+	})
+	if err != nil {
+		log.Error("Happened error when saving data to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	if !changed {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "user already has role "+request.Role)
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, pkg.Null()))
+}
+
+// TerminateGame ends a game in progress with the result the moderator gives.
+// The players see the reason, and the game does not change ratings.
+func (u AdminServiceImpl) TerminateGame(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program admin terminate game")
+	actor := adminActor(c)
+	var request dto.TerminateGameRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error("Happened error when mapping request from FE. Error", err)
+		pkg.PanicException(constant.InvalidRequest)
+	}
+	var winner string
+	switch request.Result {
+	case "white":
+		winner = "w"
+	case "black":
+		winner = "b"
+	case "draw":
+		winner = "d"
+	case "abort":
+		winner = constant.WinnerAborted
+	default:
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "result must be white, black, draw or abort")
+	}
+	reason := requireReason(request.Reason)
+	gameID, err := strconv.Atoi(c.Param("gameId"))
+	if err != nil || gameID <= 0 {
+		pkg.PanicException(constant.InvalidRequest)
+	}
+
+	// The audit entry is saved with the result, so the game is not ended
+	// unrecorded.
+	switch err := u.socketService.TerminateGame(strconv.Itoa(gameID), winner, reason, &dao.AuditLog{
+		ActorID:    actor.ID,
+		Action:     AuditTerminateGame,
+		TargetType: "game",
+		TargetID:   gameID,
+		Reason:     reason,
+		Detail:     "result " + request.Result,
+	}); {
+	case errors.Is(err, ErrGameNotFound):
+		pkg.PanicException(constant.DataNotFound)
+	case errors.Is(err, ErrGameOver):
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "game is already over")
+	case err != nil:
+		pkg.PanicException(constant.UnknownError)
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, pkg.Null()))
+}
+
+// DeleteAbandonedGames deletes unfinished games that never got a move and are
+// older than older_than_hours (default 24, at least 1). Games that may still be
+// waiting -- ones with more time than that for a move, direct challenges and
+// tournament games -- are kept; see AdminRepository.DeleteAbandonedGames.
+// Admin only.
+func (u AdminServiceImpl) DeleteAbandonedGames(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program admin delete abandoned games")
+	actor := adminActor(c)
+	age := defaultAbandonedAge
+	if hours := queryInt(c, "older_than_hours"); hours != 0 {
+		if hours < 1 {
+			pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "older_than_hours must be at least 1")
+		}
+		age = time.Duration(hours) * time.Hour
+	}
+
+	ids, err := u.adminRepository.DeleteAbandonedGames(time.Now(), age, dao.AuditLog{
+		ActorID:    actor.ID,
+		Action:     AuditDeleteAbandoned,
+		TargetType: "game",
+		Reason:     "older than " + age.String(),
+	})
+	if err != nil {
+		log.Error("Happened error when saving data to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	if ids == nil {
+		ids = []int{}
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, dto.DeletedGames{GameIDs: ids}))
+}
+
+// GetAuditLogs pages through the audit log, newest first. Admin only.
+func (u AdminServiceImpl) GetAuditLogs(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program admin get audit logs")
+	pageSize := adminLimit(c)
+	entries, err := u.adminRepository.FindAuditLogs(queryInt(c, "cursor"), pageSize+1)
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	page := dto.AuditLogPage{Entries: entries}
+	if len(entries) > pageSize {
+		page.Entries = entries[:pageSize]
+		next := page.Entries[pageSize-1].ID
+		page.NextCursor = &next
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, page))
+}
+
+// pathUser loads the user named by the :userID path parameter, with their role.
+func (u AdminServiceImpl) pathUser(c *gin.Context) dao.User {
+	user, err := u.adminRepository.FindUserWithRole(userIDParam(c))
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.DataNotFound)
+	}
+	return user
+}
+
+// outrankedUser loads the :userID user and refuses unless actor's role is
+// above theirs: a moderator can act on players only, an admin on moderators
+// too, and nobody on themself.
+func (u AdminServiceImpl) outrankedUser(c *gin.Context, actor dao.User) dao.User {
+	target := u.pathUser(c)
+	if constant.RoleRank(target.RoleName()) >= constant.RoleRank(actor.RoleName()) {
+		pkg.PanicException_(constant.Forbidden.GetResponseStatus(), "you cannot act on a "+target.RoleName())
+	}
+	return target
+}
+
+// adminActor is the user making an admin request.
+func adminActor(c *gin.Context) dao.User {
+	actor, ok := middleware.AuthUser(c)
+	if !ok {
+		log.Error("route is missing the auth middleware")
+		pkg.PanicException(constant.Unauthorized)
+	}
+	return actor
+}
+
+// requireReason validates the reason every admin action must give.
+func requireReason(reason string) string {
+	reason = strings.TrimSpace(reason)
+	if reason == "" || len(reason) > maxReasonLength {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+			"reason is required, at most "+strconv.Itoa(maxReasonLength)+" characters")
+	}
+	return reason
+}
+
+func adminLimit(c *gin.Context) int {
+	limit := queryInt(c, "limit")
+	if limit == 0 {
+		return defaultAdminLimit
+	}
+	if limit < 0 || limit > maxAdminLimit {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+			"limit must be between 1 and "+strconv.Itoa(maxAdminLimit))
+	}
+	return limit
+}
+
+func statusName(status int) string {
+	if status == constant.UserStatusBanned {
+		return "banned"
+	}
+	return "active"
+}
+
+// PromoteAdmin makes the registered user username an admin, so a fresh
+// deployment has someone who can hand out roles. The operator runs it through
+// cmd/admin once the account exists; nothing promotes on start-up, where a
+// configured name could be claimed by whoever registered it first. false means
+// the user was already an admin.
+func PromoteAdmin(adminRepository repository.AdminRepository, userRepository repository.UserRepository,
+	username string) (bool, error) {
+	user, err := userRepository.FindUserByUsername(normaliseUsername(username))
+	if err != nil {
+		return false, err
+	}
+	// ActorID 0 is the server itself.
+	return adminRepository.SetUserRole(user.ID, constant.RoleAdmin, dao.AuditLog{
+		Action:     AuditSetRole,
+		TargetType: "user",
+		TargetID:   user.ID,
+		Reason:     "promoted from the command line",
+		Detail:     "-> " + constant.RoleAdmin,
+	})
+}
+
+func AdminServiceInit(adminRepository repository.AdminRepository, socketService WebSocketService) *AdminServiceImpl {
+	return &AdminServiceImpl{
+		adminRepository: adminRepository,
+		socketService:   socketService,
+	}
+}
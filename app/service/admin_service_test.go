@@ -0,0 +1,61 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/repository"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type usersByName struct {
+	repository.UserRepository
+	users map[string]dao.User
+}
+
+func (r usersByName) FindUserByUsername(username string) (dao.User, error) {
+	user, ok := r.users[username]
+	if !ok {
+		return dao.User{}, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+
+// adminRepo records the roles set and the audit entries written with them.
+type adminRepo struct {
+	repository.AdminRepository
+	roles map[int]string
+	audit []dao.AuditLog
+}
+
+func (r *adminRepo) SetUserRole(userID int, role string, entry dao.AuditLog) (bool, error) {
+	if r.roles[userID] == role {
+		return false, nil
+	}
+	r.roles[userID] = role
+	r.audit = append(r.audit, entry)
+	return true, nil
+}
+
+func TestPromoteAdmin(t *testing.T) {
+	users := usersByName{users: map[string]dao.User{"alice": {ID: 7}}}
+	admins := &adminRepo{roles: map[int]string{}}
+
+	if changed, err := PromoteAdmin(admins, users, " Alice "); !changed || err != nil {
+		t.Fatalf("PromoteAdmin = %v, %v; want promoted", changed, err)
+	}
+	if admins.roles[7] != constant.RoleAdmin {
+		t.Errorf("role = %q, want admin", admins.roles[7])
+	}
+	if len(admins.audit) != 1 || admins.audit[0].TargetID != 7 || admins.audit[0].Action != AuditSetRole {
+		t.Errorf("audit = %+v, want one set_role entry for user 7", admins.audit)
+	}
+
+	if changed, err := PromoteAdmin(admins, users, "alice"); changed || err != nil {
+		t.Errorf("promoting an admin again = %v, %v; want unchanged", changed, err)
+	}
+	if _, err := PromoteAdmin(admins, users, "bob"); err == nil {
+		t.Error("promoting an unregistered name succeeded")
+	}
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/engine"
+	"chess-engine/app/middleware"
+	"chess-engine/app/pkg"
+	"chess-engine/app/repository"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// Limits on one analysis request. A search is CPU-bound and the server has
+// live games to run, so the caller can ask for less than these but not more.
+const (
+	maxAnalysisDepth    = 30
+	maxAnalysisMoveTime = 10 * time.Second
+	maxAnalysisMultiPV  = 5
+	// defaultAnalysisMoveTime applies when the request sets neither a depth
+	// nor a move time.
+	defaultAnalysisMoveTime = time.Second
+	// analysisHashMB is the size of the transposition table each request gets.
+	// Tables are per request so two analyses never see each other's entries,
+	// and are dropped when the request finishes.
+	analysisHashMB = 16
+	// analysisQueueDepth is how many requests may wait for a worker before new
+	// ones are turned away as busy.
+	analysisQueueDepth = 16
+)
+
+type AnalysisService interface {
+	AnalysePosition(c *gin.Context)
+}
+
+type AnalysisServiceImpl struct {
+	chessRepository repository.ChessRepository
+	jobs            chan analysisJob
+}
+
+// analysisJob is one queued search. stop is closed if the client goes away
+// before a worker picks the job up or while it runs; done receives the result.
+type analysisJob struct {
+	gs      dao.GameState
+	history []uint64
+	depth   int
+	budget  time.Duration
+	multiPV int
+	stop    <-chan struct{}
+	done    chan<- dto.AnalysisResponse
+}
+
+// AnalysePosition runs the engine on a position and returns its best lines.
+func (u AnalysisServiceImpl) AnalysePosition(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program analyse position")
+	var request dto.AnalysisRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error("Happened error when mapping request from FE. Error", err)
+		pkg.PanicException(constant.InvalidRequest)
+	}
+
+	switch {
+	case request.Depth < 0 || request.Depth > maxAnalysisDepth:
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+			fmt.Sprintf("depth must be between 1 and %d, or 0 to search by time alone", maxAnalysisDepth))
+	case request.MoveTimeMs < 0 || time.Duration(request.MoveTimeMs)*time.Millisecond > maxAnalysisMoveTime:
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+			fmt.Sprintf("movetime_ms must be between 1 and %d, or 0 for the default", maxAnalysisMoveTime.Milliseconds()))
+	case request.MultiPV < 0 || request.MultiPV > maxAnalysisMultiPV:
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+			fmt.Sprintf("multipv must be between 1 and %d, or 0 for one line", maxAnalysisMultiPV))
+	}
+	// A depth alone is still held to the time cap: deep searches of sharp
+	// positions can otherwise run for minutes.
+	budget := time.Duration(request.MoveTimeMs) * time.Millisecond
+	if budget == 0 {
+		budget = maxAnalysisMoveTime
+		if request.Depth == 0 {
+			budget = defaultAnalysisMoveTime
+		}
+	}
+	caller, signedIn := middleware.AuthUser(c)
+	gs, history := u.analysisPosition(request, caller, signedIn)
+	multiPV := request.MultiPV
+	if multiPV == 0 {
+		multiPV = 1
+	}
+
+	done := make(chan dto.AnalysisResponse, 1)
+	job := analysisJob{
+		gs:      gs,
+		history: history,
+		depth:   request.Depth,
+		budget:  budget,
+		multiPV: multiPV,
+		stop:    c.Request.Context().Done(),
+		done:    done,
+	}
+	select {
+	case u.jobs <- job:
+	default:
+		pkg.PanicException_(constant.ServiceBusy.GetResponseStatus(), "too many analyses in progress, try again shortly")
+	}
+
+	select {
+	case response := <-done:
+		c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, response))
+	case <-c.Request.Context().Done():
+		// The client left; the worker sees the same signal and stops.
+		log.Info("analysis request cancelled by the client")
+	}
+}
+
+// analysisPosition resolves the request to the position to search and the
+// keys of the positions before it, for repetition detection.
+//
+// A game still in progress is analysed only for a signed-in caller who is not
+// playing in it: engine lines for one's own game are cheating, and an
+// anonymous caller could be either player.
+func (u AnalysisServiceImpl) analysisPosition(request dto.AnalysisRequest, caller dao.User, signedIn bool) (dao.GameState, []uint64) {
+	switch {
+	case request.FEN != "" && request.GameId != "":
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "give either fen or game_id, not both")
+	case request.FEN != "":
+		if request.Ply != nil {
+			pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "ply applies only with game_id")
+		}
+		gs, err := engine.ParseStartFEN(request.FEN)
+		if err != nil {
+			pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), err.Error())
+		}
+		return gs, nil
+	case request.GameId != "":
+		game, err := u.chessRepository.FindChessGameById(request.GameId)
+		if err != nil {
+			log.Error("Happened error when get data from database. Error", err)
+			pkg.PanicException(constant.DataNotFound)
+		}
+		if game.Winner == "" {
+			if !signedIn {
+				pkg.PanicException_(constant.Unauthorized.GetResponseStatus(), "sign in to analyse a game in progress")
+			}
+			if seatOf(game, caller) != "" {
+				pkg.PanicException_(constant.Forbidden.GetResponseStatus(), "a game in progress cannot be analysed by its players")
+			}
+		}
+		moves := engine.RecordedMoves(game.Moves)
+		if request.Ply != nil {
+			if *request.Ply < 0 || *request.Ply > len(moves) {
+				pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+					fmt.Sprintf("ply must be between 0 and %d for this game", len(moves)))
+			}
+			moves = moves[:*request.Ply]
+		}
+		start := engine.GameStartState(game)
+		gs, err := engine.ReplayMoves(start, moves)
+		if err != nil {
+			log.Errorf("Happened error when replaying game %s for analysis. Error %v", request.GameId, err)
+			pkg.PanicException(constant.UnknownError)
+		}
+		return gs, engine.SearchHistory(engine.ReplayGameKeys(start, moves))
+	}
+	pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "fen or game_id is required")
+	return dao.GameState{}, nil
+}
+
+// analysisWorker runs queued searches one at a time, for the life of the
+// process.
+func analysisWorker(jobs <-chan analysisJob) {
+	for job := range jobs {
+		select {
+		case <-job.stop:
+			continue // abandoned while queued
+		default:
+		}
+		job.done <- runAnalysis(job)
+	}
+}
+
+// runAnalysis searches for job.multiPV lines at once: the search scores that
+// many root moves exactly, rather than being run again for each line.
+func runAnalysis(job analysisJob) dto.AnalysisResponse {
+	start := time.Now()
+	response := dto.AnalysisResponse{FEN: engine.ToFEN(job.gs), Lines: []dto.AnalysisLine{}}
+	res := engine.Search(job.gs, engine.SearchOptions{
+		MaxDepth: job.depth,
+		MoveTime: job.budget,
+		Stop:     job.stop,
+		History:  job.history,
+		Table:    engine.NewTranspositionTable(analysisHashMB),
+		MultiPV:  job.multiPV,
+	}, nil)
+	for _, line := range res.Lines {
+		response.Lines = append(response.Lines, analysisLine(job.gs, line, res))
+	}
+	response.Nodes = res.Nodes
+	response.TimeMs = time.Since(start).Milliseconds()
+	return response
+}
+
+func analysisLine(gs dao.GameState, line engine.SearchLine, res engine.SearchResult) dto.AnalysisLine {
+	result := dto.AnalysisLine{
+		Score: line.Score,
+		Mate:  line.Mate,
+		PV:    make([]string, 0, len(line.PV)),
+		Depth: res.Depth,
+		Nodes: res.Nodes,
+	}
+	for _, m := range line.PV {
+		result.PV = append(result.PV, engine.MoveToUCI(m))
+	}
+	san, err := engine.SANLine(gs, line.PV)
+	if err != nil {
+		// The PV comes from the search's own move generator, so this is a
+		// bug; the UCI line is still worth returning.
+		log.Errorf("Happened error when converting PV %v to SAN. Error %v", result.PV, err)
+	}
+	result.PVSAN = san
+	return result
+}
+
+// AnalysisServiceInit starts the worker pool: one worker per two CPUs, at
+// least one, so analysis can never take every core from the live games.
+func AnalysisServiceInit(chessRepo repository.ChessRepository) *AnalysisServiceImpl {
+	jobs := make(chan analysisJob, analysisQueueDepth)
+	workers := runtime.NumCPU() / 2
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go analysisWorker(jobs)
+	}
+	return &AnalysisServiceImpl{
+		chessRepository: chessRepo,
+		jobs:            jobs,
+	}
+}
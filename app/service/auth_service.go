@@ -0,0 +1,313 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/middleware"
+	"chess-engine/app/pkg"
+	"chess-engine/app/repository"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// Registered accounts and their sessions. A guest is a dao.User known only by
+// the token of its one session; registering gives the row a username and
+// password so it can be signed back into from anywhere. Each login is a new
+// session (dao.Session), listed and revoked on its own.
+
+// Password length limits. The upper bound keeps a hash request from being
+// used to make the server chew through megabytes of input.
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 128
+)
+
+// maxDeviceLength caps a session's device label.
+const maxDeviceLength = 120
+
+// usernamePattern is what a username may look like once lowercased.
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_-]{3,24}$`)
+
+type AuthService interface {
+	Register(c *gin.Context)
+	Login(c *gin.Context)
+	Logout(c *gin.Context)
+	RefreshSession(c *gin.Context)
+	GetSessions(c *gin.Context)
+	RevokeSession(c *gin.Context)
+	RevokeOtherSessions(c *gin.Context)
+}
+
+type AuthServiceImpl struct {
+	userRepository    repository.UserRepository
+	sessionRepository repository.SessionRepository
+}
+
+// Register sets a username and password. Given a guest's token it claims that
+// guest's row, so the games played as a guest stay with the account; without
+// a token it creates a fresh account. The username becomes the display name.
+func (u AuthServiceImpl) Register(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program register user")
+	var request dto.RegisterRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error("Happened error when mapping request from FE. Error", err)
+		pkg.PanicException(constant.InvalidRequest)
+	}
+	username := normaliseUsername(request.Username)
+	if !usernamePattern.MatchString(username) || username == strings.ToLower(constant.BotName) {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+			"username must be 3 to 24 letters, digits, '_' or '-'")
+	}
+	if n := len(request.Password); n < minPasswordLength || n > maxPasswordLength {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "password must be 8 to 128 characters")
+	}
+	if _, err := u.userRepository.FindUserByUsername(username); err == nil {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "username is taken")
+	}
+
+	user := dao.User{Status: constant.UserStatusActive}
+	var session dao.Session
+	if request.Token != "" {
+		var err error
+		session, err = u.sessionRepository.FindSessionByToken(request.Token)
+		if err != nil {
+			log.Error("Happened error when finding session by token. Error", err)
+			pkg.PanicException(constant.Unauthorized)
+		}
+		if session.User.Username != nil {
+			pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "this account is already registered")
+		}
+		user = session.User
+	}
+	user.Username = &username
+	user.Name = username
+	user.PasswordHash = pkg.HashPassword(request.Password)
+
+	saved, err := u.userRepository.Save(&user)
+	if err != nil {
+		// Most likely a concurrent registration won the username between the
+		// check above and the unique index.
+		log.Error("Happened error when saving data to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+
+	// A claimed guest keeps the session it registered from.
+	token := request.Token
+	if token == "" {
+		session, token = createSession(u.sessionRepository, saved.ID, deviceLabel(c, request.Device))
+	}
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, createdUser(saved, session, token)))
+}
+
+// Login exchanges a username and password for a new session.
+func (u AuthServiceImpl) Login(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program login user")
+	var request dto.LoginRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error("Happened error when mapping request from FE. Error", err)
+		pkg.PanicException(constant.InvalidRequest)
+	}
+
+	user, err := u.userRepository.FindUserByUsername(normaliseUsername(request.Username))
+	if err != nil || user.PasswordHash == "" {
+		// Hash anyway, so an unknown username takes as long to refuse as a
+		// wrong password and the timing does not say which names exist.
+		pkg.CheckPassword(request.Password, dummyPasswordHash())
+		pkg.PanicException_(constant.Unauthorized.GetResponseStatus(), "wrong username or password")
+	}
+	if !pkg.CheckPassword(request.Password, user.PasswordHash) {
+		pkg.PanicException_(constant.Unauthorized.GetResponseStatus(), "wrong username or password")
+	}
+	if user.Status == constant.UserStatusBanned {
+		pkg.PanicException_(constant.Forbidden.GetResponseStatus(), "account is banned")
+	}
+
+	session, token := createSession(u.sessionRepository, user.ID, deviceLabel(c, request.Device))
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, createdUser(user, session, token)))
+}
+
+// Logout ends the session the request was made with. Guests are refused:
+// with no password, a guest signed out can never get back in.
+func (u AuthServiceImpl) Logout(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program logout user")
+	session := authSession(c)
+	if session.User.PasswordHash == "" {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+			"set a password before logging out, or this guest account cannot be recovered")
+	}
+
+	if _, err := u.sessionRepository.RevokeSession(session.UserID, session.ID); err != nil {
+		log.Error("Happened error when saving data to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, pkg.Null()))
+}
+
+// RefreshSession rotates the request's session token: the response carries a
+// new token with a fresh expiry, and the old one stops working.
+func (u AuthServiceImpl) RefreshSession(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program refresh session")
+	session := authSession(c)
+	rotated, token, err := u.sessionRepository.RotateSession(session)
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		// Revoked, or refreshed by a concurrent request, since RequireAuth
+		// looked it up.
+		pkg.PanicException(constant.Unauthorized)
+	}
+	if err != nil {
+		log.Error("Happened error when saving data to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, createdUser(session.User, rotated, token)))
+}
+
+// GetSessions lists the caller's live sessions.
+func (u AuthServiceImpl) GetSessions(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program get sessions")
+	current := authSession(c)
+	sessions, err := u.sessionRepository.FindUserSessions(current.UserID)
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	infos := make([]dto.SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, dto.SessionInfo{Session: session, Current: session.ID == current.ID})
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, infos))
+}
+
+// RevokeSession ends one of the caller's sessions, by the id from
+// GetSessions. Revoking the current session is allowed and works as a logout.
+func (u AuthServiceImpl) RevokeSession(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program revoke session")
+	current := authSession(c)
+	sessionID, err := strconv.Atoi(c.Param("sessionID"))
+	if err != nil {
+		log.Error("Invalid sessionID path parameter:", err)
+		pkg.PanicException(constant.InvalidRequest)
+	}
+	revoked, err := u.sessionRepository.RevokeSession(current.UserID, sessionID)
+	if err != nil {
+		log.Error("Happened error when saving data to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	if !revoked {
+		pkg.PanicException(constant.DataNotFound)
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, pkg.Null()))
+}
+
+// RevokeOtherSessions ends every session of the caller's but the current one,
+// for "sign out everywhere else".
+func (u AuthServiceImpl) RevokeOtherSessions(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program revoke other sessions")
+	current := authSession(c)
+	if err := u.sessionRepository.RevokeOtherSessions(current.UserID, current.ID); err != nil {
+		log.Error("Happened error when saving data to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, pkg.Null()))
+}
+
+// createSession signs userID in and returns the session and its token.
+func createSession(sessionRepository repository.SessionRepository, userID int, device string) (dao.Session, string) {
+	session, token, err := sessionRepository.CreateSession(userID, device)
+	if err != nil {
+		log.Error("Happened error when saving data to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	return session, token
+}
+
+// authSession returns the session resolved by RequireAuth.
+func authSession(c *gin.Context) dao.Session {
+	session, ok := middleware.AuthSession(c)
+	if !ok {
+		log.Error("route is missing the auth middleware")
+		pkg.PanicException(constant.Unauthorized)
+	}
+	return session
+}
+
+// deviceLabel is the label for a new session: the client's own if it sent
+// one, otherwise its User-Agent.
+func deviceLabel(c *gin.Context, device string) string {
+	device = strings.TrimSpace(device)
+	if device == "" {
+		device = c.Request.UserAgent()
+	}
+	if len(device) > maxDeviceLength {
+		device = device[:maxDeviceLength]
+	}
+	return device
+}
+
+func normaliseUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// dummyPasswordHash is a hash no password is checked against for real, made on
+// first use so start-up does not pay for it.
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash = pkg.HashPassword(pkg.GenerateRandomString(32))
+	})
+	return dummyHash
+}
+
+// createdUser is the response that carries a session token, for the routes
+// that hand one out.
+func createdUser(user dao.User, session dao.Session, token string) dto.CreatedUser {
+	created := dto.CreatedUser{
+		ID:        user.ID,
+		Name:      user.Name,
+		Status:    user.Status,
+		MetaData:  user.MetaData,
+		Token:     token,
+		ExpiresAt: session.ExpiresAt,
+	}
+	if user.Username != nil {
+		created.Username = *user.Username
+	}
+	return created
+}
+
+func AuthServiceInit(userRepository repository.UserRepository, sessionRepository repository.SessionRepository) *AuthServiceImpl {
+	return &AuthServiceImpl{
+		userRepository:    userRepository,
+		sessionRepository: sessionRepository,
+	}
+}
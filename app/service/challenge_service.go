@@ -0,0 +1,359 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/engine"
+	"chess-engine/app/pkg"
+	"chess-engine/app/repository"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Direct challenges. A challenge is a game created with the challenger
+// seated and the other seat reserved for one named user, who is told on their
+// user channel and can accept -- taking the seat -- or decline. Until then
+// the game stays out of the lobby and nobody else can join it by invite code.
+// An unanswered challenge lapses after repository.ChallengeTTL, and is swept
+// up -- deleted, and both sides told -- within challengeSweepInterval of that.
+// The sweep reads the database, so challenges that lapse while the server is
+// down are cleared when it comes back.
+
+// challengeSweepInterval is how often lapsed challenges are looked for.
+const challengeSweepInterval = time.Minute
+
+type ChallengeService interface {
+	CreateChallenge(c *gin.Context)
+	GetChallenges(c *gin.Context)
+	AcceptChallenge(c *gin.Context)
+	DeclineChallenge(c *gin.Context)
+	CancelChallenge(c *gin.Context)
+}
+
+type ChallengeServiceImpl struct {
+	chessRepository     repository.ChessRepository
+	userRepository      repository.UserRepository
+	notificationService NotificationService
+}
+
+// CreateChallenge challenges another user to a game.
+func (u ChallengeServiceImpl) CreateChallenge(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program create challenge")
+	challenger := authSession(c).User
+	var request dto.ChallengeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error("Happened error when mapping request from FE. Error", err)
+		pkg.PanicException(constant.InvalidRequest)
+	}
+	timeControl := parseTimeControl(request.TimeControlRequest)
+	start := parseStartPosition(request.FEN)
+	target := u.challengeTarget(request)
+	if target.ID == challenger.ID {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "you cannot challenge yourself")
+	}
+	if target.Name == constant.BotName {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "play the bot from a bot game instead")
+	}
+	if target.Status == constant.UserStatusBanned {
+		pkg.PanicException(constant.DataNotFound)
+	}
+
+	asWhite := pkg.GenerateRandomBool()
+	switch strings.ToLower(request.Colour) {
+	case "", "random":
+	case "white", "w":
+		asWhite = true
+	case "black", "b":
+		asWhite = false
+	default:
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "colour must be white, black or random")
+	}
+
+	game := dao.ChessGame{
+		InviteCode:       pkg.GenerateRandomString(20),
+		StartFEN:         request.FEN,
+		Rated:            request.Rated,
+		ChallengedUserId: &target.ID,
+	}
+	timeControl.Apply(&game)
+	if asWhite {
+		game.WhiteUserId = &challenger.ID
+	} else {
+		game.BlackUserId = &challenger.ID
+	}
+	if err := u.chessRepository.SaveChessGameToDB(&game); err != nil {
+		log.Error("Happened error when saving game to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	if err := storeInitialState(u.chessRepository, game.ID, start, timeControl); err != nil {
+		log.Error("Happened error when saving game state to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	log.Infof("User %d challenged user %d to game %d", challenger.ID, target.ID, game.ID)
+
+	if asWhite {
+		game.WhiteUser = &dao.User{ID: challenger.ID, Name: challenger.Name}
+	} else {
+		game.BlackUser = &dao.User{ID: challenger.ID, Name: challenger.Name}
+	}
+	game.ChallengedUser = &dao.User{ID: target.ID, Name: target.Name}
+	challenge := challengeOf(game, time.Now())
+	u.notificationService.Notify(target.ID, dto.WebSocketMessage{
+		Type:    dto.MessageChallenge,
+		Status:  "success",
+		Message: challenger.Name + " challenges you to a game",
+		Payload: challenge,
+	})
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, challenge))
+}
+
+// GetChallenges lists the caller's open challenges, sent and received.
+func (u ChallengeServiceImpl) GetChallenges(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program get challenges")
+	user := authSession(c).User
+	games, err := u.chessRepository.FindOpenChallenges(user.ID)
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	ids := make([]int, len(games))
+	for i, game := range games {
+		ids[i] = game.ID
+	}
+	created, err := u.chessRepository.FindChessGamesCreatedAt(ids)
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+
+	result := dto.Challenges{Incoming: []dto.Challenge{}, Outgoing: []dto.Challenge{}}
+	for _, game := range games {
+		challenge := challengeOf(game, created[game.ID])
+		if *game.ChallengedUserId == user.ID {
+			result.Incoming = append(result.Incoming, challenge)
+		} else {
+			result.Outgoing = append(result.Outgoing, challenge)
+		}
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, result))
+}
+
+// AcceptChallenge seats the challenged user and starts the game.
+func (u ChallengeServiceImpl) AcceptChallenge(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program accept challenge")
+	user := authSession(c).User
+	game := u.openChallenge(c)
+	if *game.ChallengedUserId != user.ID {
+		pkg.PanicException_(constant.Forbidden.GetResponseStatus(), "this challenge is for another player")
+	}
+	accepted, err := u.chessRepository.AcceptChallenge(game, user.ID)
+	if err != nil {
+		log.Error("Happened error when saving game to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	if !accepted {
+		pkg.PanicException_(constant.DataNotFound.GetResponseStatus(), "the challenge is no longer open")
+	}
+	log.Infof("User %d accepted the challenge of game %d", user.ID, game.ID)
+
+	// The challenger may already be watching the game, which puts it in the
+	// cache with the seat still empty.
+	if full, err := u.chessRepository.FindChessGameById(strconv.Itoa(game.ID)); err == nil {
+		if err := u.chessRepository.SaveChessGameToCache(&full); err != nil {
+			log.Warn("Could not update game cache: ", err)
+		}
+	}
+	u.notifyChallenger(game, dto.MessageChallengeAccepted, user.Name+" accepted your challenge")
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, game.ID))
+}
+
+// DeclineChallenge turns a challenge down; the game is deleted.
+func (u ChallengeServiceImpl) DeclineChallenge(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program decline challenge")
+	user := authSession(c).User
+	game := u.openChallenge(c)
+	if *game.ChallengedUserId != user.ID {
+		pkg.PanicException_(constant.Forbidden.GetResponseStatus(), "this challenge is for another player")
+	}
+	u.withdraw(game)
+	u.notifyChallenger(game, dto.MessageChallengeDeclined, user.Name+" declined your challenge")
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, game.ID))
+}
+
+// CancelChallenge calls off the caller's own challenge before it is answered.
+func (u ChallengeServiceImpl) CancelChallenge(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program cancel challenge")
+	user := authSession(c).User
+	game := u.openChallenge(c)
+	if challengerOf(game) == nil || challengerOf(game).ID != user.ID {
+		pkg.PanicException_(constant.Forbidden.GetResponseStatus(), "only the challenger can cancel a challenge")
+	}
+	u.withdraw(game)
+	u.notificationService.Notify(*game.ChallengedUserId, dto.WebSocketMessage{
+		Type:    dto.MessageChallengeCancelled,
+		Status:  "success",
+		Message: user.Name + " called off their challenge",
+		Payload: challengeOf(game, time.Time{}),
+	})
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, game.ID))
+}
+
+// challengeTarget looks up the user a challenge request names.
+func (u ChallengeServiceImpl) challengeTarget(request dto.ChallengeRequest) dao.User {
+	var target dao.User
+	var err error
+	switch {
+	case strings.TrimSpace(request.Username) != "":
+		target, err = u.userRepository.FindUserByUsername(normaliseUsername(request.Username))
+	case request.UserID > 0:
+		target, err = u.userRepository.FindUserById(request.UserID)
+	default:
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "name the player to challenge by username or user_id")
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		pkg.PanicException(constant.DataNotFound)
+	}
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	return target
+}
+
+// openChallenge loads the open challenge named by the :gameId path parameter.
+func (u ChallengeServiceImpl) openChallenge(c *gin.Context) dao.ChessGame {
+	gameID, err := strconv.Atoi(c.Param("gameId"))
+	if err != nil || gameID <= 0 {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "invalid game id")
+	}
+	game, err := u.chessRepository.FindOpenChallenge(gameID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		pkg.PanicException_(constant.DataNotFound.GetResponseStatus(), "the challenge is no longer open")
+	}
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	return game
+}
+
+func (u ChallengeServiceImpl) withdraw(game dao.ChessGame) {
+	withdrawn, err := u.chessRepository.WithdrawChallenge(game.ID)
+	if err != nil {
+		log.Error("Happened error when deleting data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	if !withdrawn {
+		pkg.PanicException_(constant.DataNotFound.GetResponseStatus(), "the challenge is no longer open")
+	}
+}
+
+func (u ChallengeServiceImpl) notifyChallenger(game dao.ChessGame, messageType, message string) {
+	challenger := challengerOf(game)
+	if challenger == nil {
+		return
+	}
+	u.notificationService.Notify(challenger.ID, dto.WebSocketMessage{
+		Type:    messageType,
+		Status:  "success",
+		Message: message,
+		Payload: challengeOf(game, time.Time{}),
+	})
+}
+
+// sweep runs sweepOnce at start-up and every challengeSweepInterval after.
+func (u ChallengeServiceImpl) sweep() {
+	ticker := time.NewTicker(challengeSweepInterval)
+	defer ticker.Stop()
+	for {
+		u.sweepOnce()
+		<-ticker.C
+	}
+}
+
+// sweepOnce deletes the challenges that lapsed unanswered and tells both
+// sides. A challenge some other instance swept first is not reported again.
+func (u ChallengeServiceImpl) sweepOnce() {
+	games, err := u.chessRepository.DeleteExpiredChallenges()
+	if err != nil {
+		log.Error("Error sweeping expired challenges:", err)
+	}
+	for _, game := range games {
+		challenge := challengeOf(game, time.Time{})
+		for _, user := range []*dao.User{challenge.Challenger, challenge.Challenged} {
+			if user == nil {
+				continue
+			}
+			u.notificationService.Notify(user.ID, dto.WebSocketMessage{
+				Type:    dto.MessageChallengeExpired,
+				Status:  "success",
+				Message: "the challenge was not answered in time",
+				Payload: challenge,
+			})
+		}
+	}
+}
+
+// challengerOf is the user seated in a challenge, who sent it.
+func challengerOf(game dao.ChessGame) *dao.User {
+	if game.WhiteUser != nil {
+		return game.WhiteUser
+	}
+	return game.BlackUser
+}
+
+// challengeOf describes an open challenge created at createdAt; a zero
+// createdAt leaves ExpiresAt out.
+func challengeOf(game dao.ChessGame, createdAt time.Time) dto.Challenge {
+	challenge := dto.Challenge{
+		GameID:      game.ID,
+		Challenger:  challengerOf(game),
+		Challenged:  game.ChallengedUser,
+		Colour:      dto.SeatBlack,
+		TimeControl: engine.GameTimeControl(game).String(),
+		Rated:       game.Rated,
+		StartFEN:    game.StartFEN,
+	}
+	if game.WhiteUser != nil {
+		challenge.Colour = dto.SeatWhite
+	}
+	if !createdAt.IsZero() {
+		expiresAt := createdAt.Add(repository.ChallengeTTL)
+		challenge.ExpiresAt = &expiresAt
+	}
+	return challenge
+}
+
+func ChallengeServiceInit(chessRepository repository.ChessRepository, userRepository repository.UserRepository,
+	notificationService NotificationService) *ChallengeServiceImpl {
+	service := &ChallengeServiceImpl{
+		chessRepository:     chessRepository,
+		userRepository:      userRepository,
+		notificationService: notificationService,
+	}
+	go service.sweep()
+	return service
+}
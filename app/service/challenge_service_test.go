@@ -0,0 +1,219 @@
+package service
+
+import (
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/middleware"
+	"chess-engine/app/repository"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// challengeRepo holds one open challenge, game 5: user 1 as White has
+// challenged user 2. Methods the challenges do not use are left to the nil
+// interface and panic.
+type challengeRepo struct {
+	repository.ChessRepository
+	closed    bool // answered, withdrawn or expired meanwhile
+	accepted  bool
+	withdrawn bool
+	expired   []dao.ChessGame
+}
+
+func openTestChallenge() dao.ChessGame {
+	challenger, challenged := 1, 2
+	return dao.ChessGame{
+		ID:               5,
+		WhiteUserId:      &challenger,
+		WhiteUser:        &dao.User{ID: 1, Name: "alice"},
+		ChallengedUserId: &challenged,
+		ChallengedUser:   &dao.User{ID: 2, Name: "bob"},
+		BaseSeconds:      300,
+	}
+}
+
+func (r *challengeRepo) FindOpenChallenge(gameID int) (dao.ChessGame, error) {
+	if gameID != 5 {
+		return dao.ChessGame{}, gorm.ErrRecordNotFound
+	}
+	return openTestChallenge(), nil
+}
+
+func (r *challengeRepo) AcceptChallenge(game dao.ChessGame, userID int) (bool, error) {
+	r.accepted = !r.closed
+	return r.accepted, nil
+}
+
+func (r *challengeRepo) WithdrawChallenge(gameID int) (bool, error) {
+	r.withdrawn = !r.closed
+	return r.withdrawn, nil
+}
+
+func (r *challengeRepo) FindChessGameById(id string) (dao.ChessGame, error) {
+	return dao.ChessGame{}, errors.New("not cached in this test")
+}
+
+func (r *challengeRepo) DeleteExpiredChallenges() ([]dao.ChessGame, error) {
+	expired := r.expired
+	r.expired = nil
+	return expired, nil
+}
+
+// sentNotification is one message a recordingNotifier was asked to send.
+type sentNotification struct {
+	userID int
+	kind   string
+}
+
+type recordingNotifier struct{ sent []sentNotification }
+
+func (n *recordingNotifier) RegisterUser(*websocket.Conn, string) (int, bool) { return 0, false }
+func (n *recordingNotifier) UnregisterUser(int, *websocket.Conn)              {}
+func (n *recordingNotifier) Notify(userID int, message dto.WebSocketMessage) bool {
+	n.sent = append(n.sent, sentNotification{userID, message.Type})
+	return true
+}
+
+// tokenSessions signs in "user-<id>" as that user.
+type tokenSessions struct{ repository.SessionRepository }
+
+func (tokenSessions) FindSessionByToken(token string) (dao.Session, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(token, "user-"))
+	if err != nil {
+		return dao.Session{}, errors.New("not found")
+	}
+	return dao.Session{UserID: id, User: dao.User{ID: id, Name: "user " + strconv.Itoa(id)}}, nil
+}
+
+func TestChallengeAnswers(t *testing.T) {
+	const (
+		challenger = 1
+		challenged = 2
+		stranger   = 3
+	)
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		userID     int
+		closed     bool
+		wantCode   int
+		wantChange bool             // the challenge was accepted or withdrawn
+		wantSent   sentNotification // zero: nobody is told
+	}{
+		{"accept", http.MethodPost, "/5/accept", challenged, false, http.StatusOK, true,
+			sentNotification{challenger, dto.MessageChallengeAccepted}},
+		{"accept own challenge", http.MethodPost, "/5/accept", challenger, false, http.StatusForbidden, false, sentNotification{}},
+		{"accept someone else's", http.MethodPost, "/5/accept", stranger, false, http.StatusForbidden, false, sentNotification{}},
+		{"accept once closed", http.MethodPost, "/5/accept", challenged, true, http.StatusBadRequest, false, sentNotification{}},
+		{"accept unknown", http.MethodPost, "/6/accept", challenged, false, http.StatusBadRequest, false, sentNotification{}},
+		{"accept bad id", http.MethodPost, "/x/accept", challenged, false, http.StatusBadRequest, false, sentNotification{}},
+
+		{"decline", http.MethodPost, "/5/decline", challenged, false, http.StatusOK, true,
+			sentNotification{challenger, dto.MessageChallengeDeclined}},
+		{"decline own challenge", http.MethodPost, "/5/decline", challenger, false, http.StatusForbidden, false, sentNotification{}},
+		{"decline someone else's", http.MethodPost, "/5/decline", stranger, false, http.StatusForbidden, false, sentNotification{}},
+		{"decline once closed", http.MethodPost, "/5/decline", challenged, true, http.StatusBadRequest, false, sentNotification{}},
+
+		{"cancel", http.MethodDelete, "/5", challenger, false, http.StatusOK, true,
+			sentNotification{challenged, dto.MessageChallengeCancelled}},
+		{"cancel as the challenged", http.MethodDelete, "/5", challenged, false, http.StatusForbidden, false, sentNotification{}},
+		{"cancel someone else's", http.MethodDelete, "/5", stranger, false, http.StatusForbidden, false, sentNotification{}},
+		{"cancel once closed", http.MethodDelete, "/5", challenger, true, http.StatusBadRequest, false, sentNotification{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := &challengeRepo{closed: c.closed}
+			notifier := &recordingNotifier{}
+			svc := ChallengeServiceImpl{chessRepository: repo, notificationService: notifier}
+
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			challenges := r.Group("/", middleware.RequireAuth(tokenSessions{}))
+			challenges.POST("/:gameId/accept", svc.AcceptChallenge)
+			challenges.POST("/:gameId/decline", svc.DeclineChallenge)
+			challenges.DELETE("/:gameId", svc.CancelChallenge)
+
+			req := httptest.NewRequest(c.method, c.path, nil)
+			req.Header.Set("Authorization", "Bearer user-"+strconv.Itoa(c.userID))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != c.wantCode {
+				t.Errorf("status = %d, want %d (body %s)", w.Code, c.wantCode, w.Body.String())
+			}
+			if changed := repo.accepted || repo.withdrawn; changed != c.wantChange {
+				t.Errorf("challenge changed = %v, want %v", changed, c.wantChange)
+			}
+			var want []sentNotification
+			if c.wantSent != (sentNotification{}) {
+				want = append(want, c.wantSent)
+			}
+			if len(notifier.sent) != len(want) || (len(want) == 1 && notifier.sent[0] != want[0]) {
+				t.Errorf("notified %v, want %v", notifier.sent, want)
+			}
+		})
+	}
+}
+
+func TestChallengeSweep(t *testing.T) {
+	repo := &challengeRepo{expired: []dao.ChessGame{openTestChallenge()}}
+	notifier := &recordingNotifier{}
+	svc := ChallengeServiceImpl{chessRepository: repo, notificationService: notifier}
+
+	svc.sweepOnce()
+	want := []sentNotification{{1, dto.MessageChallengeExpired}, {2, dto.MessageChallengeExpired}}
+	if len(notifier.sent) != 2 || notifier.sent[0] != want[0] || notifier.sent[1] != want[1] {
+		t.Errorf("notified %v, want %v", notifier.sent, want)
+	}
+
+	// A challenge is swept once: the next sweep finds nothing to report.
+	svc.sweepOnce()
+	if len(notifier.sent) != 2 {
+		t.Errorf("notified %v after a second sweep, want no more", notifier.sent)
+	}
+}
+
+func TestChallengeOf(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	game := openTestChallenge()
+	game.Rated = true
+	got := challengeOf(game, created)
+	if got.GameID != 5 || got.Challenger == nil || got.Challenger.ID != 1 || got.Challenged == nil || got.Challenged.ID != 2 {
+		t.Errorf("challengeOf = %+v, want game 5 from user 1 to user 2", got)
+	}
+	if got.Colour != dto.SeatWhite || got.TimeControl != "5+0" || !got.Rated {
+		t.Errorf("challengeOf = %+v, want rated 5+0 with the challenger as White", got)
+	}
+	if got.ExpiresAt == nil || !got.ExpiresAt.Equal(created.Add(repository.ChallengeTTL)) {
+		t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, created.Add(repository.ChallengeTTL))
+	}
+
+	// The challenger in the black seat, untimed, from a set position, with
+	// no creation time known.
+	challenger := 1
+	game = dao.ChessGame{
+		ID:             6,
+		BlackUserId:    &challenger,
+		BlackUser:      &dao.User{ID: 1, Name: "alice"},
+		ChallengedUser: &dao.User{ID: 2, Name: "bob"},
+		StartFEN:       "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1",
+	}
+	got = challengeOf(game, time.Time{})
+	if got.Colour != dto.SeatBlack || got.Challenger == nil || got.Challenger.ID != 1 {
+		t.Errorf("challengeOf = %+v, want the challenger as Black", got)
+	}
+	if got.TimeControl != "" || got.StartFEN != game.StartFEN || got.ExpiresAt != nil {
+		t.Errorf("challengeOf = %+v, want untimed from the set position with no expiry", got)
+	}
+}
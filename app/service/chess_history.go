@@ -0,0 +1,279 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/engine"
+	"chess-engine/app/pkg"
+	"chess-engine/app/repository"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// A user's game history and the statistics drawn from it. Both are public, as
+// the games themselves are.
+
+// Page sizes for GET /api/user/:userID/games.
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// openingPlies is how many plies name an opening in the stats: two moves
+// each, enough to tell a Sicilian from a French without splitting every game
+// into its own line. maxOpenings is how many openings are listed.
+const (
+	openingPlies = 4
+	maxOpenings  = 5
+)
+
+// GetUserGames lists the games a user played, newest first, a page at a time.
+// Query parameters, all optional: colour (white|black), result
+// (win|loss|draw|aborted|ongoing), opponent (a user id), bot_level, from and
+// to (dates as 2006-01-02 or RFC 3339 times; a bare to date includes that
+// day), cursor (next_cursor from the previous page) and limit.
+func (u ChessServiceImpl) GetUserGames(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program get user games")
+	userID := userIDParam(c)
+	filter := repository.UserGameFilter{
+		UserID:     userID,
+		OpponentID: queryInt(c, "opponent"),
+		BotLevel:   c.Query("bot_level"),
+		Before:     queryInt(c, "cursor"),
+		Limit:      defaultHistoryLimit,
+	}
+	switch colour := c.Query("colour"); colour {
+	case "":
+	case "white", "w":
+		filter.Colour = "w"
+	case "black", "b":
+		filter.Colour = "b"
+	default:
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "colour must be white or black")
+	}
+	switch result := c.Query("result"); result {
+	case "", repository.ResultWin, repository.ResultLoss, repository.ResultDraw,
+		repository.ResultAborted, repository.ResultOngoing:
+		filter.Result = result
+	default:
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+			"result must be one of win, loss, draw, aborted, ongoing")
+	}
+	if limit := queryInt(c, "limit"); limit != 0 {
+		if limit < 0 || limit > maxHistoryLimit {
+			pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+				"limit must be between 1 and "+strconv.Itoa(maxHistoryLimit))
+		}
+		filter.Limit = limit
+	}
+	filter.From = queryDate(c, "from", false)
+	filter.To = queryDate(c, "to", true)
+
+	// One extra row says whether there is another page.
+	pageSize := filter.Limit
+	filter.Limit++
+	games, err := u.chessRepository.FindUserGames(filter)
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	page := dto.UserGamesPage{Games: []dto.GameSummary{}}
+	if len(games) > pageSize {
+		games = games[:pageSize]
+		next := games[pageSize-1].ID
+		page.NextCursor = &next
+	}
+
+	ids := make([]int, 0, len(games))
+	for _, game := range games {
+		ids = append(ids, game.ID)
+	}
+	created, err := u.chessRepository.FindChessGamesCreatedAt(ids)
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	for _, game := range games {
+		page.Games = append(page.Games, gameSummary(game, userID, created[game.ID]))
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, page))
+}
+
+// GetUserStats returns a user's results overall and by colour, and the
+// openings they play most. Aborted and pass-and-play games do not count.
+func (u ChessServiceImpl) GetUserStats(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program get user stats")
+	userID := userIDParam(c)
+	counts, err := u.chessRepository.CountUserResults(userID)
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	openings, err := u.chessRepository.FindUserOpenings(userID, openingPlies)
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+
+	stats := dto.UserStats{
+		White: resultTotals(counts.WhiteWins, counts.WhiteDraws, counts.WhiteLosses),
+		Black: resultTotals(counts.BlackWins, counts.BlackDraws, counts.BlackLosses),
+		Total: resultTotals(counts.WhiteWins+counts.BlackWins, counts.WhiteDraws+counts.BlackDraws,
+			counts.WhiteLosses+counts.BlackLosses),
+		Openings: openingTotals(openings),
+	}
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, stats))
+}
+
+func gameSummary(game dao.ChessGame, userID int, createdAt time.Time) dto.GameSummary {
+	tc := engine.GameTimeControl(game)
+	summary := dto.GameSummary{
+		ID:          game.ID,
+		White:       game.WhiteUser,
+		Black:       game.BlackUser,
+		Winner:      game.Winner,
+		Result:      userResult(game, userID),
+		Termination: game.Termination,
+		BotLevel:    game.BotLevel,
+		TimeControl: tc.String(),
+		Category:    tc.Category(),
+		Rated:       game.WhiteRatingChange != nil,
+		CreatedAt:   createdAt,
+	}
+	if game.WhiteUser != nil && game.WhiteUser.ID == userID {
+		summary.RatingChange = game.WhiteRatingChange
+	} else {
+		summary.RatingChange = game.BlackRatingChange
+	}
+	return summary
+}
+
+// userResult is the game's outcome for userID. In a pass-and-play game the
+// user won whichever side won.
+func userResult(game dao.ChessGame, userID int) string {
+	switch game.Winner {
+	case "":
+		return repository.ResultOngoing
+	case "d":
+		return repository.ResultDraw
+	case constant.WinnerAborted:
+		return repository.ResultAborted
+	}
+	winner := game.WhiteUser
+	if game.Winner == "b" {
+		winner = game.BlackUser
+	}
+	if winner != nil && winner.ID == userID {
+		return repository.ResultWin
+	}
+	return repository.ResultLoss
+}
+
+func resultTotals(wins, draws, losses int) dto.ResultTotals {
+	t := dto.ResultTotals{Games: wins + draws + losses, Wins: wins, Draws: draws, Losses: losses}
+	if t.Games > 0 {
+		t.Score = 100 * (float64(wins) + float64(draws)/2) / float64(t.Games)
+	}
+	return t
+}
+
+// openingTotals groups games by their first openingPlies moves and returns the
+// maxOpenings most played, most games first. Games too short to have reached
+// the end of an opening are left out.
+func openingTotals(openings []repository.UserGameOpening) []dto.OpeningTotals {
+	type tally struct{ wins, draws, losses int }
+	tallies := map[string]*tally{}
+	for _, o := range openings {
+		if len(o.Moves) < openingPlies {
+			continue
+		}
+		moves, err := engine.FormatMovetext("", o.Moves)
+		if err != nil {
+			log.Errorf("Happened error when naming the opening of game %d. Error %v", o.GameID, err)
+			continue
+		}
+		t := tallies[moves]
+		if t == nil {
+			t = &tally{}
+			tallies[moves] = t
+		}
+		switch {
+		case o.Winner == "d":
+			t.draws++
+		case (o.Winner == "w") == o.AsWhite:
+			t.wins++
+		default:
+			t.losses++
+		}
+	}
+
+	totals := make([]dto.OpeningTotals, 0, len(tallies))
+	for moves, t := range tallies {
+		totals = append(totals, dto.OpeningTotals{Moves: moves, ResultTotals: resultTotals(t.wins, t.draws, t.losses)})
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Games != totals[j].Games {
+			return totals[i].Games > totals[j].Games
+		}
+		return totals[i].Moves < totals[j].Moves
+	})
+	if len(totals) > maxOpenings {
+		totals = totals[:maxOpenings]
+	}
+	return totals
+}
+
+// userIDParam reads the :userID path parameter.
+func userIDParam(c *gin.Context) int {
+	userID, err := strconv.Atoi(c.Param("userID"))
+	if err != nil || userID <= 0 {
+		log.Error("Invalid userID path parameter:", err)
+		pkg.PanicException(constant.InvalidRequest)
+	}
+	return userID
+}
+
+// queryInt reads an optional integer query parameter, 0 when absent.
+func queryInt(c *gin.Context, name string) int {
+	raw := c.Query(name)
+	if raw == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), name+" must be a number")
+	}
+	return n
+}
+
+// queryDate reads an optional date or time query parameter. A bare date is
+// midnight UTC; with endOfDay it is the midnight after, so an exclusive upper
+// bound still takes in the whole day.
+func queryDate(c *gin.Context, name string, endOfDay bool) time.Time {
+	raw := c.Query(name)
+	if raw == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), name+" must be a date (2006-01-02) or an RFC 3339 time")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
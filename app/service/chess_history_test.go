@@ -0,0 +1,231 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/repository"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// historyRepo records the filter GetUserGames asks for and answers with games.
+// Methods the history does not use are left to the nil interface and panic.
+type historyRepo struct {
+	repository.ChessRepository
+	games  []dao.ChessGame
+	filter repository.UserGameFilter
+}
+
+func (r *historyRepo) FindUserGames(f repository.UserGameFilter) ([]dao.ChessGame, error) {
+	r.filter = f
+	games := r.games
+	if len(games) > f.Limit {
+		games = games[:f.Limit]
+	}
+	return games, nil
+}
+
+func (r *historyRepo) FindChessGamesCreatedAt(ids []int) (map[int]time.Time, error) {
+	return map[int]time.Time{}, nil
+}
+
+func getUserGames(t *testing.T, repo *historyRepo, query string) (*httptest.ResponseRecorder, dto.UserGamesPage) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/user/:userID/games", ChessServiceImpl{chessRepository: repo}.GetUserGames)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/user/7/games"+query, nil))
+
+	var resp dto.ApiResponse[dto.UserGamesPage]
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding %s: %v", w.Body.String(), err)
+		}
+	}
+	return w, resp.Data
+}
+
+func TestGetUserGamesFilters(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	cases := []struct {
+		name  string
+		query string
+		want  repository.UserGameFilter
+	}{
+		{"defaults", "", repository.UserGameFilter{}},
+		{"white", "?colour=white", repository.UserGameFilter{Colour: "w"}},
+		{"b", "?colour=b", repository.UserGameFilter{Colour: "b"}},
+		{"result", "?result=draw", repository.UserGameFilter{Result: repository.ResultDraw}},
+		{"opponent and bot", "?opponent=9&bot_level=hard", repository.UserGameFilter{OpponentID: 9, BotLevel: "hard"}},
+		// A bare to date takes in the whole day.
+		{"dates", "?from=2024-03-01&to=2024-03-31",
+			repository.UserGameFilter{From: day("2024-03-01"), To: day("2024-04-01")}},
+		{"rfc 3339 to", "?to=2024-03-31T12:00:00Z",
+			repository.UserGameFilter{To: time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)}},
+		{"cursor", "?cursor=120", repository.UserGameFilter{Before: 120}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := &historyRepo{}
+			w, _ := getUserGames(t, repo, c.query)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d (body %s)", w.Code, w.Body.String())
+			}
+			want := c.want
+			want.UserID = 7
+			// One more than the page, to see whether there is another.
+			want.Limit = defaultHistoryLimit + 1
+			if got := repo.filter; got != want {
+				t.Errorf("filter = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	for _, query := range []string{
+		"?colour=red", "?result=won", "?opponent=x", "?cursor=x",
+		"?limit=0x", "?limit=-1", "?limit=101", "?from=yesterday", "?to=2024-13-01",
+	} {
+		t.Run("rejects "+query, func(t *testing.T) {
+			w, _ := getUserGames(t, &historyRepo{}, query)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400 (body %s)", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetUserGamesPagination(t *testing.T) {
+	var games []dao.ChessGame
+	for id := 10; id > 0; id-- {
+		games = append(games, dao.ChessGame{ID: id})
+	}
+	cases := []struct {
+		name      string
+		games     []dao.ChessGame
+		limit     string
+		wantLimit int
+		wantIDs   []int
+		wantNext  int // 0: the last page
+	}{
+		{"more to come", games, "3", 4, []int{10, 9, 8}, 8},
+		{"exactly one page", games[:3], "3", 4, []int{10, 9, 8}, 0},
+		{"short page", games[:2], "3", 4, []int{10, 9}, 0},
+		{"empty", nil, "3", 4, nil, 0},
+		{"default size", games, "", defaultHistoryLimit + 1, []int{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}, 0},
+		{"largest page", games, "100", 101, []int{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := &historyRepo{games: c.games}
+			query := ""
+			if c.limit != "" {
+				query = "?limit=" + c.limit
+			}
+			w, page := getUserGames(t, repo, query)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d (body %s)", w.Code, w.Body.String())
+			}
+			if repo.filter.Limit != c.wantLimit {
+				t.Errorf("asked for %d games, want %d", repo.filter.Limit, c.wantLimit)
+			}
+			var ids []int
+			for _, g := range page.Games {
+				ids = append(ids, g.ID)
+			}
+			if len(ids) != len(c.wantIDs) {
+				t.Fatalf("games %v, want %v", ids, c.wantIDs)
+			}
+			for i := range ids {
+				if ids[i] != c.wantIDs[i] {
+					t.Fatalf("games %v, want %v", ids, c.wantIDs)
+				}
+			}
+			switch {
+			case c.wantNext == 0 && page.NextCursor != nil:
+				t.Errorf("next_cursor = %d on the last page", *page.NextCursor)
+			case c.wantNext != 0 && (page.NextCursor == nil || *page.NextCursor != c.wantNext):
+				t.Errorf("next_cursor = %v, want %d", page.NextCursor, c.wantNext)
+			}
+		})
+	}
+}
+
+func TestUserResult(t *testing.T) {
+	alice, bob := &dao.User{ID: 1}, &dao.User{ID: 2}
+	cases := []struct {
+		name   string
+		game   dao.ChessGame
+		userID int
+		want   string
+	}{
+		{"ongoing", dao.ChessGame{WhiteUser: alice, BlackUser: bob}, 1, repository.ResultOngoing},
+		{"draw", dao.ChessGame{WhiteUser: alice, BlackUser: bob, Winner: "d"}, 2, repository.ResultDraw},
+		{"aborted", dao.ChessGame{WhiteUser: alice, BlackUser: bob, Winner: constant.WinnerAborted}, 1, repository.ResultAborted},
+		{"white wins as white", dao.ChessGame{WhiteUser: alice, BlackUser: bob, Winner: "w"}, 1, repository.ResultWin},
+		{"white wins as black", dao.ChessGame{WhiteUser: alice, BlackUser: bob, Winner: "w"}, 2, repository.ResultLoss},
+		{"black wins as black", dao.ChessGame{WhiteUser: alice, BlackUser: bob, Winner: "b"}, 2, repository.ResultWin},
+		{"black wins as white", dao.ChessGame{WhiteUser: alice, BlackUser: bob, Winner: "b"}, 1, repository.ResultLoss},
+		{"bot beats the user", dao.ChessGame{WhiteUser: alice, BotLevel: "easy", Winner: "b"}, 1, repository.ResultLoss},
+		{"pass and play", dao.ChessGame{WhiteUser: alice, BlackUser: alice, Winner: "b"}, 1, repository.ResultWin},
+	}
+	for _, c := range cases {
+		if got := userResult(c.game, c.userID); got != c.want {
+			t.Errorf("%s: userResult = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestOpeningTotals(t *testing.T) {
+	italian := []string{"Pe2e4", "pe7e5", "Ng1f3", "nb8c6"}
+	french := []string{"Pe2e4", "pe7e6", "Pd2d4", "pd7d5"}
+	openings := []repository.UserGameOpening{
+		{GameID: 1, AsWhite: true, Winner: "w", Moves: italian},
+		{GameID: 2, AsWhite: false, Winner: "w", Moves: italian},
+		{GameID: 3, AsWhite: true, Winner: "d", Moves: italian},
+		{GameID: 4, AsWhite: false, Winner: "b", Moves: french},
+		// Too short to name an opening.
+		{GameID: 5, AsWhite: true, Winner: "w", Moves: italian[:2]},
+		// Not legal from the start: skipped, not fatal.
+		{GameID: 6, AsWhite: true, Winner: "w", Moves: []string{"Pe2e5", "pe7e5", "Ng1f3", "nb8c6"}},
+	}
+	got := openingTotals(openings)
+	want := []dto.OpeningTotals{
+		{Moves: "1. e4 e5 2. Nf3 Nc6", ResultTotals: dto.ResultTotals{Games: 3, Wins: 1, Draws: 1, Losses: 1, Score: 50}},
+		{Moves: "1. e4 e6 2. d4 d5", ResultTotals: dto.ResultTotals{Games: 1, Wins: 1, Score: 100}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("openingTotals = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("opening %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// Only the most played are listed, ties by moves.
+	var many []repository.UserGameOpening
+	for _, first := range []string{"Pa2a3", "Pb2b3", "Pc2c3", "Pd2d3", "Pf2f3", "Pg2g3", "Ph2h3"} {
+		many = append(many, repository.UserGameOpening{AsWhite: true, Winner: "w", Moves: []string{first, "pe7e5", "Pe2e4", "nb8c6"}})
+	}
+	many = append(many, repository.UserGameOpening{AsWhite: true, Winner: "w", Moves: []string{"Ph2h3", "pe7e5", "Pe2e4", "nb8c6"}})
+	got = openingTotals(many)
+	if len(got) != maxOpenings {
+		t.Fatalf("%d openings listed, want %d", len(got), maxOpenings)
+	}
+	if got[0].Moves != "1. h3 e5 2. e4 Nc6" || got[0].Games != 2 {
+		t.Errorf("most played = %+v, want 1. h3 e5 2. e4 Nc6 twice", got[0])
+	}
+	if got[1].Moves != "1. a3 e5 2. e4 Nc6" {
+		t.Errorf("second = %q, want the first of the ties by moves", got[1].Moves)
+	}
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/engine"
+	"chess-engine/app/pkg"
+	"chess-engine/app/repository"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// PGN export and import. Games are stored as "Pe2e4" move rows; these handlers
+// translate to and from the SAN movetext that other chess software reads.
+
+// pgnContentType is the registered media type for PGN files.
+const pgnContentType = "application/x-chess-pgn"
+
+// maxImportGames bounds how many games one import request may create.
+const maxImportGames = 100
+
+// GetChessGamePGN returns a game as a PGN file. Finished and in-progress games
+// are both exported; an unfinished game's result is "*".
+func (u ChessServiceImpl) GetChessGamePGN(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program export chess game pgn")
+	gameId := c.Param("gameId")
+	game, err := u.chessRepository.FindChessGameById(gameId)
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.DataNotFound)
+	}
+	createdAt, err := u.chessRepository.FindChessGameCreatedAt(gameId)
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.DataNotFound)
+	}
+
+	date := "????.??.??"
+	if !createdAt.IsZero() {
+		date = createdAt.UTC().Format("2006.01.02")
+	}
+	result := engine.PGNResult(game.Winner)
+	tags := []engine.PGNTag{
+		{Name: "Event", Value: pgnEvent(game)},
+		{Name: "Site", Value: c.Request.Host},
+		{Name: "Date", Value: date},
+		{Name: "Round", Value: "-"},
+		{Name: "White", Value: pgnPlayer(game.WhiteUser)},
+		{Name: "Black", Value: pgnPlayer(game.BlackUser)},
+		{Name: "Result", Value: result},
+	}
+	if game.StartFEN != "" {
+		tags = append(tags,
+			engine.PGNTag{Name: "SetUp", Value: "1"},
+			engine.PGNTag{Name: "FEN", Value: game.StartFEN})
+	}
+	if tc := engine.GameTimeControl(game); tc.Timed() {
+		tags = append(tags, engine.PGNTag{Name: "TimeControl", Value: pgnTimeControl(tc)})
+	}
+	tags = append(tags, engine.PGNTag{Name: "Termination", Value: pgnTermination(game)})
+
+	pgn, err := engine.FormatPGN(tags, game.StartFEN, engine.RecordedMoves(game.Moves), result)
+	if err != nil {
+		log.Errorf("Happened error when converting game %s to PGN. Error %v", gameId, err)
+		pkg.PanicException(constant.UnknownError)
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="game-%d.pgn"`, game.ID))
+	c.Data(http.StatusOK, pgnContentType, []byte(pgn))
+}
+
+// ImportChessGame creates games from PGN text. Every game is checked move by
+// move before anything is saved, so a file with one bad move imports nothing,
+// and the error names the game and ply at fault. The response is the new game
+// ids, in file order.
+func (u ChessServiceImpl) ImportChessGame(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program import chess game")
+	var request dto.ImportChessGameRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error("Happened error when mapping request from FE. Error", err)
+		pkg.PanicException(constant.InvalidRequest)
+	}
+	plies := -1
+	if request.Ply != nil {
+		if *request.Ply < 0 {
+			pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "ply must not be negative")
+		}
+		plies = *request.Ply
+	}
+
+	human, err := u.chessRepository.FindUserByToken(request.Token)
+	if err != nil || human.ID == 0 {
+		log.Error("Error fetching user by token:", err)
+		pkg.PanicException(constant.Unauthorized)
+	}
+
+	games, err := engine.ParsePGN(request.PGN)
+	if err != nil {
+		log.Error("Happened error when parsing PGN. Error", err)
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), err.Error())
+	}
+	if len(games) == 0 {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "no games found in PGN")
+	}
+	if len(games) > maxImportGames {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+			fmt.Sprintf("too many games: %d, at most %d per import", len(games), maxImportGames))
+	}
+
+	type replayed struct {
+		startFEN string
+		moves    []string
+		state    dao.GameState
+	}
+	replays := make([]replayed, 0, len(games))
+	for i, g := range games {
+		moves, state, err := g.Replay(plies)
+		if err != nil {
+			log.Errorf("Happened error when replaying imported game %d. Error %v", i+1, err)
+			pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), fmt.Sprintf("game %d: %v", i+1, err))
+		}
+		replays = append(replays, replayed{g.Tag("FEN"), moves, state})
+	}
+
+	imports := make([]repository.ImportedGame, 0, len(replays))
+	for _, r := range replays {
+		newGame := &dao.ChessGame{
+			InviteCode: pkg.GenerateRandomString(20),
+			StartFEN:   r.startFEN,
+			WhiteUser:  &human,
+			BlackUser:  &human,
+		}
+		// The game is over if the position it stops at is: a game imported at
+		// its final, mating move is finished, while one that ended by
+		// resignation is left open to be played on.
+		newGame.Winner, newGame.Termination = positionResult(engine.GameStartState(*newGame), r.state, r.moves)
+		rows := make([]dao.GameMove, 0, len(r.moves))
+		for _, m := range r.moves {
+			rows = append(rows, dao.GameMove{Move: m})
+		}
+		imports = append(imports, repository.ImportedGame{Game: newGame, State: r.state, Moves: rows})
+	}
+	// All the games or none: a file with one bad move imports nothing, and
+	// neither does one the database fails on halfway.
+	if err := u.chessRepository.SaveImportedGames(imports); err != nil {
+		log.Error("Happened error when saving imported games to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+
+	ids := make([]int, 0, len(imports))
+	for _, g := range imports {
+		ids = append(ids, g.Game.ID)
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, ids))
+}
+
+// positionResult decides whether the game that reached gs by playing moves
+// from start is already over by the rules, returning its Winner and
+// Termination, or two empty strings if play can go on.
+func positionResult(start, gs dao.GameState, moves []string) (winner, termination string) {
+	switch _, status := engine.GenerateLegalMovesForAllPositions(gs); status {
+	case "white_checkmate":
+		return "b", constant.TerminationCheckmate
+	case "black_checkmate":
+		return "w", constant.TerminationCheckmate
+	case "stalemate":
+		return "d", constant.TerminationStalemate
+	}
+	if draw := engine.DrawStatus(gs, engine.ReplayGameKeys(start, moves)); draw != "" {
+		return "d", draw
+	}
+	return "", ""
+}
+
+// pgnEvent names the game the way Lichess does: "Rated blitz game" for one
+// that changed ratings, "Casual game" otherwise.
+func pgnEvent(game dao.ChessGame) string {
+	kind := "Casual"
+	if game.WhiteRatingChange != nil {
+		kind = "Rated " + engine.GameTimeControl(game).Category()
+	}
+	if isBotGame(game) {
+		return kind + " game vs computer (" + game.BotLevel + ")"
+	}
+	return kind + " game"
+}
+
+// pgnPlayer names a seat. An empty seat is "?", PGN's unknown value.
+func pgnPlayer(user *dao.User) string {
+	if user == nil || user.Name == "" {
+		return "?"
+	}
+	return user.Name
+}
+
+// pgnTimeControl writes a time control in the TimeControl tag's own syntax:
+// "base+increment" in seconds for a live game, or "*seconds" for the time
+// allowed per move in correspondence.
+func pgnTimeControl(tc engine.TimeControl) string {
+	if tc.DaysPerMove > 0 {
+		return "*" + strconv.Itoa(tc.DaysPerMove*24*60*60)
+	}
+	return strconv.Itoa(int(tc.Base.Seconds())) + "+" + strconv.Itoa(int(tc.Increment.Seconds()))
+}
+
+// pgnTermination carries the stored termination reason (draw_repetition,
+// resignation, ...) through unchanged, so the exported file says why a draw
+// was a draw. Games still in progress are "unterminated", as in the PGN
+// standard; games that ended before terminations were recorded are "unknown".
+func pgnTermination(game dao.ChessGame) string {
+	switch {
+	case game.Winner == "":
+		return "unterminated"
+	case game.Termination == "":
+		return "unknown"
+	}
+	return game.Termination
+}
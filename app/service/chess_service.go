@@ -8,6 +8,7 @@ import (
 	"chess-engine/app/pkg"
 	"chess-engine/app/repository"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
@@ -16,10 +17,15 @@ import (
 type ChessService interface {
 	GetAllChessGame(c *gin.Context)
 	GetChessGameById(c *gin.Context)
+	GetChessGamePGN(c *gin.Context)
+	GetGameSeries(c *gin.Context)
+	ImportChessGame(c *gin.Context)
 	CreateChessGame(c *gin.Context)
 	CreateBotChessGame(c *gin.Context)
 	CreateLocalChessGame(c *gin.Context)
 	JoinChessGame(c *gin.Context)
+	GetUserGames(c *gin.Context)
+	GetUserStats(c *gin.Context)
 }
 
 type ChessServiceImpl struct {
@@ -44,6 +50,7 @@ func (u ChessServiceImpl) GetChessGameById(c *gin.Context) {
 	legalMoves, _ := engine.GenerateLegalMovesForAllPositions(game.State)
 	legalMoves = engine.FilterMovesByTurn(legalMoves, game.State)
 	game.LegalMoves = engine.ConvertLegalMovesToMap(legalMoves)
+	game.Clock = engine.ClockSnapshotAt(game, time.Now())
 
 	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, game))
 }
@@ -66,11 +73,13 @@ func (u ChessServiceImpl) CreateChessGame(c *gin.Context) {
 	defer pkg.PanicHandler(c)
 
 	log.Info("start to execute program create chess state")
-	var request dto.TokenGetRequest
+	var request dto.CreateChessGameRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		log.Error("Happened error when mapping request from FE. Error", err)
 		pkg.PanicException(constant.InvalidRequest)
 	}
+	timeControl := parseTimeControl(request.TimeControlRequest)
+	start := parseStartPosition(request.FEN)
 
 	creatorUser, err := u.chessRepository.FindUserByToken(request.Token)
 	if err != nil || creatorUser.ID == 0 {
@@ -82,7 +91,10 @@ func (u ChessServiceImpl) CreateChessGame(c *gin.Context) {
 	newGame := dao.ChessGame{
 		InviteCode: pkg.GenerateRandomString(20),
 		Winner:     "",
+		StartFEN:   request.FEN,
+		Rated:      request.Rated,
 	}
+	timeControl.Apply(&newGame)
 
 	if isAssignWhite := pkg.GenerateRandomBool(); isAssignWhite {
 		newGame.WhiteUser = &creatorUser
@@ -94,24 +106,7 @@ func (u ChessServiceImpl) CreateChessGame(c *gin.Context) {
 		log.Error("Happened error when saving game to database. Error", err)
 		pkg.PanicException(constant.UnknownError)
 	}
-	initialGameState := dao.GameState{
-		GameID:         newGame.ID,
-		WhiteBitboard:  0xFFFF,
-		BlackBitboard:  0xFFFF000000000000,
-		PawnBitboard:   0x00FF00000000FF00,
-		RookBitboard:   0x8100000000000081,
-		KnightBitboard: 0x4200000000000042,
-		BishopBitboard: 0x2400000000000024,
-		QueenBitboard:  0x0800000000000008,
-		KingBitboard:   0x1000000000000010,
-		EnPassant:      0,
-		CastlingRights: "KQkq",
-		Turn:           "w",
-	}
-	if err := u.chessRepository.SaveGameStateToDB(&initialGameState); err != nil {
-		log.Error("Happened error when saving game state to database. Error", err)
-		pkg.PanicException(constant.UnknownError)
-	}
+	u.saveInitialState(newGame.ID, start, timeControl)
 
 	// Return a success response
 	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, newGame.ID))
@@ -125,11 +120,13 @@ func (u ChessServiceImpl) CreateLocalChessGame(c *gin.Context) {
 	defer pkg.PanicHandler(c)
 
 	log.Info("start to execute program create local (pass & play) chess game")
-	var request dto.TokenGetRequest
+	var request dto.CreateChessGameRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		log.Error("Happened error when mapping request from FE. Error", err)
 		pkg.PanicException(constant.InvalidRequest)
 	}
+	timeControl := parseTimeControl(request.TimeControlRequest)
+	start := parseStartPosition(request.FEN)
 
 	human, err := u.chessRepository.FindUserByToken(request.Token)
 	if err != nil {
@@ -140,31 +137,16 @@ func (u ChessServiceImpl) CreateLocalChessGame(c *gin.Context) {
 	newGame := dao.ChessGame{
 		InviteCode: pkg.GenerateRandomString(20),
 		Winner:     "",
+		StartFEN:   request.FEN,
 		WhiteUser:  &human,
 		BlackUser:  &human,
 	}
+	timeControl.Apply(&newGame)
 	if err := u.chessRepository.SaveChessGameToDB(&newGame); err != nil {
 		log.Error("Happened error when saving game to database. Error", err)
 		pkg.PanicException(constant.UnknownError)
 	}
-	initialGameState := dao.GameState{
-		GameID:         newGame.ID,
-		WhiteBitboard:  0xFFFF,
-		BlackBitboard:  0xFFFF000000000000,
-		PawnBitboard:   0x00FF00000000FF00,
-		RookBitboard:   0x8100000000000081,
-		KnightBitboard: 0x4200000000000042,
-		BishopBitboard: 0x2400000000000024,
-		QueenBitboard:  0x0800000000000008,
-		KingBitboard:   0x1000000000000010,
-		EnPassant:      0,
-		CastlingRights: "KQkq",
-		Turn:           "w",
-	}
-	if err := u.chessRepository.SaveGameStateToDB(&initialGameState); err != nil {
-		log.Error("Happened error when saving game state to database. Error", err)
-		pkg.PanicException(constant.UnknownError)
-	}
+	u.saveInitialState(newGame.ID, start, timeControl)
 
 	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, newGame.ID))
 }
@@ -185,6 +167,8 @@ func (u ChessServiceImpl) CreateBotChessGame(c *gin.Context) {
 	if level != "easy" && level != "medium" && level != "hard" {
 		level = "easy"
 	}
+	timeControl := parseTimeControl(request.TimeControlRequest)
+	start := parseStartPosition(request.FEN)
 
 	human, err := u.chessRepository.FindUserByToken(request.Token)
 	if err != nil {
@@ -200,9 +184,12 @@ func (u ChessServiceImpl) CreateBotChessGame(c *gin.Context) {
 	newGame := dao.ChessGame{
 		InviteCode: pkg.GenerateRandomString(20),
 		Winner:     "",
+		StartFEN:   request.FEN,
 		BotLevel:   level,
 	}
-	// The human always plays White against the bot.
+	timeControl.Apply(&newGame)
+	// The human always plays White against the bot. From a set-up position
+	// with Black to move, that means the bot moves first.
 	newGame.WhiteUser = &human
 	newGame.BlackUser = &bot
 
@@ -210,24 +197,7 @@ func (u ChessServiceImpl) CreateBotChessGame(c *gin.Context) {
 		log.Error("Happened error when saving game to database. Error", err)
 		pkg.PanicException(constant.UnknownError)
 	}
-	initialGameState := dao.GameState{
-		GameID:         newGame.ID,
-		WhiteBitboard:  0xFFFF,
-		BlackBitboard:  0xFFFF000000000000,
-		PawnBitboard:   0x00FF00000000FF00,
-		RookBitboard:   0x8100000000000081,
-		KnightBitboard: 0x4200000000000042,
-		BishopBitboard: 0x2400000000000024,
-		QueenBitboard:  0x0800000000000008,
-		KingBitboard:   0x1000000000000010,
-		EnPassant:      0,
-		CastlingRights: "KQkq",
-		Turn:           "w",
-	}
-	if err := u.chessRepository.SaveGameStateToDB(&initialGameState); err != nil {
-		log.Error("Happened error when saving game state to database. Error", err)
-		pkg.PanicException(constant.UnknownError)
-	}
+	u.saveInitialState(newGame.ID, start, timeControl)
 
 	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, newGame.ID))
 }
@@ -254,6 +224,19 @@ func (u ChessServiceImpl) JoinChessGame(c *gin.Context) {
 		pkg.PanicException(constant.DataNotFound)
 	}
 
+	if game.ChallengedUserId != nil && *game.ChallengedUserId != joinUser.ID {
+		log.Errorf("Join rejected: game %d is reserved for user %d", game.ID, *game.ChallengedUserId)
+		pkg.PanicException_(constant.Forbidden.GetResponseStatus(), "this game is reserved for the player challenged")
+	}
+
+	if request.TimeControl != "" || request.DaysPerMove != 0 {
+		want := parseTimeControl(request.TimeControlRequest)
+		if have := engine.GameTimeControl(game); have != want {
+			log.Errorf("Join rejected: game %d is %q, joiner asked for %q", game.ID, have, want)
+			pkg.PanicException(constant.InvalidRequest)
+		}
+	}
+
 	if game.WhiteUser == nil {
 		game.WhiteUser = &joinUser
 	} else if game.BlackUser == nil {
@@ -271,6 +254,53 @@ func (u ChessServiceImpl) JoinChessGame(c *gin.Context) {
 	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, game.ID))
 }
 
+// parseStartPosition validates the optional starting FEN of a create request.
+// An empty FEN is the standard starting position. The error says what is wrong
+// with the position, since "Invalid Request" alone gives a user setting up a
+// drill nothing to go on.
+func parseStartPosition(fen string) dao.GameState {
+	if fen == "" {
+		return engine.StartState()
+	}
+	gs, err := engine.ParseStartFEN(fen)
+	if err != nil {
+		log.Error("Invalid starting FEN: ", err)
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), err.Error())
+	}
+	return gs
+}
+
+// saveInitialState stores a new game's first GameState: the starting position
+// with both clocks set. The three create handlers each used to spell out the
+// standard position's bitboards by hand.
+func (u ChessServiceImpl) saveInitialState(gameID int, start dao.GameState, timeControl engine.TimeControl) {
+	if err := storeInitialState(u.chessRepository, gameID, start, timeControl); err != nil {
+		log.Error("Happened error when saving game state to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+}
+
+// storeInitialState is saveInitialState for callers outside a request, which
+// cannot answer a failure with a panic.
+func storeInitialState(chessRepository repository.ChessRepository, gameID int, start dao.GameState,
+	timeControl engine.TimeControl) error {
+	state := start
+	state.GameID = gameID
+	engine.StartClocks(&state, timeControl)
+	return chessRepository.SaveGameStateToDB(&state)
+}
+
+// parseTimeControl validates the clock part of a request. A malformed control
+// is rejected rather than quietly creating an untimed game.
+func parseTimeControl(request dto.TimeControlRequest) engine.TimeControl {
+	tc, err := engine.ParseTimeControl(request.TimeControl, request.DaysPerMove)
+	if err != nil {
+		log.Error("Invalid time control: ", err)
+		pkg.PanicException(constant.InvalidRequest)
+	}
+	return tc
+}
+
 func ChessServiceInit(chessRepository repository.ChessRepository) *ChessServiceImpl {
 	return &ChessServiceImpl{
 		chessRepository: chessRepository,
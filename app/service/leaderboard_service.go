@@ -0,0 +1,129 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/engine"
+	"chess-engine/app/pkg"
+	"chess-engine/app/repository"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// Page sizes for GET /api/leaderboard.
+const (
+	defaultLeaderboardLimit = 50
+	maxLeaderboardLimit     = 100
+)
+
+// provisionalDeviation is the rating deviation above which a rating is
+// provisional: too few recent games to say where the player stands. A new
+// player starts at 350 and comes under it after a dozen or so games.
+const provisionalDeviation = 110
+
+// defaultInactiveDays is how long a player may go without a rated game in a
+// category before dropping off its leaderboard, unless LEADERBOARD_INACTIVE_DAYS
+// says otherwise. They come back with their next rated game.
+const defaultInactiveDays = 30
+
+type LeaderboardService interface {
+	GetLeaderboard(c *gin.Context)
+}
+
+type LeaderboardServiceImpl struct {
+	leaderboardRepository repository.LeaderboardRepository
+	inactiveAfter         time.Duration
+}
+
+// GetLeaderboard returns the top rated players of a category. Query
+// parameters: category (required; see engine.Categories) and limit.
+func (u LeaderboardServiceImpl) GetLeaderboard(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program get leaderboard")
+	category := c.Query("category")
+	if !isCategory(category) {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "unknown category "+strconv.Quote(category))
+	}
+	limit := defaultLeaderboardLimit
+	if n := queryInt(c, "limit"); n > 0 {
+		limit = min(n, maxLeaderboardLimit)
+	}
+
+	now := time.Now()
+	leaders, err := u.leaderboardRepository.FindLeaders(category, limit, func(e repository.LeaderboardEntry) bool {
+		return onLeaderboard(e, now, u.inactiveAfter)
+	})
+	if err != nil {
+		log.Error("Happened error when get data from Redis. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+
+	board := dto.Leaderboard{Category: category, Entries: make([]dto.LeaderboardEntry, len(leaders))}
+	for i, e := range leaders {
+		board.Entries[i] = dto.LeaderboardEntry{
+			Rank:      i + 1,
+			User:      e.User,
+			Rating:    int(math.Round(e.Rating)),
+			Deviation: int(math.Round(e.Deviation)),
+			Games:     e.Games,
+			RatedAt:   e.RatedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, board))
+}
+
+// onLeaderboard reports whether a player belongs on the board at now: rated
+// within inactiveAfter, and no longer provisional. Either way a player once
+// off stays off until their next rated game, as FindLeaders requires.
+func onLeaderboard(e repository.LeaderboardEntry, now time.Time, inactiveAfter time.Duration) bool {
+	if now.Sub(e.RatedAt) > inactiveAfter {
+		return false
+	}
+	// The deviation is stored as of the last game and widens while the
+	// player is away, as it does when they next play.
+	r := currentRating(&dao.Rating{Rating: e.Rating, Deviation: e.Deviation, Volatility: e.Volatility, RatedAt: e.RatedAt}, now)
+	return r.Deviation <= provisionalDeviation
+}
+
+func isCategory(category string) bool {
+	for _, c := range engine.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// inactiveAfterFromEnv reads LEADERBOARD_INACTIVE_DAYS; unset or unreadable is
+// defaultInactiveDays.
+func inactiveAfterFromEnv() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("LEADERBOARD_INACTIVE_DAYS"))
+	if err != nil || days <= 0 {
+		days = defaultInactiveDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func LeaderboardServiceInit(leaderboardRepository repository.LeaderboardRepository) *LeaderboardServiceImpl {
+	// In the background: start-up need not wait, and until the new boards are
+	// swapped in the old ones are served.
+	go func() {
+		if err := leaderboardRepository.RebuildLeaderboards(engine.Categories); err != nil {
+			// The boards fill up again as games are rated.
+			log.Error("Could not rebuild the leaderboards: ", err)
+		}
+	}()
+	return &LeaderboardServiceImpl{
+		leaderboardRepository: leaderboardRepository,
+		inactiveAfter:         inactiveAfterFromEnv(),
+	}
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"chess-engine/app/repository"
+	"testing"
+	"time"
+)
+
+func TestOnLeaderboard(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	inactiveAfter := 30 * day
+	entry := func(deviation float64, ago time.Duration) repository.LeaderboardEntry {
+		return repository.LeaderboardEntry{Rating: 1800, Deviation: deviation, Volatility: 0.06, Games: 40, RatedAt: now.Add(-ago)}
+	}
+
+	cases := []struct {
+		name  string
+		entry repository.LeaderboardEntry
+		want  bool
+	}{
+		{"established and active", entry(60, day), true},
+		{"just played", entry(provisionalDeviation, 0), true},
+		{"provisional", entry(provisionalDeviation+1, 0), false},
+		{"new player", entry(350, 0), false},
+		// Under the line when last rated, but it widens while they are away.
+		{"drifted provisional", entry(100, 29*day), false},
+		{"still settled", entry(100, 7*day), true},
+		{"last rated on the cut-off", entry(60, inactiveAfter), true},
+		{"inactive", entry(60, inactiveAfter+time.Second), false},
+		{"never rated", repository.LeaderboardEntry{Rating: 1500, Deviation: 60, Volatility: 0.06}, false},
+	}
+	for _, c := range cases {
+		if got := onLeaderboard(c.entry, now, inactiveAfter); got != c.want {
+			t.Errorf("%s: onLeaderboard = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/repository"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+// NotificationService is the user-level WebSocket channel, /ws/user: one per
+// signed-in client rather than per game, for whatever reaches a player outside
+// a game, such as a seek being matched. A user may be connected from several
+// tabs or devices, and every connection gets every notification.
+type NotificationService interface {
+	RegisterUser(conn *websocket.Conn, token string) (int, bool)
+	UnregisterUser(userID int, conn *websocket.Conn)
+	Notify(userID int, message dto.WebSocketMessage) bool
+}
+
+type NotificationServiceImpl struct {
+	sessionRepository repository.SessionRepository
+	// mutex guards clients. It is not held while writing: each connection
+	// serialises its own writes.
+	mutex   sync.Mutex
+	clients map[int]map[*websocket.Conn]*lockedConn
+}
+
+// socketWriteWait bounds one write to a socket, so a client that has stopped
+// reading fails its own write instead of holding up everyone else's.
+const socketWriteWait = 10 * time.Second
+
+// lockedConn is a socket written to from more than one goroutine. A gorilla
+// connection takes one writer at a time, so writes go through the
+// connection's own mutex rather than whatever lock guards the set it is in.
+type lockedConn struct {
+	*websocket.Conn
+	writeMu sync.Mutex
+}
+
+// writeJSON sends v, failing if the client does not take it within
+// socketWriteWait.
+func (c *lockedConn) writeJSON(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.SetWriteDeadline(time.Now().Add(socketWriteWait))
+	return c.WriteJSON(v)
+}
+
+// RegisterUser authenticates a connection by its session token and adds it to
+// the user's connections. It returns the user's id, or false if the token is
+// not a live session.
+func (n *NotificationServiceImpl) RegisterUser(conn *websocket.Conn, token string) (int, bool) {
+	session, err := n.sessionRepository.FindSessionByToken(token)
+	if err != nil {
+		log.Error("Error fetching session for user socket:", err)
+		return 0, false
+	}
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.clients[session.UserID] == nil {
+		n.clients[session.UserID] = make(map[*websocket.Conn]*lockedConn)
+	}
+	n.clients[session.UserID][conn] = &lockedConn{Conn: conn}
+	log.Infof("User %d connected to notifications", session.UserID)
+	return session.UserID, true
+}
+
+// UnregisterUser removes and closes a connection.
+func (n *NotificationServiceImpl) UnregisterUser(userID int, conn *websocket.Conn) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.remove(userID, conn)
+}
+
+// Notify sends message to every connection of userID, and reports whether
+// there was at least one to send it to. The connections are written to outside
+// the mutex, so one slow client does not stall notifications to everyone.
+func (n *NotificationServiceImpl) Notify(userID int, message dto.WebSocketMessage) bool {
+	n.mutex.Lock()
+	conns := make([]*lockedConn, 0, len(n.clients[userID]))
+	for _, conn := range n.clients[userID] {
+		conns = append(conns, conn)
+	}
+	n.mutex.Unlock()
+
+	sent := false
+	for _, conn := range conns {
+		if err := conn.writeJSON(message); err != nil {
+			log.Error("Error sending notification to user: ", err)
+			n.UnregisterUser(userID, conn.Conn)
+			continue
+		}
+		sent = true
+	}
+	return sent
+}
+
+// remove drops a connection. The caller must hold the mutex.
+func (n *NotificationServiceImpl) remove(userID int, conn *websocket.Conn) {
+	conns := n.clients[userID]
+	if _, ok := conns[conn]; !ok {
+		return
+	}
+	delete(conns, conn)
+	conn.Close()
+	if len(conns) == 0 {
+		delete(n.clients, userID)
+	}
+}
+
+func NotificationServiceInit(sessionRepository repository.SessionRepository) *NotificationServiceImpl {
+	return &NotificationServiceImpl{
+		sessionRepository: sessionRepository,
+		clients:           make(map[int]map[*websocket.Conn]*lockedConn),
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/engine"
+	"chess-engine/app/rating"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ratingPeriod is the Glicko-2 rating period. Each game is rated on its own as
+// it finishes; a player's deviation also widens by one period's worth for every
+// period they went without a rated game.
+const ratingPeriod = 24 * time.Hour
+
+// ratingPolicy decides which finished games change ratings. By default only
+// games created as rated between two different people are: casual games, bot
+// games and pass-and-play (local) games are not, and each of the
+// RATE_CASUAL_GAMES, RATE_BOT_GAMES and RATE_LOCAL_GAMES environment variables
+// turns its kind on. A local game can only set a player against themself, so
+// rating one settles their deviation without moving the rating.
+type ratingPolicy struct {
+	casual, bot, local bool
+}
+
+func ratingPolicyFromEnv() ratingPolicy {
+	return ratingPolicy{
+		casual: envBool("RATE_CASUAL_GAMES"),
+		bot:    envBool("RATE_BOT_GAMES"),
+		local:  envBool("RATE_LOCAL_GAMES"),
+	}
+}
+
+// envBool reads a boolean environment variable; unset or unreadable is false.
+func envBool(name string) bool {
+	v, err := strconv.ParseBool(os.Getenv(name))
+	return err == nil && v
+}
+
+// rates reports whether game's result should change ratings. Aborted games,
+// games with an empty seat, games from a set-up position, which may hand one
+// side a won game, and games a moderator ended, whose result was not played
+// out, never do.
+func (p ratingPolicy) rates(game dao.ChessGame) bool {
+	switch {
+	case game.Winner == "" || game.Winner == constant.WinnerAborted:
+		return false
+	case game.Termination == constant.TerminationAdjudication:
+		return false
+	case game.WhiteUser == nil || game.BlackUser == nil || game.StartFEN != "":
+		return false
+	case isBotGame(game):
+		return p.bot
+	case isLocalGame(game):
+		return p.local
+	}
+	return game.Rated || p.casual
+}
+
+// rateGame updates the players' ratings for a game that has just finished and
+// been saved, and records the change on game. A rating failure is logged and
+// leaves the game unrated rather than failing the move that ended it. The
+// caller must hold the game's lock.
+func (ws *WebSocketServiceImpl) rateGame(game *dao.ChessGame, now time.Time) {
+	if !ws.ratingPolicy.rates(*game) {
+		return
+	}
+	whiteScore := 0.5
+	switch game.Winner {
+	case "w":
+		whiteScore = 1
+	case "b":
+		whiteScore = 0
+	}
+
+	category := engine.GameTimeControl(*game).Category()
+	var newRows []*dao.Rating
+	rated, err := ws.ratingRepository.RateGame(game, category, func(white, black *dao.Rating) {
+		newRows = []*dao.Rating{white, black}
+		w, b := currentRating(white, now), currentRating(black, now)
+		var newWhite, newBlack rating.Rating
+		if white == black {
+			// Both results fall in one rating period for the one player.
+			newWhite = rating.Update(w, []rating.Result{{Opponent: w, Score: whiteScore}, {Opponent: w, Score: 1 - whiteScore}})
+			newBlack = newWhite
+		} else {
+			newWhite = rating.Update(w, []rating.Result{{Opponent: b, Score: whiteScore}})
+			newBlack = rating.Update(b, []rating.Result{{Opponent: w, Score: 1 - whiteScore}})
+		}
+		game.WhiteRatingChange = ratingChange(white.Rating, newWhite.Rating)
+		game.BlackRatingChange = ratingChange(black.Rating, newBlack.Rating)
+		storeRating(white, newWhite, now)
+		if black != white {
+			storeRating(black, newBlack, now)
+		}
+	})
+	if err != nil {
+		log.Errorf("Could not rate game %d: %v", game.ID, err)
+		game.WhiteRatingChange, game.BlackRatingChange = nil, nil
+		return
+	}
+	if !rated {
+		return
+	}
+	if err := ws.chessRepository.SaveChessGameToCache(game); err != nil {
+		log.Warn("Could not update game cache: ", err)
+	}
+	// The leaderboards are rebuilt from the ratings at start-up, so one that
+	// misses an update here is only behind until then.
+	for i, user := range []*dao.User{game.WhiteUser, game.BlackUser} {
+		if err := ws.leaderboardRepository.UpdateLeaderboard(*user, *newRows[i]); err != nil {
+			log.Warnf("Could not update the %s leaderboard: %v", category, err)
+		}
+	}
+}
+
+// currentRating is a stored rating as of now, its deviation widened for the
+// rating periods since its last game.
+func currentRating(row *dao.Rating, now time.Time) rating.Rating {
+	r := rating.Rating{Rating: row.Rating, Deviation: row.Deviation, Volatility: row.Volatility}
+	if !row.RatedAt.IsZero() {
+		r = r.Decay(float64(now.Sub(row.RatedAt) / ratingPeriod))
+	}
+	return r
+}
+
+func storeRating(row *dao.Rating, r rating.Rating, now time.Time) {
+	row.Rating, row.Deviation, row.Volatility = r.Rating, r.Deviation, r.Volatility
+	row.Games++
+	row.RatedAt = now
+}
+
+func ratingChange(before, after float64) *int {
+	change := int(math.Round(after - before))
+	return &change
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/engine"
+	"chess-engine/app/pkg"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Rematches. Once a game is over either player can offer to play again; when
+// the other accepts, a new game is created with the same players the other
+// way round, the same time control, starting position and bot level, and
+// linked back to the old one. The offer and answer travel on the finished
+// game's socket, which both players are still connected to.
+
+// applyRematch runs a rematch message under the game's lock, as applyAction
+// does for a game in progress.
+func (ws *WebSocketServiceImpl) applyRematch(gameId, action string, user dao.User) {
+	lk := ws.lockFor(gameId)
+	lk.Lock()
+	defer lk.Unlock()
+
+	game, err := ws.loadGame(gameId)
+	if err != nil {
+		log.Error("Error fetching game state:", err)
+		ws.sendError(gameId, "could not load game")
+		return
+	}
+	if game.Winner == "" {
+		ws.sendError(gameId, "the game is not over yet")
+		return
+	}
+	if game.RematchGameID != nil {
+		ws.sendError(gameId, "the rematch has already started")
+		return
+	}
+
+	side := seatOf(game, user)
+	if side == "" {
+		log.Errorf("Rejecting %s: user %d is not in game %s", action, user.ID, gameId)
+		ws.sendError(gameId, "only the players can ask for a rematch")
+		return
+	}
+	opponent := engine.ToggleTurn(side)
+	now := time.Now()
+
+	switch action {
+	case dto.MessageRematchOffer:
+		switch {
+		case isBotGame(game) || isLocalGame(game):
+			// The bot always wants another game, and one person playing both
+			// sides has nobody to ask.
+			ws.startRematch(gameId, &game, now)
+		case game.RematchOffer == opponent:
+			// Both sides want a rematch: offering into a standing offer accepts it.
+			ws.startRematch(gameId, &game, now)
+		case game.RematchOffer == side:
+			ws.sendError(gameId, "rematch already offered")
+		default:
+			game.RematchOffer = side
+			ws.saveAndBroadcast(gameId, &game, dto.MessageRematchOffer, now)
+		}
+
+	case dto.MessageRematchAccept:
+		if game.RematchOffer != opponent {
+			ws.sendError(gameId, "there is no rematch offer to accept")
+			return
+		}
+		ws.startRematch(gameId, &game, now)
+
+	case dto.MessageRematchDecline:
+		if game.RematchOffer != opponent {
+			ws.sendError(gameId, "there is no rematch offer to decline")
+			return
+		}
+		game.RematchOffer = ""
+		ws.saveAndBroadcast(gameId, &game, dto.MessageRematchDecline, now)
+
+	default:
+		log.Errorf("Unknown action %q for game %s", action, gameId)
+		ws.sendError(gameId, "unknown action")
+	}
+}
+
+// startRematch creates the rematch of a finished game, links the two, and
+// tells the old game's clients where to go. The caller must hold the game's
+// lock, which is what stops two accepts creating two rematches.
+func (ws *WebSocketServiceImpl) startRematch(gameId string, game *dao.ChessGame, now time.Time) {
+	previousID := game.ID
+	rematch := rematchOf(*game)
+	err := ws.chessRepository.SaveChessGameToDB(&rematch)
+	if err == nil {
+		err = storeInitialState(ws.chessRepository, rematch.ID, engine.GameStartState(*game), engine.GameTimeControl(*game))
+	}
+	if err != nil {
+		log.Errorf("Could not create the rematch of game %s: %v", gameId, err)
+		ws.sendError(gameId, "could not create the rematch")
+		return
+	}
+	log.Infof("Game %d is the rematch of game %s", rematch.ID, gameId)
+
+	game.RematchOffer = ""
+	game.RematchGameID = &rematch.ID
+	ws.saveAndBroadcast(gameId, game, dto.MessageRematch, now)
+
+	payload := dto.Rematch{GameID: rematch.ID, PreviousGameID: previousID}
+	if series, err := ws.chessRepository.FindGameSeries(previousID); err != nil {
+		// The players can still go and play; only the score is missing.
+		log.Error("Error finding game series:", err)
+	} else {
+		payload.Series = seriesScore(series)
+	}
+	ws.BroadcastMessage(gameId, dto.WebSocketMessage{
+		Type:    dto.MessageRematch,
+		Status:  "success",
+		Payload: payload,
+	})
+}
+
+// rematchOf is the game a rematch of game starts as: the same players the
+// other way round, the same time control, rating, starting position and bot
+// level, linked back to game.
+func rematchOf(game dao.ChessGame) dao.ChessGame {
+	previousID := game.ID
+	rematch := dao.ChessGame{
+		InviteCode:     pkg.GenerateRandomString(20),
+		Rated:          game.Rated,
+		BotLevel:       game.BotLevel,
+		StartFEN:       game.StartFEN,
+		WhiteUserId:    game.BlackUserId,
+		BlackUserId:    game.WhiteUserId,
+		PreviousGameID: &previousID,
+	}
+	engine.GameTimeControl(game).Apply(&rematch)
+	return rematch
+}
+
+// GetGameSeries returns the head-to-head score of the rematches leading up to
+// and including a game.
+func (u ChessServiceImpl) GetGameSeries(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program get game series")
+	gameID, err := strconv.Atoi(c.Param("gameId"))
+	if err != nil || gameID <= 0 {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "invalid game id")
+	}
+	games, err := u.chessRepository.FindGameSeries(gameID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		pkg.PanicException(constant.DataNotFound)
+	}
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, seriesScore(games)))
+}
+
+// seriesScore totals a series, oldest game first. The players are the first
+// game's; colours swap from game to game, so results are credited by user.
+func seriesScore(games []dao.ChessGame) dto.SeriesScore {
+	score := dto.SeriesScore{Games: len(games), Players: []dto.SeriesPlayer{}}
+	if len(games) == 0 {
+		return score
+	}
+	index := map[int]int{}
+	for _, u := range []*dao.User{games[0].WhiteUser, games[0].BlackUser} {
+		if u == nil {
+			continue
+		}
+		if _, seen := index[u.ID]; !seen {
+			index[u.ID] = len(score.Players)
+			score.Players = append(score.Players, dto.SeriesPlayer{UserID: u.ID, Name: u.Name})
+		}
+	}
+	credit := func(u *dao.User, points float64, win bool) {
+		if u == nil {
+			return
+		}
+		if i, ok := index[u.ID]; ok {
+			score.Players[i].Score += points
+			if win {
+				score.Players[i].Wins++
+			}
+		}
+	}
+	for _, g := range games {
+		switch g.Winner {
+		case "w":
+			credit(g.WhiteUser, 1, true)
+		case "b":
+			credit(g.BlackUser, 1, true)
+		case "d":
+			score.Draws++
+			credit(g.WhiteUser, 0.5, false)
+			credit(g.BlackUser, 0.5, false)
+		}
+	}
+	return score
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"testing"
+)
+
+func TestSeriesScore(t *testing.T) {
+	alice := &dao.User{ID: 1, Name: "alice"}
+	bob := &dao.User{ID: 2, Name: "bob"}
+	carol := &dao.User{ID: 3, Name: "carol"}
+	// aliceWhite and bobWhite are one game of the series each way round.
+	aliceWhite := func(winner string) dao.ChessGame {
+		return dao.ChessGame{WhiteUser: alice, BlackUser: bob, Winner: winner}
+	}
+	bobWhite := func(winner string) dao.ChessGame {
+		return dao.ChessGame{WhiteUser: bob, BlackUser: alice, Winner: winner}
+	}
+	players := func(aliceWins int, aliceScore float64, bobWins int, bobScore float64) []dto.SeriesPlayer {
+		return []dto.SeriesPlayer{
+			{UserID: 1, Name: "alice", Wins: aliceWins, Score: aliceScore},
+			{UserID: 2, Name: "bob", Wins: bobWins, Score: bobScore},
+		}
+	}
+
+	cases := []struct {
+		name  string
+		games []dao.ChessGame
+		want  dto.SeriesScore
+	}{
+		{"no games", nil, dto.SeriesScore{Players: []dto.SeriesPlayer{}}},
+		{"one win", []dao.ChessGame{aliceWhite("w")},
+			dto.SeriesScore{Games: 1, Players: players(1, 1, 0, 0)}},
+		// Alice wins as White, then as Black: the colours swap, the credit
+		// follows the player.
+		{"colour swap", []dao.ChessGame{aliceWhite("w"), bobWhite("b")},
+			dto.SeriesScore{Games: 2, Players: players(2, 2, 0, 0)}},
+		{"one each", []dao.ChessGame{aliceWhite("b"), bobWhite("b")},
+			dto.SeriesScore{Games: 2, Players: players(1, 1, 1, 1)}},
+		{"draws", []dao.ChessGame{aliceWhite("d"), bobWhite("d"), aliceWhite("w")},
+			dto.SeriesScore{Games: 3, Players: players(1, 2, 0, 1), Draws: 2}},
+		// Aborted and unfinished games count as played but score nothing.
+		{"aborts and the game under way", []dao.ChessGame{
+			aliceWhite(constant.WinnerAborted), bobWhite("w"), aliceWhite(""),
+		}, dto.SeriesScore{Games: 3, Players: players(0, 0, 1, 1)}},
+		// Against the bot only the person is a player.
+		{"bot game", []dao.ChessGame{
+			{WhiteUser: alice, BotLevel: "easy", Winner: "b"},
+			{BlackUser: alice, BotLevel: "easy", Winner: "b"},
+		}, dto.SeriesScore{Games: 2, Players: []dto.SeriesPlayer{{UserID: 1, Name: "alice", Wins: 1, Score: 1}}}},
+		// One person playing both sides is one player, scoring every result.
+		{"pass and play", []dao.ChessGame{
+			{WhiteUser: alice, BlackUser: alice, Winner: "w"},
+			{WhiteUser: alice, BlackUser: alice, Winner: "d"},
+		}, dto.SeriesScore{Games: 2, Draws: 1, Players: []dto.SeriesPlayer{{UserID: 1, Name: "alice", Wins: 1, Score: 2}}}},
+		// Only the first game's players are scored.
+		{"a stranger", []dao.ChessGame{aliceWhite("w"), {WhiteUser: carol, BlackUser: alice, Winner: "w"}},
+			dto.SeriesScore{Games: 2, Players: players(1, 1, 0, 0)}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := seriesScore(c.games)
+			if got.Games != c.want.Games || got.Draws != c.want.Draws || len(got.Players) != len(c.want.Players) {
+				t.Fatalf("seriesScore = %+v, want %+v", got, c.want)
+			}
+			for i := range got.Players {
+				if got.Players[i] != c.want.Players[i] {
+					t.Errorf("player %d = %+v, want %+v", i, got.Players[i], c.want.Players[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRematchOf(t *testing.T) {
+	white, black := 1, 2
+	game := dao.ChessGame{
+		ID:               40,
+		WhiteUserId:      &white,
+		BlackUserId:      &black,
+		Rated:            true,
+		StartFEN:         "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1",
+		BaseSeconds:      180,
+		IncrementSeconds: 2,
+		Winner:           "w",
+		RematchOffer:     "b",
+		InviteCode:       "old-invite",
+	}
+	rematch := rematchOf(game)
+
+	if rematch.WhiteUserId == nil || *rematch.WhiteUserId != black ||
+		rematch.BlackUserId == nil || *rematch.BlackUserId != white {
+		t.Errorf("seats %v/%v, want the players the other way round", rematch.WhiteUserId, rematch.BlackUserId)
+	}
+	if rematch.PreviousGameID == nil || *rematch.PreviousGameID != game.ID {
+		t.Errorf("PreviousGameID = %v, want %d", rematch.PreviousGameID, game.ID)
+	}
+	if !rematch.Rated || rematch.StartFEN != game.StartFEN ||
+		rematch.BaseSeconds != 180 || rematch.IncrementSeconds != 2 || rematch.DaysPerMove != 0 {
+		t.Errorf("rematch %+v does not carry over the rating, position and time control", rematch)
+	}
+	if rematch.ID != 0 || rematch.Winner != "" || rematch.RematchOffer != "" || rematch.RematchGameID != nil {
+		t.Errorf("rematch %+v carries over the old game's outcome", rematch)
+	}
+	if rematch.InviteCode == "" || rematch.InviteCode == game.InviteCode {
+		t.Errorf("invite code %q, want a fresh one", rematch.InviteCode)
+	}
+
+	// A bot game stays a bot game at the same level, with the person now on
+	// the other side; a correspondence game keeps its days per move.
+	bot := rematchOf(dao.ChessGame{ID: 41, WhiteUserId: &white, BotLevel: "hard", DaysPerMove: 3})
+	if bot.BotLevel != "hard" || bot.WhiteUserId != nil || bot.BlackUserId == nil || *bot.BlackUserId != white {
+		t.Errorf("bot rematch %+v, want the person as Black against the same bot", bot)
+	}
+	if bot.DaysPerMove != 3 {
+		t.Errorf("DaysPerMove = %d, want 3", bot.DaysPerMove)
+	}
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/engine"
+	"chess-engine/app/pkg"
+	"chess-engine/app/rating"
+	"chess-engine/app/repository"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// Matchmaking. A player enters the pool with a seek -- a time control, rated
+// or casual, and how far from their own rating an opponent may be -- and a
+// matcher pairs compatible seeks, creates the game and tells both players on
+// their user channel (see NotificationService). The pool lives in memory: a
+// restart empties it, and the clients seek again.
+
+// The rating window. A seek starts out matching only close ratings and
+// widens by seekWidenStep every seekWidenInterval, up to the seek's own
+// range, so a quick match is a fair one and a long wait still ends in a game.
+const (
+	seekInitialWindow  = 100
+	seekWidenStep      = 50
+	seekWidenInterval  = 5 * time.Second
+	defaultSeekRange   = 400
+	maxSeekRange       = 1000
+	seekMatchInterval  = time.Second
+	seekTTL            = 10 * time.Minute
+	matchedSeekVisible = time.Minute
+)
+
+type SeekService interface {
+	CreateSeek(c *gin.Context)
+	CancelSeek(c *gin.Context)
+	GetSeek(c *gin.Context)
+}
+
+type SeekServiceImpl struct {
+	chessRepository     repository.ChessRepository
+	ratingRepository    repository.RatingRepository
+	notificationService NotificationService
+
+	mutex sync.Mutex
+	// seeks is the pool, one seek per user.
+	seeks map[int]*seek
+	// matched is the game each recently matched user was put in, until
+	// matchedSeekVisible after the match.
+	matched map[int]matchedSeek
+}
+
+type seek struct {
+	user     dao.User
+	tc       engine.TimeControl
+	rated    bool
+	rating   float64
+	maxRange int
+	since    time.Time
+}
+
+type matchedSeek struct {
+	gameID int
+	at     time.Time
+}
+
+// window is how far apart two ratings may be for s to accept the pairing, at
+// now.
+func (s *seek) window(now time.Time) int {
+	w := seekInitialWindow + seekWidenStep*int(now.Sub(s.since)/seekWidenInterval)
+	if w > s.maxRange {
+		w = s.maxRange
+	}
+	return w
+}
+
+// compatible reports whether a and b can be paired at now: the same game
+// wanted, and each rating within the other's window.
+func compatible(a, b *seek, now time.Time) bool {
+	if a.user.ID == b.user.ID || a.tc != b.tc || a.rated != b.rated {
+		return false
+	}
+	gap := math.Abs(a.rating - b.rating)
+	return gap <= float64(a.window(now)) && gap <= float64(b.window(now))
+}
+
+// pairSeeks pairs the pool, longest waiting first, each with the closest
+// rated compatible seek still unpaired.
+func pairSeeks(pool []*seek, now time.Time) [][2]*seek {
+	sort.Slice(pool, func(i, j int) bool {
+		if !pool[i].since.Equal(pool[j].since) {
+			return pool[i].since.Before(pool[j].since)
+		}
+		return pool[i].user.ID < pool[j].user.ID
+	})
+	paired := make([]bool, len(pool))
+	var pairs [][2]*seek
+	for i, a := range pool {
+		if paired[i] {
+			continue
+		}
+		best := -1
+		for j := i + 1; j < len(pool); j++ {
+			if paired[j] || !compatible(a, pool[j], now) {
+				continue
+			}
+			if best < 0 || math.Abs(a.rating-pool[j].rating) < math.Abs(a.rating-pool[best].rating) {
+				best = j
+			}
+		}
+		if best >= 0 {
+			paired[i], paired[best] = true, true
+			pairs = append(pairs, [2]*seek{a, pool[best]})
+		}
+	}
+	return pairs
+}
+
+// CreateSeek enters the caller into the pool, replacing a seek they already
+// had.
+func (u *SeekServiceImpl) CreateSeek(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program create seek")
+	user := authSession(c).User
+	var request dto.SeekRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error("Happened error when mapping request from FE. Error", err)
+		pkg.PanicException(constant.InvalidRequest)
+	}
+	tc := parseTimeControl(request.TimeControlRequest)
+	maxRange := request.RatingRange
+	if maxRange == 0 {
+		maxRange = defaultSeekRange
+	}
+	if maxRange < 0 || maxRange > maxSeekRange {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+			"rating_range must be between 1 and "+strconv.Itoa(maxSeekRange))
+	}
+	r, err := u.currentRating(user.ID, tc.Category())
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+
+	s := &seek{
+		user:     dao.User{ID: user.ID, Name: user.Name},
+		tc:       tc,
+		rated:    request.Rated,
+		rating:   r,
+		maxRange: maxRange,
+		since:    time.Now(),
+	}
+	u.mutex.Lock()
+	u.seeks[user.ID] = s
+	delete(u.matched, user.ID)
+	status := u.status(user.ID, s.since)
+	u.mutex.Unlock()
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, status))
+}
+
+// CancelSeek takes the caller out of the pool. It is not an error to have no
+// seek: a match may have just beaten the cancel.
+func (u *SeekServiceImpl) CancelSeek(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program cancel seek")
+	user := authSession(c).User
+	u.mutex.Lock()
+	delete(u.seeks, user.ID)
+	status := u.status(user.ID, time.Now())
+	u.mutex.Unlock()
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, status))
+}
+
+// GetSeek reports the caller's seek, or the game it was matched into.
+func (u *SeekServiceImpl) GetSeek(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	user := authSession(c).User
+	u.mutex.Lock()
+	status := u.status(user.ID, time.Now())
+	u.mutex.Unlock()
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, status))
+}
+
+// status describes userID's place in the pool. The caller must hold the mutex.
+func (u *SeekServiceImpl) status(userID int, now time.Time) dto.SeekStatus {
+	if s := u.seeks[userID]; s != nil {
+		since := s.since
+		return dto.SeekStatus{
+			Seeking:     true,
+			TimeControl: s.tc.String(),
+			Rated:       s.rated,
+			Rating:      int(math.Round(s.rating)),
+			RatingRange: s.maxRange,
+			Window:      s.window(now),
+			Since:       &since,
+		}
+	}
+	if m, ok := u.matched[userID]; ok {
+		gameID := m.gameID
+		return dto.SeekStatus{GameID: &gameID}
+	}
+	return dto.SeekStatus{}
+}
+
+// currentRating is the user's rating in category, the default for a category
+// they have not played.
+func (u *SeekServiceImpl) currentRating(userID int, category string) (float64, error) {
+	rows, err := u.ratingRepository.FindRatingsByUser(userID)
+	if err != nil {
+		return 0, err
+	}
+	for _, row := range rows {
+		if row.Category == category {
+			return row.Rating, nil
+		}
+	}
+	return rating.Default().Rating, nil
+}
+
+// run is the matcher: once a second it drops stale seeks and pairs the rest.
+func (u *SeekServiceImpl) run() {
+	ticker := time.NewTicker(seekMatchInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		u.matchOnce(now)
+	}
+}
+
+func (u *SeekServiceImpl) matchOnce(now time.Time) {
+	u.mutex.Lock()
+	var expired []int
+	pool := make([]*seek, 0, len(u.seeks))
+	for id, s := range u.seeks {
+		if now.Sub(s.since) > seekTTL {
+			delete(u.seeks, id)
+			expired = append(expired, id)
+			continue
+		}
+		pool = append(pool, s)
+	}
+	for id, m := range u.matched {
+		if now.Sub(m.at) > matchedSeekVisible {
+			delete(u.matched, id)
+		}
+	}
+	pairs := pairSeeks(pool, now)
+	for _, p := range pairs {
+		delete(u.seeks, p[0].user.ID)
+		delete(u.seeks, p[1].user.ID)
+	}
+	u.mutex.Unlock()
+
+	for _, id := range expired {
+		u.notificationService.Notify(id, dto.WebSocketMessage{
+			Type:    dto.MessageSeekExpired,
+			Status:  "success",
+			Message: "no opponent found, seek again to keep waiting",
+		})
+	}
+	for _, p := range pairs {
+		u.startMatch(p[0], p[1], now)
+	}
+}
+
+// startMatch creates the game for a pairing, with colours drawn at random as
+// CreateChessGame does, and tells both players. If the game cannot be created
+// both seeks go back in the pool.
+func (u *SeekServiceImpl) startMatch(a, b *seek, now time.Time) {
+	white, black := a, b
+	if !pkg.GenerateRandomBool() {
+		white, black = b, a
+	}
+	game := dao.ChessGame{
+		InviteCode:  pkg.GenerateRandomString(20),
+		Rated:       a.rated,
+		WhiteUserId: &white.user.ID,
+		BlackUserId: &black.user.ID,
+	}
+	a.tc.Apply(&game)
+	err := u.chessRepository.SaveChessGameToDB(&game)
+	if err == nil {
+		err = storeInitialState(u.chessRepository, game.ID, engine.StartState(), a.tc)
+	}
+	if err != nil {
+		log.Errorf("Happened error when creating a game for users %d and %d. Error %v", a.user.ID, b.user.ID, err)
+		u.mutex.Lock()
+		for _, s := range []*seek{a, b} {
+			if _, seeking := u.seeks[s.user.ID]; !seeking {
+				u.seeks[s.user.ID] = s
+			}
+		}
+		u.mutex.Unlock()
+		return
+	}
+	log.Infof("Matched users %d and %d into game %d", white.user.ID, black.user.ID, game.ID)
+
+	u.mutex.Lock()
+	u.matched[white.user.ID] = matchedSeek{gameID: game.ID, at: now}
+	u.matched[black.user.ID] = matchedSeek{gameID: game.ID, at: now}
+	u.mutex.Unlock()
+
+	for _, side := range []struct {
+		me, opponent *seek
+		colour       string
+	}{{white, black, dto.SeatWhite}, {black, white, dto.SeatBlack}} {
+		opponent := side.opponent.user
+		u.notificationService.Notify(side.me.user.ID, dto.WebSocketMessage{
+			Type:   dto.MessageMatchFound,
+			Status: "success",
+			Payload: dto.MatchFound{
+				GameID:      game.ID,
+				Colour:      side.colour,
+				Opponent:    &opponent,
+				TimeControl: a.tc.String(),
+				Rated:       a.rated,
+			},
+		})
+	}
+}
+
+func SeekServiceInit(chessRepository repository.ChessRepository, ratingRepository repository.RatingRepository,
+	notificationService NotificationService) *SeekServiceImpl {
+	service := &SeekServiceImpl{
+		chessRepository:     chessRepository,
+		ratingRepository:    ratingRepository,
+		notificationService: notificationService,
+		seeks:               make(map[int]*seek),
+		matched:             make(map[int]matchedSeek),
+	}
+	go service.run()
+	return service
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/engine"
+	"testing"
+	"time"
+)
+
+var (
+	seekEpoch = time.Unix(1_700_000_000, 0)
+	blitz     = engine.TimeControl{Base: 3 * time.Minute, Increment: 2 * time.Second}
+	rapid     = engine.TimeControl{Base: 10 * time.Minute}
+)
+
+// newSeek is a rated blitz seek with the default range, placed at
+// seekEpoch+at.
+func newSeek(userID int, rating float64, at time.Duration) *seek {
+	return &seek{
+		user:     dao.User{ID: userID},
+		tc:       blitz,
+		rated:    true,
+		rating:   rating,
+		maxRange: defaultSeekRange,
+		since:    seekEpoch.Add(at),
+	}
+}
+
+func TestSeekWindow(t *testing.T) {
+	cases := []struct {
+		name     string
+		waited   time.Duration
+		maxRange int
+		want     int
+	}{
+		{"new", 0, defaultSeekRange, seekInitialWindow},
+		{"just short of a step", seekWidenInterval - time.Millisecond, defaultSeekRange, seekInitialWindow},
+		{"one step", seekWidenInterval, defaultSeekRange, seekInitialWindow + seekWidenStep},
+		{"three steps", 3*seekWidenInterval + time.Second, defaultSeekRange, seekInitialWindow + 3*seekWidenStep},
+		{"capped by the range", time.Hour, defaultSeekRange, defaultSeekRange},
+		{"a narrow range", 0, 50, 50},
+		{"the widest range", time.Hour, maxSeekRange, maxSeekRange},
+	}
+	for _, c := range cases {
+		s := &seek{maxRange: c.maxRange, since: seekEpoch}
+		if got := s.window(seekEpoch.Add(c.waited)); got != c.want {
+			t.Errorf("%s: window = %d, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSeekCompatible(t *testing.T) {
+	now := seekEpoch
+	later := seekEpoch.Add(2 * seekWidenInterval) // windows of 200
+	with := func(s *seek, f func(*seek)) *seek { f(s); return s }
+
+	cases := []struct {
+		name string
+		a, b *seek
+		now  time.Time
+		want bool
+	}{
+		{"same rating", newSeek(1, 1500, 0), newSeek(2, 1500, 0), now, true},
+		{"at the edge of the window", newSeek(1, 1500, 0), newSeek(2, 1600, 0), now, true},
+		{"just outside it", newSeek(1, 1500, 0), newSeek(2, 1600.5, 0), now, false},
+		{"inside once widened", newSeek(1, 1500, 0), newSeek(2, 1700, 0), later, true},
+		// Both windows must take the gap: a new seek has not widened yet.
+		{"only one widened", newSeek(1, 1500, 0), newSeek(2, 1700, 2*seekWidenInterval), later, false},
+		{"the other's narrow range", newSeek(1, 1500, 0),
+			with(newSeek(2, 1560, 0), func(s *seek) { s.maxRange = 50 }), now, false},
+		{"oneself", newSeek(1, 1500, 0), newSeek(1, 1500, time.Second), now, false},
+		{"other time control", newSeek(1, 1500, 0),
+			with(newSeek(2, 1500, 0), func(s *seek) { s.tc = rapid }), now, false},
+		{"rated against casual", newSeek(1, 1500, 0),
+			with(newSeek(2, 1500, 0), func(s *seek) { s.rated = false }), now, false},
+	}
+	for _, c := range cases {
+		if got := compatible(c.a, c.b, c.now); got != c.want {
+			t.Errorf("%s: compatible = %v, want %v", c.name, got, c.want)
+		}
+		if got := compatible(c.b, c.a, c.now); got != c.want {
+			t.Errorf("%s, swapped: compatible = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPairSeeks(t *testing.T) {
+	type pair [2]int // user ids, longest waiting first
+	cases := []struct {
+		name string
+		pool []*seek
+		now  time.Duration
+		want []pair
+	}{
+		{"empty", nil, 0, nil},
+		{"alone", []*seek{newSeek(1, 1500, 0)}, 0, nil},
+		{"a pair", []*seek{newSeek(2, 1550, time.Second), newSeek(1, 1500, 0)}, time.Second, []pair{{1, 2}}},
+		{"too far apart", []*seek{newSeek(1, 1500, 0), newSeek(2, 1800, 0)}, 0, nil},
+		// The longest waiting takes the closest rating, not the first found.
+		{"closest first", []*seek{
+			newSeek(1, 1500, 0), newSeek(2, 1590, time.Second), newSeek(3, 1510, 2*time.Second),
+		}, 2 * time.Second, []pair{{1, 3}}},
+		// 2 is closer to 3, but 1 has waited longer and gets first pick.
+		{"longest waiting picks first", []*seek{
+			newSeek(3, 1580, 2*time.Second), newSeek(1, 1500, 0), newSeek(2, 1560, time.Second),
+		}, 2 * time.Second, []pair{{1, 2}}},
+		{"everyone paired once", []*seek{
+			newSeek(1, 1500, 0), newSeek(2, 1505, time.Second),
+			newSeek(3, 2000, 2*time.Second), newSeek(4, 2010, 3*time.Second),
+		}, 3 * time.Second, []pair{{1, 2}, {3, 4}}},
+		// Same wait: the lower user id goes first, so pairing is repeatable.
+		{"ties by user id", []*seek{
+			newSeek(3, 1500, 0), newSeek(2, 1500, 0), newSeek(1, 1500, 0),
+		}, 0, []pair{{1, 2}}},
+		{"time controls kept apart", []*seek{
+			newSeek(1, 1500, 0), {user: dao.User{ID: 2}, tc: rapid, rated: true, rating: 1500,
+				maxRange: defaultSeekRange, since: seekEpoch},
+		}, 0, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got []pair
+			for _, p := range pairSeeks(c.pool, seekEpoch.Add(c.now)) {
+				got = append(got, pair{p[0].user.ID, p[1].user.ID})
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("pairs %v, want %v", got, c.want)
+			}
+			for i := range got {
+				if got[i] != c.want[i] {
+					t.Fatalf("pairs %v, want %v", got, c.want)
+				}
+			}
+		})
+	}
+}
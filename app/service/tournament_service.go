@@ -0,0 +1,679 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/engine"
+	"chess-engine/app/pkg"
+	"chess-engine/app/rating"
+	"chess-engine/app/repository"
+	"chess-engine/app/tournament"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Tournaments. An organiser creates a Swiss or an arena, players join, and
+// once it is started a runner pairs it: a Swiss round by round as each
+// finishes, an arena whenever two players are free, until its time is up.
+// The games are ordinary games, played over /ws/:gameId; the runner reads
+// their results back from ChessGame.Winner. Pairing and scoring are package
+// tournament's. Everyone watching /ws/tournament/:tournamentId is sent the
+// standings each time they change.
+
+const (
+	tournamentTickInterval = 2 * time.Second
+	// tournamentFirstMoveLimit is how long White has to make the first move
+	// of a tournament game, which no clock times. A player who lets it pass
+	// loses the game and is withdrawn, so an absent player neither holds up a
+	// Swiss round nor keeps being paired in an arena.
+	tournamentFirstMoveLimit = 2 * time.Minute
+	maxTournamentName        = 80
+	maxTournamentRounds      = 15
+	maxArenaMinutes          = 180
+)
+
+type TournamentService interface {
+	CreateTournament(c *gin.Context)
+	GetTournaments(c *gin.Context)
+	GetTournament(c *gin.Context)
+	JoinTournament(c *gin.Context)
+	WithdrawFromTournament(c *gin.Context)
+	StartTournament(c *gin.Context)
+	RegisterClient(tournamentID int, conn *websocket.Conn) bool
+	UnregisterClient(tournamentID int, conn *websocket.Conn)
+}
+
+type TournamentServiceImpl struct {
+	tournamentRepository repository.TournamentRepository
+	ratingRepository     repository.RatingRepository
+	socketService        WebSocketService
+	notificationService  NotificationService
+
+	// advanceMu serialises pairing: the runner's ticks and the first round,
+	// which StartTournament pairs straight away.
+	advanceMu sync.Mutex
+
+	// mutex guards clients. As in NotificationService it is not held while
+	// writing, and nor is advanceMu.
+	mutex   sync.Mutex
+	clients map[int]map[*websocket.Conn]*lockedConn
+}
+
+// CreateTournament sets up a tournament, which takes entries until its
+// organiser starts it.
+func (u *TournamentServiceImpl) CreateTournament(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program create tournament")
+	user := authSession(c).User
+	var request dto.TournamentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Error("Happened error when mapping request from FE. Error", err)
+		pkg.PanicException(constant.InvalidRequest)
+	}
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len(name) > maxTournamentName {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+			"name must be between 1 and "+strconv.Itoa(maxTournamentName)+" characters")
+	}
+	tc, err := engine.ParseTimeControl(request.TimeControl, 0)
+	if err != nil || !tc.Timed() {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "a tournament needs a live time control such as 3+2")
+	}
+
+	t := dao.Tournament{
+		Name:             name,
+		Kind:             request.Kind,
+		Status:           constant.TournamentStatusCreated,
+		BaseSeconds:      int(tc.Base / time.Second),
+		IncrementSeconds: int(tc.Increment / time.Second),
+		Rated:            request.Rated,
+		CreatedByID:      user.ID,
+	}
+	switch request.Kind {
+	case tournament.KindSwiss:
+		if request.Rounds < 1 || request.Rounds > maxTournamentRounds {
+			pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+				"rounds must be between 1 and "+strconv.Itoa(maxTournamentRounds))
+		}
+		t.Rounds = request.Rounds
+	case tournament.KindArena:
+		if request.DurationMinutes < 1 || request.DurationMinutes > maxArenaMinutes {
+			pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(),
+				"duration_minutes must be between 1 and "+strconv.Itoa(maxArenaMinutes))
+		}
+		t.DurationMinutes = request.DurationMinutes
+	default:
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "kind must be swiss or arena")
+	}
+	if err := u.tournamentRepository.SaveTournament(&t); err != nil {
+		log.Error("Happened error when saving tournament to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	log.Infof("User %d created %s tournament %d", user.ID, t.Kind, t.ID)
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, u.mustDetail(t.ID)))
+}
+
+// GetTournaments lists the latest tournaments.
+func (u *TournamentServiceImpl) GetTournaments(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program get tournaments")
+	tournaments, err := u.tournamentRepository.FindTournaments()
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, tournaments))
+}
+
+// GetTournament returns a tournament with its standings and pairings.
+func (u *TournamentServiceImpl) GetTournament(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program get tournament")
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, u.mustDetail(tournamentParam(c))))
+}
+
+// JoinTournament enters the caller, before the start or during play; a
+// player who withdrew comes back with their score. A Swiss pairs a late
+// entrant from its next round, an arena from its next pairing.
+func (u *TournamentServiceImpl) JoinTournament(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program join tournament")
+	user := authSession(c).User
+	t := u.findTournament(tournamentParam(c))
+	if t.Status == constant.TournamentStatusFinished {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "the tournament is over")
+	}
+	if err := u.tournamentRepository.JoinTournament(t.ID, user.ID); err != nil {
+		log.Error("Happened error when saving tournament player to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	log.Infof("User %d joined tournament %d", user.ID, t.ID)
+
+	detail := u.mustDetail(t.ID)
+	u.broadcast(detail)
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, detail))
+}
+
+// WithdrawFromTournament stops the caller being paired again. A game they are
+// playing is played out, and their results so far stand.
+func (u *TournamentServiceImpl) WithdrawFromTournament(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program withdraw from tournament")
+	user := authSession(c).User
+	t := u.findTournament(tournamentParam(c))
+	withdrawn, err := u.tournamentRepository.WithdrawFromTournament(t.ID, user.ID)
+	if err != nil {
+		log.Error("Happened error when saving tournament player to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	if !withdrawn {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "you are not playing in this tournament")
+	}
+	log.Infof("User %d withdrew from tournament %d", user.ID, t.ID)
+
+	detail := u.mustDetail(t.ID)
+	u.broadcast(detail)
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, detail))
+}
+
+// StartTournament starts a tournament and pairs its first round. Only the
+// organiser or a moderator may start it.
+func (u *TournamentServiceImpl) StartTournament(c *gin.Context) {
+	defer pkg.PanicHandler(c)
+
+	log.Info("start to execute program start tournament")
+	user := authSession(c).User
+	id := tournamentParam(c)
+	if u.start(id, user) {
+		u.broadcastTournament(id)
+	}
+
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, u.mustDetail(id)))
+}
+
+// start starts a tournament for StartTournament and pairs its first round,
+// reporting whether there is anything new to tell the watchers.
+func (u *TournamentServiceImpl) start(id int, user dao.User) bool {
+	u.advanceMu.Lock()
+	defer u.advanceMu.Unlock()
+	t := u.findTournament(id)
+	if t.CreatedByID != user.ID && constant.RoleRank(user.RoleName()) < constant.RoleRank(constant.RoleModerator) {
+		pkg.PanicException_(constant.Forbidden.GetResponseStatus(), "only the organiser can start the tournament")
+	}
+	if t.Status != constant.TournamentStatusCreated {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "the tournament has already started")
+	}
+	if len(activePlayers(t)) < 2 {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "a tournament needs at least two players")
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": constant.TournamentStatusRunning, "started_at": now}
+	t.Status, t.StartedAt = constant.TournamentStatusRunning, &now
+	if t.Kind == tournament.KindArena {
+		endsAt := now.Add(time.Duration(t.DurationMinutes) * time.Minute)
+		updates["ends_at"] = endsAt
+		t.EndsAt = &endsAt
+	}
+	if err := u.tournamentRepository.UpdateTournament(t.ID, updates); err != nil {
+		log.Error("Happened error when saving tournament to database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	log.Infof("User %d started tournament %d", user.ID, t.ID)
+	changed, err := u.advance(t, now)
+	if err != nil {
+		// The runner pairs it on its next tick instead.
+		log.Error("Happened error when pairing the first round. Error", err)
+	}
+	return changed
+}
+
+// RegisterClient adds a connection to a tournament's channel and sends it the
+// standings as they stand. It reports false for a tournament that does not
+// exist.
+func (u *TournamentServiceImpl) RegisterClient(tournamentID int, conn *websocket.Conn) bool {
+	detail, err := u.detail(tournamentID)
+	if err != nil {
+		return false
+	}
+	client := &lockedConn{Conn: conn}
+	u.mutex.Lock()
+	if u.clients[tournamentID] == nil {
+		u.clients[tournamentID] = make(map[*websocket.Conn]*lockedConn)
+	}
+	u.clients[tournamentID][conn] = client
+	u.mutex.Unlock()
+
+	if err := client.writeJSON(tournamentMessage(detail)); err != nil {
+		log.Error("Error sending tournament to client: ", err)
+		u.UnregisterClient(tournamentID, conn)
+	}
+	return true
+}
+
+// UnregisterClient removes and closes a connection.
+func (u *TournamentServiceImpl) UnregisterClient(tournamentID int, conn *websocket.Conn) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.remove(tournamentID, conn)
+}
+
+// broadcastTournament sends a tournament as it now stands to everyone
+// watching it. The caller must not hold advanceMu: a slow watcher would hold
+// up pairing.
+func (u *TournamentServiceImpl) broadcastTournament(id int) {
+	detail, err := u.detail(id)
+	if err != nil {
+		log.Errorf("Happened error when loading tournament %d to broadcast. Error %v", id, err)
+		return
+	}
+	u.broadcast(detail)
+}
+
+// broadcast sends detail to everyone watching its tournament, writing outside
+// the mutex.
+func (u *TournamentServiceImpl) broadcast(detail dto.TournamentDetail) {
+	id := detail.Tournament.ID
+	u.mutex.Lock()
+	conns := make([]*lockedConn, 0, len(u.clients[id]))
+	for _, conn := range u.clients[id] {
+		conns = append(conns, conn)
+	}
+	u.mutex.Unlock()
+
+	message := tournamentMessage(detail)
+	for _, conn := range conns {
+		if err := conn.writeJSON(message); err != nil {
+			log.Error("Error broadcasting tournament to client: ", err)
+			u.UnregisterClient(id, conn.Conn)
+		}
+	}
+}
+
+// remove drops a connection. The caller must hold the mutex.
+func (u *TournamentServiceImpl) remove(tournamentID int, conn *websocket.Conn) {
+	conns := u.clients[tournamentID]
+	if _, ok := conns[conn]; !ok {
+		return
+	}
+	delete(conns, conn)
+	conn.Close()
+	if len(conns) == 0 {
+		delete(u.clients, tournamentID)
+	}
+}
+
+func tournamentMessage(detail dto.TournamentDetail) dto.WebSocketMessage {
+	return dto.WebSocketMessage{Type: dto.MessageTournament, Status: "success", Payload: detail}
+}
+
+// run is the runner: every tournamentTickInterval it advances each running
+// tournament. It works from the database alone, so after a restart it picks
+// up where it left off.
+func (u *TournamentServiceImpl) run() {
+	ticker := time.NewTicker(tournamentTickInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		tournaments, err := u.tournamentRepository.FindRunningTournaments()
+		if err != nil {
+			continue
+		}
+		for _, t := range tournaments {
+			u.advanceMu.Lock()
+			changed, err := u.advance(t, now)
+			u.advanceMu.Unlock()
+			if err != nil {
+				log.Errorf("Happened error when advancing tournament %d. Error %v", t.ID, err)
+			}
+			if changed {
+				u.broadcastTournament(t.ID)
+			}
+		}
+	}
+}
+
+// advance brings a running tournament up to date: it records the results of
+// finished games, forfeits games nobody started, pairs whoever is due a game
+// and finishes the tournament once it is over. It reports whether anything
+// changed, even alongside an error, so that the caller can tell the watchers
+// once it has let go of advanceMu, which it must hold for the call.
+func (u *TournamentServiceImpl) advance(t dao.Tournament, now time.Time) (bool, error) {
+	settled, err := u.tournamentRepository.SettleResults(t.ID)
+	if err != nil {
+		return false, err
+	}
+	changed := settled > 0
+	if u.forfeitUnstarted(t, now) {
+		t = u.findTournamentOr(t)
+	}
+
+	rows, err := u.tournamentRepository.FindPairings(t.ID)
+	if err != nil {
+		return changed, err
+	}
+	players, err := u.players(t)
+	if err != nil {
+		return changed, err
+	}
+	history := pairingsOf(rows)
+	active := activePlayers(t)
+	playing := map[int]bool{}
+	for _, p := range history {
+		if p.Result == tournament.ResultPending {
+			playing[p.White], playing[p.Black] = true, true
+		}
+	}
+
+	var next []tournament.Pairing
+	finish := false
+	switch t.Kind {
+	case tournament.KindSwiss:
+		if len(playing) > 0 {
+			break
+		}
+		if t.CurrentRound >= t.Rounds || len(active) < 2 {
+			finish = true
+			break
+		}
+		next = tournament.PairSwiss(players, active, history, t.CurrentRound+1)
+	case tournament.KindArena:
+		if t.EndsAt != nil && !now.Before(*t.EndsAt) {
+			finish = len(playing) == 0
+			break
+		}
+		free := map[int]bool{}
+		for id := range active {
+			if !playing[id] {
+				free[id] = true
+			}
+		}
+		next = tournament.PairArena(players, free, history, t.CurrentRound+1)
+	}
+
+	if len(next) > 0 {
+		if err := u.startGames(t, next); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	if finish {
+		err := u.tournamentRepository.UpdateTournament(t.ID, map[string]interface{}{
+			"status":      constant.TournamentStatusFinished,
+			"finished_at": now,
+		})
+		if err != nil {
+			return changed, err
+		}
+		log.Infof("Tournament %d finished", t.ID)
+		changed = true
+	}
+	return changed, nil
+}
+
+// startGames stores a round's pairings, creates their games and tells each
+// player where to play.
+func (u *TournamentServiceImpl) startGames(t dao.Tournament, next []tournament.Pairing) error {
+	tc := tournamentTimeControl(t)
+	rows := make([]dao.TournamentPairing, len(next))
+	for i, p := range next {
+		rows[i] = dao.TournamentPairing{Round: p.Round, WhiteUserID: p.White, Result: p.Result}
+		if !p.IsBye() {
+			black := p.Black
+			rows[i].BlackUserID = &black
+		}
+	}
+	stored, err := u.tournamentRepository.CreatePairings(t, next[len(next)-1].Round, rows,
+		func(p dao.TournamentPairing) (dao.ChessGame, dao.GameState) {
+			white := p.WhiteUserID
+			game := dao.ChessGame{
+				InviteCode:  pkg.GenerateRandomString(20),
+				Rated:       t.Rated,
+				WhiteUserId: &white,
+				BlackUserId: p.BlackUserID,
+			}
+			tc.Apply(&game)
+			state := engine.StartState()
+			engine.StartClocks(&state, tc)
+			return game, state
+		})
+	if err != nil {
+		return err
+	}
+	log.Infof("Paired %d boards of tournament %d", len(stored), t.ID)
+
+	users := map[int]*dao.User{}
+	for _, p := range t.Players {
+		users[p.UserID] = p.User
+	}
+	for _, p := range stored {
+		game := dto.TournamentGame{TournamentID: t.ID, Round: p.Round, GameID: p.GameID, TimeControl: tc.String()}
+		if p.BlackUserID == nil {
+			u.notifyPaired(p.WhiteUserID, game, "you have a bye this round")
+			continue
+		}
+		white, black := game, game
+		white.Colour, white.Opponent = dto.SeatWhite, users[*p.BlackUserID]
+		black.Colour, black.Opponent = dto.SeatBlack, users[p.WhiteUserID]
+		u.notifyPaired(p.WhiteUserID, white, "your next tournament game is ready")
+		u.notifyPaired(*p.BlackUserID, black, "your next tournament game is ready")
+	}
+	return nil
+}
+
+func (u *TournamentServiceImpl) notifyPaired(userID int, game dto.TournamentGame, message string) {
+	u.notificationService.Notify(userID, dto.WebSocketMessage{
+		Type:    dto.MessageTournamentGame,
+		Status:  "success",
+		Message: message,
+		Payload: game,
+	})
+}
+
+// forfeitUnstarted ends the games whose White has not moved within
+// tournamentFirstMoveLimit of the pairing, as a win for Black, and withdraws
+// the absent player. It reports whether it withdrew anyone.
+func (u *TournamentServiceImpl) forfeitUnstarted(t dao.Tournament, now time.Time) bool {
+	unstarted, err := u.tournamentRepository.FindUnstartedGames(t.ID, now.Add(-tournamentFirstMoveLimit))
+	if err != nil {
+		return false
+	}
+	withdrew := false
+	for _, p := range unstarted {
+		err := u.socketService.TerminateGame(strconv.Itoa(*p.GameID), "b", "White did not make a first move in time", nil)
+		if err != nil {
+			// ErrGameOver: White moved, or the game ended, in the meantime.
+			continue
+		}
+		log.Infof("Forfeited tournament %d game %d for White not moving", t.ID, *p.GameID)
+		if ok, err := u.tournamentRepository.WithdrawFromTournament(t.ID, p.WhiteUserID); err != nil || !ok {
+			continue
+		}
+		withdrew = true
+		u.notificationService.Notify(p.WhiteUserID, dto.WebSocketMessage{
+			Type:    dto.MessageTournamentWithdrawn,
+			Status:  "success",
+			Message: "you were withdrawn from " + t.Name + " for not starting your game; join again to play on",
+		})
+	}
+	return withdrew
+}
+
+// players returns a tournament's entrants, withdrawn ones included, with
+// their ratings in its time control's category.
+func (u *TournamentServiceImpl) players(t dao.Tournament) ([]tournament.Player, error) {
+	ids := make([]int, len(t.Players))
+	for i, p := range t.Players {
+		ids[i] = p.UserID
+	}
+	rows, err := u.ratingRepository.FindRatings(ids, tournamentTimeControl(t).Category())
+	if err != nil {
+		return nil, err
+	}
+	ratings := make(map[int]float64, len(rows))
+	for _, row := range rows {
+		ratings[row.UserID] = row.Rating
+	}
+	players := make([]tournament.Player, len(ids))
+	for i, id := range ids {
+		r, ok := ratings[id]
+		if !ok {
+			r = rating.Default().Rating
+		}
+		players[i] = tournament.Player{ID: id, Rating: r}
+	}
+	return players, nil
+}
+
+// detail builds the TournamentDetail of a tournament as it stands.
+func (u *TournamentServiceImpl) detail(id int) (dto.TournamentDetail, error) {
+	t, err := u.tournamentRepository.FindTournamentById(id)
+	if err != nil {
+		return dto.TournamentDetail{}, err
+	}
+	rows, err := u.tournamentRepository.FindPairings(t.ID)
+	if err != nil {
+		return dto.TournamentDetail{}, err
+	}
+	players, err := u.players(t)
+	if err != nil {
+		return dto.TournamentDetail{}, err
+	}
+	entries := make(map[int]dao.TournamentPlayer, len(t.Players))
+	ratings := make(map[int]float64, len(players))
+	for i, p := range t.Players {
+		entries[p.UserID] = p
+		ratings[p.UserID] = players[i].Rating
+	}
+
+	standings := tournament.Standings(t.Kind, players, pairingsOf(rows))
+	detail := dto.TournamentDetail{
+		TimeControl: tournamentTimeControl(t).String(),
+		Standings:   make([]dto.TournamentStanding, len(standings)),
+		Pairings:    rows,
+	}
+	for i, s := range standings {
+		entry := entries[s.PlayerID]
+		detail.Standings[i] = dto.TournamentStanding{
+			Rank:            s.Rank,
+			User:            entry.User,
+			Rating:          int(math.Round(ratings[s.PlayerID])),
+			Score:           s.Score,
+			Buchholz:        s.Buchholz,
+			SonnebornBerger: s.SonnebornBerger,
+			Played:          s.Played,
+			Wins:            s.Wins,
+			Draws:           s.Draws,
+			Losses:          s.Losses,
+			Streak:          s.Streak,
+			Withdrawn:       entry.Withdrawn,
+		}
+	}
+	// The standings carry the players.
+	t.Players = nil
+	detail.Tournament = t
+	return detail, nil
+}
+
+// mustDetail is detail for a request handler.
+func (u *TournamentServiceImpl) mustDetail(id int) dto.TournamentDetail {
+	detail, err := u.detail(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		pkg.PanicException(constant.DataNotFound)
+	}
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	return detail
+}
+
+// findTournament loads a tournament with its players for a request handler.
+func (u *TournamentServiceImpl) findTournament(id int) dao.Tournament {
+	t, err := u.tournamentRepository.FindTournamentById(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		pkg.PanicException(constant.DataNotFound)
+	}
+	if err != nil {
+		log.Error("Happened error when get data from database. Error", err)
+		pkg.PanicException(constant.UnknownError)
+	}
+	return t
+}
+
+// findTournamentOr reloads t, or returns it unchanged if that fails.
+func (u *TournamentServiceImpl) findTournamentOr(t dao.Tournament) dao.Tournament {
+	if fresh, err := u.tournamentRepository.FindTournamentById(t.ID); err == nil {
+		return fresh
+	}
+	return t
+}
+
+// tournamentParam reads the :tournamentId path parameter.
+func tournamentParam(c *gin.Context) int {
+	id, err := strconv.Atoi(c.Param("tournamentId"))
+	if err != nil || id <= 0 {
+		pkg.PanicException_(constant.InvalidRequest.GetResponseStatus(), "invalid tournament id")
+	}
+	return id
+}
+
+// activePlayers are the entrants who have not withdrawn.
+func activePlayers(t dao.Tournament) map[int]bool {
+	active := map[int]bool{}
+	for _, p := range t.Players {
+		if !p.Withdrawn {
+			active[p.UserID] = true
+		}
+	}
+	return active
+}
+
+func tournamentTimeControl(t dao.Tournament) engine.TimeControl {
+	return engine.TimeControl{
+		Base:      time.Duration(t.BaseSeconds) * time.Second,
+		Increment: time.Duration(t.IncrementSeconds) * time.Second,
+	}
+}
+
+// pairingsOf converts stored pairings for package tournament.
+func pairingsOf(rows []dao.TournamentPairing) []tournament.Pairing {
+	pairings := make([]tournament.Pairing, len(rows))
+	for i, row := range rows {
+		pairings[i] = tournament.Pairing{Round: row.Round, White: row.WhiteUserID, Result: row.Result}
+		if row.BlackUserID != nil {
+			pairings[i].Black = *row.BlackUserID
+		}
+	}
+	return pairings
+}
+
+func TournamentServiceInit(tournamentRepository repository.TournamentRepository, ratingRepository repository.RatingRepository,
+	socketService WebSocketService, notificationService NotificationService) *TournamentServiceImpl {
+	service := &TournamentServiceImpl{
+		tournamentRepository: tournamentRepository,
+		ratingRepository:     ratingRepository,
+		socketService:        socketService,
+		notificationService:  notificationService,
+		clients:              make(map[int]map[*websocket.Conn]*lockedConn),
+	}
+	go service.run()
+	return service
+}
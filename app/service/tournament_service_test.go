@@ -0,0 +1,281 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/repository"
+	"chess-engine/app/tournament"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// tournamentRepo is one tournament and its games, in memory.
+type tournamentRepo struct {
+	repository.TournamentRepository
+	t         dao.Tournament
+	pairings  []dao.TournamentPairing
+	winners   map[int]string // game id -> ChessGame.Winner
+	unstarted []dao.TournamentPairing
+	nextGame  int
+}
+
+func newTournamentRepo(t dao.Tournament, players ...int) *tournamentRepo {
+	for _, id := range players {
+		t.Players = append(t.Players, dao.TournamentPlayer{TournamentID: t.ID, UserID: id, User: &dao.User{ID: id}})
+	}
+	return &tournamentRepo{t: t, winners: map[int]string{}, nextGame: 100}
+}
+
+func (r *tournamentRepo) FindTournamentById(id int) (dao.Tournament, error) {
+	t := r.t
+	t.Players = append([]dao.TournamentPlayer(nil), r.t.Players...)
+	return t, nil
+}
+
+func (r *tournamentRepo) UpdateTournament(id int, updates map[string]interface{}) error {
+	if status, ok := updates["status"].(string); ok {
+		r.t.Status = status
+	}
+	return nil
+}
+
+func (r *tournamentRepo) WithdrawFromTournament(id, userID int) (bool, error) {
+	for i, p := range r.t.Players {
+		if p.UserID == userID && !p.Withdrawn {
+			r.t.Players[i].Withdrawn = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *tournamentRepo) FindPairings(id int) ([]dao.TournamentPairing, error) {
+	return append([]dao.TournamentPairing(nil), r.pairings...), nil
+}
+
+func (r *tournamentRepo) CreatePairings(t dao.Tournament, round int, pairings []dao.TournamentPairing,
+	newGame func(dao.TournamentPairing) (dao.ChessGame, dao.GameState)) ([]dao.TournamentPairing, error) {
+	for i := range pairings {
+		pairings[i].TournamentID = t.ID
+		if pairings[i].BlackUserID != nil {
+			newGame(pairings[i])
+			r.nextGame++
+			id := r.nextGame
+			pairings[i].GameID = &id
+		}
+	}
+	r.pairings = append(r.pairings, pairings...)
+	r.t.CurrentRound = round
+	return pairings, nil
+}
+
+func (r *tournamentRepo) SettleResults(id int) (int64, error) {
+	settled := int64(0)
+	for i, p := range r.pairings {
+		if p.GameID != nil && p.Result == tournament.ResultPending && r.winners[*p.GameID] != "" {
+			r.pairings[i].Result = r.winners[*p.GameID]
+			settled++
+		}
+	}
+	return settled, nil
+}
+
+func (r *tournamentRepo) FindUnstartedGames(id int, pairedBefore time.Time) ([]dao.TournamentPairing, error) {
+	return r.unstarted, nil
+}
+
+// finishRound ends every game still going as a win for White.
+func (r *tournamentRepo) finishRound() {
+	for _, p := range r.pairings {
+		if p.GameID != nil && r.winners[*p.GameID] == "" {
+			r.winners[*p.GameID] = "w"
+		}
+	}
+}
+
+func (r *tournamentRepo) pending() int {
+	n := 0
+	for _, p := range r.pairings {
+		if p.Result == tournament.ResultPending && p.BlackUserID != nil {
+			n++
+		}
+	}
+	return n
+}
+
+type noRatings struct{ repository.RatingRepository }
+
+func (noRatings) FindRatings([]int, string) ([]dao.Rating, error) { return nil, nil }
+
+// terminator records the games TerminateGame ends. Those in moved have seen
+// White move meanwhile, and are not ended.
+type terminator struct {
+	WebSocketService
+	moved      map[string]bool
+	terminated []string
+}
+
+func (s *terminator) TerminateGame(gameId, winner, reason string, audit *dao.AuditLog) error {
+	if s.moved[gameId] {
+		return errors.New("game over")
+	}
+	s.terminated = append(s.terminated, gameId+":"+winner)
+	return nil
+}
+
+func newTournamentService(repo *tournamentRepo) (*TournamentServiceImpl, *terminator, *recordingNotifier) {
+	sockets := &terminator{moved: map[string]bool{}}
+	notifier := &recordingNotifier{}
+	return &TournamentServiceImpl{
+		tournamentRepository: repo,
+		ratingRepository:     noRatings{},
+		socketService:        sockets,
+		notificationService:  notifier,
+		clients:              make(map[int]map[*websocket.Conn]*lockedConn),
+	}, sockets, notifier
+}
+
+// mustAdvance runs advance as the runner does and fails on an error.
+func mustAdvance(t *testing.T, svc *TournamentServiceImpl, repo *tournamentRepo, now time.Time) bool {
+	t.Helper()
+	changed, err := svc.advance(repo.t, now)
+	if err != nil {
+		t.Fatalf("advance: %v", err)
+	}
+	return changed
+}
+
+func TestAdvanceSwissRounds(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	repo := newTournamentRepo(dao.Tournament{
+		ID: 1, Kind: tournament.KindSwiss, Status: constant.TournamentStatusRunning, Rounds: 2, BaseSeconds: 180,
+	}, 1, 2, 3, 4)
+	svc, _, notifier := newTournamentService(repo)
+
+	if !mustAdvance(t, svc, repo, now) || repo.t.CurrentRound != 1 || repo.pending() != 2 {
+		t.Fatalf("round 1: current round %d with %d games, want 1 with 2", repo.t.CurrentRound, repo.pending())
+	}
+	if len(notifier.sent) != 4 {
+		t.Errorf("%d players told of their games, want 4", len(notifier.sent))
+	}
+
+	// Nothing happens while the round is being played.
+	if mustAdvance(t, svc, repo, now) || repo.t.CurrentRound != 1 {
+		t.Errorf("advance changed something mid-round: current round %d", repo.t.CurrentRound)
+	}
+
+	// One game done: its result is recorded, but the round waits for the other.
+	g := *repo.pairings[0].GameID
+	repo.winners[g] = "d"
+	if !mustAdvance(t, svc, repo, now) {
+		t.Error("a finished game was not reported as a change")
+	}
+	if repo.t.CurrentRound != 1 || repo.pending() != 1 || repo.pairings[0].Result != "d" {
+		t.Errorf("after one result: round %d, %d pending, result %q; want round 1 waiting on the other game",
+			repo.t.CurrentRound, repo.pending(), repo.pairings[0].Result)
+	}
+
+	repo.finishRound()
+	if !mustAdvance(t, svc, repo, now) || repo.t.CurrentRound != 2 || repo.pending() != 2 {
+		t.Fatalf("round 2: current round %d with %d games, want 2 with 2", repo.t.CurrentRound, repo.pending())
+	}
+
+	// The last round over, the tournament is.
+	repo.finishRound()
+	if !mustAdvance(t, svc, repo, now) || repo.t.Status != constant.TournamentStatusFinished {
+		t.Errorf("after the last round: status %q, want finished", repo.t.Status)
+	}
+	if repo.t.CurrentRound != 2 || len(repo.pairings) != 4 {
+		t.Errorf("after the last round: round %d with %d pairings, want no third round", repo.t.CurrentRound, len(repo.pairings))
+	}
+}
+
+func TestAdvanceSwissRunsOutOfPlayers(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	repo := newTournamentRepo(dao.Tournament{
+		ID: 1, Kind: tournament.KindSwiss, Status: constant.TournamentStatusRunning, Rounds: 5,
+	}, 1, 2)
+	svc, _, _ := newTournamentService(repo)
+	mustAdvance(t, svc, repo, now)
+	repo.finishRound()
+	repo.t.Players[1].Withdrawn = true
+
+	if !mustAdvance(t, svc, repo, now) || repo.t.Status != constant.TournamentStatusFinished {
+		t.Errorf("one player left: status %q, want finished", repo.t.Status)
+	}
+}
+
+func TestAdvanceArena(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	endsAt := start.Add(30 * time.Minute)
+	repo := newTournamentRepo(dao.Tournament{
+		ID: 2, Kind: tournament.KindArena, Status: constant.TournamentStatusRunning,
+		DurationMinutes: 30, EndsAt: &endsAt, BaseSeconds: 60,
+	}, 1, 2, 3)
+	svc, _, _ := newTournamentService(repo)
+
+	// Three players: one game, and one waits.
+	if !mustAdvance(t, svc, repo, start) || repo.pending() != 1 {
+		t.Fatalf("%d games under way, want 1", repo.pending())
+	}
+	if mustAdvance(t, svc, repo, start) || repo.pending() != 1 {
+		t.Errorf("nobody free to pair, yet %d games under way", repo.pending())
+	}
+
+	// Time is up with a game still going: it is played out, nobody else is
+	// paired, and the arena does not end yet.
+	repo.pairings = append(repo.pairings, dao.TournamentPairing{Round: 1, WhiteUserID: 3})
+	repo.pairings[len(repo.pairings)-1].Result = tournament.ResultWhite // a bye, settled
+	if mustAdvance(t, svc, repo, endsAt) || repo.t.Status != constant.TournamentStatusRunning {
+		t.Errorf("at the end with a game going: status %q, want running", repo.t.Status)
+	}
+
+	repo.finishRound()
+	if !mustAdvance(t, svc, repo, endsAt.Add(time.Minute)) || repo.t.Status != constant.TournamentStatusFinished {
+		t.Errorf("after the last game: status %q, want finished", repo.t.Status)
+	}
+	if repo.pending() != 0 {
+		t.Errorf("%d games paired after the end", repo.pending())
+	}
+}
+
+func TestForfeitUnstarted(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	game, moved := 10, 11
+	black := 2
+	repo := newTournamentRepo(dao.Tournament{ID: 3, Name: "Friday blitz", Kind: tournament.KindArena}, 1, 2, 3, 4)
+	repo.unstarted = []dao.TournamentPairing{
+		{Round: 1, WhiteUserID: 1, BlackUserID: &black, GameID: &game},
+		// White moves between the query and the forfeit.
+		{Round: 1, WhiteUserID: 3, BlackUserID: &black, GameID: &moved},
+	}
+	svc, sockets, notifier := newTournamentService(repo)
+	sockets.moved[strconv.Itoa(moved)] = true
+
+	if !svc.forfeitUnstarted(repo.t, now) {
+		t.Fatal("forfeitUnstarted reported nobody withdrawn")
+	}
+	if len(sockets.terminated) != 1 || sockets.terminated[0] != "10:b" {
+		t.Errorf("terminated %v, want game 10 as a win for Black", sockets.terminated)
+	}
+	for _, p := range repo.t.Players {
+		if p.Withdrawn != (p.UserID == 1) {
+			t.Errorf("player %d withdrawn = %v", p.UserID, p.Withdrawn)
+		}
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0] != (sentNotification{1, dto.MessageTournamentWithdrawn}) {
+		t.Errorf("notified %v, want only player 1 of the withdrawal", notifier.sent)
+	}
+
+	// A second pass finds the same game already over.
+	sockets.moved[strconv.Itoa(game)] = true
+	repo.unstarted = repo.unstarted[:1]
+	if svc.forfeitUnstarted(repo.t, now) {
+		t.Error("forfeitUnstarted withdrew someone twice")
+	}
+}
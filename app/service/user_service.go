@@ -24,7 +24,8 @@ type UserService interface {
 }
 
 type UserServiceImpl struct {
-	userRepository repository.UserRepository
+	userRepository    repository.UserRepository
+	sessionRepository repository.SessionRepository
 }
 
 // requireSelf resolves the authenticated user and the :userID path parameter and
@@ -110,9 +111,8 @@ func (u UserServiceImpl) AddUserData(c *gin.Context) {
 
 	log.Info("start to execute program add data user")
 	request := dao.User{
-		Token:  pkg.GenerateRandomString(80),
 		Name:   pkg.GenerateRandomUserName(),
-		Status: 1,
+		Status: constant.UserStatusActive,
 	}
 
 	data, err := u.userRepository.Save(&request)
@@ -121,15 +121,11 @@ func (u UserServiceImpl) AddUserData(c *gin.Context) {
 		pkg.PanicException(constant.UnknownError)
 	}
 
-	// The only response that carries the token: dao.User hides it so the game
+	// The guest's one session. Its token is the only way back into the account
+	// until the guest registers; dao.User never carries it, so the game
 	// broadcast cannot leak it.
-	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, dto.CreatedUser{
-		ID:       data.ID,
-		Name:     data.Name,
-		Status:   data.Status,
-		MetaData: data.MetaData,
-		Token:    data.Token,
-	}))
+	session, token := createSession(u.sessionRepository, data.ID, deviceLabel(c, ""))
+	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, createdUser(data, session, token)))
 }
 
 func (u UserServiceImpl) GetAllUser(c *gin.Context) {
@@ -160,8 +156,9 @@ func (u UserServiceImpl) DeleteUser(c *gin.Context) {
 	c.JSON(http.StatusOK, pkg.BuildResponse(constant.Success, pkg.Null()))
 }
 
-func UserServiceInit(userRepository repository.UserRepository) *UserServiceImpl {
+func UserServiceInit(userRepository repository.UserRepository, sessionRepository repository.SessionRepository) *UserServiceImpl {
 	return &UserServiceImpl{
-		userRepository: userRepository,
+		userRepository:    userRepository,
+		sessionRepository: sessionRepository,
 	}
 }
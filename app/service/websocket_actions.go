@@ -0,0 +1,253 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/domain/dto"
+	"chess-engine/app/engine"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Game actions other than moves: resigning, offering, accepting and declining
+// draws, aborting, and takebacks. Before these existed every message was
+// handed to ProcessMove, so a game could only end over the board. Rematches,
+// which come after the game, are in rematch.go.
+
+// ProcessAction authenticates a non-move game message and applies it.
+func (ws *WebSocketServiceImpl) ProcessAction(gameId string, message dto.WebSocketMessage) {
+	var action dto.GameAction
+	if !ws.bindPayload(gameId, message, &action) {
+		return
+	}
+	user, ok := ws.authenticate(gameId, action.Token)
+	if !ok {
+		return
+	}
+
+	switch message.Type {
+	case dto.MessageRematchOffer, dto.MessageRematchAccept, dto.MessageRematchDecline:
+		ws.applyRematch(gameId, message.Type, user)
+	default:
+		ws.applyAction(gameId, message.Type, user)
+	}
+}
+
+// applyAction runs one action under the game's lock, so it cannot interleave
+// with a move or a flag falling.
+func (ws *WebSocketServiceImpl) applyAction(gameId, action string, user dao.User) {
+	lk := ws.lockFor(gameId)
+	lk.Lock()
+	defer lk.Unlock()
+
+	game, err := ws.loadGame(gameId)
+	if err != nil {
+		log.Error("Error fetching game state:", err)
+		ws.sendError(gameId, "could not load game")
+		return
+	}
+	if game.Winner != "" {
+		ws.sendError(gameId, "game is over")
+		return
+	}
+
+	side := seatOf(game, user)
+	if side == "" {
+		log.Errorf("Rejecting %s: user %d is not in game %s", action, user.ID, gameId)
+		ws.sendError(gameId, "only the players can "+actionVerb(action))
+		return
+	}
+	opponent := engine.ToggleTurn(side)
+	now := time.Now()
+
+	switch action {
+	case dto.MessageResign:
+		ws.finishGame(gameId, &game, opponent, constant.TerminationResignation, sideName(side)+"_resigned", now)
+
+	case dto.MessageDrawOffer:
+		switch {
+		case isBotGame(game):
+			ws.sendError(gameId, "the bot does not accept draw offers")
+		case game.DrawOffer == opponent:
+			// Both sides want a draw: offering into a standing offer accepts it.
+			ws.finishGame(gameId, &game, "d", constant.TerminationAgreement, constant.TerminationAgreement, now)
+		case game.DrawOffer == side:
+			ws.sendError(gameId, "draw already offered")
+		default:
+			game.DrawOffer = side
+			ws.saveAndBroadcast(gameId, &game, dto.MessageDrawOffer, now)
+		}
+
+	case dto.MessageDrawAccept:
+		if game.DrawOffer != opponent {
+			ws.sendError(gameId, "there is no draw offer to accept")
+			return
+		}
+		ws.finishGame(gameId, &game, "d", constant.TerminationAgreement, constant.TerminationAgreement, now)
+
+	case dto.MessageDrawDecline:
+		if game.DrawOffer != opponent {
+			ws.sendError(gameId, "there is no draw offer to decline")
+			return
+		}
+		game.DrawOffer = ""
+		ws.saveAndBroadcast(gameId, &game, dto.MessageDrawDecline, now)
+
+	case dto.MessageAbort:
+		// Aborting is for games that never really started: a player who does not
+		// turn up, or a wrong time control. Once both sides have moved, a player
+		// who wants out has to resign.
+		if len(game.Moves) >= 2 {
+			ws.sendError(gameId, "the game can no longer be aborted; resign instead")
+			return
+		}
+		ws.finishGame(gameId, &game, constant.WinnerAborted, constant.TerminationAborted, constant.TerminationAborted, now)
+
+	case dto.MessageTakebackRequest:
+		plies, reason := takebackPlies(game, side)
+		if reason != "" {
+			ws.sendError(gameId, reason)
+			return
+		}
+		// Nobody has to agree when the opponent is the bot, or when one person
+		// is playing both sides.
+		if isBotGame(game) || isLocalGame(game) {
+			ws.takeBack(gameId, &game, plies, now)
+			return
+		}
+		if game.TakebackOffer == side {
+			ws.sendError(gameId, "takeback already requested")
+			return
+		}
+		game.TakebackOffer = side
+		ws.saveAndBroadcast(gameId, &game, dto.MessageTakebackRequest, now)
+
+	case dto.MessageTakebackAccept:
+		if game.TakebackOffer != opponent {
+			ws.sendError(gameId, "there is no takeback request to accept")
+			return
+		}
+		plies, reason := takebackPlies(game, opponent)
+		if reason != "" {
+			ws.sendError(gameId, reason)
+			return
+		}
+		ws.takeBack(gameId, &game, plies, now)
+
+	case dto.MessageTakebackDecline:
+		if game.TakebackOffer != opponent {
+			ws.sendError(gameId, "there is no takeback request to decline")
+			return
+		}
+		game.TakebackOffer = ""
+		ws.saveAndBroadcast(gameId, &game, dto.MessageTakebackDecline, now)
+
+	default:
+		log.Errorf("Unknown action %q for game %s", action, gameId)
+		ws.sendError(gameId, "unknown action")
+	}
+}
+
+// takeBack rewinds the game by plies. GameState is overwritten in place on
+// every move, so the earlier position is rebuilt by replaying the remaining
+// moves; the trailing move rows are deleted in the same transaction that saves
+// it. The caller must hold the game's lock.
+func (ws *WebSocketServiceImpl) takeBack(gameId string, game *dao.ChessGame, plies int, now time.Time) {
+	keep := append([]dao.GameMove(nil), game.Moves[:len(game.Moves)-plies]...)
+	dropped := game.Moves[len(game.Moves)-plies:]
+
+	state, err := engine.ReplayMoves(engine.GameStartState(*game), engine.RecordedMoves(keep))
+	if err != nil {
+		log.Errorf("Could not replay game %s for a takeback: %v", gameId, err)
+		ws.sendError(gameId, "could not take the move back")
+		return
+	}
+
+	// The replay rebuilds the position only. The row identity carries over, and
+	// so do the clocks: time already spent stays spent, and the side now to
+	// move starts thinking afresh.
+	engine.StopClocks(&game.State, now)
+	state.ID, state.GameID = game.State.ID, game.State.GameID
+	state.WhiteClockMs, state.BlackClockMs = game.State.WhiteClockMs, game.State.BlackClockMs
+	if len(keep) > 0 && engine.GameTimeControl(*game).Timed() {
+		state.ClockStartedAt = now.UnixMilli()
+	}
+
+	game.State = state
+	game.Moves = keep
+	game.DrawOffer = ""
+	game.TakebackOffer = ""
+	if err := ws.persistTakeback(game, dropped); err != nil {
+		log.Error("Error persisting takeback:", err)
+		ws.sendError(gameId, "could not take the move back")
+		return
+	}
+	ws.armClock(gameId, *game, now)
+	ws.broadcastGameUpdate(gameId, game, "success", "takeback", now)
+}
+
+// takebackPlies returns how many plies must go for requester to get their last
+// move back, or a reason it cannot be done. If the opponent has already
+// replied, the reply goes too, so the requester is on move again.
+func takebackPlies(game dao.ChessGame, requester string) (int, string) {
+	plies := 1
+	switch {
+	case isLocalGame(game):
+		// One person plays both sides, so "my last move" is simply the last move.
+	case game.State.Turn == requester:
+		plies = 2
+	case isBotGame(game):
+		// The bot is already thinking about a reply to the move in question.
+		return 0, "wait for the bot to reply, then take back"
+	}
+	if len(game.Moves) < plies {
+		return 0, "there is no move to take back"
+	}
+	return plies, ""
+}
+
+// seatOf returns the colour user plays in game, or "" for a non-player. In a
+// pass-and-play game the one user holds both seats, so the action is taken on
+// behalf of the side to move.
+func seatOf(game dao.ChessGame, user dao.User) string {
+	isWhite := game.WhiteUser != nil && game.WhiteUser.ID == user.ID
+	isBlack := game.BlackUser != nil && game.BlackUser.ID == user.ID
+	switch {
+	case isWhite && isBlack:
+		return game.State.Turn
+	case isWhite:
+		return "w"
+	case isBlack:
+		return "b"
+	}
+	return ""
+}
+
+func isBotGame(game dao.ChessGame) bool {
+	return game.BotLevel != ""
+}
+
+// isLocalGame reports a pass-and-play game: one user seated as both colours.
+func isLocalGame(game dao.ChessGame) bool {
+	return game.WhiteUser != nil && game.BlackUser != nil && game.WhiteUser.ID == game.BlackUser.ID
+}
+
+func sideName(side string) string {
+	if side == "b" {
+		return "black"
+	}
+	return "white"
+}
+
+func actionVerb(action string) string {
+	switch action {
+	case dto.MessageResign:
+		return "resign"
+	case dto.MessageAbort:
+		return "abort"
+	case dto.MessageTakebackRequest, dto.MessageTakebackAccept, dto.MessageTakebackDecline:
+		return "take moves back"
+	}
+	return "offer or answer a draw"
+}
@@ -7,8 +7,10 @@ import (
 	"chess-engine/app/engine"
 	"chess-engine/app/pkg"
 	"chess-engine/app/repository"
+	"errors"
 	"fmt"
 	"hash/fnv"
+	"os"
 	"sync"
 	"time"
 
@@ -17,21 +19,44 @@ import (
 )
 
 type WebSocketService interface {
-	RegisterClient(gameId string, conn *websocket.Conn)
+	RegisterClient(gameId string, conn *websocket.Conn, token string) string
 	UnregisterClient(gameId string, conn *websocket.Conn)
 	BroadcastMessage(gameID string, message dto.WebSocketMessage)
+	SendToClient(gameId string, conn *websocket.Conn, message dto.WebSocketMessage)
+	SendSnapshot(gameId string, conn *websocket.Conn)
+	Sync(gameId string, conn *websocket.Conn, message dto.WebSocketMessage)
 	ProcessMove(gameId string, message dto.WebSocketMessage)
+	ProcessAction(gameId string, message dto.WebSocketMessage)
 	MaybePlayBotMove(gameId string)
+	CheckClock(gameId string)
+	TerminateGame(gameId, winner, reason string, audit *dao.AuditLog) error
 }
 
 type WebSocketServiceImpl struct {
-	gameClients     map[string]map[*websocket.Conn]bool // Map[gameID] -> Map[Conn] -> bool
-	broadcast       chan gameBroadcastMessage           // Messages tied to a game_id
-	register        chan clientRegistration             // Registration with game_id
-	unregister      chan clientRegistration             // Unregistration with game_id
+	gameClients     map[string]map[*websocket.Conn]string // Map[gameID] -> Map[Conn] -> seat (dto.Seat*)
+	broadcast       chan gameBroadcastMessage             // Messages tied to a game_id
+	register        chan clientRegistration               // Registration with game_id
+	unregister      chan clientRegistration               // Unregistration with game_id
 	chessRepository repository.ChessRepository
-	mutex           sync.Mutex
-	gameLocks       [gameLockStripes]sync.Mutex // serializes move application per game
+	// sessionRepository checks the token every game-changing message carries.
+	sessionRepository repository.SessionRepository
+	// ratingRepository and ratingPolicy rate games as they finish; see
+	// rateGame.
+	ratingRepository repository.RatingRepository
+	ratingPolicy     ratingPolicy
+	// leaderboardRepository gets each new rating; see rateGame.
+	leaderboardRepository repository.LeaderboardRepository
+	mutex                 sync.Mutex
+	gameLocks             [gameLockStripes]sync.Mutex // serializes move application per game
+	// clocks holds one pending flag check per timed game in progress, keyed by
+	// game id. See armClock.
+	clocks  map[string]*time.Timer
+	clockMu sync.Mutex
+	// book is the bot's opening book, nil when none is configured.
+	book *engine.Book
+	// tablebase is the bot's Syzygy endgame tablebases, nil when none are
+	// configured.
+	tablebase *engine.Tablebase
 }
 
 // gameLockStripes is the size of the fixed lock table below. It must be a power
@@ -57,6 +82,7 @@ func (ws *WebSocketServiceImpl) lockFor(gameID string) *sync.Mutex {
 type clientRegistration struct {
 	GameID string
 	Conn   *websocket.Conn
+	Seat   string
 }
 
 type gameBroadcastMessage struct {
@@ -65,21 +91,94 @@ type gameBroadcastMessage struct {
 }
 
 // Constructor
-func NewWebSocketService(chessRepository repository.ChessRepository) *WebSocketServiceImpl {
+func NewWebSocketService(chessRepository repository.ChessRepository, sessionRepository repository.SessionRepository,
+	ratingRepository repository.RatingRepository, leaderboardRepository repository.LeaderboardRepository) *WebSocketServiceImpl {
 	service := &WebSocketServiceImpl{
-		gameClients:     make(map[string]map[*websocket.Conn]bool),
-		broadcast:       make(chan gameBroadcastMessage),
-		register:        make(chan clientRegistration),
-		unregister:      make(chan clientRegistration),
-		chessRepository: chessRepository,
+		gameClients:           make(map[string]map[*websocket.Conn]string),
+		broadcast:             make(chan gameBroadcastMessage),
+		register:              make(chan clientRegistration),
+		unregister:            make(chan clientRegistration),
+		chessRepository:       chessRepository,
+		sessionRepository:     sessionRepository,
+		ratingRepository:      ratingRepository,
+		ratingPolicy:          ratingPolicyFromEnv(),
+		leaderboardRepository: leaderboardRepository,
+		book:                  openingBookFromEnv(),
+		tablebase:             tablebaseFromEnv(),
+		clocks:                make(map[string]*time.Timer),
 	}
 	go service.run()
 	return service
 }
 
-// Register a client to a specific game
-func (ws *WebSocketServiceImpl) RegisterClient(gameID string, conn *websocket.Conn) {
-	ws.register <- clientRegistration{GameID: gameID, Conn: conn}
+// openingBookFromEnv loads the Polyglot book named by OPENING_BOOK. The bot
+// plays without a book when the variable is unset or the file cannot be read.
+func openingBookFromEnv() *engine.Book {
+	path := os.Getenv("OPENING_BOOK")
+	if path == "" {
+		return nil
+	}
+	book, err := engine.OpenBook(path)
+	if err != nil {
+		log.Errorf("Happened error when loading opening book %s. Error %v", path, err)
+		return nil
+	}
+	return book
+}
+
+// tablebaseFromEnv opens the Syzygy tablebases in the directories listed in
+// SYZYGY_PATH. The bot plays endings by search alone when the variable is
+// unset or no tables are found there.
+func tablebaseFromEnv() *engine.Tablebase {
+	path := os.Getenv("SYZYGY_PATH")
+	if path == "" {
+		return nil
+	}
+	tb, err := engine.OpenTablebase(path)
+	if err != nil {
+		log.Errorf("Happened error when loading tablebases %s. Error %v", path, err)
+		return nil
+	}
+	return tb
+}
+
+// RegisterClient adds a connection to a game and returns the seat it was given:
+// dto.SeatWhite or dto.SeatBlack if token belongs to that player, otherwise
+// dto.SeatSpectator. Every connection used to join one undifferentiated set,
+// so nothing told a player's socket from a watcher's.
+//
+// The seat is fixed for the life of the connection. A watcher who then takes a
+// seat (joins by invite code) reconnects to be treated as a player.
+func (ws *WebSocketServiceImpl) RegisterClient(gameID string, conn *websocket.Conn, token string) string {
+	seat := ws.seatFor(gameID, token)
+	ws.register <- clientRegistration{GameID: gameID, Conn: conn, Seat: seat}
+	return seat
+}
+
+// seatFor classifies a connecting token. Anything that does not resolve to one
+// of the game's players -- no token, an unknown one, a game that will not load
+// -- watches.
+func (ws *WebSocketServiceImpl) seatFor(gameID, token string) string {
+	if token == "" {
+		return dto.SeatSpectator
+	}
+	session, err := ws.sessionRepository.FindSessionByToken(token)
+	if err != nil {
+		return dto.SeatSpectator
+	}
+	user := session.User
+	game, err := ws.loadGame(gameID)
+	if err != nil {
+		return dto.SeatSpectator
+	}
+	switch {
+	case game.WhiteUser != nil && game.WhiteUser.ID == user.ID:
+		// A pass-and-play player holds both seats; white is as good as either.
+		return dto.SeatWhite
+	case game.BlackUser != nil && game.BlackUser.ID == user.ID:
+		return dto.SeatBlack
+	}
+	return dto.SeatSpectator
 }
 
 // Unregister a client from a specific game
@@ -92,37 +191,114 @@ func (ws *WebSocketServiceImpl) BroadcastMessage(gameID string, message dto.WebS
 	ws.broadcast <- gameBroadcastMessage{GameID: gameID, Message: message}
 }
 
+// SendToClient writes a message to one connection of a game, for replies that
+// concern only the sender, such as refusing a spectator's move. It takes the
+// same mutex as the broadcast loop, so the two never write to a connection at
+// once.
+func (ws *WebSocketServiceImpl) SendToClient(gameID string, conn *websocket.Conn, message dto.WebSocketMessage) {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	clients := ws.gameClients[gameID]
+	if _, ok := clients[conn]; !ok {
+		return
+	}
+	message.Spectators = spectatorCount(clients)
+	if err := conn.WriteJSON(message); err != nil {
+		log.Error("Error sending message to client: ", err)
+	}
+}
+
+// spectatorCount counts the watching connections of a game. The caller must
+// hold ws.mutex.
+func spectatorCount(clients map[*websocket.Conn]string) int {
+	n := 0
+	for _, seat := range clients {
+		if seat == dto.SeatSpectator {
+			n++
+		}
+	}
+	return n
+}
+
+// announceSpectators tells a game's clients how many are watching, after
+// someone arrives or leaves. The caller must hold ws.mutex.
+func announceSpectators(gameID string, clients map[*websocket.Conn]string) {
+	message := dto.WebSocketMessage{
+		Type:       dto.MessageSpectators,
+		Status:     "success",
+		Spectators: spectatorCount(clients),
+	}
+	for conn := range clients {
+		if err := conn.WriteJSON(message); err != nil {
+			log.Errorf("Error sending spectator count for game %s: %v", gameID, err)
+		}
+	}
+}
+
 // ProcessMove authenticates a client message and applies the move it carries.
 func (ws *WebSocketServiceImpl) ProcessMove(gameId string, message dto.WebSocketMessage) {
+	var move dto.Move
+	if !ws.bindPayload(gameId, message, &move) {
+		return
+	}
+	user, ok := ws.authenticate(gameId, move.Token)
+	if !ok {
+		return
+	}
+
+	ws.applyMove(gameId, move, user)
+}
+
+// bindPayload decodes a client message's payload into dst, reporting a bad
+// payload to the game and returning false.
+func (ws *WebSocketServiceImpl) bindPayload(gameId string, message dto.WebSocketMessage, dst interface{}) bool {
 	// The payload is attacker-controlled. Asserting the type unconditionally
 	// (message.Payload.(map[string]interface{})) panicked on any non-object
 	// payload, and this runs on the connection's own goroutine, so that panic
 	// took down the whole server.
 	payload, ok := message.Payload.(map[string]interface{})
 	if !ok {
-		log.Errorf("Rejecting move for game %s: payload is %T, want object", gameId, message.Payload)
-		ws.sendError(gameId, "invalid move payload")
-		return
+		log.Errorf("Rejecting %q for game %s: payload is %T, want object", message.Type, gameId, message.Payload)
+		ws.sendError(gameId, "invalid "+messageNoun(message.Type)+" payload")
+		return false
 	}
-
-	var move dto.Move
-	if err := pkg.BindPayloadToStruct(payload, &move); err != nil {
-		log.Errorf("Failed to unmarshal move: %v", err)
-		ws.sendError(gameId, "invalid move payload")
-		return
+	if err := pkg.BindPayloadToStruct(payload, dst); err != nil {
+		log.Errorf("Failed to unmarshal %q payload: %v", message.Type, err)
+		ws.sendError(gameId, "invalid "+messageNoun(message.Type)+" payload")
+		return false
 	}
+	return true
+}
 
-	user, err := ws.chessRepository.FindUserByToken(move.Token)
-	if err != nil || user.ID == 0 {
-		// The token doesn't map to a user (e.g. a stale token after a DB reset).
-		// Bail out with a clear message instead of running ProcessMove with a
-		// zero user, which would report the misleading "user 0 is not in the game".
-		log.Error("Error fetching user by token:", err)
+// authenticate resolves the token a client message carried to a live session.
+// Every message that changes a game goes through here, so a move and a
+// resignation are held to the same standard, and a session revoked mid-game
+// stops working at its next move rather than when the socket closes.
+func (ws *WebSocketServiceImpl) authenticate(gameId, token string) (dao.User, bool) {
+	session, err := ws.sessionRepository.FindSessionByToken(token)
+	if errors.Is(err, repository.ErrUserBanned) {
+		ws.sendError(gameId, "account is banned")
+		return dao.User{}, false
+	}
+	if err != nil {
+		// The token is unknown, expired or revoked. Bail out with a clear
+		// message instead of running ProcessMove with a zero user, which would
+		// report the misleading "user 0 is not in the game".
+		log.Error("Error fetching session by token:", err)
 		ws.sendError(gameId, "session expired, please reload")
-		return
+		return dao.User{}, false
 	}
+	return session.User, true
+}
 
-	ws.applyMove(gameId, move, user)
+// messageNoun names a message type in an error, keeping the historical
+// "invalid move payload" wording for moves.
+func messageNoun(messageType string) string {
+	switch messageType {
+	case "", dto.MessageMove, dto.MessageGameUpdate:
+		return "move"
+	}
+	return messageType
 }
 
 // applyMove runs the load -> validate -> persist -> broadcast pipeline for an
@@ -144,6 +320,19 @@ func (ws *WebSocketServiceImpl) applyMove(gameId string, move dto.Move, user dao
 		return
 	}
 
+	// A move that arrives after the mover's flag fell loses on time, even if
+	// the flag timer has not fired yet.
+	now := time.Now()
+	timeControl := engine.GameTimeControl(game)
+	if flagged := engine.FlaggedSide(game.State, timeControl, now); flagged != "" && game.Winner == "" {
+		ws.finishOnTime(gameId, &game, flagged, now)
+		return
+	}
+	if game.Winner != "" {
+		ws.sendError(gameId, "game is over")
+		return
+	}
+
 	status := "success"
 	statusMessage := ""
 	legalMoves := make(map[uint64]uint64)
@@ -154,6 +343,7 @@ func (ws *WebSocketServiceImpl) applyMove(gameId string, move dto.Move, user dao
 		statusMessage = err.Error()
 		log.Error("Error processing move:", err)
 	} else {
+		engine.PunchClock(&game.State, timeControl, now)
 		legalMoves, gameStatus = engine.GenerateLegalMovesForAllPositions(game.State)
 		gameMove := dao.GameMove{
 			GameID: game.ID,
@@ -161,12 +351,15 @@ func (ws *WebSocketServiceImpl) applyMove(gameId string, move dto.Move, user dao
 		}
 		if gameStatus == "white_checkmate" {
 			game.Winner = "b"
+			game.Termination = constant.TerminationCheckmate
 		}
 		if gameStatus == "black_checkmate" {
 			game.Winner = "w"
+			game.Termination = constant.TerminationCheckmate
 		}
 		if gameStatus == "stalemate" {
 			game.Winner = "d"
+			game.Termination = constant.TerminationStalemate
 		}
 
 		// Draws. Checkmate takes precedence, so this only runs when the game is
@@ -176,12 +369,26 @@ func (ws *WebSocketServiceImpl) applyMove(gameId string, move dto.Move, user dao
 			// game.Moves does not yet include the move just made (it is appended
 			// after a successful persist), so add it for the replay.
 			played := append(engine.RecordedMoves(game.Moves), gameMove.Move)
-			if draw := engine.DrawStatus(game.State, engine.ReplayGameKeys(played)); draw != "" {
+			if draw := engine.DrawStatus(game.State, engine.ReplayGameKeys(engine.GameStartState(game), played)); draw != "" {
 				game.Winner = "d"
+				game.Termination = draw
 				gameStatus = draw
 			}
 		}
 
+		// A draw offer lapses once the side it was made to plays on instead of
+		// answering it. The offerer may still move after offering.
+		if game.DrawOffer == game.State.Turn {
+			game.DrawOffer = ""
+		}
+		// A takeback request is about the position it was made in; any move
+		// makes it stale.
+		game.TakebackOffer = ""
+
+		if game.Winner != "" {
+			engine.StopClocks(&game.State, now)
+		}
+
 		// Every one of these writes used to be `_ =`. A database or Redis outage
 		// looked exactly like a successful move: the client saw the new position
 		// broadcast and only found out on reload that it was never saved.
@@ -191,11 +398,15 @@ func (ws *WebSocketServiceImpl) applyMove(gameId string, move dto.Move, user dao
 			log.Error("Error persisting move:", err)
 		} else {
 			game.Moves = append(game.Moves, gameMove)
+			if game.Winner != "" {
+				ws.rateGame(&game, now)
+			}
 		}
 	}
 
 	game.BoardLayout = engine.GetBoardLayout()
 	game.CurrentState = engine.ConvertGameStateToMap(game.State)
+	game.Clock = engine.ClockSnapshotAt(game, now)
 
 	// Only regenerate when the move was rejected; `len(legalMoves) == 0` was a
 	// bad sentinel because a real checkmate legitimately has no legal moves and
@@ -205,20 +416,292 @@ func (ws *WebSocketServiceImpl) applyMove(gameId string, move dto.Move, user dao
 	}
 	legalMoves = engine.FilterMovesByTurn(legalMoves, game.State)
 	game.LegalMoves = engine.ConvertLegalMovesToMap(legalMoves)
+	// An error and the game's status can come together, as in a move that
+	// could not be saved in a position in check.
+	message := statusMessage
+	if message != "" && gameStatus != "" {
+		message += "; "
+	}
+	message += gameStatus
 	response := dto.WebSocketMessage{
 		Type:    "game_update",
 		Status:  status,
-		Message: statusMessage + gameStatus,
+		Message: message,
 		Payload: game,
+		Seq:     game.Seq,
 	}
 	ws.BroadcastMessage(gameId, response)
 
 	// If it's now the bot's turn, let it reply through this same pipeline.
 	if status == "success" {
+		ws.armClock(gameId, game, now)
 		go ws.MaybePlayBotMove(gameId)
 	}
 }
 
+// CheckClock ends the game if the side to move has run out of time, and
+// otherwise (re)schedules the check for the moment its flag would fall. It is
+// what declares a loss on time without waiting for either client to send
+// anything, and it runs on connect so a game whose timer was lost to a restart
+// is still decided.
+func (ws *WebSocketServiceImpl) CheckClock(gameId string) {
+	lk := ws.lockFor(gameId)
+	lk.Lock()
+	defer lk.Unlock()
+
+	game, err := ws.loadGame(gameId)
+	if err != nil {
+		log.Error("Error fetching game for clock check:", err)
+		return
+	}
+	now := time.Now()
+	if flagged := engine.FlaggedSide(game.State, engine.GameTimeControl(game), now); flagged != "" && game.Winner == "" {
+		ws.finishOnTime(gameId, &game, flagged, now)
+		return
+	}
+	ws.armClock(gameId, game, now)
+}
+
+// Errors from TerminateGame.
+var (
+	ErrGameNotFound = errors.New("game not found")
+	ErrGameOver     = errors.New("game is over")
+)
+
+// TerminateGame ends a game in progress from outside it, for a moderator or a
+// tournament: winner is "w", "b", "d" or constant.WinnerAborted, and reason is
+// kept on the game and shown to the players. audit, when not nil, is saved
+// with the result; if the save fails the game goes on and the error is
+// returned.
+func (ws *WebSocketServiceImpl) TerminateGame(gameId, winner, reason string, audit *dao.AuditLog) error {
+	lk := ws.lockFor(gameId)
+	lk.Lock()
+	defer lk.Unlock()
+
+	game, err := ws.loadGame(gameId)
+	if err != nil {
+		log.Error("Error fetching game to terminate:", err)
+		return ErrGameNotFound
+	}
+	if game.Winner != "" {
+		return ErrGameOver
+	}
+	now := time.Now()
+	game.Winner = winner
+	game.Termination = constant.TerminationAdjudication
+	game.TerminationNote = reason
+	game.DrawOffer = ""
+	game.TakebackOffer = ""
+	engine.StopClocks(&game.State, now)
+	if err := ws.saveNextSeq(&game, func() error {
+		return ws.chessRepository.SaveTerminatedGame(&game, audit)
+	}); err != nil {
+		log.Error("Error persisting terminated game:", err)
+		return err
+	}
+	ws.armClock(gameId, game, now)
+	ws.rateGame(&game, now)
+	ws.broadcastGameUpdate(gameId, &game, "success", reason, now)
+	return nil
+}
+
+// finishOnTime decides a game whose side to move has flagged, persists the
+// result and broadcasts it. The caller must hold the game's lock.
+func (ws *WebSocketServiceImpl) finishOnTime(gameId string, game *dao.ChessGame, flagged string, now time.Time) {
+	winner, gameStatus := engine.TimeoutResult(game.State, flagged)
+	termination := constant.TerminationTimeout
+	if winner == "d" {
+		termination = constant.TerminationTimeoutVsInsufficientMaterial
+	}
+	ws.finishGame(gameId, game, winner, termination, gameStatus, now)
+}
+
+// finishGame ends a game without a move -- a flag falling, a resignation, an
+// agreed draw, an abort -- then persists and broadcasts the result. The caller
+// must hold the game's lock.
+func (ws *WebSocketServiceImpl) finishGame(gameId string, game *dao.ChessGame, winner, termination, gameStatus string, now time.Time) {
+	game.Winner = winner
+	game.Termination = termination
+	game.DrawOffer = ""
+	engine.StopClocks(&game.State, now)
+	ws.armClock(gameId, *game, now)
+	ws.saveAndBroadcast(gameId, game, gameStatus, now)
+}
+
+// saveAndBroadcast persists a game changed without a move and sends every
+// client the new state, with message as the status text. The caller must hold
+// the game's lock.
+func (ws *WebSocketServiceImpl) saveAndBroadcast(gameId string, game *dao.ChessGame, message string, now time.Time) {
+	status := "success"
+	if err := ws.persist(game, nil); err != nil {
+		status = "error"
+		message = "result could not be saved, please reload"
+		log.Error("Error persisting game:", err)
+	} else if game.Winner != "" {
+		ws.rateGame(game, now)
+	}
+	ws.broadcastGameUpdate(gameId, game, status, message, now)
+}
+
+// broadcastGameUpdate sends every client of a game its state as of now.
+func (ws *WebSocketServiceImpl) broadcastGameUpdate(gameId string, game *dao.ChessGame, status, message string, now time.Time) {
+	decorateGame(game, now)
+	ws.BroadcastMessage(gameId, dto.WebSocketMessage{
+		Type:    dto.MessageGameUpdate,
+		Status:  status,
+		Message: message,
+		Payload: *game,
+		Seq:     game.Seq,
+	})
+}
+
+// decorateGame fills in the fields a game_update payload carries beyond the
+// stored game: the board, the clock as of now, and the legal moves.
+func decorateGame(game *dao.ChessGame, now time.Time) {
+	game.BoardLayout = engine.GetBoardLayout()
+	game.CurrentState = engine.ConvertGameStateToMap(game.State)
+	game.Clock = engine.ClockSnapshotAt(*game, now)
+	legalMoves := map[uint64]uint64{}
+	if game.Winner == "" {
+		legalMoves, _ = engine.GenerateLegalMovesForAllPositions(game.State)
+		legalMoves = engine.FilterMovesByTurn(legalMoves, game.State)
+	}
+	game.LegalMoves = engine.ConvertLegalMovesToMap(legalMoves)
+}
+
+// SendSnapshot sends one connection the game as it stands, as a game_update.
+// It runs when a client connects, so the board is there without waiting for a
+// move or a separate REST call that could race the moves arriving meanwhile.
+func (ws *WebSocketServiceImpl) SendSnapshot(gameId string, conn *websocket.Conn) {
+	// Under the game's lock the snapshot cannot be taken halfway through a
+	// move. A broadcast queued just before may still reach the client after
+	// it; its lower Seq tells the client to ignore it.
+	lk := ws.lockFor(gameId)
+	lk.Lock()
+	defer lk.Unlock()
+
+	game, err := ws.loadGame(gameId)
+	if err != nil {
+		log.Error("Error fetching game for snapshot:", err)
+		ws.SendToClient(gameId, conn, dto.WebSocketMessage{
+			Type:    dto.MessageGameUpdate,
+			Status:  "error",
+			Message: "could not load game",
+		})
+		return
+	}
+	ws.sendSnapshot(gameId, conn, game, time.Now())
+}
+
+func (ws *WebSocketServiceImpl) sendSnapshot(gameId string, conn *websocket.Conn, game dao.ChessGame, now time.Time) {
+	decorateGame(&game, now)
+	ws.SendToClient(gameId, conn, dto.WebSocketMessage{
+		Type:    dto.MessageGameUpdate,
+		Status:  "success",
+		Payload: game,
+		Seq:     game.Seq,
+	})
+}
+
+// Sync answers a client that reports the last update it saw, typically after
+// reconnecting. If only moves happened since, it gets those moves; if it is
+// current, an empty list; anything else -- a takeback, an offer, the game
+// ending other than by a move, a last_seq the server never issued -- gets a
+// full snapshot. The reply goes to the asking connection only.
+func (ws *WebSocketServiceImpl) Sync(gameId string, conn *websocket.Conn, message dto.WebSocketMessage) {
+	request, ok := syncRequest(message.Payload)
+	if !ok {
+		ws.SendToClient(gameId, conn, dto.WebSocketMessage{
+			Type:    dto.MessageSync,
+			Status:  "error",
+			Message: "invalid sync payload",
+		})
+		return
+	}
+
+	lk := ws.lockFor(gameId)
+	lk.Lock()
+	defer lk.Unlock()
+
+	game, err := ws.loadGame(gameId)
+	if err != nil {
+		log.Error("Error fetching game for sync:", err)
+		ws.SendToClient(gameId, conn, dto.WebSocketMessage{
+			Type:    dto.MessageSync,
+			Status:  "error",
+			Message: "could not load game",
+		})
+		return
+	}
+	now := time.Now()
+	moves, ok := movesSince(game, request.LastSeq)
+	if !ok {
+		ws.sendSnapshot(gameId, conn, game, now)
+		return
+	}
+	ws.SendToClient(gameId, conn, dto.WebSocketMessage{
+		Type:   dto.MessageSync,
+		Status: "success",
+		Payload: dto.SyncDelta{
+			Seq:   game.Seq,
+			Moves: moves,
+			Clock: engine.ClockSnapshotAt(game, now),
+		},
+		Seq: game.Seq,
+	})
+}
+
+// syncRequest reads a sync payload. BindPayloadToStruct binds only strings, and
+// last_seq is a JSON number, so it is read by hand; a fractional or negative
+// number is as invalid as a missing one.
+func syncRequest(payload interface{}) (dto.SyncRequest, bool) {
+	fields, ok := payload.(map[string]interface{})
+	if !ok {
+		return dto.SyncRequest{}, false
+	}
+	lastSeq, ok := fields["last_seq"].(float64)
+	if !ok || lastSeq < 0 || lastSeq != float64(int(lastSeq)) {
+		return dto.SyncRequest{}, false
+	}
+	return dto.SyncRequest{LastSeq: int(lastSeq)}, true
+}
+
+// movesSince returns the moves made after update lastSeq, reporting false
+// unless every update since was one of those moves.
+func movesSince(game dao.ChessGame, lastSeq int) ([]dao.GameMove, bool) {
+	if lastSeq < 0 || lastSeq > game.Seq {
+		return nil, false
+	}
+	moves := []dao.GameMove{}
+	for _, m := range game.Moves {
+		if m.Seq > lastSeq {
+			moves = append(moves, m)
+		}
+	}
+	return moves, len(moves) == game.Seq-lastSeq
+}
+
+// armClock replaces the game's pending flag check with one timed for when the
+// side to move runs out, or just cancels it if no clock is running. One timer
+// per live timed game, each replaced on every move, so finished and untimed
+// games hold none.
+func (ws *WebSocketServiceImpl) armClock(gameId string, game dao.ChessGame, now time.Time) {
+	ws.clockMu.Lock()
+	defer ws.clockMu.Unlock()
+
+	if t, ok := ws.clocks[gameId]; ok {
+		t.Stop()
+		delete(ws.clocks, gameId)
+	}
+	if game.Winner != "" || game.State.ClockStartedAt == 0 {
+		return
+	}
+	// A little slack so the check lands after the deadline, not a hair before
+	// it, which would just re-arm the timer for the last millisecond.
+	remaining := engine.ClockRemaining(game.State, game.State.Turn, now) + 50*time.Millisecond
+	ws.clocks[gameId] = time.AfterFunc(remaining, func() { ws.CheckClock(gameId) })
+}
+
 // loadGame reads a game from the cache, falling back to the database.
 func (ws *WebSocketServiceImpl) loadGame(gameId string) (dao.ChessGame, error) {
 	game, err := ws.chessRepository.GetChessGameFromCache(gameId)
@@ -241,15 +724,45 @@ func (ws *WebSocketServiceImpl) loadGame(gameId string) (dao.ChessGame, error) {
 
 // persist writes the move and the resulting game state. The database writes are
 // the ones that matter; a cache write failure is logged but not fatal.
+// gameMove is nil when the game changed without a move, e.g. a flag falling.
 func (ws *WebSocketServiceImpl) persist(game *dao.ChessGame, gameMove *dao.GameMove) error {
-	if err := ws.chessRepository.SaveGameMoveToDB(gameMove); err != nil {
-		return fmt.Errorf("save game move: %w", err)
-	}
-	if err := ws.chessRepository.SaveGameStateToDB(&game.State); err != nil {
-		return fmt.Errorf("save game state: %w", err)
-	}
-	if err := ws.chessRepository.SaveChessGameToDB(game); err != nil {
-		return fmt.Errorf("save game: %w", err)
+	return ws.saveNextSeq(game, func() error {
+		if gameMove != nil {
+			gameMove.Seq = game.Seq
+			if err := ws.chessRepository.SaveGameMoveToDB(gameMove); err != nil {
+				return fmt.Errorf("save game move: %w", err)
+			}
+		}
+		if err := ws.chessRepository.SaveGameStateToDB(&game.State); err != nil {
+			return fmt.Errorf("save game state: %w", err)
+		}
+		if err := ws.chessRepository.SaveChessGameToDB(game); err != nil {
+			return fmt.Errorf("save game: %w", err)
+		}
+		return nil
+	})
+}
+
+// persistTakeback deletes the taken-back moves and writes the rewound game,
+// all or nothing.
+func (ws *WebSocketServiceImpl) persistTakeback(game *dao.ChessGame, dropped []dao.GameMove) error {
+	return ws.saveNextSeq(game, func() error {
+		if err := ws.chessRepository.TakeBackGameMoves(game, dropped); err != nil {
+			return fmt.Errorf("take back moves: %w", err)
+		}
+		return nil
+	})
+}
+
+// saveNextSeq runs save with game on its next Seq, then refreshes the cache.
+//
+// Every save is an update and takes the next game.Seq, so the broadcast that
+// follows carries a number no earlier one did. A failed save gives it back.
+func (ws *WebSocketServiceImpl) saveNextSeq(game *dao.ChessGame, save func() error) error {
+	game.Seq++
+	if err := save(); err != nil {
+		game.Seq--
+		return err
 	}
 	if err := ws.chessRepository.SaveChessGameToCache(game); err != nil {
 		log.Warn("Could not update game cache: ", err)
@@ -291,7 +804,7 @@ func (ws *WebSocketServiceImpl) MaybePlayBotMove(gameId string) {
 		return
 	}
 
-	move := engine.ChooseBotMove(&game)
+	move := engine.ChooseBotMove(&game, ws.book, ws.tablebase)
 	if move == nil {
 		return
 	}
@@ -318,16 +831,19 @@ func (ws *WebSocketServiceImpl) run() {
 		case reg := <-ws.register:
 			ws.mutex.Lock()
 			if _, exists := ws.gameClients[reg.GameID]; !exists {
-				ws.gameClients[reg.GameID] = make(map[*websocket.Conn]bool)
+				ws.gameClients[reg.GameID] = make(map[*websocket.Conn]string)
 			}
-			ws.gameClients[reg.GameID][reg.Conn] = true
+			ws.gameClients[reg.GameID][reg.Conn] = reg.Seat
+			// Sent on every arrival, not only a spectator's, so the newcomer
+			// learns the count too.
+			announceSpectators(reg.GameID, ws.gameClients[reg.GameID])
 			ws.mutex.Unlock()
-			log.Infof("Client connected to game %s", reg.GameID)
+			log.Infof("Client connected to game %s as %s", reg.GameID, reg.Seat)
 
 		case unreg := <-ws.unregister:
 			ws.mutex.Lock()
 			if clients, exists := ws.gameClients[unreg.GameID]; exists {
-				if _, ok := clients[unreg.Conn]; ok {
+				if seat, ok := clients[unreg.Conn]; ok {
 					delete(clients, unreg.Conn)
 					unreg.Conn.Close()
 					log.Infof("Client disconnected from game %s", unreg.GameID)
@@ -336,6 +852,8 @@ func (ws *WebSocketServiceImpl) run() {
 					if len(clients) == 0 {
 						delete(ws.gameClients, unreg.GameID)
 						log.Infof("No clients left for game %s. Removed from active games.", unreg.GameID)
+					} else if seat == dto.SeatSpectator {
+						announceSpectators(unreg.GameID, clients)
 					}
 				}
 			}
@@ -344,6 +862,7 @@ func (ws *WebSocketServiceImpl) run() {
 		case broadcast := <-ws.broadcast:
 			ws.mutex.Lock()
 			if clients, exists := ws.gameClients[broadcast.GameID]; exists {
+				broadcast.Message.Spectators = spectatorCount(clients)
 				for conn := range clients {
 					err := conn.WriteJSON(broadcast.Message)
 					if err != nil {
@@ -363,6 +882,7 @@ func (ws *WebSocketServiceImpl) run() {
 	}
 }
 
-func WebSocketServiceInit(chessRepository repository.ChessRepository) WebSocketService {
-	return NewWebSocketService(chessRepository)
+func WebSocketServiceInit(chessRepository repository.ChessRepository, sessionRepository repository.SessionRepository,
+	ratingRepository repository.RatingRepository, leaderboardRepository repository.LeaderboardRepository) WebSocketService {
+	return NewWebSocketService(chessRepository, sessionRepository, ratingRepository, leaderboardRepository)
 }
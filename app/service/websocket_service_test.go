@@ -0,0 +1,144 @@
+package service
+
+import (
+	"chess-engine/app/constant"
+	"chess-engine/app/domain/dao"
+	"chess-engine/app/engine"
+	"chess-engine/app/repository"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSyncRequest(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload string
+		want    int
+		ok      bool
+	}{
+		{"numeric", `{"last_seq": 7}`, 7, true},
+		{"zero", `{"last_seq": 0}`, 0, true},
+		{"string", `{"last_seq": "7"}`, 0, false},
+		{"missing", `{}`, 0, false},
+		{"null", `{"last_seq": null}`, 0, false},
+		{"negative", `{"last_seq": -1}`, 0, false},
+		{"fractional", `{"last_seq": 2.5}`, 0, false},
+		{"not an object", `[7]`, 0, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Payloads reach the handler decoded into interface{}, as here.
+			var payload interface{}
+			if err := json.Unmarshal([]byte(c.payload), &payload); err != nil {
+				t.Fatal(err)
+			}
+			got, ok := syncRequest(payload)
+			if ok != c.ok || got.LastSeq != c.want {
+				t.Errorf("syncRequest(%s) = %d, %v; want %d, %v", c.payload, got.LastSeq, ok, c.want, c.ok)
+			}
+		})
+	}
+}
+
+func TestMovesSince(t *testing.T) {
+	// Three moves at updates 1-3, then a draw offer at 4.
+	game := dao.ChessGame{Seq: 4, Moves: []dao.GameMove{{Seq: 1}, {Seq: 2}, {Seq: 3}}}
+	// Four moves, the last two taken back at update 5: the moves that are
+	// left cannot tell a client at update 4 what happened.
+	takenBack := dao.ChessGame{Seq: 5, Moves: []dao.GameMove{{Seq: 1}, {Seq: 2}}}
+
+	cases := []struct {
+		name      string
+		game      dao.ChessGame
+		lastSeq   int
+		wantMoves int
+		ok        bool
+	}{
+		{"current", game, 4, 0, true},
+		{"behind by moves", dao.ChessGame{Seq: 3, Moves: game.Moves}, 1, 2, true},
+		{"behind by a non-move update", game, 2, 1, false},
+		{"from the start", dao.ChessGame{Seq: 3, Moves: game.Moves}, 0, 3, true},
+		{"ahead", game, 5, 0, false},
+		{"negative", game, -1, 0, false},
+		{"deleted by takeback", takenBack, 4, 0, false},
+		{"behind a takeback", takenBack, 2, 0, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			moves, ok := movesSince(c.game, c.lastSeq)
+			if ok != c.ok || len(moves) != c.wantMoves {
+				t.Errorf("movesSince(seq %d, %d) = %d moves, %v; want %d, %v",
+					c.game.Seq, c.lastSeq, len(moves), ok, c.wantMoves, c.ok)
+			}
+			for _, m := range moves {
+				if m.Seq <= c.lastSeq {
+					t.Errorf("move at update %d returned for last_seq %d", m.Seq, c.lastSeq)
+				}
+			}
+		})
+	}
+}
+
+// terminatedGames is one game in progress that SaveTerminatedGame can end.
+type terminatedGames struct {
+	repository.ChessRepository
+	game   dao.ChessGame
+	fail   bool
+	saved  *dao.ChessGame
+	audits []dao.AuditLog
+}
+
+func (r *terminatedGames) GetChessGameFromCache(string) (dao.ChessGame, error) { return r.game, nil }
+
+func (r *terminatedGames) SaveChessGameToCache(game *dao.ChessGame) error {
+	r.game = *game
+	return nil
+}
+
+func (r *terminatedGames) SaveTerminatedGame(game *dao.ChessGame, entry *dao.AuditLog) error {
+	if r.fail {
+		return errors.New("connection lost")
+	}
+	saved := *game
+	r.saved = &saved
+	r.audits = append(r.audits, *entry)
+	return nil
+}
+
+func TestTerminateGame(t *testing.T) {
+	repo := &terminatedGames{game: dao.ChessGame{ID: 5, State: engine.StartState()}, fail: true}
+	ws := &WebSocketServiceImpl{
+		chessRepository: repo,
+		broadcast:       make(chan gameBroadcastMessage, 1),
+		clocks:          map[string]*time.Timer{},
+	}
+	entry := &dao.AuditLog{ActorID: 1, Action: AuditTerminateGame, TargetID: 5}
+
+	// Nothing is saved, so the game goes on and nothing is recorded.
+	if err := ws.TerminateGame("5", "w", "cheating", entry); err == nil {
+		t.Fatal("TerminateGame reported success when the save failed")
+	}
+	if repo.game.Winner != "" || len(ws.broadcast) != 0 {
+		t.Errorf("after a failed save: winner %q, %d broadcasts; want the game unchanged", repo.game.Winner, len(ws.broadcast))
+	}
+
+	repo.fail = false
+	if err := ws.TerminateGame("5", "w", "cheating", entry); err != nil {
+		t.Fatalf("TerminateGame: %v", err)
+	}
+	if repo.saved == nil || repo.saved.Winner != "w" || repo.saved.Termination != constant.TerminationAdjudication {
+		t.Errorf("saved %+v, want the game adjudicated to White", repo.saved)
+	}
+	if len(repo.audits) != 1 || repo.audits[0] != *entry {
+		t.Errorf("audit entries %+v, want the moderator's", repo.audits)
+	}
+	if len(ws.broadcast) != 1 {
+		t.Errorf("%d broadcasts, want the result", len(ws.broadcast))
+	}
+
+	if err := ws.TerminateGame("5", "d", "again", entry); !errors.Is(err, ErrGameOver) {
+		t.Errorf("ending an ended game: %v, want ErrGameOver", err)
+	}
+}
@@ -0,0 +1,40 @@
+package tournament
+
+// PairArena pairs the players of an arena who are free to play now. players
+// are all the entrants; available are the ones waiting for a game. They are
+// ranked by arena score and rating and paired with their neighbours, skipping
+// the opponent a player has just faced where another is free. With an odd
+// number waiting, the lowest ranked waits for the next game to end.
+func PairArena(players []Player, available map[int]bool, pairings []Pairing, round int) []Pairing {
+	h := readHistory(KindArena, players, pairings)
+	last := map[int]int{}
+	for _, p := range pairings {
+		if !p.IsBye() {
+			last[p.White], last[p.Black] = p.Black, p.White
+		}
+	}
+
+	var pool []int
+	for _, p := range ranked(players, h) {
+		if available[p.ID] {
+			pool = append(pool, p.ID)
+		}
+	}
+
+	var result []Pairing
+	for len(pool) >= 2 {
+		top := pool[0]
+		pick := 1
+		for i := 1; i < len(pool); i++ {
+			if last[top] != pool[i] {
+				pick = i
+				break
+			}
+		}
+		opponent := pool[pick]
+		white, black := h.orient(top, opponent, len(result)%2 == 0)
+		result = append(result, Pairing{Round: round + len(result), White: white, Black: black})
+		pool = append(pool[1:pick:pick], pool[pick+1:]...)
+	}
+	return result
+}
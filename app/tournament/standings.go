@@ -0,0 +1,280 @@
+// Package tournament pairs and scores Swiss and arena tournaments. Like
+// package rating it is pure: it works on player ids, ratings and results, and
+// storing tournaments, creating their games and reading the results back are
+// up to the caller.
+package tournament
+
+import "sort"
+
+// Kinds of tournament.
+const (
+	// KindSwiss plays a fixed number of rounds. Every round waits for all of
+	// its games, then pairs players on equal scores against each other.
+	KindSwiss = "swiss"
+	// KindArena runs for a fixed time. A player is paired again as soon as
+	// their game ends, with whoever else is free and closest in the standings.
+	KindArena = "arena"
+)
+
+// Results of a pairing, the same values as dao.ChessGame.Winner. A bye is a
+// pairing with no Black, and a ResultWhite.
+const (
+	ResultPending = ""
+	ResultWhite   = "w"
+	ResultBlack   = "b"
+	ResultDraw    = "d"
+	// ResultAborted games score nothing for either side and are left out of
+	// the tiebreaks, as if unplayed; in a Swiss the two are still not paired
+	// again.
+	ResultAborted = "a"
+)
+
+// Player is an entrant. Rating orders players on equal scores.
+type Player struct {
+	ID     int
+	Rating float64
+}
+
+// Pairing is one board of one round: White against Black, or a bye for White
+// when Black is 0. In an arena Round only numbers the pairings in the order
+// they were made.
+type Pairing struct {
+	Round  int
+	White  int
+	Black  int
+	Result string
+}
+
+// IsBye reports whether p is a bye rather than a game.
+func (p Pairing) IsBye() bool {
+	return p.Black == 0
+}
+
+// Standing is a player's place in a tournament.
+type Standing struct {
+	PlayerID int
+	Rank     int
+	Score    float64
+	// Buchholz is the sum of the scores of the player's opponents.
+	Buchholz float64
+	// SonnebornBerger is the sum of the scores of the opponents the player
+	// beat, plus half those of the opponents they drew.
+	SonnebornBerger float64
+	Played          int
+	Wins            int
+	Draws           int
+	Losses          int
+	// Streak is the arena winning streak the player is on; see ArenaPoints.
+	Streak int
+}
+
+// outcome is one game from one player's side.
+type outcome struct {
+	opponent int
+	score    float64 // 1, ½ or 0
+}
+
+// Standings ranks players by score, then Buchholz, then Sonneborn-Berger, then
+// rating. Pairings are taken in order; only finished ones count. Swiss games
+// score 1, ½ and 0, a bye 1. Arena games score by ArenaPoints.
+func Standings(kind string, players []Player, pairings []Pairing) []Standing {
+	index := make(map[int]int, len(players))
+	standings := make([]Standing, len(players))
+	for i, p := range players {
+		index[p.ID] = i
+		standings[i].PlayerID = p.ID
+	}
+	games := make([][]outcome, len(players))
+
+	for _, p := range pairings {
+		w, wok := index[p.White]
+		if !wok || p.Result == ResultPending || p.Result == ResultAborted {
+			continue
+		}
+		if p.IsBye() {
+			standings[w].Score++
+			continue
+		}
+		b, bok := index[p.Black]
+		if !bok {
+			continue
+		}
+		ws, bs := 0.5, 0.5
+		switch p.Result {
+		case ResultWhite:
+			ws, bs = 1, 0
+		case ResultBlack:
+			ws, bs = 0, 1
+		}
+		for _, side := range []struct {
+			i, opponent int
+			score       float64
+		}{{w, p.Black, ws}, {b, p.White, bs}} {
+			s := &standings[side.i]
+			s.Played++
+			switch side.score {
+			case 1:
+				s.Wins++
+			case 0.5:
+				s.Draws++
+			default:
+				s.Losses++
+			}
+			if kind == KindArena {
+				points, streak := ArenaPoints(side.score, s.Streak)
+				s.Score += points
+				s.Streak = streak
+			} else {
+				s.Score += side.score
+			}
+			games[side.i] = append(games[side.i], outcome{opponent: side.opponent, score: side.score})
+		}
+	}
+
+	for i := range standings {
+		for _, g := range games[i] {
+			opponentScore := standings[index[g.opponent]].Score
+			standings[i].Buchholz += opponentScore
+			standings[i].SonnebornBerger += g.score * opponentScore
+		}
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		switch {
+		case a.Score != b.Score:
+			return a.Score > b.Score
+		case a.Buchholz != b.Buchholz:
+			return a.Buchholz > b.Buchholz
+		case a.SonnebornBerger != b.SonnebornBerger:
+			return a.SonnebornBerger > b.SonnebornBerger
+		}
+		ra, rb := players[index[a.PlayerID]].Rating, players[index[b.PlayerID]].Rating
+		if ra != rb {
+			return ra > rb
+		}
+		return a.PlayerID < b.PlayerID
+	})
+	for i := range standings {
+		standings[i].Rank = i + 1
+	}
+	return standings
+}
+
+// ArenaPoints scores an arena game as Lichess does: 2 for a win, 1 for a draw,
+// doubled once a player has won two in a row and until they stop winning. It
+// takes the game's score (1, ½ or 0) and the player's winning streak before it,
+// and returns the points and the streak after it.
+func ArenaPoints(score float64, streak int) (float64, int) {
+	points := 2 * score
+	if streak >= 2 {
+		points *= 2
+	}
+	if score == 1 {
+		return points, streak + 1
+	}
+	return points, 0
+}
+
+// history is what pairing needs to know of the rounds so far.
+type history struct {
+	score     map[int]float64
+	opponents map[int]map[int]bool
+	colours   map[int][]byte // 'w' and 'b', oldest first
+	hadBye    map[int]bool
+}
+
+func readHistory(kind string, players []Player, pairings []Pairing) history {
+	h := history{
+		score:     map[int]float64{},
+		opponents: map[int]map[int]bool{},
+		colours:   map[int][]byte{},
+		hadBye:    map[int]bool{},
+	}
+	for _, s := range Standings(kind, players, pairings) {
+		h.score[s.PlayerID] = s.Score
+	}
+	for _, p := range pairings {
+		if p.IsBye() {
+			h.hadBye[p.White] = true
+			continue
+		}
+		for _, pair := range [][2]int{{p.White, p.Black}, {p.Black, p.White}} {
+			if h.opponents[pair[0]] == nil {
+				h.opponents[pair[0]] = map[int]bool{}
+			}
+			h.opponents[pair[0]][pair[1]] = true
+		}
+		if p.Result != ResultAborted {
+			h.colours[p.White] = append(h.colours[p.White], 'w')
+			h.colours[p.Black] = append(h.colours[p.Black], 'b')
+		}
+	}
+	return h
+}
+
+// colourBalance is how many more games a player has had as White than as Black.
+func (h history) colourBalance(id int) int {
+	n := 0
+	for _, c := range h.colours[id] {
+		if c == 'w' {
+			n++
+		} else {
+			n--
+		}
+	}
+	return n
+}
+
+// lastColour is the colour of a player's last game, or 0 before the first.
+func (h history) lastColour(id int) byte {
+	cs := h.colours[id]
+	if len(cs) == 0 {
+		return 0
+	}
+	return cs[len(cs)-1]
+}
+
+// orient decides colours for higher (the better placed) against lower: the one
+// who has had White less often gets it; on a tie, the one who had Black last;
+// failing that, higher gets White if firstWhite.
+func (h history) orient(higher, lower int, firstWhite bool) (white, black int) {
+	hb, lb := h.colourBalance(higher), h.colourBalance(lower)
+	switch {
+	case hb < lb:
+		return higher, lower
+	case lb < hb:
+		return lower, higher
+	}
+	hl, ll := h.lastColour(higher), h.lastColour(lower)
+	switch {
+	case hl == 'b' && ll != 'b':
+		return higher, lower
+	case ll == 'b' && hl != 'b':
+		return lower, higher
+	case hl == 'w' && ll != 'w':
+		return lower, higher
+	case ll == 'w' && hl != 'w':
+		return higher, lower
+	}
+	if firstWhite {
+		return higher, lower
+	}
+	return lower, higher
+}
+
+// ranked sorts players by score in h, then rating, then id.
+func ranked(players []Player, h history) []Player {
+	sorted := append([]Player(nil), players...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if h.score[a.ID] != h.score[b.ID] {
+			return h.score[a.ID] > h.score[b.ID]
+		}
+		if a.Rating != b.Rating {
+			return a.Rating > b.Rating
+		}
+		return a.ID < b.ID
+	})
+	return sorted
+}
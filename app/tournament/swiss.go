@@ -0,0 +1,122 @@
+package tournament
+
+// maxPairingSteps bounds the backtracking search in PairSwiss. A club-sized
+// event needs a handful of steps; if a late round of a small event exhausts
+// the budget, the round is paired again allowing rematches.
+const maxPairingSteps = 200000
+
+// PairSwiss pairs round of a Swiss in the manner of the Dutch system.
+// players are all the entrants, withdrawn ones included, since the scores of
+// their past games still count; available are the ones to pair.
+//
+// Players are ranked by score, then rating. Each score group is split in
+// half and the top half meets the bottom half in order -- first against first
+// -- with the lowest ranked of an odd group floating down to the next. Nobody
+// meets the same opponent twice while another pairing exists. With an odd
+// number of players the lowest ranked who has not yet had one gets a bye.
+// Colours go to whoever has had that colour less, then alternate.
+func PairSwiss(players []Player, available map[int]bool, pairings []Pairing, round int) []Pairing {
+	h := readHistory(KindSwiss, players, pairings)
+	var pool []Player
+	for _, p := range ranked(players, h) {
+		if available[p.ID] {
+			pool = append(pool, p)
+		}
+	}
+
+	var result []Pairing
+	if len(pool)%2 == 1 {
+		bye := len(pool) - 1
+		for i := len(pool) - 1; i >= 0; i-- {
+			if !h.hadBye[pool[i].ID] {
+				bye = i
+				break
+			}
+		}
+		result = append(result, Pairing{Round: round, White: pool[bye].ID, Result: ResultWhite})
+		pool = append(pool[:bye:bye], pool[bye+1:]...)
+	}
+
+	ids := make([]int, len(pool))
+	for i, p := range pool {
+		ids[i] = p.ID
+	}
+	s := swissSearch{h: h, steps: maxPairingSteps}
+	pairs, ok := s.pair(ids)
+	if !ok {
+		s = swissSearch{h: h, steps: maxPairingSteps, allowRematch: true}
+		pairs, _ = s.pair(ids)
+	}
+
+	boards := make([]Pairing, 0, len(pairs)+len(result))
+	for board, pair := range pairs {
+		// In the first round colours alternate down the boards.
+		white, black := h.orient(pair[0], pair[1], board%2 == 0)
+		boards = append(boards, Pairing{Round: round, White: white, Black: black})
+	}
+	return append(boards, result...)
+}
+
+type swissSearch struct {
+	h            history
+	steps        int
+	allowRematch bool
+}
+
+// pair pairs ids, which are in rank order, or reports that it cannot. The top
+// player is paired first, trying opponents in the order the Dutch system
+// prefers, and the rest recursively.
+func (s *swissSearch) pair(ids []int) ([][2]int, bool) {
+	if len(ids) == 0 {
+		return nil, true
+	}
+	if s.steps <= 0 {
+		return nil, false
+	}
+	s.steps--
+
+	top := ids[0]
+	for _, i := range s.candidates(ids) {
+		opponent := ids[i]
+		if !s.allowRematch && s.h.opponents[top][opponent] {
+			continue
+		}
+		rest := make([]int, 0, len(ids)-2)
+		for j, id := range ids[1:] {
+			if j+1 != i {
+				rest = append(rest, id)
+			}
+		}
+		if pairs, ok := s.pair(rest); ok {
+			return append([][2]int{{top, opponent}}, pairs...), true
+		}
+	}
+	return nil, false
+}
+
+// candidates orders the possible opponents of ids[0] by preference, as indexes
+// into ids: within its score group, the player half a group down first, then
+// the rest of the bottom half, then the top half from the bottom up; then the
+// players of lower groups, in rank order.
+func (s *swissSearch) candidates(ids []int) []int {
+	score := s.h.score[ids[0]]
+	group := 1
+	for group < len(ids) && s.h.score[ids[group]] == score {
+		group++
+	}
+	order := make([]int, 0, len(ids)-1)
+	half := group / 2
+	if half == 0 {
+		half = 1
+	}
+	for i := half; i < group; i++ {
+		order = append(order, i)
+	}
+	for i := half - 1; i >= 1; i-- {
+		order = append(order, i)
+	}
+	for i := group; i < len(ids); i++ {
+		order = append(order, i)
+	}
+	return order
+}
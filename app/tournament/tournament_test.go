@@ -0,0 +1,223 @@
+package tournament
+
+import "testing"
+
+func entrants(n int) ([]Player, map[int]bool) {
+	players := make([]Player, n)
+	available := map[int]bool{}
+	for i := range players {
+		// Player 1 is the highest rated.
+		players[i] = Player{ID: i + 1, Rating: float64(2000 - 10*i)}
+		available[i+1] = true
+	}
+	return players, available
+}
+
+func opponents(p Pairing) [2]int {
+	if p.White < p.Black {
+		return [2]int{p.White, p.Black}
+	}
+	return [2]int{p.Black, p.White}
+}
+
+func TestPairSwissFirstRoundIsTopHalfAgainstBottomHalf(t *testing.T) {
+	players, available := entrants(8)
+	got := PairSwiss(players, available, nil, 1)
+	want := [][2]int{{1, 5}, {2, 6}, {3, 7}, {4, 8}}
+	if len(got) != len(want) {
+		t.Fatalf("got %d pairings, want %d: %+v", len(got), len(want), got)
+	}
+	for i, p := range got {
+		if opponents(p) != want[i] {
+			t.Errorf("board %d: %v, want %v", i+1, opponents(p), want[i])
+		}
+	}
+	// Colours alternate down the boards.
+	if got[0].White != 1 || got[1].White != 6 || got[2].White != 3 || got[3].White != 8 {
+		t.Errorf("first-round colours do not alternate: %+v", got)
+	}
+}
+
+func TestPairSwissGivesTheByeToTheLowestRankedOnce(t *testing.T) {
+	players, available := entrants(5)
+	first := PairSwiss(players, available, nil, 1)
+	var bye Pairing
+	for _, p := range first {
+		if p.IsBye() {
+			bye = p
+		}
+	}
+	if bye.White != 5 || bye.Result != ResultWhite {
+		t.Fatalf("round 1 bye went to %d, want 5 with a point: %+v", bye.White, first)
+	}
+
+	// Everyone draws, so 5 is still lowest ranked but has had the bye.
+	for i := range first {
+		if !first[i].IsBye() {
+			first[i].Result = ResultDraw
+		}
+	}
+	second := PairSwiss(players, available, first, 2)
+	for _, p := range second {
+		if p.IsBye() && p.White == 5 {
+			t.Errorf("player 5 got a second bye: %+v", second)
+		}
+	}
+}
+
+func TestPairSwissAvoidsRematches(t *testing.T) {
+	players, available := entrants(4)
+	var history []Pairing
+	seen := map[[2]int]bool{}
+	for round := 1; round <= 3; round++ {
+		pairings := PairSwiss(players, available, history, round)
+		for i := range pairings {
+			key := opponents(pairings[i])
+			if seen[key] {
+				t.Fatalf("round %d repeats %v: %+v", round, key, pairings)
+			}
+			seen[key] = true
+			pairings[i].Result = ResultWhite
+		}
+		history = append(history, pairings...)
+	}
+	if len(seen) != 6 {
+		t.Errorf("three rounds of four players should be a round robin, got %d pairs", len(seen))
+	}
+}
+
+func TestPairSwissPairsWinnersTogether(t *testing.T) {
+	players, available := entrants(8)
+	round1 := PairSwiss(players, available, nil, 1)
+	for i := range round1 {
+		// The higher rated player wins every game.
+		if round1[i].White < round1[i].Black {
+			round1[i].Result = ResultWhite
+		} else {
+			round1[i].Result = ResultBlack
+		}
+	}
+	round2 := PairSwiss(players, available, round1, 2)
+	winners := map[int]bool{1: true, 2: true, 3: true, 4: true}
+	for _, p := range round2 {
+		if winners[p.White] != winners[p.Black] {
+			t.Errorf("%d and %d are on different scores: %+v", p.White, p.Black, round2)
+		}
+		// Each has had one of each colour or will balance now.
+		h := readHistory(KindSwiss, players, round1)
+		if h.colourBalance(p.White) > h.colourBalance(p.Black) {
+			t.Errorf("%d gets White again ahead of %d", p.White, p.Black)
+		}
+	}
+}
+
+func TestPairSwissSkipsUnavailablePlayers(t *testing.T) {
+	players, available := entrants(4)
+	available[2] = false
+	got := PairSwiss(players, available, nil, 1)
+	for _, p := range got {
+		if p.White == 2 || p.Black == 2 {
+			t.Errorf("withdrawn player paired: %+v", got)
+		}
+	}
+	if len(got) != 2 {
+		t.Errorf("three players should make one game and one bye, got %+v", got)
+	}
+}
+
+func TestStandingsTiebreaks(t *testing.T) {
+	players, _ := entrants(4)
+	pairings := []Pairing{
+		{Round: 1, White: 1, Black: 3, Result: ResultWhite},
+		{Round: 1, White: 2, Black: 4, Result: ResultWhite},
+		{Round: 2, White: 4, Black: 1, Result: ResultDraw},
+		{Round: 2, White: 3, Black: 2, Result: ResultBlack},
+		{Round: 3, White: 2, Black: 1, Result: ResultDraw},
+		{Round: 3, White: 3, Black: 4, Result: ResultDraw},
+	}
+	got := Standings(KindSwiss, players, pairings)
+	// 2 has 2½ from 4 (1), 3 (½) and 1 (2): Buchholz 3½, and Sonneborn-Berger
+	// 1 + ½ for the wins and half of 2 for the draw. 1 has 2 from 3 (½), 4 (1)
+	// and 2 (2½): Buchholz 4, Sonneborn-Berger ½ + ½ + 1¼.
+	want := []struct {
+		id        int
+		score, bh float64
+		sb        float64
+	}{{2, 2.5, 3.5, 2.5}, {1, 2, 4, 2.25}, {4, 1, 5, 1.25}, {3, 0.5, 5.5, 0.5}}
+	for i, w := range want {
+		s := got[i]
+		if s.PlayerID != w.id || s.Score != w.score || s.Buchholz != w.bh || s.SonnebornBerger != w.sb || s.Rank != i+1 {
+			t.Errorf("rank %d: %+v, want player %d with %v / %v / %v", i+1, s, w.id, w.score, w.bh, w.sb)
+		}
+	}
+}
+
+func TestStandingsBreakTiesBeforeRating(t *testing.T) {
+	players, _ := entrants(4)
+	got := Standings(KindSwiss, players, []Pairing{
+		{Round: 1, White: 1, Black: 4, Result: ResultWhite},
+		{Round: 1, White: 3, Black: 2, Result: ResultWhite},
+		{Round: 2, White: 1, Black: 3, Result: ResultWhite},
+		{Round: 2, White: 2, Black: 4, Result: ResultDraw},
+	})
+	// 2 and 4 both have ½, but 4 met the stronger field: 1 (2) and 2 (½)
+	// against 3 (1) and 4 (½). 4 ranks above 2 despite the lower rating.
+	if got[2].PlayerID != 4 || got[3].PlayerID != 2 {
+		t.Errorf("got %+v, want 4 above 2 on Buchholz", got)
+	}
+}
+
+func TestStandingsCountByesAndSkipAbortedGames(t *testing.T) {
+	players, _ := entrants(3)
+	got := Standings(KindSwiss, players, []Pairing{
+		{Round: 1, White: 1, Black: 2, Result: ResultAborted},
+		{Round: 1, White: 3, Result: ResultWhite},
+	})
+	if got[0].PlayerID != 3 || got[0].Score != 1 || got[0].Played != 0 {
+		t.Errorf("the bye should score a point without a game: %+v", got)
+	}
+	for _, s := range got[1:] {
+		if s.Score != 0 || s.Played != 0 || s.Buchholz != 0 {
+			t.Errorf("an aborted game counted: %+v", s)
+		}
+	}
+}
+
+func TestArenaPointsDoubleOnAStreak(t *testing.T) {
+	streak := 0
+	var total float64
+	for _, score := range []float64{1, 1, 1, 0.5, 1} {
+		var points float64
+		points, streak = ArenaPoints(score, streak)
+		total += points
+	}
+	// 2 + 2 + 4 (on fire) + 2 (a draw on fire) + 2 (streak broken).
+	if total != 12 || streak != 1 {
+		t.Errorf("total %v, streak %d; want 12 and 1", total, streak)
+	}
+}
+
+func TestPairArenaAvoidsTheLastOpponent(t *testing.T) {
+	players, available := entrants(4)
+	history := []Pairing{
+		{Round: 1, White: 1, Black: 2, Result: ResultWhite},
+		{Round: 2, White: 3, Black: 4, Result: ResultWhite},
+	}
+	// 1 and 3 lead on 2 points; 1 faced 2 last, so 1 meets 3 and 2 meets 4.
+	got := PairArena(players, available, history, 3)
+	if len(got) != 2 {
+		t.Fatalf("got %+v, want two games", got)
+	}
+	if opponents(got[0]) != [2]int{1, 3} || opponents(got[1]) != [2]int{2, 4} {
+		t.Errorf("got %+v, want 1-3 and 2-4", got)
+	}
+	if got[0].Round != 3 || got[1].Round != 4 {
+		t.Errorf("arena pairings should number on from 3: %+v", got)
+	}
+
+	available = map[int]bool{1: true, 2: true}
+	got = PairArena(players, available, history, 3)
+	if len(got) != 1 || opponents(got[0]) != [2]int{1, 2} {
+		t.Errorf("with only the last opponent free, the rematch should go ahead: %+v", got)
+	}
+}
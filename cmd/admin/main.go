@@ -0,0 +1,51 @@
+// Command admin runs one-off administration tasks against the game server's
+// database, with the same DB_DSN as the server.
+//
+// Build:  go build -o bin/admin ./cmd/admin
+// Run:    ./bin/admin promote <username>
+//
+// promote makes a registered user an admin. It is how a deployment gets its
+// first admin; after that, admins hand out roles through /api/admin.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"chess-engine/app/repository"
+	"chess-engine/app/service"
+	"chess-engine/config"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = "usage: admin promote <username>"
+
+func main() {
+	_ = godotenv.Load()
+	config.InitLog()
+
+	if len(os.Args) != 3 || os.Args[1] != "promote" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	username := os.Args[2]
+
+	db := config.ConnectToDB()
+	userRepo := repository.UserRepositoryInit(db)
+	// Creates the role rows SetUserRole looks up, on a database the server has
+	// not started against yet.
+	repository.RoleRepositoryInit(db)
+	adminRepo := repository.AdminRepositoryInit(db)
+
+	changed, err := service.PromoteAdmin(adminRepo, userRepo, username)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not promote %s: %v\n", username, err)
+		os.Exit(1)
+	}
+	if !changed {
+		fmt.Printf("%s is already an admin\n", username)
+		return
+	}
+	fmt.Printf("%s is now an admin\n", username)
+}
@@ -28,6 +28,8 @@ const (
 	defaultMovesToGo    = 30
 	minThinkTime        = 10 * time.Millisecond
 	defaultHashMB       = 16
+	maxMultiPV          = 64
+	maxThreads          = 256
 )
 
 type uciEngine struct {
@@ -43,10 +45,22 @@ type uciEngine struct {
 	// table persists across searches and across moves, so a position examined
 	// while thinking about move 20 is still cached at move 21.
 	table *engine.TranspositionTable
+	// multiPV is how many best lines each search reports.
+	multiPV int
+	// threads is how many threads each search uses.
+	threads int
+	// book is the BookFile opening book, played from while ownBook is set.
+	book    *engine.Book
+	ownBook bool
+	// tablebase is the SyzygyPath tablebases, nil when none are set.
+	tablebase *engine.Tablebase
 
 	searchMu sync.Mutex
 	stopCh   chan struct{} // non-nil while a search is running
-	wg       sync.WaitGroup
+	// ponderHitCh is non-nil while a "go ponder" search waits for the
+	// opponent to play the expected move.
+	ponderHitCh chan struct{}
+	wg          sync.WaitGroup
 }
 
 func main() {
@@ -55,6 +69,8 @@ func main() {
 		state:        engine.StartState(),
 		moveOverhead: defaultMoveOverhead,
 		table:        engine.NewTranspositionTable(defaultHashMB),
+		multiPV:      1,
+		threads:      1,
 	}
 
 	scanner := bufio.NewScanner(os.Stdin)
@@ -84,7 +100,7 @@ func main() {
 		case "stop":
 			eng.stopSearch()
 		case "ponderhit":
-			// Not implemented: we never start a ponder search, so ignore.
+			eng.ponderHit()
 		case "quit":
 			eng.stopSearch()
 			eng.out.Flush()
@@ -100,6 +116,12 @@ func (e *uciEngine) handleUCI() {
 	e.println("id author " + engineAuthor)
 	e.println("option name Hash type spin default 16 min 1 max 1024")
 	e.println("option name Move Overhead type spin default 30 min 0 max 5000")
+	e.println("option name MultiPV type spin default 1 min 1 max " + strconv.Itoa(maxMultiPV))
+	e.println("option name Ponder type check default false")
+	e.println("option name Threads type spin default 1 min 1 max " + strconv.Itoa(maxThreads))
+	e.println("option name OwnBook type check default false")
+	e.println("option name BookFile type string default <empty>")
+	e.println("option name SyzygyPath type string default <empty>")
 	e.println("uciok")
 }
 
@@ -130,6 +152,44 @@ func (e *uciEngine) handleSetOption(fields []string) {
 		if ms, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && ms >= 0 {
 			e.moveOverhead = time.Duration(ms) * time.Millisecond
 		}
+	case strings.EqualFold(name, "MultiPV"):
+		if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && n >= 1 && n <= maxMultiPV {
+			e.multiPV = n
+		}
+	case strings.EqualFold(name, "Threads"):
+		if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && n >= 1 && n <= maxThreads {
+			e.threads = n
+		}
+	case strings.EqualFold(name, "OwnBook"):
+		e.ownBook = strings.EqualFold(strings.TrimSpace(value), "true")
+	case strings.EqualFold(name, "BookFile"):
+		path := strings.TrimSpace(value)
+		if path == "" || path == "<empty>" {
+			e.book = nil
+			return
+		}
+		book, err := engine.OpenBook(path)
+		if err != nil {
+			e.infoString("cannot load book: " + err.Error())
+			return
+		}
+		e.book = book
+	case strings.EqualFold(name, "SyzygyPath"):
+		path := strings.TrimSpace(value)
+		if path == "" || path == "<empty>" {
+			e.tablebase = nil
+			return
+		}
+		tb, err := engine.OpenTablebase(path)
+		if err != nil {
+			e.infoString("cannot load tablebases: " + err.Error())
+			return
+		}
+		e.tablebase = tb
+		e.infoString("found " + strconv.Itoa(tb.MaxPieces) + "-piece tablebases")
+	case strings.EqualFold(name, "Ponder"):
+		// Only tells us the GUI may send "go ponder"; the time allotment does
+		// not change with it, so there is nothing to set.
 	case strings.EqualFold(name, "Hash"):
 		// Previously advertised and silently ignored, because there was no
 		// transposition table to size.
@@ -198,6 +258,7 @@ func (e *uciEngine) handleGo(fields []string) {
 	var wtime, btime, winc, binc, movetime int
 	movestogo := 0
 	hasTime := false
+	ponder := false
 
 	for i := 1; i < len(fields); i++ {
 		readInt := func() int {
@@ -228,11 +289,24 @@ func (e *uciEngine) handleGo(fields []string) {
 			movestogo = readInt()
 		case "infinite":
 			opts.Infinite = true
-		case "nodes", "mate", "searchmoves", "ponder":
+		case "ponder":
+			// The GUI has already played the move we expect in reply, so the
+			// position is the one we will have to move from if it comes.
+			ponder = true
+		case "nodes", "mate", "searchmoves":
 			// Not supported; consume any trailing value defensively.
 		}
 	}
 
+	// A book move is played at once. Analysis ("go infinite") and pondering
+	// want a search, and once out of book every go searches.
+	if e.ownBook && !opts.Infinite && !ponder {
+		if m, ok := e.book.Probe(e.state); ok {
+			e.printBestMove(engine.SearchResult{Best: m, HasBest: true, PV: []dto.Move{m}})
+			return
+		}
+	}
+
 	opts.MoveTime = e.budget(movetime, wtime, btime, winc, binc, movestogo, hasTime)
 	if !opts.Infinite && opts.MaxDepth <= 0 && opts.MoveTime == 0 {
 		opts.MaxDepth = 6 // sensible default for "go" with no limits
@@ -240,24 +314,43 @@ func (e *uciEngine) handleGo(fields []string) {
 
 	stop := make(chan struct{})
 	opts.Stop = stop
+	var hit chan struct{}
+	if ponder {
+		hit = make(chan struct{})
+		opts.PonderHit = hit
+	}
 
 	e.searchMu.Lock()
 	e.stopCh = stop
+	e.ponderHitCh = hit
 	e.searchMu.Unlock()
 
 	opts.History = e.history
 	opts.Table = e.table
+	opts.MultiPV = e.multiPV
+	opts.Threads = e.threads
+	opts.Tablebase = e.tablebase
 
 	gs := e.state
 	e.wg.Add(1)
 	go func() {
 		defer e.wg.Done()
 		result := engine.Search(gs, opts, e.printInfo)
+		if ponder {
+			// A ponder search may not answer before the GUI says how the
+			// opponent replied, however soon it finishes. After a stop the
+			// bestmove is still owed, but the GUI throws it away.
+			select {
+			case <-hit:
+			case <-stop:
+			}
+		}
 		e.printBestMove(result)
 
 		e.searchMu.Lock()
 		if e.stopCh == stop {
 			e.stopCh = nil
+			e.ponderHitCh = nil
 		}
 		e.searchMu.Unlock()
 	}()
@@ -296,6 +389,8 @@ func clampThink(d time.Duration) time.Duration {
 	return d
 }
 
+// printInfo prints a completed depth: one info line per line of the search,
+// numbered by multipv, best first.
 func (e *uciEngine) printInfo(r engine.SearchResult) {
 	ms := r.Elapsed.Milliseconds()
 	nps := int64(0)
@@ -303,19 +398,21 @@ func (e *uciEngine) printInfo(r engine.SearchResult) {
 		nps = int64(r.Nodes) * 1000 / ms
 	}
 
-	var score string
-	if r.Mate != 0 {
-		score = "mate " + strconv.Itoa(r.Mate)
-	} else {
-		score = "cp " + strconv.Itoa(r.Score)
-	}
+	for i, l := range r.Lines {
+		var score string
+		if l.Mate != 0 {
+			score = "mate " + strconv.Itoa(l.Mate)
+		} else {
+			score = "cp " + strconv.Itoa(l.Score)
+		}
 
-	line := fmt.Sprintf("info depth %d score %s nodes %d nps %d time %d",
-		r.Depth, score, r.Nodes, nps, ms)
-	if len(r.PV) > 0 {
-		line += " pv " + pvString(r.PV)
+		line := fmt.Sprintf("info depth %d multipv %d score %s nodes %d nps %d time %d",
+			r.Depth, i+1, score, r.Nodes, nps, ms)
+		if len(l.PV) > 0 {
+			line += " pv " + pvString(l.PV)
+		}
+		e.println(line)
 	}
-	e.println(line)
 }
 
 func (e *uciEngine) printBestMove(r engine.SearchResult) {
@@ -323,6 +420,11 @@ func (e *uciEngine) printBestMove(r engine.SearchResult) {
 		e.println("bestmove 0000")
 		return
 	}
+	// The reply we expect goes with the move, for the GUI to ponder on.
+	if len(r.PV) >= 2 {
+		e.println("bestmove " + engine.MoveToUCI(r.Best) + " ponder " + engine.MoveToUCI(r.PV[1]))
+		return
+	}
 	e.println("bestmove " + engine.MoveToUCI(r.Best))
 }
 
@@ -341,11 +443,24 @@ func (e *uciEngine) stopSearch() {
 	if e.stopCh != nil {
 		close(e.stopCh)
 		e.stopCh = nil
+		e.ponderHitCh = nil
 	}
 	e.searchMu.Unlock()
 	e.wg.Wait()
 }
 
+// ponderHit turns the running ponder search into an ordinary one: the
+// opponent played the expected move, so the search carries on, on the clock
+// now, with the time it has already spent counted.
+func (e *uciEngine) ponderHit() {
+	e.searchMu.Lock()
+	defer e.searchMu.Unlock()
+	if e.ponderHitCh != nil {
+		close(e.ponderHitCh)
+		e.ponderHitCh = nil
+	}
+}
+
 func (e *uciEngine) infoString(s string) {
 	e.println("info string " + s)
 }
@@ -7,18 +7,39 @@ import (
 )
 
 type Initialization struct {
-	UserRepo   repository.UserRepository
-	userSvc    service.UserService
-	UserCtrl   controller.UserController
-	RoleRepo   repository.RoleRepository
-	ChessCtrl  controller.ChessController
-	chessSvc   service.ChessService
-	chessRepo  repository.ChessRepository
-	SocketCtrl controller.WebSocketController
-	socketSvc  service.WebSocketService
+	UserRepo        repository.UserRepository
+	SessionRepo     repository.SessionRepository
+	userSvc         service.UserService
+	UserCtrl        controller.UserController
+	RoleRepo        repository.RoleRepository
+	ChessCtrl       controller.ChessController
+	chessSvc        service.ChessService
+	chessRepo       repository.ChessRepository
+	SocketCtrl      controller.WebSocketController
+	socketSvc       service.WebSocketService
+	AnalysisCtrl    controller.AnalysisController
+	analysisSvc     service.AnalysisService
+	AuthCtrl        controller.AuthController
+	authSvc         service.AuthService
+	AdminCtrl       controller.AdminController
+	adminSvc        service.AdminService
+	adminRepo       repository.AdminRepository
+	NotifyCtrl      controller.NotificationController
+	notifySvc       service.NotificationService
+	SeekCtrl        controller.SeekController
+	seekSvc         service.SeekService
+	ChallengeCtrl   controller.ChallengeController
+	challengeSvc    service.ChallengeService
+	LeaderboardCtrl controller.LeaderboardController
+	leaderboardSvc  service.LeaderboardService
+	leaderboardRepo repository.LeaderboardRepository
+	TournamentCtrl  controller.TournamentController
+	tournamentSvc   service.TournamentService
+	tournamentRepo  repository.TournamentRepository
 }
 
 func NewInitialization(userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
 	userService service.UserService,
 	userCtrl controller.UserController,
 	roleRepo repository.RoleRepository,
@@ -26,16 +47,55 @@ func NewInitialization(userRepo repository.UserRepository,
 	chessSvc service.ChessService,
 	chessRepo repository.ChessRepository,
 	socketSvc service.WebSocketService,
-	SocketCtrl controller.WebSocketController) *Initialization {
+	SocketCtrl controller.WebSocketController,
+	analysisSvc service.AnalysisService,
+	AnalysisCtrl controller.AnalysisController,
+	authSvc service.AuthService,
+	AuthCtrl controller.AuthController,
+	adminRepo repository.AdminRepository,
+	adminSvc service.AdminService,
+	AdminCtrl controller.AdminController,
+	notifySvc service.NotificationService,
+	NotifyCtrl controller.NotificationController,
+	seekSvc service.SeekService,
+	SeekCtrl controller.SeekController,
+	challengeSvc service.ChallengeService,
+	ChallengeCtrl controller.ChallengeController,
+	leaderboardRepo repository.LeaderboardRepository,
+	leaderboardSvc service.LeaderboardService,
+	LeaderboardCtrl controller.LeaderboardController,
+	tournamentRepo repository.TournamentRepository,
+	tournamentSvc service.TournamentService,
+	TournamentCtrl controller.TournamentController) *Initialization {
 	return &Initialization{
-		UserRepo:   userRepo,
-		userSvc:    userService,
-		UserCtrl:   userCtrl,
-		RoleRepo:   roleRepo,
-		ChessCtrl:  ChessCtrl,
-		chessSvc:   chessSvc,
-		chessRepo:  chessRepo,
-		socketSvc:  socketSvc,
-		SocketCtrl: SocketCtrl,
+		UserRepo:        userRepo,
+		SessionRepo:     sessionRepo,
+		userSvc:         userService,
+		UserCtrl:        userCtrl,
+		RoleRepo:        roleRepo,
+		ChessCtrl:       ChessCtrl,
+		chessSvc:        chessSvc,
+		chessRepo:       chessRepo,
+		socketSvc:       socketSvc,
+		SocketCtrl:      SocketCtrl,
+		analysisSvc:     analysisSvc,
+		AnalysisCtrl:    AnalysisCtrl,
+		authSvc:         authSvc,
+		AuthCtrl:        AuthCtrl,
+		adminRepo:       adminRepo,
+		adminSvc:        adminSvc,
+		AdminCtrl:       AdminCtrl,
+		notifySvc:       notifySvc,
+		NotifyCtrl:      NotifyCtrl,
+		seekSvc:         seekSvc,
+		SeekCtrl:        SeekCtrl,
+		challengeSvc:    challengeSvc,
+		ChallengeCtrl:   ChallengeCtrl,
+		leaderboardRepo: leaderboardRepo,
+		leaderboardSvc:  leaderboardSvc,
+		LeaderboardCtrl: LeaderboardCtrl,
+		tournamentRepo:  tournamentRepo,
+		tournamentSvc:   tournamentSvc,
+		TournamentCtrl:  TournamentCtrl,
 	}
 }
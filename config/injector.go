@@ -26,6 +26,10 @@ var userCtrlSet = wire.NewSet(controller.UserControllerInit,
 	wire.Bind(new(controller.UserController), new(*controller.UserControllerImpl)),
 )
 
+var sessionRepoSet = wire.NewSet(repository.SessionRepositoryInit,
+	wire.Bind(new(repository.SessionRepository), new(*repository.SessionRepositoryImpl)),
+)
+
 var roleRepoSet = wire.NewSet(repository.RoleRepositoryInit,
 	wire.Bind(new(repository.RoleRepository), new(*repository.RoleRepositoryImpl)),
 )
@@ -34,6 +38,10 @@ var chessRepoSet = wire.NewSet(repository.ChessRepositoryInit,
 	wire.Bind(new(repository.ChessRepository), new(*repository.ChessRepositoryImpl)),
 )
 
+var ratingRepoSet = wire.NewSet(repository.RatingRepositoryInit,
+	wire.Bind(new(repository.RatingRepository), new(*repository.RatingRepositoryImpl)),
+)
+
 var chessCtrlSet = wire.NewSet(controller.ChessControllerInit,
 	wire.Bind(new(controller.ChessController), new(*controller.ChessControllerImpl)),
 )
@@ -50,7 +58,83 @@ var socketCtrlSet = wire.NewSet(controller.WebSocketControllerInit,
 	wire.Bind(new(controller.WebSocketController), new(*controller.WebSocketControllerImpl)),
 )
 
+var analysisSvcSet = wire.NewSet(service.AnalysisServiceInit,
+	wire.Bind(new(service.AnalysisService), new(*service.AnalysisServiceImpl)),
+)
+
+var analysisCtrlSet = wire.NewSet(controller.AnalysisControllerInit,
+	wire.Bind(new(controller.AnalysisController), new(*controller.AnalysisControllerImpl)),
+)
+
+var authSvcSet = wire.NewSet(service.AuthServiceInit,
+	wire.Bind(new(service.AuthService), new(*service.AuthServiceImpl)),
+)
+
+var authCtrlSet = wire.NewSet(controller.AuthControllerInit,
+	wire.Bind(new(controller.AuthController), new(*controller.AuthControllerImpl)),
+)
+
+var adminRepoSet = wire.NewSet(repository.AdminRepositoryInit,
+	wire.Bind(new(repository.AdminRepository), new(*repository.AdminRepositoryImpl)),
+)
+
+var adminSvcSet = wire.NewSet(service.AdminServiceInit,
+	wire.Bind(new(service.AdminService), new(*service.AdminServiceImpl)),
+)
+
+var adminCtrlSet = wire.NewSet(controller.AdminControllerInit,
+	wire.Bind(new(controller.AdminController), new(*controller.AdminControllerImpl)),
+)
+
+var notifySvcSet = wire.NewSet(service.NotificationServiceInit,
+	wire.Bind(new(service.NotificationService), new(*service.NotificationServiceImpl)),
+)
+
+var notifyCtrlSet = wire.NewSet(controller.NotificationControllerInit,
+	wire.Bind(new(controller.NotificationController), new(*controller.NotificationControllerImpl)),
+)
+
+var seekSvcSet = wire.NewSet(service.SeekServiceInit,
+	wire.Bind(new(service.SeekService), new(*service.SeekServiceImpl)),
+)
+
+var seekCtrlSet = wire.NewSet(controller.SeekControllerInit,
+	wire.Bind(new(controller.SeekController), new(*controller.SeekControllerImpl)),
+)
+
+var challengeSvcSet = wire.NewSet(service.ChallengeServiceInit,
+	wire.Bind(new(service.ChallengeService), new(*service.ChallengeServiceImpl)),
+)
+
+var challengeCtrlSet = wire.NewSet(controller.ChallengeControllerInit,
+	wire.Bind(new(controller.ChallengeController), new(*controller.ChallengeControllerImpl)),
+)
+
+var leaderboardRepoSet = wire.NewSet(repository.LeaderboardRepositoryInit,
+	wire.Bind(new(repository.LeaderboardRepository), new(*repository.LeaderboardRepositoryImpl)),
+)
+
+var leaderboardSvcSet = wire.NewSet(service.LeaderboardServiceInit,
+	wire.Bind(new(service.LeaderboardService), new(*service.LeaderboardServiceImpl)),
+)
+
+var leaderboardCtrlSet = wire.NewSet(controller.LeaderboardControllerInit,
+	wire.Bind(new(controller.LeaderboardController), new(*controller.LeaderboardControllerImpl)),
+)
+
+var tournamentRepoSet = wire.NewSet(repository.TournamentRepositoryInit,
+	wire.Bind(new(repository.TournamentRepository), new(*repository.TournamentRepositoryImpl)),
+)
+
+var tournamentSvcSet = wire.NewSet(service.TournamentServiceInit,
+	wire.Bind(new(service.TournamentService), new(*service.TournamentServiceImpl)),
+)
+
+var tournamentCtrlSet = wire.NewSet(controller.TournamentControllerInit,
+	wire.Bind(new(controller.TournamentController), new(*controller.TournamentControllerImpl)),
+)
+
 func Init() *Initialization {
-	wire.Build(NewInitialization, db, userCtrlSet, userServiceSet, userRepoSet, roleRepoSet, chessCtrlSet, chessSvcSet, chessRepoSet, socketCtrlSet, socketSvcSet)
+	wire.Build(NewInitialization, db, userCtrlSet, userServiceSet, userRepoSet, sessionRepoSet, roleRepoSet, chessCtrlSet, chessSvcSet, chessRepoSet, ratingRepoSet, socketCtrlSet, socketSvcSet, analysisSvcSet, analysisCtrlSet, authSvcSet, authCtrlSet, adminRepoSet, adminSvcSet, adminCtrlSet, notifySvcSet, notifyCtrlSet, seekSvcSet, seekCtrlSet, challengeSvcSet, challengeCtrlSet, leaderboardRepoSet, leaderboardSvcSet, leaderboardCtrlSet, tournamentRepoSet, tournamentSvcSet, tournamentCtrlSet)
 	return nil
 }
@@ -20,16 +20,37 @@ func Init() *Initialization {
 	redisClient := InitRedis() // Initialize Redis
 
 	userRepositoryImpl := repository.UserRepositoryInit(gormDB)
-	userServiceImpl := service.UserServiceInit(userRepositoryImpl)
+	sessionRepositoryImpl := repository.SessionRepositoryInit(gormDB)
+	userServiceImpl := service.UserServiceInit(userRepositoryImpl, sessionRepositoryImpl)
 	userControllerImpl := controller.UserControllerInit(userServiceImpl)
 	roleRepositoryImpl := repository.RoleRepositoryInit(gormDB)
 	chessRepositoryImpl := repository.ChessRepositoryInit(gormDB, redisClient)
 	chessServiceImpl := service.ChessServiceInit(chessRepositoryImpl)
 	chessControllerImpl := controller.ChessControllerInit(chessServiceImpl)
-	socketServiceImpl := service.WebSocketServiceInit(chessRepositoryImpl)
+	ratingRepositoryImpl := repository.RatingRepositoryInit(gormDB)
+	leaderboardRepositoryImpl := repository.LeaderboardRepositoryInit(gormDB, redisClient)
+	socketServiceImpl := service.WebSocketServiceInit(chessRepositoryImpl, sessionRepositoryImpl, ratingRepositoryImpl, leaderboardRepositoryImpl)
 	socketControllerImpl := controller.WebSocketControllerInit(socketServiceImpl)
-
-	initialization := NewInitialization(userRepositoryImpl, userServiceImpl, userControllerImpl, roleRepositoryImpl, chessControllerImpl, chessServiceImpl, chessRepositoryImpl, socketServiceImpl, socketControllerImpl)
+	analysisServiceImpl := service.AnalysisServiceInit(chessRepositoryImpl)
+	analysisControllerImpl := controller.AnalysisControllerInit(analysisServiceImpl)
+	authServiceImpl := service.AuthServiceInit(userRepositoryImpl, sessionRepositoryImpl)
+	authControllerImpl := controller.AuthControllerInit(authServiceImpl)
+	adminRepositoryImpl := repository.AdminRepositoryInit(gormDB)
+	adminServiceImpl := service.AdminServiceInit(adminRepositoryImpl, socketServiceImpl)
+	adminControllerImpl := controller.AdminControllerInit(adminServiceImpl)
+	notificationServiceImpl := service.NotificationServiceInit(sessionRepositoryImpl)
+	notificationControllerImpl := controller.NotificationControllerInit(notificationServiceImpl)
+	seekServiceImpl := service.SeekServiceInit(chessRepositoryImpl, ratingRepositoryImpl, notificationServiceImpl)
+	seekControllerImpl := controller.SeekControllerInit(seekServiceImpl)
+	challengeServiceImpl := service.ChallengeServiceInit(chessRepositoryImpl, userRepositoryImpl, notificationServiceImpl)
+	challengeControllerImpl := controller.ChallengeControllerInit(challengeServiceImpl)
+	leaderboardServiceImpl := service.LeaderboardServiceInit(leaderboardRepositoryImpl)
+	leaderboardControllerImpl := controller.LeaderboardControllerInit(leaderboardServiceImpl)
+	tournamentRepositoryImpl := repository.TournamentRepositoryInit(gormDB)
+	tournamentServiceImpl := service.TournamentServiceInit(tournamentRepositoryImpl, ratingRepositoryImpl, socketServiceImpl, notificationServiceImpl)
+	tournamentControllerImpl := controller.TournamentControllerInit(tournamentServiceImpl)
+
+	initialization := NewInitialization(userRepositoryImpl, sessionRepositoryImpl, userServiceImpl, userControllerImpl, roleRepositoryImpl, chessControllerImpl, chessServiceImpl, chessRepositoryImpl, socketServiceImpl, socketControllerImpl, analysisServiceImpl, analysisControllerImpl, authServiceImpl, authControllerImpl, adminRepositoryImpl, adminServiceImpl, adminControllerImpl, notificationServiceImpl, notificationControllerImpl, seekServiceImpl, seekControllerImpl, challengeServiceImpl, challengeControllerImpl, leaderboardRepositoryImpl, leaderboardServiceImpl, leaderboardControllerImpl, tournamentRepositoryImpl, tournamentServiceImpl, tournamentControllerImpl)
 	return initialization
 }
 
@@ -43,10 +64,14 @@ var userRepoSet = wire.NewSet(repository.UserRepositoryInit, wire.Bind(new(repos
 
 var userCtrlSet = wire.NewSet(controller.UserControllerInit, wire.Bind(new(controller.UserController), new(*controller.UserControllerImpl)))
 
+var sessionRepoSet = wire.NewSet(repository.SessionRepositoryInit, wire.Bind(new(repository.SessionRepository), new(*repository.SessionRepositoryImpl)))
+
 var roleRepoSet = wire.NewSet(repository.RoleRepositoryInit, wire.Bind(new(repository.RoleRepository), new(*repository.RoleRepositoryImpl)))
 
 var chessRepoSet = wire.NewSet(repository.ChessRepositoryInit, wire.Bind(new(repository.ChessRepository), new(*repository.ChessRepositoryImpl)))
 
+var ratingRepoSet = wire.NewSet(repository.RatingRepositoryInit, wire.Bind(new(repository.RatingRepository), new(*repository.RatingRepositoryImpl)))
+
 var chessCtrlSet = wire.NewSet(controller.ChessControllerInit, wire.Bind(new(controller.ChessController), new(*controller.ChessControllerImpl)))
 
 var chessSvcSet = wire.NewSet(service.ChessServiceInit, wire.Bind(new(service.ChessService), new(*service.ChessServiceImpl)))
@@ -54,3 +79,41 @@ var chessSvcSet = wire.NewSet(service.ChessServiceInit, wire.Bind(new(service.Ch
 var socketSvcSet = wire.NewSet(service.WebSocketServiceInit, wire.Bind(new(service.WebSocketService), new(*service.WebSocketServiceImpl)))
 
 var socketCtrlSet = wire.NewSet(controller.WebSocketControllerInit, wire.Bind(new(controller.WebSocketController), new(*controller.WebSocketControllerImpl)))
+
+var analysisSvcSet = wire.NewSet(service.AnalysisServiceInit, wire.Bind(new(service.AnalysisService), new(*service.AnalysisServiceImpl)))
+
+var analysisCtrlSet = wire.NewSet(controller.AnalysisControllerInit, wire.Bind(new(controller.AnalysisController), new(*controller.AnalysisControllerImpl)))
+
+var authSvcSet = wire.NewSet(service.AuthServiceInit, wire.Bind(new(service.AuthService), new(*service.AuthServiceImpl)))
+
+var authCtrlSet = wire.NewSet(controller.AuthControllerInit, wire.Bind(new(controller.AuthController), new(*controller.AuthControllerImpl)))
+
+var adminRepoSet = wire.NewSet(repository.AdminRepositoryInit, wire.Bind(new(repository.AdminRepository), new(*repository.AdminRepositoryImpl)))
+
+var adminSvcSet = wire.NewSet(service.AdminServiceInit, wire.Bind(new(service.AdminService), new(*service.AdminServiceImpl)))
+
+var adminCtrlSet = wire.NewSet(controller.AdminControllerInit, wire.Bind(new(controller.AdminController), new(*controller.AdminControllerImpl)))
+
+var notifySvcSet = wire.NewSet(service.NotificationServiceInit, wire.Bind(new(service.NotificationService), new(*service.NotificationServiceImpl)))
+
+var notifyCtrlSet = wire.NewSet(controller.NotificationControllerInit, wire.Bind(new(controller.NotificationController), new(*controller.NotificationControllerImpl)))
+
+var seekSvcSet = wire.NewSet(service.SeekServiceInit, wire.Bind(new(service.SeekService), new(*service.SeekServiceImpl)))
+
+var seekCtrlSet = wire.NewSet(controller.SeekControllerInit, wire.Bind(new(controller.SeekController), new(*controller.SeekControllerImpl)))
+
+var challengeSvcSet = wire.NewSet(service.ChallengeServiceInit, wire.Bind(new(service.ChallengeService), new(*service.ChallengeServiceImpl)))
+
+var challengeCtrlSet = wire.NewSet(controller.ChallengeControllerInit, wire.Bind(new(controller.ChallengeController), new(*controller.ChallengeControllerImpl)))
+
+var leaderboardRepoSet = wire.NewSet(repository.LeaderboardRepositoryInit, wire.Bind(new(repository.LeaderboardRepository), new(*repository.LeaderboardRepositoryImpl)))
+
+var leaderboardSvcSet = wire.NewSet(service.LeaderboardServiceInit, wire.Bind(new(service.LeaderboardService), new(*service.LeaderboardServiceImpl)))
+
+var leaderboardCtrlSet = wire.NewSet(controller.LeaderboardControllerInit, wire.Bind(new(controller.LeaderboardController), new(*controller.LeaderboardControllerImpl)))
+
+var tournamentRepoSet = wire.NewSet(repository.TournamentRepositoryInit, wire.Bind(new(repository.TournamentRepository), new(*repository.TournamentRepositoryImpl)))
+
+var tournamentSvcSet = wire.NewSet(service.TournamentServiceInit, wire.Bind(new(service.TournamentService), new(*service.TournamentServiceImpl)))
+
+var tournamentCtrlSet = wire.NewSet(controller.TournamentControllerInit, wire.Bind(new(controller.TournamentController), new(*controller.TournamentControllerImpl)))
@@ -80,3 +80,150 @@ export async function createLocalGame() {
 export async function joinGame(inviteCode) {
 	return post('/api/chess/game/join', { invite_code: inviteCode, token: token() });
 }
+
+// Store the identity returned by register/login and hydrate the `user` store.
+function adopt(j) {
+	if (j.response_key === 'SUCCESS') {
+		localStorage.setItem(KEY_TOKEN, j.data.token);
+		localStorage.setItem(KEY_DATA, JSON.stringify(j.data));
+		user.set(j.data);
+	}
+	return j;
+}
+
+// Register claims the current guest identity, keeping its games.
+export async function register(username, password) {
+	return adopt(await post('/api/auth/register', { token: token(), username, password }));
+}
+
+export async function login(username, password) {
+	return adopt(await post('/api/auth/login', { username, password }));
+}
+
+// Logout ends this device's session; a fresh guest is created on the next
+// ensureUser().
+export async function logout() {
+	const res = await fetch('/api/auth/logout', {
+		method: 'POST',
+		headers: { Authorization: `Bearer ${token()}` }
+	});
+	const j = await res.json();
+	if (j.response_key === 'SUCCESS') {
+		localStorage.removeItem(KEY_TOKEN);
+		localStorage.removeItem(KEY_DATA);
+		user.set(null);
+	}
+	return j;
+}
+
+// Rotate the stored token. The old one stops working as soon as this returns.
+export async function refreshSession() {
+	const res = await fetch('/api/auth/refresh', {
+		method: 'POST',
+		headers: { Authorization: `Bearer ${token()}` }
+	});
+	return adopt(await res.json());
+}
+
+async function authed(method, url, body) {
+	const res = await fetch(url, {
+		method,
+		headers: { 'Content-Type': 'application/json', Authorization: `Bearer ${token()}` },
+		body: body === undefined ? undefined : JSON.stringify(body)
+	});
+	return res.json();
+}
+
+// Enter the matchmaking pool. The match arrives as a `match_found` message on
+// the user channel (userSocket); getSeek() covers a match missed while offline.
+export async function seek(timeControl, rated, ratingRange) {
+	return authed('POST', '/api/seek', { time_control: timeControl, rated, rating_range: ratingRange });
+}
+
+export async function cancelSeek() {
+	return authed('DELETE', '/api/seek');
+}
+
+export async function getSeek() {
+	return authed('GET', '/api/seek');
+}
+
+// The signed-in user's own channel, for notifications that belong to no game.
+export function userSocket() {
+	const proto = location.protocol === 'https:' ? 'wss' : 'ws';
+	return new WebSocket(`${proto}://${location.host}/ws/user?token=${token()}`);
+}
+
+// Challenge a player by username. colour is the caller's: 'white', 'black' or
+// 'random'. The other player hears of it as a `challenge` message on their
+// user channel.
+export async function challenge(username, colour, timeControl, rated) {
+	return authed('POST', '/api/challenge', { username, colour, time_control: timeControl, rated });
+}
+
+export async function getChallenges() {
+	return authed('GET', '/api/challenge');
+}
+
+export async function acceptChallenge(gameId) {
+	return authed('POST', `/api/challenge/${gameId}/accept`);
+}
+
+export async function declineChallenge(gameId) {
+	return authed('POST', `/api/challenge/${gameId}/decline`);
+}
+
+export async function cancelChallenge(gameId) {
+	return authed('DELETE', `/api/challenge/${gameId}`);
+}
+
+export async function getLeaderboard(category, limit) {
+	const res = await fetch(`/api/leaderboard?category=${encodeURIComponent(category)}&limit=${limit ?? ''}`);
+	const j = await res.json();
+	return j.response_key === 'SUCCESS' ? j.data : null;
+}
+
+export async function listTournaments() {
+	const res = await fetch('/api/tournament');
+	const j = await res.json();
+	return j.response_key === 'SUCCESS' && Array.isArray(j.data) ? j.data : [];
+}
+
+export async function getTournament(id) {
+	const res = await fetch(`/api/tournament/${id}`);
+	const j = await res.json();
+	return j.response_key === 'SUCCESS' ? j.data : null;
+}
+
+// kind is 'swiss' (played over rounds) or 'arena' (played for durationMinutes).
+export async function createTournament(name, kind, timeControl, rounds, durationMinutes, rated) {
+	return authed('POST', '/api/tournament', {
+		name,
+		kind,
+		time_control: timeControl,
+		rounds,
+		duration_minutes: durationMinutes,
+		rated
+	});
+}
+
+// Once paired, a player hears of each game as a `tournament_game` message on
+// their user channel.
+export async function joinTournament(id) {
+	return authed('POST', `/api/tournament/${id}/join`);
+}
+
+export async function withdrawFromTournament(id) {
+	return authed('POST', `/api/tournament/${id}/withdraw`);
+}
+
+export async function startTournament(id) {
+	return authed('POST', `/api/tournament/${id}/start`);
+}
+
+// A tournament's live standings: a `tournament` message on connecting and on
+// every change.
+export function tournamentSocket(id) {
+	const proto = location.protocol === 'https:' ? 'wss' : 'ws';
+	return new WebSocket(`${proto}://${location.host}/ws/tournament/${id}`);
+}
@@ -4,8 +4,21 @@
 // en passant, and auto-queen promotion.
 const FILES = 'abcdefgh';
 
-function initialMap() {
+// initialMap is the board a game starts from: the piece-placement field of its
+// starting FEN, or the standard position when the game has none.
+function initialMap(fen) {
 	const m = {};
+	if (fen) {
+		const ranks = fen.split(' ')[0].split('/');
+		ranks.forEach((row, r) => {
+			let f = 0;
+			for (const c of row) {
+				if (c >= '1' && c <= '8') f += Number(c);
+				else m[FILES[f++] + (8 - r)] = c;
+			}
+		});
+		return m;
+	}
 	const back = ['R', 'N', 'B', 'Q', 'K', 'B', 'N', 'R'];
 	for (let f = 0; f < 8; f++) {
 		m[FILES[f] + '8'] = back[f].toLowerCase();
@@ -43,8 +56,8 @@ function applyMove(map, s) {
 }
 
 // positions[k] = the board map after k plies (positions[0] = start).
-export function positionsFrom(moves) {
-	const positions = [initialMap()];
+export function positionsFrom(moves, startFen) {
+	const positions = [initialMap(startFen)];
 	let cur = { ...positions[0] };
 	for (const mv of moves || []) {
 		cur = { ...cur };
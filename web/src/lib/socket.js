@@ -1,12 +1,16 @@
 // Live game socket. Binds the server's game_update broadcasts to the
 // currentGame store and sends moves. One connection at a time; reconnects on
 // unexpected drops; rebinds when the game id changes.
-import { currentGame } from './stores.js';
+import { currentGame, spectators } from './stores.js';
 import { token } from './api.js';
 
 let socket = null;
 let currentId = null;
 let reconnectTimer = null;
+// Highest update number applied for the current game, -1 before the first. The server sends a
+// snapshot on every connect, and a broadcast queued before it can arrive just
+// after; its lower seq marks it stale.
+let lastSeq = -1;
 
 const moveSound =
 	typeof Audio !== 'undefined'
@@ -15,7 +19,9 @@ const moveSound =
 
 function open() {
 	const proto = location.protocol === 'https:' ? 'wss' : 'ws';
-	socket = new WebSocket(`${proto}://${location.host}/ws/${currentId}`);
+	// The token decides whether this connection plays or only watches.
+	const auth = encodeURIComponent(token() ?? '');
+	socket = new WebSocket(`${proto}://${location.host}/ws/${currentId}?token=${auth}`);
 	socket.onmessage = (e) => {
 		let msg;
 		try {
@@ -23,8 +29,16 @@ function open() {
 		} catch {
 			return;
 		}
-		if (msg.payload) currentGame.set(msg.payload);
-		if (msg.status === 'success') moveSound?.play().catch(() => {});
+		if (typeof msg.spectators === 'number') spectators.set(msg.spectators);
+		if (msg.type !== 'game_update' || !msg.payload) return;
+		const seq = msg.seq ?? 0;
+		if (seq < lastSeq) return;
+		// The snapshot on first connect is not news, and neither is one that
+		// repeats what is already shown.
+		const fresh = lastSeq >= 0 && seq > lastSeq;
+		lastSeq = seq;
+		currentGame.set(msg.payload);
+		if (fresh && msg.status === 'success') moveSound?.play().catch(() => {});
 	};
 	socket.onclose = (e) => {
 		if (currentId && !e.wasClean) reconnectTimer = setTimeout(open, 3000);
@@ -35,6 +49,7 @@ export function connectSocket(id) {
 	if (currentId === id && socket && socket.readyState <= WebSocket.OPEN) return;
 	disconnectSocket();
 	currentId = id;
+	lastSeq = -1;
 	open();
 }
 
@@ -58,3 +73,12 @@ export function sendMove(piece, source, destination) {
 		})
 	);
 }
+
+// Non-move game actions: 'resign', 'draw_offer', 'draw_accept', 'draw_decline'
+// and 'abort'; after the game, 'rematch_offer', 'rematch_accept' and
+// 'rematch_decline'. The result arrives as an ordinary game_update, and an
+// agreed rematch also as a 'rematch' message carrying the new game id.
+export function sendAction(type) {
+	if (!socket || socket.readyState !== WebSocket.OPEN) return;
+	socket.send(JSON.stringify({ type, payload: { game_id: currentId, token: token() } }));
+}
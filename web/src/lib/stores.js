@@ -9,3 +9,6 @@ export const user = writable(null);
 // Move-review cursor: null = follow the live position; otherwise the ply count
 // (0 = start) currently shown on the board.
 export const reviewPly = writable(null);
+
+// How many connections are watching the live game, as last reported by the server.
+export const spectators = writable(0);